@@ -19,7 +19,10 @@ import (
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
@@ -28,6 +31,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/store"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/tui"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
@@ -177,6 +181,12 @@ func main() {
 		objectStoreBucket    string
 		objectStoreLocalPath string
 		objectStoreInst      *store.ObjectTokenStore
+		useSQLStore          bool
+		sqlStoreDriver       string
+		sqlStoreDSN          string
+		sqlStorePath         string
+		sqlStoreSchema       string
+		sqlStoreInst         *store.SQLStore
 	)
 
 	wd, err := os.Getwd()
@@ -203,6 +213,26 @@ func main() {
 		return "", false
 	}
 	writableBase := util.WritablePath()
+	if value, ok := lookupEnv("SQLSTORE_DRIVER", "sqlstore_driver"); ok {
+		useSQLStore = true
+		sqlStoreDriver = value
+		if value, ok = lookupEnv("SQLSTORE_DSN", "sqlstore_dsn"); ok {
+			sqlStoreDSN = value
+		}
+		if value, ok = lookupEnv("SQLSTORE_PATH", "sqlstore_path"); ok {
+			sqlStorePath = value
+		}
+		if value, ok = lookupEnv("SQLSTORE_SCHEMA", "sqlstore_schema"); ok {
+			sqlStoreSchema = value
+		}
+		if sqlStorePath == "" {
+			if writableBase != "" {
+				sqlStorePath = filepath.Join(writableBase, "cliproxy.db")
+			} else {
+				sqlStorePath = filepath.Join(wd, "cliproxy.db")
+			}
+		}
+	}
 	if value, ok := lookupEnv("PGSTORE_DSN", "pgstore_dsn"); ok {
 		usePostgresStore = true
 		pgStoreDSN = value
@@ -261,9 +291,39 @@ func main() {
 	}
 
 	// Determine and load the configuration file.
-	// Prefer the Postgres store when configured, otherwise fallback to git or local files.
+	// Prefer the SQL store, then the Postgres store when configured, otherwise fallback to git or local files.
 	var configFilePath string
-	if usePostgresStore {
+	if useSQLStore {
+		configFilePath = configPath
+		if configFilePath == "" {
+			configFilePath = filepath.Join(wd, "config.yaml")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		sqlStoreInst, err = store.NewSQLStore(ctx, store.SQLStoreConfig{
+			Driver:     sqlStoreDriver,
+			DSN:        sqlStoreDSN,
+			Path:       sqlStorePath,
+			Schema:     sqlStoreSchema,
+			ConfigPath: configFilePath,
+		})
+		cancel()
+		if err != nil {
+			log.Errorf("failed to initialize sql token store: %v", err)
+			return
+		}
+		examplePath := filepath.Join(wd, "config.example.yaml")
+		ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		if errBootstrap := sqlStoreInst.Bootstrap(ctx, examplePath); errBootstrap != nil {
+			cancel()
+			log.Errorf("failed to bootstrap sql-backed config: %v", errBootstrap)
+			return
+		}
+		cancel()
+		cfg, err = config.LoadConfigOptional(configFilePath, isCloudDeploy)
+		if err == nil {
+			log.Infof("sql token store enabled (%s)", sqlStoreInst.Driver())
+		}
+	} else if usePostgresStore {
 		if pgStoreLocalPath == "" {
 			pgStoreLocalPath = wd
 		}
@@ -446,6 +506,10 @@ func main() {
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	if err = authcrypt.Configure(cfg.AuthEncryption); err != nil {
+		log.Errorf("failed to configure auth encryption: %v", err)
+		return
+	}
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
 		log.Errorf("failed to configure log output: %v", err)
@@ -472,8 +536,20 @@ func main() {
 	}
 
 	// Register the shared token store once so all components use the same persistence backend.
-	if usePostgresStore {
+	if useSQLStore {
+		sdkAuth.RegisterTokenStore(sqlStoreInst)
+		if sqlStoreInst.Driver() == "postgres" {
+			usage.UseSharedPostgres(sqlStoreInst.DB(), sqlStoreSchema)
+			responsestore.UseSharedPostgres(sqlStoreInst.DB(), sqlStoreSchema)
+			batch.UseSharedPostgres(sqlStoreInst.DB(), sqlStoreSchema)
+			cluster.UseSharedPostgres(sqlStoreInst.DB(), sqlStoreSchema)
+		}
+	} else if usePostgresStore {
 		sdkAuth.RegisterTokenStore(pgStoreInst)
+		usage.UseSharedPostgres(pgStoreInst.DB(), pgStoreSchema)
+		responsestore.UseSharedPostgres(pgStoreInst.DB(), pgStoreSchema)
+		batch.UseSharedPostgres(pgStoreInst.DB(), pgStoreSchema)
+		cluster.UseSharedPostgres(pgStoreInst.DB(), pgStoreSchema)
 	} else if useObjectStore {
 		sdkAuth.RegisterTokenStore(objectStoreInst)
 	} else if useGitStore {
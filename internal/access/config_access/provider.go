@@ -22,15 +22,17 @@ func Register(cfg *sdkconfig.SDKConfig) {
 		return
 	}
 
-	sdkaccess.RegisterProvider(
-		sdkaccess.AccessProviderTypeConfigAPIKey,
-		newProvider(sdkaccess.DefaultAccessProviderName, keys),
-	)
+	p := newProvider(sdkaccess.DefaultAccessProviderName, keys)
+	p.policies, p.defaultPolicy = buildPolicies(cfg.APIKeyPolicies)
+	sdkaccess.RegisterProvider(sdkaccess.AccessProviderTypeConfigAPIKey, p)
 }
 
 type provider struct {
 	name string
 	keys map[string]struct{}
+	// policies maps keys to their model policy; defaultPolicy applies to keys without an entry.
+	policies      map[string]sdkaccess.ModelPolicy
+	defaultPolicy *sdkaccess.ModelPolicy
 }
 
 func newProvider(name string, keys []string) *provider {
@@ -45,6 +47,49 @@ func newProvider(name string, keys []string) *provider {
 	return &provider{name: providerName, keys: keySet}
 }
 
+// buildPolicies indexes api-key-policies by key. The first entry listing a key wins,
+// and the first "*" entry becomes the default policy.
+func buildPolicies(entries []sdkconfig.APIKeyPolicy) (map[string]sdkaccess.ModelPolicy, *sdkaccess.ModelPolicy) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	policies := make(map[string]sdkaccess.ModelPolicy)
+	var defaultPolicy *sdkaccess.ModelPolicy
+	for _, entry := range entries {
+		policy := sdkaccess.ModelPolicy{
+			AllowedModels:         normalizeKeys(entry.AllowedModels),
+			AllowedProviders:      normalizeKeys(entry.AllowedProviders),
+			DisableThinkingSuffix: entry.DisableThinkingSuffix,
+		}
+		for _, key := range entry.APIKeys {
+			key = strings.TrimSpace(key)
+			switch {
+			case key == "":
+			case key == "*":
+				if defaultPolicy == nil {
+					defaultPolicy = &policy
+				}
+			default:
+				if _, exists := policies[key]; !exists {
+					policies[key] = policy
+				}
+			}
+		}
+	}
+	return policies, defaultPolicy
+}
+
+// policyFor returns the model policy that applies to key.
+func (p *provider) policyFor(key string) sdkaccess.ModelPolicy {
+	if policy, ok := p.policies[key]; ok {
+		return policy
+	}
+	if p.defaultPolicy != nil {
+		return *p.defaultPolicy
+	}
+	return sdkaccess.ModelPolicy{}
+}
+
 func (p *provider) Identifier() string {
 	if p == nil || p.name == "" {
 		return sdkaccess.DefaultAccessProviderName
@@ -90,12 +135,14 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 			continue
 		}
 		if _, ok := p.keys[candidate.value]; ok {
+			metadata := map[string]string{
+				"source": candidate.source,
+			}
+			p.policyFor(candidate.value).WriteMetadata(metadata)
 			return &sdkaccess.Result{
 				Provider:  p.Identifier(),
 				Principal: candidate.value,
-				Metadata: map[string]string{
-					"source": candidate.source,
-				},
+				Metadata:  metadata,
 			}, nil
 		}
 	}
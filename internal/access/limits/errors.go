@@ -0,0 +1,93 @@
+package limits
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Reason identifies which configured limit rejected a request.
+type Reason string
+
+const (
+	ReasonRequestsPerMinute  Reason = "requests_per_minute"
+	ReasonTokensPerDay       Reason = "tokens_per_day"
+	ReasonMonthlyTokenBudget Reason = "monthly_token_budget"
+	ReasonModelNotAllowed    Reason = "model_not_allowed"
+	ReasonProviderNotAllowed Reason = "provider_not_allowed"
+	ReasonThinkingNotAllowed Reason = "thinking_suffix_not_allowed"
+)
+
+// LimitError describes a request rejected by a client API key limit.
+type LimitError struct {
+	Reason     Reason
+	Message    string
+	RetryAfter time.Duration
+	status     int
+}
+
+func (e *LimitError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
+// StatusCode returns the HTTP status for the rejection (429 for exhausted limits, 403 for disallowed models).
+func (e *LimitError) StatusCode() int {
+	if e == nil || e.status <= 0 {
+		return http.StatusTooManyRequests
+	}
+	return e.status
+}
+
+// Headers returns the response headers clients should receive, including Retry-After when applicable.
+func (e *LimitError) Headers() http.Header {
+	if e == nil || e.RetryAfter <= 0 {
+		return nil
+	}
+	headers := make(http.Header)
+	headers.Set("Retry-After", strconv.Itoa(retryAfterSeconds(e.RetryAfter)))
+	return headers
+}
+
+func newExhaustedError(reason Reason, retryAfter time.Duration) *LimitError {
+	var message string
+	switch reason {
+	case ReasonRequestsPerMinute:
+		message = "API key request rate limit exceeded"
+	case ReasonTokensPerDay:
+		message = "API key daily token limit exceeded"
+	case ReasonMonthlyTokenBudget:
+		message = "API key monthly token budget exhausted"
+	default:
+		message = "API key limit exceeded"
+	}
+	if retryAfter > 0 {
+		message = fmt.Sprintf("%s, retry after %ds", message, retryAfterSeconds(retryAfter))
+	}
+	return &LimitError{
+		Reason:     reason,
+		Message:    message,
+		RetryAfter: retryAfter,
+		status:     http.StatusTooManyRequests,
+	}
+}
+
+func newModelNotAllowedError(model string) *LimitError {
+	return &LimitError{
+		Reason:  ReasonModelNotAllowed,
+		Message: fmt.Sprintf("API key is not allowed to use model %s", model),
+		status:  http.StatusForbidden,
+	}
+}
+
+// NewPolicyError reports a request rejected by the model policy attached to a client API key.
+func NewPolicyError(reason Reason, message string) *LimitError {
+	return &LimitError{
+		Reason:  reason,
+		Message: message,
+		status:  http.StatusForbidden,
+	}
+}
@@ -0,0 +1,293 @@
+// Package limits enforces per-client API key quotas (request rates, token budgets
+// and model allowlists) before requests are dispatched to upstream providers.
+// Token counters are fed from the usage record stream published by the runtime.
+package limits
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const requestWindow = time.Minute
+
+func init() {
+	coreusage.RegisterPlugin(defaultLimiter)
+}
+
+var defaultLimiter = NewLimiter()
+
+// Default returns the shared limiter fed by the global usage manager.
+func Default() *Limiter { return defaultLimiter }
+
+// Limiter tracks per-key request admissions and token consumption.
+// It implements coreusage.Plugin so token counters follow the same records
+// that feed the usage statistics store.
+type Limiter struct {
+	mu   sync.Mutex
+	keys map[string]*keyState
+	now  func() time.Time
+}
+
+// keyState holds the rolling counters for a single client API key.
+type keyState struct {
+	requests    []time.Time
+	day         string
+	dayTokens   int64
+	month       string
+	monthTokens int64
+}
+
+// KeyUsage is a point-in-time view of the counters tracked for a client API key.
+type KeyUsage struct {
+	RequestsLastMinute int    `json:"requests_last_minute"`
+	Day                string `json:"day"`
+	TokensToday        int64  `json:"tokens_today"`
+	Month              string `json:"month"`
+	TokensThisMonth    int64  `json:"tokens_this_month"`
+}
+
+// NewLimiter constructs an empty limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{keys: make(map[string]*keyState), now: time.Now}
+}
+
+// HandleUsage implements coreusage.Plugin and accumulates token consumption per client key.
+func (l *Limiter) HandleUsage(_ context.Context, record coreusage.Record) {
+	if l == nil {
+		return
+	}
+	apiKey := strings.TrimSpace(record.APIKey)
+	if apiKey == "" {
+		return
+	}
+	tokens := recordTokens(record.Detail)
+	if tokens <= 0 {
+		return
+	}
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = l.now()
+	}
+	dayKey, monthKey := periodKeys(timestamp)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.stateLocked(apiKey)
+	state.rollover(periodKeys(l.now()))
+	if state.day == dayKey {
+		state.dayTokens += tokens
+	}
+	if state.month == monthKey {
+		state.monthTokens += tokens
+	}
+}
+
+// Check evaluates the limits configured for apiKey against the requested model.
+// On success the request is admitted and counted against the per-minute window.
+// A nil error means the request may proceed.
+func (l *Limiter) Check(apiKey, model string, entries []config.APIKeyLimit) *LimitError {
+	return l.evaluate(apiKey, model, entries, true)
+}
+
+// Allows evaluates the same limits as Check without admitting a request, for vetting
+// alternatives such as fallback targets before one of them is dispatched.
+func (l *Limiter) Allows(apiKey, model string, entries []config.APIKeyLimit) *LimitError {
+	return l.evaluate(apiKey, model, entries, false)
+}
+
+func (l *Limiter) evaluate(apiKey, model string, entries []config.APIKeyLimit, admit bool) *LimitError {
+	if l == nil {
+		return nil
+	}
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return nil
+	}
+	entry := MatchEntry(entries, apiKey)
+	if entry == nil {
+		return nil
+	}
+	if !ModelAllowed(entry.AllowedModels, model) {
+		return newModelNotAllowedError(model)
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.stateLocked(apiKey)
+	state.rollover(periodKeys(now))
+	state.pruneRequests(now)
+
+	if entry.MonthlyTokenBudget > 0 && state.monthTokens >= entry.MonthlyTokenBudget {
+		return newExhaustedError(ReasonMonthlyTokenBudget, untilNextMonth(now))
+	}
+	if entry.TokensPerDay > 0 && state.dayTokens >= entry.TokensPerDay {
+		return newExhaustedError(ReasonTokensPerDay, untilNextDay(now))
+	}
+	if entry.RequestsPerMinute > 0 && len(state.requests) >= entry.RequestsPerMinute {
+		retryAfter := state.requests[0].Add(requestWindow).Sub(now)
+		return newExhaustedError(ReasonRequestsPerMinute, retryAfter)
+	}
+	if admit && entry.RequestsPerMinute > 0 {
+		state.requests = append(state.requests, now)
+	}
+	return nil
+}
+
+// Usage returns the current counters for apiKey.
+func (l *Limiter) Usage(apiKey string) KeyUsage {
+	if l == nil {
+		return KeyUsage{}
+	}
+	now := l.now()
+	day, month := periodKeys(now)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.keys[strings.TrimSpace(apiKey)]
+	if !ok {
+		return KeyUsage{Day: day, Month: month}
+	}
+	state.rollover(day, month)
+	state.pruneRequests(now)
+	return KeyUsage{
+		RequestsLastMinute: len(state.requests),
+		Day:                state.day,
+		TokensToday:        state.dayTokens,
+		Month:              state.month,
+		TokensThisMonth:    state.monthTokens,
+	}
+}
+
+// Restore seeds the token counters of apiKey for the current day and month from persisted
+// usage, so budgets survive restarts. Counters already higher are kept, which makes restoring
+// the same period twice harmless.
+func (l *Limiter) Restore(apiKey string, tokensToday, tokensThisMonth int64) {
+	if l == nil {
+		return
+	}
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.stateLocked(apiKey)
+	state.rollover(periodKeys(l.now()))
+	state.dayTokens = max(state.dayTokens, tokensToday)
+	state.monthTokens = max(state.monthTokens, tokensThisMonth)
+}
+
+// Reset drops all counters tracked for apiKey.
+func (l *Limiter) Reset(apiKey string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.keys, strings.TrimSpace(apiKey))
+	l.mu.Unlock()
+}
+
+func (l *Limiter) stateLocked(apiKey string) *keyState {
+	state, ok := l.keys[apiKey]
+	if !ok {
+		day, month := periodKeys(l.now())
+		state = &keyState{day: day, month: month}
+		l.keys[apiKey] = state
+	}
+	return state
+}
+
+// rollover resets the day and month counters when the calendar period changes.
+func (s *keyState) rollover(day, month string) {
+	if s.day != day {
+		s.day = day
+		s.dayTokens = 0
+	}
+	if s.month != month {
+		s.month = month
+		s.monthTokens = 0
+	}
+}
+
+func (s *keyState) pruneRequests(now time.Time) {
+	cutoff := now.Add(-requestWindow)
+	idx := 0
+	for idx < len(s.requests) && !s.requests[idx].After(cutoff) {
+		idx++
+	}
+	if idx > 0 {
+		s.requests = append(s.requests[:0], s.requests[idx:]...)
+	}
+}
+
+// MatchEntry returns the limit entry that applies to apiKey. Explicit key matches
+// take precedence over "*" entries; within each group the first entry wins.
+func MatchEntry(entries []config.APIKeyLimit, apiKey string) *config.APIKeyLimit {
+	var wildcard *config.APIKeyLimit
+	for i := range entries {
+		for _, key := range entries[i].APIKeys {
+			key = strings.TrimSpace(key)
+			if key == apiKey {
+				return &entries[i]
+			}
+			if key == "*" && wildcard == nil {
+				wildcard = &entries[i]
+			}
+		}
+	}
+	return wildcard
+}
+
+// ModelAllowed reports whether model matches one of the allowlist patterns.
+// An empty allowlist permits every model.
+func ModelAllowed(patterns []string, model string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, pattern := range patterns {
+		if util.MatchModelPattern(strings.ToLower(strings.TrimSpace(pattern)), model) {
+			return true
+		}
+	}
+	return false
+}
+
+func recordTokens(detail coreusage.Detail) int64 {
+	if detail.TotalTokens > 0 {
+		return detail.TotalTokens
+	}
+	return detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
+}
+
+func periodKeys(t time.Time) (day, month string) {
+	return t.Format("2006-01-02"), t.Format("2006-01")
+}
+
+func untilNextDay(now time.Time) time.Duration {
+	year, month, day := now.Date()
+	next := time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+	return next.Sub(now)
+}
+
+func untilNextMonth(now time.Time) time.Duration {
+	year, month, _ := now.Date()
+	next := time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location())
+	return next.Sub(now)
+}
+
+// retryAfterSeconds rounds a wait duration up to whole seconds, never below one.
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
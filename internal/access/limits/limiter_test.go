@@ -0,0 +1,156 @@
+package limits
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestLimiter(now time.Time) (*Limiter, *time.Time) {
+	clock := now
+	l := NewLimiter()
+	l.now = func() time.Time { return clock }
+	return l, &clock
+}
+
+func TestLimiterRequestsPerMinute(t *testing.T) {
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	l, clock := newTestLimiter(start)
+	entries := []config.APIKeyLimit{{APIKeys: []string{"k1"}, RequestsPerMinute: 2}}
+
+	for i := 0; i < 2; i++ {
+		if err := l.Check("k1", "gpt-5", entries); err != nil {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+	*clock = start.Add(20 * time.Second)
+	err := l.Check("k1", "gpt-5", entries)
+	if err == nil {
+		t.Fatal("expected third request to be rejected")
+	}
+	if err.StatusCode() != http.StatusTooManyRequests || err.Reason != ReasonRequestsPerMinute {
+		t.Fatalf("unexpected rejection: status=%d reason=%s", err.StatusCode(), err.Reason)
+	}
+	if got := err.Headers().Get("Retry-After"); got != "40" {
+		t.Fatalf("Retry-After = %q, want 40", got)
+	}
+
+	*clock = start.Add(61 * time.Second)
+	if err = l.Check("k1", "gpt-5", entries); err != nil {
+		t.Fatalf("request after window rejected: %v", err)
+	}
+	if err = l.Check("other", "gpt-5", entries); err != nil {
+		t.Fatalf("unlimited key rejected: %v", err)
+	}
+}
+
+func TestLimiterAllowsDoesNotAdmit(t *testing.T) {
+	l, _ := newTestLimiter(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	entries := []config.APIKeyLimit{{APIKeys: []string{"k1"}, RequestsPerMinute: 1, AllowedModels: []string{"gpt-*"}}}
+
+	for i := 0; i < 3; i++ {
+		if err := l.Allows("k1", "gpt-5", entries); err != nil {
+			t.Fatalf("Allows %d rejected: %v", i, err)
+		}
+	}
+	if err := l.Allows("k1", "claude-sonnet-4", entries); err == nil || err.Reason != ReasonModelNotAllowed {
+		t.Fatalf("expected model rejection, got %v", err)
+	}
+	if err := l.Check("k1", "gpt-5", entries); err != nil {
+		t.Fatalf("Check after Allows rejected: %v", err)
+	}
+	if err := l.Allows("k1", "gpt-5", entries); err == nil || err.Reason != ReasonRequestsPerMinute {
+		t.Fatalf("expected Allows to report the exhausted window, got %v", err)
+	}
+}
+
+func TestLimiterTokenBudgetsFromUsageRecords(t *testing.T) {
+	start := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	l, clock := newTestLimiter(start)
+	entries := []config.APIKeyLimit{{APIKeys: []string{"*"}, TokensPerDay: 1000, MonthlyTokenBudget: 5000}}
+
+	l.HandleUsage(context.Background(), coreusage.Record{
+		APIKey:      "k1",
+		RequestedAt: start,
+		Detail:      coreusage.Detail{InputTokens: 600, OutputTokens: 400},
+	})
+	err := l.Check("k1", "claude-sonnet-4", entries)
+	if err == nil || err.Reason != ReasonTokensPerDay {
+		t.Fatalf("expected daily limit rejection, got %v", err)
+	}
+	if got := err.Headers().Get("Retry-After"); got != "3600" {
+		t.Fatalf("Retry-After = %q, want 3600", got)
+	}
+
+	// A new day (and month) resets both counters.
+	*clock = start.Add(2 * time.Hour)
+	if err = l.Check("k1", "claude-sonnet-4", entries); err != nil {
+		t.Fatalf("request on new day rejected: %v", err)
+	}
+	usage := l.Usage("k1")
+	if usage.TokensToday != 0 || usage.TokensThisMonth != 0 || usage.Month != "2026-04" {
+		t.Fatalf("unexpected usage after rollover: %+v", usage)
+	}
+
+	l.HandleUsage(context.Background(), coreusage.Record{
+		APIKey:      "k1",
+		RequestedAt: *clock,
+		Detail:      coreusage.Detail{TotalTokens: 5000},
+	})
+	err = l.Check("k1", "claude-sonnet-4", entries)
+	if err == nil || err.Reason != ReasonMonthlyTokenBudget {
+		t.Fatalf("expected monthly budget rejection, got %v", err)
+	}
+}
+
+func TestLimiterAllowedModels(t *testing.T) {
+	l, _ := newTestLimiter(time.Now())
+	entries := []config.APIKeyLimit{
+		{APIKeys: []string{"k1"}, AllowedModels: []string{"gpt-*", "claude-*-sonnet"}},
+		{APIKeys: []string{"*"}, AllowedModels: []string{"gemini-*"}},
+	}
+
+	cases := []struct {
+		key   string
+		model string
+		ok    bool
+	}{
+		{"k1", "gpt-5", true},
+		{"k1", "GPT-5-codex", true},
+		{"k1", "claude-3-7-sonnet", true},
+		{"k1", "gemini-2.5-pro", false},
+		{"k2", "gemini-2.5-pro", true},
+		{"k2", "gpt-5", false},
+	}
+	for _, tc := range cases {
+		err := l.Check(tc.key, tc.model, entries)
+		if tc.ok && err != nil {
+			t.Errorf("Check(%s, %s) rejected: %v", tc.key, tc.model, err)
+		}
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("Check(%s, %s) admitted, want rejection", tc.key, tc.model)
+			} else if err.StatusCode() != http.StatusForbidden {
+				t.Errorf("Check(%s, %s) status = %d, want 403", tc.key, tc.model, err.StatusCode())
+			}
+		}
+	}
+}
+
+func TestLimiterRestoreSeedsTokenCounters(t *testing.T) {
+	l, _ := newTestLimiter(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	entries := []config.APIKeyLimit{{APIKeys: []string{"k1"}, MonthlyTokenBudget: 5000}}
+
+	l.Restore("k1", 100, 5000)
+	if err := l.Check("k1", "gpt-5", entries); err == nil || err.Reason != ReasonMonthlyTokenBudget {
+		t.Fatalf("expected the restored budget to be exhausted, got %v", err)
+	}
+	l.Restore("k1", 50, 10)
+	if usage := l.Usage("k1"); usage.TokensToday != 100 || usage.TokensThisMonth != 5000 {
+		t.Fatalf("restoring lower counters changed them: %+v", usage)
+	}
+}
@@ -0,0 +1,74 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/limits"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// GetAPIKeyLimits returns the configured per-client API key limits.
+func (h *Handler) GetAPIKeyLimits(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(200, gin.H{"api-key-limits": []config.APIKeyLimit{}})
+		return
+	}
+	c.JSON(200, gin.H{"api-key-limits": h.cfg.APIKeyLimits})
+}
+
+// PutAPIKeyLimits replaces all per-client API key limit entries.
+func (h *Handler) PutAPIKeyLimits(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "config unavailable"})
+		return
+	}
+	var body struct {
+		Value []config.APIKeyLimit `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	h.cfg.APIKeyLimits = body.Value
+	h.cfg.SanitizeAPIKeyLimits()
+	h.persist(c)
+}
+
+// DeleteAPIKeyLimits removes all per-client API key limit entries.
+func (h *Handler) DeleteAPIKeyLimits(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "config unavailable"})
+		return
+	}
+	h.cfg.APIKeyLimits = nil
+	h.persist(c)
+}
+
+// GetAPIKeyLimitUsage reports the live counters for every configured client API key.
+func (h *Handler) GetAPIKeyLimitUsage(c *gin.Context) {
+	type keyUsage struct {
+		Index int                 `json:"index"`
+		Key   string              `json:"key"`
+		Limit *config.APIKeyLimit `json:"limit,omitempty"`
+		Usage limits.KeyUsage     `json:"usage"`
+	}
+	out := make([]keyUsage, 0)
+	if h != nil && h.cfg != nil {
+		limiter := limits.Default()
+		for i, key := range h.cfg.APIKeys {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			out = append(out, keyUsage{
+				Index: i,
+				Key:   key,
+				Limit: limits.MatchEntry(h.cfg.APIKeyLimits, key),
+				Usage: limiter.Usage(key),
+			})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": out})
+}
@@ -0,0 +1,25 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetCircuitBreakers returns the circuit breaker of every openai-compatibility base URL that
+// has failed since startup.
+func (h *Handler) GetCircuitBreakers(c *gin.Context) {
+	breakers := make([]coreauth.BreakerStatus, 0)
+	if h != nil && h.authManager != nil {
+		breakers = append(breakers, h.authManager.BreakerStatuses()...)
+	}
+	open := 0
+	for _, status := range breakers {
+		if status.State != coreauth.BreakerClosed {
+			open++
+		}
+	}
+	enabled := h != nil && h.cfg != nil && h.cfg.CircuitBreaker.Enable
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "open": open, "breakers": breakers})
+}
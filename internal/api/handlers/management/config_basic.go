@@ -28,7 +28,7 @@ func (h *Handler) GetConfig(c *gin.Context) {
 		c.JSON(200, gin.H{})
 		return
 	}
-	c.JSON(200, new(*h.cfg))
+	c.JSON(200, new(*h.cfg.WithSecretReferences()))
 }
 
 type releaseInfo struct {
@@ -286,6 +286,8 @@ func normalizeRoutingStrategy(strategy string) (string, bool) {
 		return "round-robin", true
 	case "fill-first", "fillfirst", "ff":
 		return "fill-first", true
+	case "health-weighted", "healthweighted", "health":
+		return "health-weighted", true
 	default:
 		return "", false
 	}
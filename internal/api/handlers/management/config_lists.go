@@ -120,7 +120,7 @@ func (h *Handler) DeleteAPIKeys(c *gin.Context) {
 
 // gemini-api-key: []GeminiKey
 func (h *Handler) GetGeminiKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"gemini-api-key": h.cfg.GeminiKey})
+	c.JSON(200, gin.H{"gemini-api-key": h.cfg.WithSecretReferences().GeminiKey})
 }
 func (h *Handler) PutGeminiKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -166,7 +166,7 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 		targetIndex = *body.Index
 	}
 	if targetIndex == -1 && body.Match != nil {
-		match := strings.TrimSpace(*body.Match)
+		match := h.cfg.ResolvedSecret(strings.TrimSpace(*body.Match))
 		if match != "" {
 			for i := range h.cfg.GeminiKey {
 				if h.cfg.GeminiKey[i].APIKey == match {
@@ -213,7 +213,7 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 }
 
 func (h *Handler) DeleteGeminiKey(c *gin.Context) {
-	if val := strings.TrimSpace(c.Query("api-key")); val != "" {
+	if val := h.cfg.ResolvedSecret(strings.TrimSpace(c.Query("api-key"))); val != "" {
 		out := make([]config.GeminiKey, 0, len(h.cfg.GeminiKey))
 		for _, v := range h.cfg.GeminiKey {
 			if v.APIKey != val {
@@ -243,7 +243,7 @@ func (h *Handler) DeleteGeminiKey(c *gin.Context) {
 
 // claude-api-key: []ClaudeKey
 func (h *Handler) GetClaudeKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"claude-api-key": h.cfg.ClaudeKey})
+	c.JSON(200, gin.H{"claude-api-key": h.cfg.WithSecretReferences().ClaudeKey})
 }
 func (h *Handler) PutClaudeKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -293,7 +293,7 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 		targetIndex = *body.Index
 	}
 	if targetIndex == -1 && body.Match != nil {
-		match := strings.TrimSpace(*body.Match)
+		match := h.cfg.ResolvedSecret(strings.TrimSpace(*body.Match))
 		for i := range h.cfg.ClaudeKey {
 			if h.cfg.ClaudeKey[i].APIKey == match {
 				targetIndex = i
@@ -335,7 +335,7 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 }
 
 func (h *Handler) DeleteClaudeKey(c *gin.Context) {
-	if val := c.Query("api-key"); val != "" {
+	if val := h.cfg.ResolvedSecret(c.Query("api-key")); val != "" {
 		out := make([]config.ClaudeKey, 0, len(h.cfg.ClaudeKey))
 		for _, v := range h.cfg.ClaudeKey {
 			if v.APIKey != val {
@@ -362,7 +362,7 @@ func (h *Handler) DeleteClaudeKey(c *gin.Context) {
 
 // openai-compatibility: []OpenAICompatibility
 func (h *Handler) GetOpenAICompat(c *gin.Context) {
-	c.JSON(200, gin.H{"openai-compatibility": normalizedOpenAICompatibilityEntries(h.cfg.OpenAICompatibility)})
+	c.JSON(200, gin.H{"openai-compatibility": normalizedOpenAICompatibilityEntries(h.cfg.WithSecretReferences().OpenAICompatibility)})
 }
 func (h *Handler) PutOpenAICompat(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -488,7 +488,7 @@ func (h *Handler) DeleteOpenAICompat(c *gin.Context) {
 
 // vertex-api-key: []VertexCompatKey
 func (h *Handler) GetVertexCompatKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"vertex-api-key": h.cfg.VertexCompatAPIKey})
+	c.JSON(200, gin.H{"vertex-api-key": h.cfg.WithSecretReferences().VertexCompatAPIKey})
 }
 func (h *Handler) PutVertexCompatKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -537,7 +537,7 @@ func (h *Handler) PatchVertexCompatKey(c *gin.Context) {
 		targetIndex = *body.Index
 	}
 	if targetIndex == -1 && body.Match != nil {
-		match := strings.TrimSpace(*body.Match)
+		match := h.cfg.ResolvedSecret(strings.TrimSpace(*body.Match))
 		if match != "" {
 			for i := range h.cfg.VertexCompatAPIKey {
 				if h.cfg.VertexCompatAPIKey[i].APIKey == match {
@@ -592,7 +592,7 @@ func (h *Handler) PatchVertexCompatKey(c *gin.Context) {
 }
 
 func (h *Handler) DeleteVertexCompatKey(c *gin.Context) {
-	if val := strings.TrimSpace(c.Query("api-key")); val != "" {
+	if val := h.cfg.ResolvedSecret(strings.TrimSpace(c.Query("api-key"))); val != "" {
 		out := make([]config.VertexCompatKey, 0, len(h.cfg.VertexCompatAPIKey))
 		for _, v := range h.cfg.VertexCompatAPIKey {
 			if v.APIKey != val {
@@ -805,7 +805,7 @@ func (h *Handler) DeleteOAuthModelAlias(c *gin.Context) {
 
 // codex-api-key: []CodexKey
 func (h *Handler) GetCodexKeys(c *gin.Context) {
-	c.JSON(200, gin.H{"codex-api-key": h.cfg.CodexKey})
+	c.JSON(200, gin.H{"codex-api-key": h.cfg.WithSecretReferences().CodexKey})
 }
 func (h *Handler) PutCodexKeys(c *gin.Context) {
 	data, err := c.GetRawData()
@@ -862,7 +862,7 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 		targetIndex = *body.Index
 	}
 	if targetIndex == -1 && body.Match != nil {
-		match := strings.TrimSpace(*body.Match)
+		match := h.cfg.ResolvedSecret(strings.TrimSpace(*body.Match))
 		for i := range h.cfg.CodexKey {
 			if h.cfg.CodexKey[i].APIKey == match {
 				targetIndex = i
@@ -911,7 +911,7 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 }
 
 func (h *Handler) DeleteCodexKey(c *gin.Context) {
-	if val := c.Query("api-key"); val != "" {
+	if val := h.cfg.ResolvedSecret(c.Query("api-key")); val != "" {
 		out := make([]config.CodexKey, 0, len(h.cfg.CodexKey))
 		for _, v := range h.cfg.CodexKey {
 			if v.APIKey != val {
@@ -1073,7 +1073,7 @@ func (h *Handler) GetAmpCode(c *gin.Context) {
 		c.JSON(200, gin.H{"ampcode": config.AmpCode{}})
 		return
 	}
-	c.JSON(200, gin.H{"ampcode": h.cfg.AmpCode})
+	c.JSON(200, gin.H{"ampcode": h.cfg.WithSecretReferences().AmpCode})
 }
 
 // GetAmpUpstreamURL returns the ampcode upstream URL.
@@ -1102,7 +1102,7 @@ func (h *Handler) GetAmpUpstreamAPIKey(c *gin.Context) {
 		c.JSON(200, gin.H{"upstream-api-key": ""})
 		return
 	}
-	c.JSON(200, gin.H{"upstream-api-key": h.cfg.AmpCode.UpstreamAPIKey})
+	c.JSON(200, gin.H{"upstream-api-key": h.cfg.WithSecretReferences().AmpCode.UpstreamAPIKey})
 }
 
 // PutAmpUpstreamAPIKey updates the ampcode upstream API key.
@@ -1225,7 +1225,7 @@ func (h *Handler) GetAmpUpstreamAPIKeys(c *gin.Context) {
 		c.JSON(200, gin.H{"upstream-api-keys": []config.AmpUpstreamAPIKeyEntry{}})
 		return
 	}
-	c.JSON(200, gin.H{"upstream-api-keys": h.cfg.AmpCode.UpstreamAPIKeys})
+	c.JSON(200, gin.H{"upstream-api-keys": h.cfg.WithSecretReferences().AmpCode.UpstreamAPIKeys})
 }
 
 // PutAmpUpstreamAPIKeys replaces all ampcode upstream API keys mappings.
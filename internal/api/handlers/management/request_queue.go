@@ -0,0 +1,22 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetRequestQueue returns the requests parked per model while their credentials cool down.
+func (h *Handler) GetRequestQueue(c *gin.Context) {
+	models := make([]coreauth.QueueStat, 0)
+	if h != nil && h.authManager != nil {
+		models = append(models, h.authManager.QueueStats()...)
+	}
+	total := 0
+	for _, stat := range models {
+		total += stat.Queued
+	}
+	enabled := h != nil && h.cfg != nil && h.cfg.RequestQueue.Enable
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "queued": total, "models": models})
+}
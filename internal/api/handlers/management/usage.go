@@ -2,7 +2,11 @@ package management
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -69,11 +73,100 @@ func (h *Handler) ImportUsageStatistics(c *gin.Context) {
 	}
 
 	result := h.usageStats.MergeSnapshot(payload.Usage)
+	persisted, errPersist := usage.PersistSnapshot(c.Request.Context(), payload.Usage)
+	if errPersist != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to persist imported usage: %v", errPersist)})
+		return
+	}
 	snapshot := h.usageStats.Snapshot()
 	c.JSON(http.StatusOK, gin.H{
 		"added":           result.Added,
 		"skipped":         result.Skipped,
+		"persisted":       persisted,
 		"total_requests":  snapshot.TotalRequests,
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// GetUsageRecords returns persisted usage records filtered by time range, API key and model.
+func (h *Handler) GetUsageRecords(c *gin.Context) {
+	store := usage.GetStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": usage.ErrStoreDisabled.Error()})
+		return
+	}
+	query, errQuery := parseUsageStoreQuery(c)
+	if errQuery != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errQuery.Error()})
+		return
+	}
+	records, err := store.Query(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"records": records, "count": len(records)})
+}
+
+// GetUsageRollup aggregates persisted usage records by the requested dimensions and time bucket.
+func (h *Handler) GetUsageRollup(c *gin.Context) {
+	store := usage.GetStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": usage.ErrStoreDisabled.Error()})
+		return
+	}
+	query, errQuery := parseUsageStoreQuery(c)
+	if errQuery != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errQuery.Error()})
+		return
+	}
+	rollup := usage.RollupQuery{StoreQuery: query, Bucket: strings.TrimSpace(c.Query("bucket"))}
+	for _, dimension := range strings.Split(c.Query("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			rollup.GroupBy = append(rollup.GroupBy, dimension)
+		}
+	}
+	rows, err := store.Rollup(c.Request.Context(), rollup)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rows": rows})
+}
+
+// parseUsageStoreQuery reads the shared from/to/api_key/model/limit query parameters.
+// Times accept RFC3339 or unix seconds.
+func parseUsageStoreQuery(c *gin.Context) (usage.StoreQuery, error) {
+	var query usage.StoreQuery
+	var err error
+	if query.From, err = parseUsageTime(c.Query("from")); err != nil {
+		return query, fmt.Errorf("invalid from: %w", err)
+	}
+	if query.To, err = parseUsageTime(c.Query("to")); err != nil {
+		return query, fmt.Errorf("invalid to: %w", err)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.To.After(query.From) {
+		return query, errors.New("to must be after from")
+	}
+	query.APIKey = strings.TrimSpace(c.Query("api_key"))
+	query.Model = strings.TrimSpace(c.Query("model"))
+	if query.Limit, err = parseLimit(c.Query("limit")); err != nil {
+		return query, fmt.Errorf("invalid limit: %w", err)
+	}
+	return query, nil
+}
+
+func parseUsageTime(raw string) (time.Time, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or unix seconds")
+	}
+	return ts.UTC(), nil
+}
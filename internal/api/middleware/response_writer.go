@@ -282,6 +282,10 @@ func (w *ResponseWriterWrapper) Finalize(c *gin.Context) error {
 		return nil
 	}
 
+	if annotator, ok := w.logger.(logging.RequestAnnotator); ok && w.requestInfo != nil {
+		annotator.AnnotateRequest(w.requestInfo.RequestID, logging.RequestAnnotationFromGin(c))
+	}
+
 	if w.isStreaming && w.streamWriter != nil {
 		if w.chunkChannel != nil {
 			close(w.chunkChannel)
@@ -329,12 +333,16 @@ func (w *ResponseWriterWrapper) cloneHeaders() map[string][]string {
 }
 
 func (w *ResponseWriterWrapper) extractAPIRequest(c *gin.Context) []byte {
-	apiRequest, isExist := c.Get("API_REQUEST")
-	if !isExist {
-		return nil
+	var data []byte
+	if apiRequest, isExist := c.Get("API_REQUEST"); isExist {
+		data, _ = apiRequest.([]byte)
 	}
-	data, ok := apiRequest.([]byte)
-	if !ok || len(data) == 0 {
+	// Decisions made before dispatch, such as prompt trimming, lead the upstream requests.
+	if notes := logging.RequestNotes(c); len(notes) > 0 {
+		section := "=== REQUEST NOTES ===\n" + strings.Join(notes, "\n") + "\n\n"
+		data = append([]byte(section), data...)
+	}
+	if len(data) == 0 {
 		return nil
 	}
 	return data
@@ -25,9 +25,13 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -65,6 +69,18 @@ func defaultRequestLoggerFactory(cfg *config.Config, configPath string) logging.
 	return logging.NewFileRequestLogger(cfg.RequestLog, "logs", configDir, cfg.ErrorLogsMaxFiles)
 }
 
+// requestLogDir resolves the directory used by the default request logger, which the audit log shares.
+func requestLogDir(configPath string) string {
+	logsDir := "logs"
+	if base := util.WritablePath(); base != "" {
+		logsDir = filepath.Join(base, "logs")
+	}
+	if !filepath.IsAbs(logsDir) {
+		logsDir = filepath.Join(filepath.Dir(configPath), logsDir)
+	}
+	return logsDir
+}
+
 // WithMiddleware appends additional Gin middleware during server construction.
 func WithMiddleware(mw ...gin.HandlerFunc) ServerOption {
 	return func(cfg *serverOptionConfig) {
@@ -138,6 +154,9 @@ type Server struct {
 	requestLogger logging.RequestLogger
 	loggerToggle  func(bool)
 
+	// auditLogger writes the structured JSONL audit log; nil in commercial mode.
+	auditLogger *logging.JSONLRequestLogger
+
 	// configFilePath is the absolute path to the YAML config file for persistence.
 	configFilePath string
 
@@ -203,6 +222,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
+	engine.Use(tracing.Middleware(logging.GetGinRequestID))
 	engine.Use(logging.GinLogrusRecovery())
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
@@ -211,17 +231,20 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Add request logging middleware (positioned after recovery, before auth)
 	// Resolve logs directory relative to the configuration file directory.
 	var requestLogger logging.RequestLogger
+	var auditLogger *logging.JSONLRequestLogger
 	var toggle func(bool)
 	if !cfg.CommercialMode {
 		if optionState.requestLoggerFactory != nil {
 			requestLogger = optionState.requestLoggerFactory(cfg, configFilePath)
 		}
 		if requestLogger != nil {
-			engine.Use(middleware.RequestLoggingMiddleware(requestLogger))
 			if setter, ok := requestLogger.(interface{ SetEnabled(bool) }); ok {
 				toggle = setter.SetEnabled
 			}
 		}
+		// The audit log shares the request logging call sites as a second sink.
+		auditLogger = logging.NewJSONLRequestLogger(cfg.AuditLog, requestLogDir(configFilePath))
+		engine.Use(middleware.RequestLoggingMiddleware(logging.NewMultiRequestLogger(requestLogger, auditLogger)))
 	}
 
 	engine.Use(corsMiddleware())
@@ -241,6 +264,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		cfg:                 cfg,
 		accessManager:       accessManager,
 		requestLogger:       requestLogger,
+		auditLogger:         auditLogger,
 		loggerToggle:        toggle,
 		configFilePath:      configFilePath,
 		currentPath:         wd,
@@ -256,6 +280,19 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	metrics.Default().SetEnabled(cfg.Metrics.Enable)
+	if err := usage.ConfigureStore(cfg); err != nil {
+		log.Errorf("failed to open usage store: %v", err)
+	}
+	if err := responsestore.Configure(cfg); err != nil {
+		log.Errorf("failed to open response store: %v", err)
+	}
+	if err := batch.Configure(cfg); err != nil {
+		log.Errorf("failed to open batch store: %v", err)
+	}
+	if err := tracing.Configure(cfg.Tracing); err != nil {
+		log.Errorf("failed to configure tracing: %v", err)
+	}
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -320,6 +357,8 @@ func (s *Server) setupRoutes() {
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	openaiBatchHandlers := openai.NewOpenAIBatchAPIHandler(s.handlers)
+	batch.SetExecutor(openaiBatchHandlers.ExecuteBatchRequest)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
@@ -327,12 +366,26 @@ func (s *Server) setupRoutes() {
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
+		v1.POST("/chat/completions/count_tokens", openaiHandlers.CountTokens)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.GET("/responses", openaiResponsesHandlers.ResponsesWebsocket)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
 		v1.POST("/responses/compact", openaiResponsesHandlers.Compact)
+		v1.POST("/responses/input_tokens", openaiResponsesHandlers.InputTokens)
+		v1.GET("/responses/:id", openaiResponsesHandlers.GetResponse)
+		v1.DELETE("/responses/:id", openaiResponsesHandlers.DeleteResponse)
+		v1.POST("/files", openaiBatchHandlers.UploadFile)
+		v1.GET("/files", openaiBatchHandlers.ListFiles)
+		v1.GET("/files/:id", openaiBatchHandlers.GetFile)
+		v1.GET("/files/:id/content", openaiBatchHandlers.GetFileContent)
+		v1.DELETE("/files/:id", openaiBatchHandlers.DeleteFile)
+		v1.POST("/batches", openaiBatchHandlers.CreateBatch)
+		v1.GET("/batches", openaiBatchHandlers.ListBatches)
+		v1.GET("/batches/:id", openaiBatchHandlers.GetBatch)
+		v1.POST("/batches/:id/cancel", openaiBatchHandlers.CancelBatch)
 	}
 
 	// Gemini compatible API routes
@@ -344,6 +397,9 @@ func (s *Server) setupRoutes() {
 		v1beta.GET("/models/*action", geminiHandlers.GeminiGetHandler)
 	}
 
+	// Prometheus metrics endpoint
+	s.engine.GET("/metrics", s.serveMetrics)
+
 	// Root endpoint
 	s.engine.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -506,6 +562,8 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/usage/records", s.mgmt.GetUsageRecords)
+		mgmt.GET("/usage/rollup", s.mgmt.GetUsageRollup)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
@@ -551,6 +609,11 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
 
+		mgmt.GET("/api-key-limits", s.mgmt.GetAPIKeyLimits)
+		mgmt.PUT("/api-key-limits", s.mgmt.PutAPIKeyLimits)
+		mgmt.DELETE("/api-key-limits", s.mgmt.DeleteAPIKeyLimits)
+		mgmt.GET("/api-key-limits/usage", s.mgmt.GetAPIKeyLimitUsage)
+
 		mgmt.GET("/gemini-api-key", s.mgmt.GetGeminiKeys)
 		mgmt.PUT("/gemini-api-key", s.mgmt.PutGeminiKeys)
 		mgmt.PATCH("/gemini-api-key", s.mgmt.PatchGeminiKey)
@@ -598,6 +661,8 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/max-retry-interval", s.mgmt.GetMaxRetryInterval)
 		mgmt.PUT("/max-retry-interval", s.mgmt.PutMaxRetryInterval)
 		mgmt.PATCH("/max-retry-interval", s.mgmt.PutMaxRetryInterval)
+		mgmt.GET("/request-queue", s.mgmt.GetRequestQueue)
+		mgmt.GET("/circuit-breakers", s.mgmt.GetCircuitBreakers)
 
 		mgmt.GET("/force-model-prefix", s.mgmt.GetForceModelPrefix)
 		mgmt.PUT("/force-model-prefix", s.mgmt.PutForceModelPrefix)
@@ -704,6 +769,19 @@ func (s *Server) serveManagementControlPanel(c *gin.Context) {
 	c.File(filePath)
 }
 
+func (s *Server) serveMetrics(c *gin.Context) {
+	collector := metrics.Default()
+	if !collector.Enabled() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", metrics.ContentType)
+	if _, err := collector.WriteTo(c.Writer); err != nil {
+		log.WithError(err).Debug("failed to write metrics response")
+	}
+}
+
 func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
 	if timeout <= 0 || onTimeout == nil {
 		return
@@ -856,6 +934,15 @@ func (s *Server) Stop(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
 
+	if s.auditLogger != nil {
+		if err := s.auditLogger.Close(); err != nil {
+			log.Warnf("failed to close audit log: %v", err)
+		}
+	}
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Warnf("failed to flush traces: %v", err)
+	}
+
 	log.Debug("API server stopped")
 	return nil
 }
@@ -925,6 +1012,38 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	}
 
+	if oldCfg == nil || oldCfg.UsageStore != cfg.UsageStore || oldCfg.AuthDir != cfg.AuthDir {
+		if err := usage.ConfigureStore(cfg); err != nil {
+			log.Errorf("failed to reconfigure usage store: %v", err)
+		}
+	}
+
+	if oldCfg == nil || oldCfg.ResponseStore != cfg.ResponseStore || oldCfg.AuthDir != cfg.AuthDir {
+		if err := responsestore.Configure(cfg); err != nil {
+			log.Errorf("failed to reconfigure response store: %v", err)
+		}
+	}
+
+	if oldCfg == nil || oldCfg.Batch != cfg.Batch || oldCfg.AuthDir != cfg.AuthDir {
+		if err := batch.Configure(cfg); err != nil {
+			log.Errorf("failed to reconfigure batch store: %v", err)
+		}
+	}
+
+	if oldCfg == nil || oldCfg.Metrics.Enable != cfg.Metrics.Enable {
+		metrics.Default().SetEnabled(cfg.Metrics.Enable)
+	}
+
+	if s.auditLogger != nil && (oldCfg == nil || !reflect.DeepEqual(oldCfg.AuditLog, cfg.AuditLog)) {
+		s.auditLogger.Configure(cfg.AuditLog)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Tracing, cfg.Tracing) {
+		if err := tracing.Configure(cfg.Tracing); err != nil {
+			log.Errorf("failed to reconfigure tracing: %v", err)
+		}
+	}
+
 	if s.requestLogger != nil && (oldCfg == nil || oldCfg.ErrorLogsMaxFiles != cfg.ErrorLogsMaxFiles) {
 		if setter, ok := s.requestLogger.(interface{ SetErrorLogsMaxFiles(int) }); ok {
 			setter.SetErrorLogsMaxFiles(cfg.ErrorLogsMaxFiles)
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
@@ -863,7 +864,7 @@ func (h *OAuthWebHandler) handleManualRefresh(c *gin.Context) {
 		}
 
 		filePath := filepath.Join(authDir, name)
-		data, err := os.ReadFile(filePath)
+		data, err := authcrypt.ReadFile(filePath)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: read error - %v", name, err))
 			continue
@@ -906,7 +907,7 @@ func (h *OAuthWebHandler) handleManualRefresh(c *gin.Context) {
 		}
 
 		tmpFile := filePath + ".tmp"
-		if err := os.WriteFile(tmpFile, updatedData, 0600); err != nil {
+		if err := authcrypt.WriteFile(tmpFile, updatedData, 0600); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: write error - %v", name, err))
 			continue
 		}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 )
 
 // KiroTokenStorage holds the persistent token data for Kiro authentication.
@@ -49,7 +51,7 @@ func (s *KiroTokenStorage) SaveTokenToFile(authFilePath string) error {
 		return fmt.Errorf("failed to marshal token storage: %w", err)
 	}
 
-	if err := os.WriteFile(authFilePath, data, 0600); err != nil {
+	if err := authcrypt.WriteFile(authFilePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write token file: %w", err)
 	}
 
@@ -58,7 +60,7 @@ func (s *KiroTokenStorage) SaveTokenToFile(authFilePath string) error {
 
 // LoadFromFile loads token storage from the specified file path.
 func LoadFromFile(authFilePath string) (*KiroTokenStorage, error) {
-	data, err := os.ReadFile(authFilePath)
+	data, err := authcrypt.ReadFile(authFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read token file: %w", err)
 	}
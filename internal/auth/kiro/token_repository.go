@@ -13,6 +13,8 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 )
 
 // FileTokenRepository 实现 TokenRepository 接口，基于文件系统存储
@@ -119,7 +121,7 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 
 	// 读取现有文件内容
 	existingData := make(map[string]any)
-	if data, err := os.ReadFile(filePath); err == nil {
+	if data, err := authcrypt.ReadFile(filePath); err == nil {
 		_ = json.Unmarshal(data, &existingData)
 	}
 
@@ -157,7 +159,7 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 
 	// 原子写入：先写入临时文件，再重命名
 	tmpPath := filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+	if err := authcrypt.WriteFile(tmpPath, raw, 0o600); err != nil {
 		return fmt.Errorf("token repository: write temp file failed: %w", err)
 	}
 	if err := os.Rename(tmpPath, filePath); err != nil {
@@ -171,7 +173,7 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 
 // readTokenFile 从文件读取 token
 func (r *FileTokenRepository) readTokenFile(path string) (*Token, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
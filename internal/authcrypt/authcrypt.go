@@ -0,0 +1,331 @@
+// Package authcrypt encrypts auth files at rest with envelope encryption: every file is
+// sealed with its own AES-256-GCM data key, which is in turn sealed with a configured
+// key-encryption key. Sealed files stay JSON, so every token store keeps handling them as
+// auth files; plaintext files pass through untouched until their next write.
+package authcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// envelopeVersion marks sealed files and versions their layout.
+const envelopeVersion = "v1"
+
+// envelope is the JSON layout of a sealed auth file.
+type envelope struct {
+	Version string `json:"cliproxy_encrypted"`
+	KeyID   string `json:"key_id"`
+	// WrappedKey is the data key sealed with the key-encryption key, nonce first.
+	WrappedKey string `json:"wrapped_key"`
+	// Data is the file content sealed with the data key, nonce first.
+	Data string `json:"data"`
+}
+
+// keyring holds the configured key-encryption keys.
+type keyring struct {
+	enabled bool
+	primary string
+	keys    map[string][]byte
+}
+
+var active atomic.Pointer[keyring]
+
+// Configure loads the keys of cfg and makes them the active keyring. On error the previous
+// keyring stays active.
+func Configure(cfg config.AuthEncryptionConfig) error {
+	ring := &keyring{enabled: cfg.Enable, keys: make(map[string][]byte, len(cfg.Keys))}
+	for i, entry := range cfg.Keys {
+		id := strings.TrimSpace(entry.ID)
+		if id == "" {
+			return fmt.Errorf("authcrypt: auth-encryption.keys[%d] has no id", i)
+		}
+		if _, dup := ring.keys[id]; dup {
+			return fmt.Errorf("authcrypt: duplicate key id %q", id)
+		}
+		key, err := loadKey(entry)
+		if err != nil {
+			return fmt.Errorf("authcrypt: key %q: %w", id, err)
+		}
+		ring.keys[id] = key
+		if ring.primary == "" {
+			ring.primary = id
+		}
+	}
+	if ring.enabled && ring.primary == "" {
+		return fmt.Errorf("authcrypt: auth-encryption is enabled without keys")
+	}
+	active.Store(ring)
+	return nil
+}
+
+func loadKey(entry config.AuthEncryptionKey) ([]byte, error) {
+	var encoded string
+	switch {
+	case strings.TrimSpace(entry.Env) != "":
+		encoded = os.Getenv(strings.TrimSpace(entry.Env))
+		if strings.TrimSpace(encoded) == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", strings.TrimSpace(entry.Env))
+		}
+	case strings.TrimSpace(entry.File) != "":
+		data, err := os.ReadFile(strings.TrimSpace(entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		encoded = string(data)
+	default:
+		return nil, fmt.Errorf("neither env nor file is set")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decode base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key is %d bytes, want 32", len(key))
+	}
+	return key, nil
+}
+
+// Enabled reports whether writes are encrypted.
+func Enabled() bool {
+	ring := active.Load()
+	return ring != nil && ring.enabled
+}
+
+// Seal returns the form data should be stored in: sealed with the primary key when encryption
+// is enabled, data itself otherwise. Sealed input is returned unchanged.
+func Seal(data []byte) ([]byte, error) {
+	ring := active.Load()
+	if ring == nil || !ring.enabled || IsSealed(data) {
+		return data, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate data key: %w", err)
+	}
+	wrapped, err := sealGCM(ring.keys[ring.primary], dataKey, []byte(ring.primary))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealGCM(dataKey, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Version:    envelopeVersion,
+		KeyID:      ring.primary,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		Data:       base64.StdEncoding.EncodeToString(sealed),
+	})
+}
+
+// Open returns the plaintext of a stored auth file. Plaintext input is returned unchanged.
+func Open(data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("authcrypt: unsupported envelope version %q", env.Version)
+	}
+	ring := active.Load()
+	var kek []byte
+	if ring != nil {
+		kek = ring.keys[env.KeyID]
+	}
+	if kek == nil {
+		return nil, fmt.Errorf("authcrypt: no key %q configured to decrypt auth file", env.KeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decode wrapped key: %w", err)
+	}
+	dataKey, err := openGCM(kek, wrapped, []byte(env.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: unwrap data key with key %q: %w", env.KeyID, err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decode data: %w", err)
+	}
+	plain, err := openGCM(dataKey, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decrypt auth file: %w", err)
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data is a sealed auth file.
+func IsSealed(data []byte) bool {
+	_, ok := parseEnvelope(data)
+	return ok
+}
+
+// Current reports whether stored data is already in the form Seal would write now: sealed
+// with the primary key when encryption is enabled, plaintext otherwise. Stores rewrite files
+// that are not current even when their content is unchanged, which migrates plaintext files
+// and rotates keys.
+func Current(data []byte) bool {
+	env, sealed := parseEnvelope(data)
+	ring := active.Load()
+	if ring == nil || !ring.enabled {
+		return !sealed
+	}
+	return sealed && env.KeyID == ring.primary
+}
+
+// ReadFile reads and opens the auth file at path.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Open(data)
+}
+
+// WriteFile seals data and writes it to path. The sealed content goes to a temporary file
+// next to path that is renamed over it, so readers never observe a partial file and
+// plaintext never reaches the disk.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := Seal(data)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, sealed, perm)
+}
+
+// WriteStorage encodes a token storage in memory and writes it to path like WriteFile. Token
+// storages tag themselves with their provider type when saving to a file; the same tag is
+// applied here from provider when the storage does not carry one.
+func WriteStorage(path string, storage any, provider string) error {
+	data, err := EncodeStorage(storage, provider)
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, data, 0o600)
+}
+
+// EncodeStorage serialises a token storage as the JSON object it would write to its auth
+// file, with "type" defaulting to provider.
+func EncodeStorage(storage any, provider string) ([]byte, error) {
+	data, err := json.Marshal(storage)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: encode token storage: %w", err)
+	}
+	var metadata map[string]any
+	if err = json.Unmarshal(data, &metadata); err != nil || metadata == nil {
+		return nil, fmt.Errorf("authcrypt: token storage is not a JSON object")
+	}
+	if typ, _ := metadata["type"].(string); strings.TrimSpace(typ) == "" && provider != "" {
+		metadata["type"] = provider
+	}
+	return json.Marshal(metadata)
+}
+
+// writeAtomic writes data to a temporary file in the directory of path and renames it over
+// path.
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	if err = tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, path)
+	return err
+}
+
+// SealFile rewrites the auth file at path in its current form. It is used after writers that
+// produce plaintext on their own, such as token storages saving themselves.
+func SealFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if Current(data) {
+		return nil
+	}
+	plain, err := Open(data)
+	if err != nil {
+		return err
+	}
+	sealed, err := Seal(plain)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, sealed, 0o600)
+}
+
+func parseEnvelope(data []byte) (envelope, bool) {
+	var env envelope
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(`"cliproxy_encrypted"`)) {
+		return env, false
+	}
+	if err := json.Unmarshal(trimmed, &env); err != nil || env.Version == "" {
+		return env, false
+	}
+	return env, true
+}
+
+func sealGCM(key, plain, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+func openGCM(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
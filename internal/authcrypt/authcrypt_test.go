@@ -0,0 +1,102 @@
+package authcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestSealOpenRotation(t *testing.T) {
+	t.Cleanup(func() { active.Store(nil) })
+	t.Setenv("AUTHCRYPT_TEST_OLD", testKey(1))
+	keyFile := filepath.Join(t.TempDir(), "new.key")
+	if err := os.WriteFile(keyFile, []byte(testKey(2)+"\n"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	plain := []byte(`{"type":"claude","refresh_token":"rt-secret"}`)
+
+	if err := Configure(config.AuthEncryptionConfig{Enable: true, Keys: []config.AuthEncryptionKey{{ID: "old", Env: "AUTHCRYPT_TEST_OLD"}}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if Current(plain) {
+		t.Fatalf("plaintext reported current while encryption is enabled")
+	}
+	sealed, err := Seal(plain)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("rt-secret")) || !IsSealed(sealed) || !Current(sealed) {
+		t.Fatalf("sealed = %s", sealed)
+	}
+
+	// Rotate: the new key encrypts, the old one still decrypts.
+	if err = Configure(config.AuthEncryptionConfig{Enable: true, Keys: []config.AuthEncryptionKey{{ID: "new", File: keyFile}, {ID: "old", Env: "AUTHCRYPT_TEST_OLD"}}}); err != nil {
+		t.Fatalf("Configure rotated: %v", err)
+	}
+	if Current(sealed) {
+		t.Fatalf("file sealed with the old key reported current")
+	}
+	if got, errOpen := Open(sealed); errOpen != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open = %s, %v", got, errOpen)
+	}
+	if got, errOpen := Open(plain); errOpen != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open plaintext = %s, %v", got, errOpen)
+	}
+
+	// Dropping the old key makes its files unreadable.
+	if err = Configure(config.AuthEncryptionConfig{Enable: true, Keys: []config.AuthEncryptionKey{{ID: "new", File: keyFile}}}); err != nil {
+		t.Fatalf("Configure without old key: %v", err)
+	}
+	if _, err = Open(sealed); err == nil || !strings.Contains(err.Error(), `"old"`) {
+		t.Fatalf("Open without key err = %v", err)
+	}
+}
+
+func TestSealFileMigratesPlaintext(t *testing.T) {
+	t.Cleanup(func() { active.Store(nil) })
+	t.Setenv("AUTHCRYPT_TEST_KEY", testKey(3))
+	path := filepath.Join(t.TempDir(), "codex.json")
+	plain := []byte(`{"type":"codex"}`)
+	if err := os.WriteFile(path, plain, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	keys := []config.AuthEncryptionKey{{ID: "k", Env: "AUTHCRYPT_TEST_KEY"}}
+	if err := Configure(config.AuthEncryptionConfig{Enable: true, Keys: keys}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := SealFile(path); err != nil {
+		t.Fatalf("SealFile: %v", err)
+	}
+	stored, _ := os.ReadFile(path)
+	if !IsSealed(stored) {
+		t.Fatalf("file not sealed: %s", stored)
+	}
+	if got, err := ReadFile(path); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("ReadFile = %s, %v", got, err)
+	}
+
+	// Disabling with the key kept writes the file back as plaintext.
+	if err := Configure(config.AuthEncryptionConfig{Keys: keys}); err != nil {
+		t.Fatalf("Configure disabled: %v", err)
+	}
+	if err := SealFile(path); err != nil {
+		t.Fatalf("SealFile disabled: %v", err)
+	}
+	if stored, _ = os.ReadFile(path); !bytes.Equal(stored, plain) {
+		t.Fatalf("file not decrypted: %s", stored)
+	}
+
+	if err := Configure(config.AuthEncryptionConfig{Enable: true}); err == nil {
+		t.Fatalf("Configure accepted encryption without keys")
+	}
+}
@@ -0,0 +1,273 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileBackend stores files, jobs and results under a directory:
+// files/<id>.json and files/<id>.jsonl, jobs/<id>.json, and results/<job id>.jsonl.
+type FileBackend struct {
+	dir string
+	// mu serialises appends to result files.
+	mu sync.Mutex
+}
+
+// NewFileBackend prepares dir (creating it when missing) for storing batch state.
+func NewFileBackend(dir string) (*FileBackend, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("batch: directory is required")
+	}
+	for _, sub := range []string{"files", "jobs", "results"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("batch: create directory: %w", err)
+		}
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+// path maps an ID to a file in sub, rejecting IDs that could escape the directory.
+func (b *FileBackend) path(sub, id, ext string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", ErrNotFound
+	}
+	return filepath.Join(b.dir, sub, id+ext), nil
+}
+
+// PutFile implements Backend.
+func (b *FileBackend) PutFile(_ context.Context, file *File, content []byte) error {
+	contentPath, err := b.path("files", file.ID, ".jsonl")
+	if err != nil {
+		return fmt.Errorf("batch: invalid file id %q", file.ID)
+	}
+	metaPath, _ := b.path("files", file.ID, ".json")
+	if err = writeFileAtomic(contentPath, content); err != nil {
+		return err
+	}
+	return writeJSONAtomic(metaPath, file)
+}
+
+// GetFile implements Backend.
+func (b *FileBackend) GetFile(_ context.Context, id string) (*File, error) {
+	path, err := b.path("files", id, ".json")
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	if err = readJSONFile(path, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FileContent implements Backend.
+func (b *FileBackend) FileContent(_ context.Context, id string) ([]byte, error) {
+	path, err := b.path("files", id, ".jsonl")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("batch: read file: %w", err)
+	}
+	return data, nil
+}
+
+// ListFiles implements Backend.
+func (b *FileBackend) ListFiles(_ context.Context) ([]*File, error) {
+	var files []*File
+	err := b.walkJSON("files", func(path string) {
+		var file File
+		if readJSONFile(path, &file) == nil {
+			files = append(files, &file)
+		}
+	})
+	return files, err
+}
+
+// DeleteFile implements Backend.
+func (b *FileBackend) DeleteFile(_ context.Context, id string) error {
+	metaPath, err := b.path("files", id, ".json")
+	if err != nil {
+		return err
+	}
+	contentPath, _ := b.path("files", id, ".jsonl")
+	if err = os.Remove(metaPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("batch: delete file: %w", err)
+	}
+	if err = os.Remove(contentPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("batch: delete file: %w", err)
+	}
+	return nil
+}
+
+// PutJob implements Backend.
+func (b *FileBackend) PutJob(_ context.Context, job *Job) error {
+	path, err := b.path("jobs", job.ID, ".json")
+	if err != nil {
+		return fmt.Errorf("batch: invalid job id %q", job.ID)
+	}
+	return writeJSONAtomic(path, job)
+}
+
+// GetJob implements Backend.
+func (b *FileBackend) GetJob(_ context.Context, id string) (*Job, error) {
+	path, err := b.path("jobs", id, ".json")
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err = readJSONFile(path, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs implements Backend.
+func (b *FileBackend) ListJobs(_ context.Context) ([]*Job, error) {
+	var jobs []*Job
+	err := b.walkJSON("jobs", func(path string) {
+		var job Job
+		if readJSONFile(path, &job) == nil {
+			jobs = append(jobs, &job)
+		}
+	})
+	return jobs, err
+}
+
+// AppendResult implements Backend.
+func (b *FileBackend) AppendResult(_ context.Context, jobID string, result Result) error {
+	path, err := b.path("results", jobID, ".jsonl")
+	if err != nil {
+		return fmt.Errorf("batch: invalid job id %q", jobID)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("batch: encode result: %w", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("batch: open results: %w", err)
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("batch: write result: %w", err)
+	}
+	return f.Close()
+}
+
+// Results implements Backend. A partially written last line from a crash is skipped.
+func (b *FileBackend) Results(_ context.Context, jobID string) ([]Result, error) {
+	path, err := b.path("results", jobID, ".jsonl")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("batch: read results: %w", err)
+	}
+	var results []Result
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		var result Result
+		if json.Unmarshal(scanner.Bytes(), &result) == nil {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// DeleteResults implements Backend.
+func (b *FileBackend) DeleteResults(_ context.Context, jobID string) error {
+	path, err := b.path("results", jobID, ".jsonl")
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("batch: delete results: %w", err)
+	}
+	return nil
+}
+
+// Close implements Backend.
+func (b *FileBackend) Close() error { return nil }
+
+func (b *FileBackend) walkJSON(sub string, fn func(path string)) error {
+	entries, err := os.ReadDir(filepath.Join(b.dir, sub))
+	if err != nil {
+		return fmt.Errorf("batch: list directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		fn(filepath.Join(b.dir, sub, name))
+	}
+	return nil
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("batch: read %s: %w", filepath.Base(path), err)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("batch: decode %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func writeJSONAtomic(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("batch: encode %s: %w", filepath.Base(path), err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("batch: create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("batch: write %s: %w", filepath.Base(path), err)
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("batch: write %s: %w", filepath.Base(path), err)
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("batch: write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresBackend stores batch state in the Postgres token store database.
+type PostgresBackend struct {
+	db      *sql.DB
+	files   string
+	jobs    string
+	results string
+}
+
+// NewPostgresBackend prepares the batch tables on an existing PostgreSQL connection.
+// Close leaves the connection open for its other users.
+func NewPostgresBackend(ctx context.Context, db *sql.DB, schema string) (*PostgresBackend, error) {
+	if db == nil {
+		return nil, fmt.Errorf("batch: postgres connection is nil")
+	}
+	qualify := quoteIdentifier
+	if schema = strings.TrimSpace(schema); schema != "" {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdentifier(schema))); err != nil {
+			return nil, fmt.Errorf("batch: create schema: %w", err)
+		}
+		qualify = func(name string) string { return quoteIdentifier(schema) + "." + quoteIdentifier(name) }
+	}
+	b := &PostgresBackend{
+		db:      db,
+		files:   qualify("batch_files"),
+		jobs:    qualify("batch_jobs"),
+		results: qualify("batch_results"),
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			data JSONB NOT NULL,
+			content BYTEA NOT NULL
+		)`, b.files),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			data JSONB NOT NULL
+		)`, b.jobs),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			job_id TEXT NOT NULL,
+			idx INTEGER NOT NULL,
+			data JSONB NOT NULL,
+			PRIMARY KEY (job_id, idx)
+		)`, b.results),
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("batch: create tables: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// PutFile implements Backend.
+func (b *PostgresBackend) PutFile(ctx context.Context, file *File, content []byte) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("batch: encode file: %w", err)
+	}
+	_, err = b.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (id, data, content) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, content = EXCLUDED.content
+	`, b.files), file.ID, string(data), content)
+	if err != nil {
+		return fmt.Errorf("batch: insert file: %w", err)
+	}
+	return nil
+}
+
+// GetFile implements Backend.
+func (b *PostgresBackend) GetFile(ctx context.Context, id string) (*File, error) {
+	var data string
+	err := b.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data FROM %s WHERE id = $1", b.files), id).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("batch: query file: %w", err)
+	}
+	var file File
+	if err = json.Unmarshal([]byte(data), &file); err != nil {
+		return nil, fmt.Errorf("batch: decode file: %w", err)
+	}
+	return &file, nil
+}
+
+// FileContent implements Backend.
+func (b *PostgresBackend) FileContent(ctx context.Context, id string) ([]byte, error) {
+	var content []byte
+	err := b.db.QueryRowContext(ctx, fmt.Sprintf("SELECT content FROM %s WHERE id = $1", b.files), id).Scan(&content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("batch: query file content: %w", err)
+	}
+	return content, nil
+}
+
+// ListFiles implements Backend.
+func (b *PostgresBackend) ListFiles(ctx context.Context) ([]*File, error) {
+	var files []*File
+	err := b.queryJSON(ctx, fmt.Sprintf("SELECT data FROM %s", b.files), func(data []byte) {
+		var file File
+		if json.Unmarshal(data, &file) == nil {
+			files = append(files, &file)
+		}
+	})
+	return files, err
+}
+
+// DeleteFile implements Backend.
+func (b *PostgresBackend) DeleteFile(ctx context.Context, id string) error {
+	res, err := b.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", b.files), id)
+	if err != nil {
+		return fmt.Errorf("batch: delete file: %w", err)
+	}
+	if n, errRows := res.RowsAffected(); errRows == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PutJob implements Backend.
+func (b *PostgresBackend) PutJob(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("batch: encode job: %w", err)
+	}
+	_, err = b.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data
+	`, b.jobs), job.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("batch: insert job: %w", err)
+	}
+	return nil
+}
+
+// GetJob implements Backend.
+func (b *PostgresBackend) GetJob(ctx context.Context, id string) (*Job, error) {
+	var data string
+	err := b.db.QueryRowContext(ctx, fmt.Sprintf("SELECT data FROM %s WHERE id = $1", b.jobs), id).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("batch: query job: %w", err)
+	}
+	var job Job
+	if err = json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("batch: decode job: %w", err)
+	}
+	return &job, nil
+}
+
+// ListJobs implements Backend.
+func (b *PostgresBackend) ListJobs(ctx context.Context) ([]*Job, error) {
+	var jobs []*Job
+	err := b.queryJSON(ctx, fmt.Sprintf("SELECT data FROM %s", b.jobs), func(data []byte) {
+		var job Job
+		if json.Unmarshal(data, &job) == nil {
+			jobs = append(jobs, &job)
+		}
+	})
+	return jobs, err
+}
+
+// AppendResult implements Backend.
+func (b *PostgresBackend) AppendResult(ctx context.Context, jobID string, result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("batch: encode result: %w", err)
+	}
+	_, err = b.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (job_id, idx, data) VALUES ($1, $2, $3)
+		ON CONFLICT (job_id, idx) DO UPDATE SET data = EXCLUDED.data
+	`, b.results), jobID, result.Index, string(data))
+	if err != nil {
+		return fmt.Errorf("batch: insert result: %w", err)
+	}
+	return nil
+}
+
+// Results implements Backend.
+func (b *PostgresBackend) Results(ctx context.Context, jobID string) ([]Result, error) {
+	var results []Result
+	err := b.queryJSON(ctx, fmt.Sprintf("SELECT data FROM %s WHERE job_id = $1", b.results), func(data []byte) {
+		var result Result
+		if json.Unmarshal(data, &result) == nil {
+			results = append(results, result)
+		}
+	}, jobID)
+	return results, err
+}
+
+// DeleteResults implements Backend.
+func (b *PostgresBackend) DeleteResults(ctx context.Context, jobID string) error {
+	if _, err := b.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE job_id = $1", b.results), jobID); err != nil {
+		return fmt.Errorf("batch: delete results: %w", err)
+	}
+	return nil
+}
+
+// Close implements Backend. The shared connection stays open.
+func (b *PostgresBackend) Close() error { return nil }
+
+func (b *PostgresBackend) queryJSON(ctx context.Context, query string, fn func(data []byte), args ...any) error {
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("batch: query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var data string
+		if err = rows.Scan(&data); err != nil {
+			return fmt.Errorf("batch: scan: %w", err)
+		}
+		fn([]byte(data))
+	}
+	return rows.Err()
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
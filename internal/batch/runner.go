@@ -0,0 +1,422 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	maxRequestsPerBatch = 50000
+	maxReportedErrors   = 100
+	minRetryWait        = time.Second
+	maxRetryWait        = 5 * time.Minute
+	storeTimeout        = 30 * time.Second
+)
+
+// Outcome is the result of executing one batch request.
+type Outcome struct {
+	StatusCode int
+	Body       []byte
+	// RetryAfter is the wait requested with a 429 response, such as the remaining credential
+	// cooldown. The runner waits and retries instead of failing the request.
+	RetryAfter time.Duration
+}
+
+// ExecuteFunc executes the body of one batch request against job.Endpoint.
+type ExecuteFunc func(ctx context.Context, job *Job, body []byte) Outcome
+
+// item is one request line of an input file.
+type item struct {
+	index    int
+	customID string
+	body     []byte
+}
+
+type outputResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type outputLine struct {
+	ID       string          `json:"id"`
+	CustomID string          `json:"custom_id"`
+	Response *outputResponse `json:"response"`
+	Error    *JobError       `json:"error"`
+}
+
+// runner executes one job. Results are persisted as they complete so a restarted runner
+// only executes the remaining requests.
+type runner struct {
+	backend Backend
+	execute ExecuteFunc
+	slots   chan struct{}
+	jobID   string
+
+	mu  sync.Mutex
+	job *Job
+}
+
+func storeContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), storeTimeout)
+}
+
+func (r *runner) run(ctx context.Context) {
+	storeCtx, cancel := storeContext()
+	job, err := r.backend.GetJob(storeCtx, r.jobID)
+	cancel()
+	if err != nil {
+		log.WithError(err).Warnf("batch: failed to load job %s", r.jobID)
+		return
+	}
+	r.job = job
+	if job.Terminal() {
+		return
+	}
+	if job.Status == StatusCancelling {
+		r.finalize(StatusCancelled)
+		return
+	}
+	if job.Status == StatusFinalizing {
+		if unixNow() >= job.ExpiresAt {
+			r.finalize(StatusExpired)
+		} else {
+			r.finalize(StatusCompleted)
+		}
+		return
+	}
+
+	storeCtx, cancel = storeContext()
+	content, err := r.backend.FileContent(storeCtx, job.InputFileID)
+	cancel()
+	if err != nil {
+		r.fail([]JobError{{Code: "invalid_file", Message: fmt.Sprintf("Input file %s is not available: %v", job.InputFileID, err)}})
+		return
+	}
+	items, problems := parseInput(content, job.Endpoint)
+	if len(problems) > 0 {
+		r.fail(problems)
+		return
+	}
+	if job.Status == StatusValidating {
+		job.Status = StatusInProgress
+		job.InProgressAt = unixNow()
+		job.RequestCounts = RequestCounts{Total: len(items)}
+		if err = r.saveJob(); err != nil {
+			log.WithError(err).Warnf("batch: failed to start job %s", job.ID)
+			return
+		}
+	}
+
+	expired := r.process(ctx, items)
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errShutdown):
+		return
+	case errors.Is(cause, errCancelled):
+		r.finalize(StatusCancelled)
+	case expired:
+		r.finalize(StatusExpired)
+	default:
+		r.finalize(StatusCompleted)
+	}
+}
+
+// process executes the requests without a recorded result and reports whether the job expired.
+func (r *runner) process(ctx context.Context, items []item) bool {
+	storeCtx, cancel := storeContext()
+	recorded, err := r.backend.Results(storeCtx, r.jobID)
+	cancel()
+	if err != nil {
+		log.WithError(err).Warnf("batch: failed to load results of job %s", r.jobID)
+	}
+	done := make(map[int]struct{}, len(recorded))
+	counts := RequestCounts{Total: len(items)}
+	for _, result := range recorded {
+		done[result.Index] = struct{}{}
+		if result.Failed {
+			counts.Failed++
+		} else {
+			counts.Completed++
+		}
+	}
+	r.mu.Lock()
+	r.job.RequestCounts = counts
+	snapshot := *r.job
+	r.mu.Unlock()
+
+	var (
+		wg      sync.WaitGroup
+		expired bool
+	)
+	deadline := time.Unix(snapshot.ExpiresAt, 0)
+dispatch:
+	for _, it := range items {
+		if _, exists := done[it.index]; exists {
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			expired = true
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case r.slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(it item) {
+			defer wg.Done()
+			defer func() { <-r.slots }()
+			if result, ok := r.executeItem(ctx, &snapshot, it, deadline); ok {
+				r.record(result)
+			}
+		}(it)
+	}
+	wg.Wait()
+	return expired
+}
+
+// executeItem runs one request, waiting out 429 responses until the job deadline.
+// It returns false when the runner stopped before the request completed.
+func (r *runner) executeItem(ctx context.Context, job *Job, it item, deadline time.Time) (Result, bool) {
+	backoff := minRetryWait
+	for {
+		out := r.execute(ctx, job, it.body)
+		if ctx.Err() != nil {
+			return Result{}, false
+		}
+		if out.StatusCode != http.StatusTooManyRequests {
+			return resultFor(it, out), true
+		}
+		wait := out.RetryAfter
+		if wait <= 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		if wait < minRetryWait {
+			wait = minRetryWait
+		}
+		if wait > maxRetryWait {
+			wait = maxRetryWait
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return resultFor(it, out), true
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Result{}, false
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *runner) record(result Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	storeCtx, cancel := storeContext()
+	defer cancel()
+	if err := r.backend.AppendResult(storeCtx, r.jobID, result); err != nil {
+		log.WithError(err).Warnf("batch: failed to record result of job %s", r.jobID)
+		return
+	}
+	if result.Failed {
+		r.job.RequestCounts.Failed++
+	} else {
+		r.job.RequestCounts.Completed++
+	}
+	if err := r.backend.PutJob(storeCtx, r.job); err != nil {
+		log.WithError(err).Warnf("batch: failed to update job %s", r.jobID)
+	}
+}
+
+func (r *runner) saveJob() error {
+	storeCtx, cancel := storeContext()
+	defer cancel()
+	return r.backend.PutJob(storeCtx, r.job)
+}
+
+func (r *runner) fail(problems []JobError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.Status = StatusFailed
+	r.job.FailedAt = unixNow()
+	r.job.Errors = &JobErrors{Object: "list", Data: problems}
+	if err := r.saveJob(); err != nil {
+		log.WithError(err).Warnf("batch: failed to update job %s", r.jobID)
+	}
+}
+
+// finalize writes the output and error files and moves the job to status.
+func (r *runner) finalize(status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.job
+	now := unixNow()
+	switch status {
+	case StatusCompleted:
+		job.Status = StatusFinalizing
+		job.FinalizingAt = now
+		if err := r.saveJob(); err != nil {
+			log.WithError(err).Warnf("batch: failed to update job %s", job.ID)
+		}
+	case StatusCancelled:
+		if job.CancellingAt == 0 {
+			job.CancellingAt = now
+		}
+	}
+
+	storeCtx, cancel := storeContext()
+	defer cancel()
+	results, err := r.backend.Results(storeCtx, job.ID)
+	if err != nil {
+		log.WithError(err).Warnf("batch: failed to load results of job %s", job.ID)
+		return
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	var output, failed bytes.Buffer
+	job.RequestCounts.Completed, job.RequestCounts.Failed = 0, 0
+	for _, result := range results {
+		target := &output
+		if result.Failed {
+			target = &failed
+			job.RequestCounts.Failed++
+		} else {
+			job.RequestCounts.Completed++
+		}
+		target.Write(result.Line)
+		target.WriteByte('\n')
+	}
+	if output.Len() > 0 {
+		if job.OutputFileID, err = r.writeOutputFile(storeCtx, job, "output", output.Bytes()); err != nil {
+			log.WithError(err).Warnf("batch: failed to write output file of job %s", job.ID)
+			return
+		}
+	}
+	if failed.Len() > 0 {
+		if job.ErrorFileID, err = r.writeOutputFile(storeCtx, job, "error", failed.Bytes()); err != nil {
+			log.WithError(err).Warnf("batch: failed to write error file of job %s", job.ID)
+			return
+		}
+	}
+
+	job.Status = status
+	switch status {
+	case StatusCompleted:
+		job.CompletedAt = now
+	case StatusCancelled:
+		job.CancelledAt = now
+	case StatusExpired:
+		job.ExpiredAt = now
+	}
+	if err = r.backend.PutJob(storeCtx, job); err != nil {
+		log.WithError(err).Warnf("batch: failed to update job %s", job.ID)
+		return
+	}
+	if err = r.backend.DeleteResults(storeCtx, job.ID); err != nil {
+		log.WithError(err).Debugf("batch: failed to drop results of job %s", job.ID)
+	}
+	log.Infof("batch %s %s (%d completed, %d failed)", job.ID, status, job.RequestCounts.Completed, job.RequestCounts.Failed)
+}
+
+func (r *runner) writeOutputFile(ctx context.Context, job *Job, kind string, content []byte) (string, error) {
+	file := &File{
+		ID:        newID("file-"),
+		Object:    "file",
+		Bytes:     int64(len(content)),
+		CreatedAt: unixNow(),
+		Filename:  fmt.Sprintf("%s_%s.jsonl", job.ID, kind),
+		Purpose:   PurposeBatchOutput,
+		Owner:     job.Owner,
+	}
+	if err := r.backend.PutFile(ctx, file, content); err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
+
+// parseInput validates a JSONL input file against endpoint and returns its requests.
+func parseInput(content []byte, endpoint string) ([]item, []JobError) {
+	var (
+		items    []item
+		problems []JobError
+	)
+	report := func(line int, code, message string) {
+		if len(problems) < maxReportedErrors {
+			problems = append(problems, JobError{Code: code, Message: message, Line: line})
+		}
+	}
+	seen := make(map[string]struct{})
+	for i, raw := range bytes.Split(content, []byte("\n")) {
+		line := i + 1
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		if !gjson.ValidBytes(raw) || !gjson.ParseBytes(raw).IsObject() {
+			report(line, "invalid_json_line", "This line is not parseable as valid JSON.")
+			continue
+		}
+		parsed := gjson.ParseBytes(raw)
+		customID := parsed.Get("custom_id").String()
+		if strings.TrimSpace(customID) == "" {
+			report(line, "missing_required_parameter", "The custom_id parameter is required.")
+			continue
+		}
+		if _, exists := seen[customID]; exists {
+			report(line, "duplicate_custom_id", fmt.Sprintf("The custom_id %q is used more than once.", customID))
+			continue
+		}
+		seen[customID] = struct{}{}
+		if method := parsed.Get("method").String(); !strings.EqualFold(method, http.MethodPost) {
+			report(line, "invalid_method", fmt.Sprintf("Only POST is supported, got %q.", method))
+			continue
+		}
+		if url := parsed.Get("url").String(); url != endpoint {
+			report(line, "mismatched_endpoint", fmt.Sprintf("The url %q does not match the batch endpoint %s.", url, endpoint))
+			continue
+		}
+		body := parsed.Get("body")
+		if !body.IsObject() || body.Get("model").String() == "" {
+			report(line, "invalid_request", "The body must be an object with a model.")
+			continue
+		}
+		items = append(items, item{index: len(items), customID: customID, body: []byte(body.Raw)})
+	}
+	if len(problems) == 0 && len(items) == 0 {
+		problems = append(problems, JobError{Code: "empty_file", Message: "The input file contains no requests."})
+	}
+	if len(items) > maxRequestsPerBatch {
+		problems = append(problems, JobError{Code: "too_many_requests", Message: fmt.Sprintf("A batch may contain at most %d requests.", maxRequestsPerBatch)})
+	}
+	return items, problems
+}
+
+func resultFor(it item, out Outcome) Result {
+	status := out.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	body := json.RawMessage(bytes.TrimSpace(out.Body))
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(out.Body))
+	}
+	line, _ := json.Marshal(outputLine{
+		ID:       newID("batch_req_"),
+		CustomID: it.customID,
+		Response: &outputResponse{StatusCode: status, RequestID: newID("req_"), Body: body},
+	})
+	return Result{Index: it.index, Failed: status < 200 || status >= 300, Line: line}
+}
@@ -0,0 +1,461 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConcurrency  = 4
+	defaultMaxFileBytes = 200 << 20
+	defaultDirName      = "batches"
+	completionWindow    = "24h"
+	completionDuration  = 24 * time.Hour
+)
+
+// supportedEndpoints lists the endpoints a batch may target.
+var supportedEndpoints = map[string]struct{}{
+	"/v1/chat/completions": {},
+	"/v1/responses":        {},
+	"/v1/embeddings":       {},
+}
+
+// service owns the active backend and the background runners.
+type service struct {
+	mu       sync.Mutex
+	backend  Backend
+	settings config.BatchConfig
+	authDir  string
+	executor ExecuteFunc
+	slots    chan struct{}
+	running  map[string]context.CancelCauseFunc
+	wg       sync.WaitGroup
+
+	sharedDB     *sql.DB
+	sharedSchema string
+}
+
+var defaultService = &service{running: make(map[string]context.CancelCauseFunc)}
+
+// errShutdown stops runners without finalizing their jobs; they resume on the next start.
+var errShutdown = errors.New("batch: shutting down")
+
+// errCancelled stops a runner because the client cancelled its job.
+var errCancelled = errors.New("batch: cancelled")
+
+// Configure opens, replaces, or closes the batch store to match cfg and resumes unfinished jobs.
+func Configure(cfg *config.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	return defaultService.apply(cfg.Batch, cfg.AuthDir)
+}
+
+// Close stops the runners and closes the backend. Unfinished jobs resume after the next Configure.
+func Close() {
+	defaultService.closeCurrent()
+}
+
+// Enabled reports whether the Batch API is active.
+func Enabled() bool {
+	defaultService.mu.Lock()
+	defer defaultService.mu.Unlock()
+	return defaultService.backend != nil
+}
+
+// SetExecutor registers the function that executes batch requests and resumes pending jobs.
+func SetExecutor(fn ExecuteFunc) {
+	defaultService.mu.Lock()
+	defaultService.executor = fn
+	defaultService.mu.Unlock()
+	defaultService.resume()
+}
+
+// MaxFileBytes returns the upload size limit.
+func MaxFileBytes() int64 {
+	defaultService.mu.Lock()
+	defer defaultService.mu.Unlock()
+	if defaultService.settings.MaxFileBytes > 0 {
+		return defaultService.settings.MaxFileBytes
+	}
+	return defaultMaxFileBytes
+}
+
+// CreateFile stores an uploaded file for owner.
+func CreateFile(ctx context.Context, owner, filename, purpose string, content []byte) (*File, error) {
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	if purpose != PurposeBatch {
+		return nil, &ValidationError{Message: fmt.Sprintf("Invalid purpose %q: only %q is supported", purpose, PurposeBatch)}
+	}
+	if int64(len(content)) > MaxFileBytes() {
+		return nil, &ValidationError{Message: fmt.Sprintf("File exceeds the maximum size of %d bytes", MaxFileBytes())}
+	}
+	file := &File{
+		ID:        newID("file-"),
+		Object:    "file",
+		Bytes:     int64(len(content)),
+		CreatedAt: unixNow(),
+		Filename:  filename,
+		Purpose:   purpose,
+		Owner:     owner,
+	}
+	if err = backend.PutFile(ctx, file, content); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetFile returns the file with id when it belongs to owner.
+func GetFile(ctx context.Context, id, owner string) (*File, error) {
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	file, err := backend.GetFile(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if file.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return file, nil
+}
+
+// FileContent returns the content of the file with id when it belongs to owner.
+func FileContent(ctx context.Context, id, owner string) (*File, []byte, error) {
+	file, err := GetFile(ctx, id, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := backend.FileContent(ctx, file.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, content, nil
+}
+
+// ListFiles returns the files of owner, newest first, optionally filtered by purpose.
+func ListFiles(ctx context.Context, owner, purpose string) ([]*File, error) {
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	all, err := backend.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*File, 0, len(all))
+	for _, file := range all {
+		if file.Owner == owner && (purpose == "" || file.Purpose == purpose) {
+			files = append(files, file)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].CreatedAt != files[j].CreatedAt {
+			return files[i].CreatedAt > files[j].CreatedAt
+		}
+		return files[i].ID > files[j].ID
+	})
+	return files, nil
+}
+
+// DeleteFile removes the file with id when it belongs to owner.
+func DeleteFile(ctx context.Context, id, owner string) error {
+	file, err := GetFile(ctx, id, owner)
+	if err != nil {
+		return err
+	}
+	backend, err := defaultService.current()
+	if err != nil {
+		return err
+	}
+	return backend.DeleteFile(ctx, file.ID)
+}
+
+// CreateJob registers a job over an uploaded input file and schedules it for the client with
+// clientKey. The input is validated in the background; a malformed file fails the job.
+func CreateJob(ctx context.Context, owner, clientKey string, access map[string]string, inputFileID, endpoint, window string, metadata map[string]string) (*Job, error) {
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := supportedEndpoints[endpoint]; !ok {
+		return nil, &ValidationError{Message: fmt.Sprintf("Unsupported endpoint %q: use /v1/chat/completions, /v1/responses or /v1/embeddings", endpoint)}
+	}
+	if window != completionWindow {
+		return nil, &ValidationError{Message: fmt.Sprintf("Unsupported completion_window %q: only %q is supported", window, completionWindow)}
+	}
+	input, err := GetFile(ctx, inputFileID, owner)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, &ValidationError{Message: fmt.Sprintf("No such file: %s", inputFileID)}
+		}
+		return nil, err
+	}
+	if input.Purpose != PurposeBatch {
+		return nil, &ValidationError{Message: fmt.Sprintf("File %s must have purpose %q", inputFileID, PurposeBatch)}
+	}
+	now := time.Now()
+	job := &Job{
+		ID:               newID("batch_"),
+		Object:           "batch",
+		Endpoint:         endpoint,
+		InputFileID:      input.ID,
+		CompletionWindow: window,
+		Status:           StatusValidating,
+		CreatedAt:        now.Unix(),
+		ExpiresAt:        now.Add(completionDuration).Unix(),
+		Metadata:         metadata,
+		Owner:            owner,
+		AccessMetadata:   access,
+		ClientKey:        clientKey,
+	}
+	if err = backend.PutJob(ctx, job); err != nil {
+		return nil, err
+	}
+	defaultService.start(job.ID)
+	return job, nil
+}
+
+// GetJob returns the job with id when it belongs to owner.
+func GetJob(ctx context.Context, id, owner string) (*Job, error) {
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	job, err := backend.GetJob(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if job.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// ListJobs returns the jobs of owner, newest first.
+func ListJobs(ctx context.Context, owner string) ([]*Job, error) {
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	all, err := backend.ListJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(all))
+	for _, job := range all {
+		if job.Owner == owner {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt != jobs[j].CreatedAt {
+			return jobs[i].CreatedAt > jobs[j].CreatedAt
+		}
+		return jobs[i].ID > jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// CancelJob requests cancellation of the job with id. Requests in flight finish; the job then
+// moves to cancelled with an output file holding the results gathered so far.
+func CancelJob(ctx context.Context, id, owner string) (*Job, error) {
+	job, err := GetJob(ctx, id, owner)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case job.Status == StatusCancelling:
+		return job, nil
+	case job.Terminal() || job.Status == StatusFinalizing:
+		return nil, &ValidationError{Message: fmt.Sprintf("Cannot cancel a batch with status %q", job.Status)}
+	}
+	backend, err := defaultService.current()
+	if err != nil {
+		return nil, err
+	}
+	job.Status = StatusCancelling
+	job.CancellingAt = unixNow()
+	if err = backend.PutJob(ctx, job); err != nil {
+		return nil, err
+	}
+	if !defaultService.cancelRunner(job.ID) {
+		defaultService.start(job.ID)
+	}
+	return job, nil
+}
+
+func newID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+func (s *service) current() (Backend, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backend == nil {
+		return nil, ErrDisabled
+	}
+	return s.backend, nil
+}
+
+// warnLegacyDir reports batch files left in auth-dir, the former default location, where every
+// token store loads them as auths.
+func warnLegacyDir(authDir string) {
+	resolved, err := util.ResolveAuthDir(authDir)
+	if err != nil || resolved == "" {
+		return
+	}
+	legacy := filepath.Join(resolved, defaultDirName)
+	if info, errStat := os.Stat(legacy); errStat == nil && info.IsDir() {
+		log.Warnf("batch: %s is inside auth-dir, where its files are loaded as auths; move it to %s or delete it", legacy, util.DataPath(defaultDirName))
+	}
+}
+
+func (s *service) apply(settings config.BatchConfig, authDir string) error {
+	settings.Path = strings.TrimSpace(settings.Path)
+
+	s.mu.Lock()
+	if s.backend != nil && s.settings == settings && s.authDir == authDir {
+		s.mu.Unlock()
+		return nil
+	}
+	sharedDB, sharedSchema := s.sharedDB, s.sharedSchema
+	s.mu.Unlock()
+
+	s.closeCurrent()
+	if !settings.Enable {
+		return nil
+	}
+
+	var (
+		backend Backend
+		err     error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if sharedDB != nil {
+		backend, err = NewPostgresBackend(ctx, sharedDB, sharedSchema)
+	} else {
+		dir := settings.Path
+		if dir == "" {
+			dir = util.DataPath(defaultDirName)
+			warnLegacyDir(authDir)
+		}
+		backend, err = NewFileBackend(dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	concurrency := settings.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	s.mu.Lock()
+	s.backend = backend
+	s.settings = settings
+	s.authDir = authDir
+	s.slots = make(chan struct{}, concurrency)
+	s.mu.Unlock()
+
+	log.Infof("batch API enabled (concurrency: %d)", concurrency)
+	s.resume()
+	return nil
+}
+
+func (s *service) closeCurrent() {
+	s.mu.Lock()
+	backend := s.backend
+	for _, cancel := range s.running {
+		cancel(errShutdown)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.backend = nil
+	s.settings = config.BatchConfig{}
+	s.slots = nil
+	s.mu.Unlock()
+	if backend != nil {
+		if err := backend.Close(); err != nil {
+			log.WithError(err).Warn("batch: failed to close store")
+		}
+	}
+}
+
+// resume starts a runner for every unfinished job.
+func (s *service) resume() {
+	backend, err := s.current()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	jobs, err := backend.ListJobs(ctx)
+	cancel()
+	if err != nil {
+		log.WithError(err).Warn("batch: failed to list jobs for resume")
+		return
+	}
+	for _, job := range jobs {
+		if !job.Terminal() {
+			s.start(job.ID)
+		}
+	}
+}
+
+// start launches the runner of a job unless it is already running or no executor is set.
+func (s *service) start(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backend == nil || s.executor == nil {
+		return
+	}
+	if _, exists := s.running[jobID]; exists {
+		return
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s.running[jobID] = cancel
+	r := &runner{backend: s.backend, execute: s.executor, slots: s.slots, jobID: jobID}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		r.run(ctx)
+		s.mu.Lock()
+		delete(s.running, jobID)
+		s.mu.Unlock()
+		cancel(nil)
+	}()
+}
+
+// cancelRunner stops the runner of a job for client cancellation. It reports whether one was running.
+func (s *service) cancelRunner(jobID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, exists := s.running[jobID]
+	if exists {
+		cancel(errCancelled)
+	}
+	return exists
+}
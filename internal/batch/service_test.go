@@ -0,0 +1,139 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func waitForJob(t *testing.T, id, owner string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := GetJob(context.Background(), id, owner)
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if job.Terminal() {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestBatchJobRunsItemsAndWritesOutput(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Batch = config.BatchConfig{Enable: true, Concurrency: 2, Path: t.TempDir()}
+	if err := Configure(cfg); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() {
+		Close()
+		SetExecutor(nil)
+	})
+
+	var cooled atomic.Bool
+	SetExecutor(func(_ context.Context, job *Job, body []byte) Outcome {
+		if job.Endpoint != "/v1/chat/completions" {
+			return Outcome{StatusCode: http.StatusInternalServerError, Body: []byte(`{}`)}
+		}
+		switch gjson.GetBytes(body, "model").String() {
+		case "missing":
+			return Outcome{StatusCode: http.StatusNotFound, Body: []byte(`{"error":{"message":"unknown model"}}`)}
+		case "cooling":
+			if cooled.CompareAndSwap(false, true) {
+				return Outcome{StatusCode: http.StatusTooManyRequests, Body: []byte(`{}`), RetryAfter: time.Millisecond}
+			}
+		}
+		return Outcome{StatusCode: http.StatusOK, Body: []byte(`{"id":"chatcmpl-1","model":"` + gjson.GetBytes(body, "model").String() + `"}`)}
+	})
+
+	ctx := context.Background()
+	input := strings.Join([]string{
+		`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m1","messages":[]}}`,
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"missing","messages":[]}}`,
+		`{"custom_id":"c","method":"POST","url":"/v1/chat/completions","body":{"model":"cooling","messages":[]}}`,
+	}, "\n")
+	file, err := CreateFile(ctx, "owner", "input.jsonl", PurposeBatch, []byte(input))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	var validation *ValidationError
+	if _, err = CreateJob(ctx, "other", "", nil, file.ID, "/v1/chat/completions", "24h", nil); !errors.As(err, &validation) {
+		t.Fatalf("another owner's file should be rejected, got %v", err)
+	}
+	if _, err = CreateJob(ctx, "owner", "", nil, file.ID, "/v1/completions", "24h", nil); !errors.As(err, &validation) {
+		t.Fatalf("unsupported endpoint should be rejected, got %v", err)
+	}
+
+	job, err := CreateJob(ctx, "owner", "", nil, file.ID, "/v1/chat/completions", "24h", map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	job = waitForJob(t, job.ID, "owner")
+	if job.Status != StatusCompleted || job.RequestCounts != (RequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Fatalf("job = %+v", job)
+	}
+	if !cooled.Load() {
+		t.Fatalf("the 429 item should have been retried")
+	}
+
+	_, output, err := FileContent(ctx, job.OutputFileID, "owner")
+	if err != nil {
+		t.Fatalf("output FileContent: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 || gjson.Get(lines[0], "custom_id").String() != "a" || gjson.Get(lines[1], "custom_id").String() != "c" ||
+		gjson.Get(lines[1], "response.status_code").Int() != 200 || gjson.Get(lines[0], "response.body.model").String() != "m1" {
+		t.Fatalf("output = %s", output)
+	}
+	_, failed, err := FileContent(ctx, job.ErrorFileID, "owner")
+	if err != nil {
+		t.Fatalf("error FileContent: %v", err)
+	}
+	if gjson.GetBytes(failed, "custom_id").String() != "b" || gjson.GetBytes(failed, "response.status_code").Int() != 404 {
+		t.Fatalf("errors = %s", failed)
+	}
+}
+
+func TestBatchJobFailsInvalidInput(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Batch = config.BatchConfig{Enable: true, Path: t.TempDir()}
+	if err := Configure(cfg); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() {
+		Close()
+		SetExecutor(nil)
+	})
+	SetExecutor(func(context.Context, *Job, []byte) Outcome {
+		return Outcome{StatusCode: http.StatusOK, Body: []byte(`{}`)}
+	})
+
+	ctx := context.Background()
+	input := `{"custom_id":"a","method":"POST","url":"/v1/responses","body":{"model":"m"}}` + "\n" + `not json`
+	file, err := CreateFile(ctx, "owner", "input.jsonl", PurposeBatch, []byte(input))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	job, err := CreateJob(ctx, "owner", "", nil, file.ID, "/v1/chat/completions", "24h", nil)
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	job = waitForJob(t, job.ID, "owner")
+	if job.Status != StatusFailed || job.Errors == nil || len(job.Errors.Data) != 2 {
+		t.Fatalf("job = %+v", job)
+	}
+	if job.Errors.Data[0].Code != "mismatched_endpoint" || job.Errors.Data[1].Line != 2 {
+		t.Fatalf("errors = %+v", job.Errors.Data)
+	}
+}
@@ -0,0 +1,201 @@
+// Package batch emulates the OpenAI Batch API. Clients upload JSONL input through /v1/files,
+// create jobs through /v1/batches, and collect the output file once the background runner has
+// executed every request through the regular credential rotation.
+package batch
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a file or job is unknown or owned by another client.
+var ErrNotFound = errors.New("batch: not found")
+
+// ErrDisabled is returned while the Batch API is not enabled.
+var ErrDisabled = errors.New("batch: the Batch API is disabled")
+
+// ValidationError reports a client error such as an unsupported endpoint or a bad input file.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// File purposes.
+const (
+	PurposeBatch       = "batch"
+	PurposeBatchOutput = "batch_output"
+)
+
+// Job statuses, as defined by the OpenAI Batch API.
+const (
+	StatusValidating = "validating"
+	StatusFailed     = "failed"
+	StatusInProgress = "in_progress"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+// File is an uploaded input file or a generated output file.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	// Owner identifies the client API key that owns the file. It is not returned to clients.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Public returns a copy of f without internal fields.
+func (f *File) Public() *File {
+	out := *f
+	out.Owner = ""
+	return &out
+}
+
+// RequestCounts tracks the progress of a job.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// JobError describes why a job failed validation.
+type JobError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// JobErrors is the errors list of a failed job.
+type JobErrors struct {
+	Object string     `json:"object"`
+	Data   []JobError `json:"data"`
+}
+
+// Job is a batch job in the OpenAI batch object shape.
+type Job struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *JobErrors        `json:"errors"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     int64             `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64             `json:"expires_at,omitempty"`
+	FinalizingAt     int64             `json:"finalizing_at,omitempty"`
+	CompletedAt      int64             `json:"completed_at,omitempty"`
+	FailedAt         int64             `json:"failed_at,omitempty"`
+	ExpiredAt        int64             `json:"expired_at,omitempty"`
+	CancellingAt     int64             `json:"cancelling_at,omitempty"`
+	CancelledAt      int64             `json:"cancelled_at,omitempty"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+
+	// Owner identifies the client API key that created the job. It is not returned to clients.
+	Owner string `json:"owner,omitempty"`
+	// AccessMetadata is the access metadata of the creating request, so the client's model
+	// policy still applies when the job runs in the background. It is not returned to clients.
+	AccessMetadata map[string]string `json:"access_metadata,omitempty"`
+	// ClientKey is the client API key that created the job, so its api-key-limits and usage
+	// attribution apply to every line. It is not returned to clients.
+	ClientKey string `json:"client_key,omitempty"`
+}
+
+// Public returns a copy of j without internal fields.
+func (j *Job) Public() *Job {
+	out := *j
+	out.Owner = ""
+	out.AccessMetadata = nil
+	out.ClientKey = ""
+	return &out
+}
+
+// Terminal reports whether the job will not change anymore.
+func (j *Job) Terminal() bool {
+	switch j.Status {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result is one executed request of a running job, kept until the job is finalized.
+type Result struct {
+	// Index is the line of the request in the input file, starting at 0.
+	Index int `json:"index"`
+	// Failed routes the line to the error file instead of the output file.
+	Failed bool `json:"failed"`
+	// Line is the output JSONL line.
+	Line json.RawMessage `json:"line"`
+}
+
+// Backend persists files, jobs and in-flight results. Implementations must be safe for
+// concurrent use.
+type Backend interface {
+	// PutFile stores a file and its content.
+	PutFile(ctx context.Context, file *File, content []byte) error
+	// GetFile returns the file metadata or ErrNotFound.
+	GetFile(ctx context.Context, id string) (*File, error)
+	// FileContent returns the file content or ErrNotFound.
+	FileContent(ctx context.Context, id string) ([]byte, error)
+	// ListFiles returns every file.
+	ListFiles(ctx context.Context) ([]*File, error)
+	// DeleteFile removes a file or returns ErrNotFound.
+	DeleteFile(ctx context.Context, id string) error
+
+	// PutJob inserts or replaces a job.
+	PutJob(ctx context.Context, job *Job) error
+	// GetJob returns the job or ErrNotFound.
+	GetJob(ctx context.Context, id string) (*Job, error)
+	// ListJobs returns every job.
+	ListJobs(ctx context.Context) ([]*Job, error)
+
+	// AppendResult records an executed request of a job.
+	AppendResult(ctx context.Context, jobID string, result Result) error
+	// Results returns the recorded results of a job in any order.
+	Results(ctx context.Context, jobID string) ([]Result, error)
+	// DeleteResults drops the recorded results of a job.
+	DeleteResults(ctx context.Context, jobID string) error
+
+	// Close releases the backend.
+	Close() error
+}
+
+// OwnerKey derives the stored owner identifier from a client API key.
+func OwnerKey(apiKey string) string {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// UseSharedPostgres registers the Postgres token store connection. When set, files and jobs
+// are kept in that database instead of under the auth directory.
+func UseSharedPostgres(db *sql.DB, schema string) {
+	defaultService.mu.Lock()
+	defaultService.sharedDB = db
+	defaultService.sharedSchema = strings.TrimSpace(schema)
+	defaultService.mu.Unlock()
+}
+
+func unixNow() int64 { return time.Now().Unix() }
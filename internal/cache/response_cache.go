@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ResponseEntry is a cached upstream response. Non-streaming responses carry Body;
+// streaming responses carry the ordered Chunks exactly as they were forwarded.
+type ResponseEntry struct {
+	Body    []byte
+	Chunks  [][]byte
+	Headers http.Header
+	// Model is the model that actually served the response.
+	Model     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Size returns the approximate memory footprint of the cached payload in bytes.
+func (e *ResponseEntry) Size() int64 {
+	if e == nil {
+		return 0
+	}
+	size := int64(len(e.Body))
+	for _, chunk := range e.Chunks {
+		size += int64(len(chunk))
+	}
+	for key, values := range e.Headers {
+		size += int64(len(key))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+// ResponseCacheStats is a point-in-time view of a response cache.
+type ResponseCacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// ResponseCache is an LRU cache of upstream responses bounded by entry count and total size.
+// Entries expire after the TTL supplied when they were stored.
+type ResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	order      *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type responseCacheItem struct {
+	key   string
+	entry *ResponseEntry
+	size  int64
+}
+
+var defaultResponseCache = NewResponseCache(0, 0)
+
+// DefaultResponseCache returns the process-wide response cache shared by the API handlers.
+func DefaultResponseCache() *ResponseCache { return defaultResponseCache }
+
+// NewResponseCache constructs a cache holding at most maxEntries entries and maxBytes bytes.
+// Non-positive limits leave the corresponding dimension unbounded.
+func NewResponseCache(maxEntries int, maxBytes int64) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// SetLimits updates the capacity limits, evicting least recently used entries as needed.
+func (c *ResponseCache) SetLimits(maxEntries int, maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxEntries = maxEntries
+	c.maxBytes = maxBytes
+	c.evictLocked()
+}
+
+// Get returns a live entry for key and marks it as recently used.
+func (c *ResponseCache) Get(key string) (*ResponseEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	item := elem.Value.(*responseCacheItem)
+	if !item.entry.ExpiresAt.IsZero() && !c.now().Before(item.entry.ExpiresAt) {
+		c.removeLocked(elem)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits++
+	return item.entry, true
+}
+
+// Put stores entry under key for ttl. Entries larger than the total size limit are ignored.
+func (c *ResponseCache) Put(key string, entry *ResponseEntry, ttl time.Duration) {
+	if entry == nil || ttl <= 0 {
+		return
+	}
+	size := entry.Size()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+	now := c.now()
+	entry.CreatedAt = now
+	entry.ExpiresAt = now.Add(ttl)
+	if elem, ok := c.items[key]; ok {
+		c.removeLocked(elem)
+	}
+	c.items[key] = c.order.PushFront(&responseCacheItem{key: key, entry: entry, size: size})
+	c.bytes += size
+	c.evictLocked()
+}
+
+// Purge removes every entry.
+func (c *ResponseCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// Stats returns the current cache counters.
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ResponseCacheStats{
+		Entries:   len(c.items),
+		Bytes:     c.bytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *ResponseCache) evictLocked() {
+	for c.order.Len() > 0 {
+		overCount := c.maxEntries > 0 && c.order.Len() > c.maxEntries
+		overSize := c.maxBytes > 0 && c.bytes > c.maxBytes
+		if !overCount && !overSize {
+			return
+		}
+		c.removeLocked(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *ResponseCache) removeLocked(elem *list.Element) {
+	item := elem.Value.(*responseCacheItem)
+	c.order.Remove(elem)
+	delete(c.items, item.key)
+	c.bytes -= item.size
+}
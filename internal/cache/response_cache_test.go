@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCache_EvictsAndExpires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewResponseCache(2, 10)
+	c.now = func() time.Time { return now }
+
+	c.Put("a", &ResponseEntry{Body: []byte("aaaa")}, time.Minute)
+	c.Put("b", &ResponseEntry{Body: []byte("bbbb")}, time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	// "b" is least recently used and is evicted once the size limit is exceeded.
+	c.Put("c", &ResponseEntry{Body: []byte("cccc")}, time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	c.Put("huge", &ResponseEntry{Body: make([]byte, 11)}, time.Minute)
+	if _, ok := c.Get("huge"); ok {
+		t.Fatal("entries larger than the cache must not be stored")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a to expire")
+	}
+	stats := c.Stats()
+	if stats.Entries != 1 || stats.Bytes != 4 || stats.Evictions != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
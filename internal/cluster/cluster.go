@@ -0,0 +1,208 @@
+// Package cluster connects instances serving the same credentials through a shared database:
+// the auth manager publishes cooldowns, quota state and disabled flags and applies those of the
+// other members, refreshes are guarded by leases, and usage recorded by the other members is
+// merged from the shared usage store.
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSyncInterval = 2 * time.Second
+	defaultLeaseTTL     = time.Minute
+	defaultClusterFile  = "cluster.db"
+	// usagePullInterval is how often usage recorded by other members is merged.
+	usagePullInterval = 30 * time.Second
+	// usagePullWindow is how far back the first usage pull looks; later pulls continue from the
+	// cursor of the previous one.
+	usagePullWindow = 15 * time.Minute
+)
+
+// membership owns the active backend and the manager it feeds.
+type membership struct {
+	mu       sync.Mutex
+	backend  *SQLState
+	manager  *coreauth.Manager
+	settings config.ClusterConfig
+	authDir  string
+	stop     chan struct{}
+
+	sharedDB     *sql.DB
+	sharedSchema string
+}
+
+var defaultMembership = &membership{}
+
+// UseSharedPostgres registers an existing PostgreSQL connection that the "postgres" driver
+// reuses when no DSN is configured.
+func UseSharedPostgres(db *sql.DB, schema string) {
+	defaultMembership.mu.Lock()
+	defaultMembership.sharedDB = db
+	defaultMembership.sharedSchema = strings.TrimSpace(schema)
+	defaultMembership.mu.Unlock()
+}
+
+// Configure joins, rejoins, or leaves the cluster to match cfg, sharing the state of manager.
+func Configure(cfg *config.Config, manager *coreauth.Manager) error {
+	if cfg == nil || manager == nil {
+		return nil
+	}
+	return defaultMembership.apply(cfg.Cluster, cfg.AuthDir, manager)
+}
+
+// Close leaves the cluster, if joined.
+func Close() {
+	defaultMembership.closeCurrent()
+}
+
+func (m *membership) apply(settings config.ClusterConfig, authDir string, manager *coreauth.Manager) error {
+	settings.Driver = strings.ToLower(strings.TrimSpace(settings.Driver))
+	settings.Path = strings.TrimSpace(settings.Path)
+	settings.DSN = strings.TrimSpace(settings.DSN)
+	settings.InstanceID = strings.TrimSpace(settings.InstanceID)
+
+	m.mu.Lock()
+	if m.backend != nil && m.settings == settings && m.authDir == authDir && m.manager == manager {
+		m.mu.Unlock()
+		return nil
+	}
+	sharedDB, sharedSchema := m.sharedDB, m.sharedSchema
+	m.mu.Unlock()
+
+	m.closeCurrent()
+	if !settings.Enable {
+		return nil
+	}
+
+	instance := settings.InstanceID
+	if instance == "" {
+		instance = defaultInstanceID()
+	}
+	var (
+		backend *SQLState
+		err     error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	switch settings.Driver {
+	case "", "postgres", "postgresql", "pgx":
+		if settings.DSN == "" {
+			if sharedDB == nil {
+				return fmt.Errorf("cluster: postgres driver requires a dsn or the postgres token store")
+			}
+			backend, err = NewPostgresState(ctx, sharedDB, sharedSchema, instance, false)
+		} else {
+			backend, err = OpenPostgresState(ctx, settings.DSN, instance)
+		}
+	case "sqlite", "sqlite3":
+		path := settings.Path
+		if path == "" {
+			resolved, errResolve := util.ResolveAuthDir(authDir)
+			if errResolve != nil {
+				return fmt.Errorf("cluster: %w", errResolve)
+			}
+			path = filepath.Join(resolved, defaultClusterFile)
+		}
+		backend, err = OpenSQLiteState(ctx, path, instance)
+	default:
+		return fmt.Errorf("cluster: unsupported driver %q", settings.Driver)
+	}
+	if err != nil {
+		return err
+	}
+
+	interval := time.Duration(settings.SyncIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+	lease := time.Duration(settings.LeaseSeconds) * time.Second
+	if lease <= 0 {
+		lease = defaultLeaseTTL
+	}
+	stop := make(chan struct{})
+	m.mu.Lock()
+	m.backend = backend
+	m.manager = manager
+	m.settings = settings
+	m.authDir = authDir
+	m.stop = stop
+	m.mu.Unlock()
+
+	manager.SetClusterState(backend, interval, lease)
+	go runUsagePull(stop)
+	log.Infof("cluster enabled (driver: %s, instance: %s)", backend.driver, instance)
+	return nil
+}
+
+func (m *membership) closeCurrent() {
+	m.mu.Lock()
+	backend, manager, stop := m.backend, m.manager, m.stop
+	m.backend = nil
+	m.manager = nil
+	m.stop = nil
+	m.settings = config.ClusterConfig{}
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	if manager != nil {
+		manager.SetClusterState(nil, 0, 0)
+	}
+	if backend != nil {
+		if err := backend.Close(); err != nil {
+			log.WithError(err).Warn("cluster: failed to close")
+		}
+	}
+}
+
+// runUsagePull merges the usage other members appended to the shared usage store, reading only
+// the records stored since the previous pull. Without a usage store there is nothing to pull,
+// and a store local to this instance only yields records that are already present.
+func runUsagePull(stop <-chan struct{}) {
+	ticker := time.NewTicker(usagePullInterval)
+	defer ticker.Stop()
+	cursor := usage.StoreCursor{StoredAt: time.Now().Add(-usagePullWindow).UnixMilli()}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), usagePullInterval)
+			result, next, err := usage.PullStore(ctx, cursor)
+			cancel()
+			cursor = next
+			if err != nil {
+				if !errors.Is(err, usage.ErrStoreDisabled) {
+					log.WithError(err).Warn("cluster: failed to pull usage of other instances")
+				}
+				continue
+			}
+			if result.Added > 0 {
+				log.Debugf("cluster: merged %d usage records of other instances", result.Added)
+			}
+		}
+	}
+}
+
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || strings.TrimSpace(host) == "" {
+		host = "cliproxy"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
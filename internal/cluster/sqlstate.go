@@ -0,0 +1,298 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	authStateTable = "cluster_auth_state"
+	leaseTable     = "cluster_leases"
+	// changesOverlap re-reads states published shortly before the cursor, which may have
+	// committed after a concurrent read advanced it. Applying a state twice is harmless.
+	changesOverlap = 5 * time.Second
+)
+
+// SQLState implements coreauth.ClusterState on top of database/sql. Publish times and lease
+// expiries come from the database clock, so members do not need synchronised clocks to see
+// each other's changes or to agree on lease ownership.
+type SQLState struct {
+	db         *sql.DB
+	driver     string
+	instance   string
+	stateTable string
+	leaseTable string
+	ownsDB     bool
+}
+
+var _ coreauth.ClusterState = (*SQLState)(nil)
+
+// OpenSQLiteState opens (creating when missing) a SQLite cluster database at path, for members
+// running on one host.
+func OpenSQLiteState(ctx context.Context, path, instance string) (*SQLState, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("cluster: sqlite path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("cluster: create sqlite directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("cluster: open sqlite database: %w", err)
+	}
+	// SQLite serialises writers; a single connection avoids SQLITE_BUSY churn.
+	db.SetMaxOpenConns(1)
+	state := &SQLState{
+		db:         db,
+		driver:     "sqlite",
+		instance:   instance,
+		stateTable: authStateTable,
+		leaseTable: leaseTable,
+		ownsDB:     true,
+	}
+	if err = state.ensureSchema(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return state, nil
+}
+
+// OpenPostgresState connects to PostgreSQL using dsn and prepares the cluster tables.
+func OpenPostgresState(ctx context.Context, dsn, instance string) (*SQLState, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: open postgres connection: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("cluster: ping postgres: %w", err)
+	}
+	state, err := NewPostgresState(ctx, db, "", instance, true)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return state, nil
+}
+
+// NewPostgresState prepares the cluster tables on an existing PostgreSQL connection.
+// When ownsDB is false, Close leaves the connection open for its other users.
+func NewPostgresState(ctx context.Context, db *sql.DB, schema, instance string, ownsDB bool) (*SQLState, error) {
+	if db == nil {
+		return nil, fmt.Errorf("cluster: postgres connection is nil")
+	}
+	prefix := ""
+	if schema = strings.TrimSpace(schema); schema != "" {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteSQLIdentifier(schema))); err != nil {
+			return nil, fmt.Errorf("cluster: create schema: %w", err)
+		}
+		prefix = quoteSQLIdentifier(schema) + "."
+	}
+	state := &SQLState{
+		db:         db,
+		driver:     "postgres",
+		instance:   instance,
+		stateTable: prefix + quoteSQLIdentifier(authStateTable),
+		leaseTable: prefix + quoteSQLIdentifier(leaseTable),
+		ownsDB:     ownsDB,
+	}
+	if err := state.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *SQLState) ensureSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			auth_id TEXT NOT NULL,
+			instance TEXT NOT NULL,
+			state TEXT NOT NULL,
+			published_at BIGINT NOT NULL,
+			PRIMARY KEY (auth_id, instance)
+		)
+	`, s.stateTable)); err != nil {
+		return fmt.Errorf("cluster: create auth state table: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s ON %s (published_at)",
+		quoteSQLIdentifier(authStateTable+"_published_at_idx"), s.stateTable,
+	)); err != nil {
+		return fmt.Errorf("cluster: create auth state index: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			expires_at BIGINT NOT NULL
+		)
+	`, s.leaseTable)); err != nil {
+		return fmt.Errorf("cluster: create lease table: %w", err)
+	}
+	return nil
+}
+
+// Instance implements coreauth.ClusterState.
+func (s *SQLState) Instance() string { return s.instance }
+
+// Now implements coreauth.ClusterState with the database clock.
+func (s *SQLState) Now(ctx context.Context) (time.Time, error) {
+	var millis int64
+	if err := s.db.QueryRowContext(ctx, "SELECT "+s.nowMillis()).Scan(&millis); err != nil {
+		return time.Time{}, fmt.Errorf("cluster: read database clock: %w", err)
+	}
+	return time.UnixMilli(millis), nil
+}
+
+// Publish implements coreauth.ClusterState.
+func (s *SQLState) Publish(ctx context.Context, states []coreauth.SharedAuthState) error {
+	if len(states) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cluster: begin publish: %w", err)
+	}
+	query := s.rebind(fmt.Sprintf(`
+		INSERT INTO %s (auth_id, instance, state, published_at) VALUES (?, ?, ?, %s)
+		ON CONFLICT (auth_id, instance) DO UPDATE SET state = excluded.state, published_at = excluded.published_at
+	`, s.stateTable, s.nowMillis()))
+	for _, state := range states {
+		payload, errMarshal := json.Marshal(state)
+		if errMarshal != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("cluster: encode state of %s: %w", state.AuthID, errMarshal)
+		}
+		if _, err = tx.ExecContext(ctx, query, state.AuthID, s.instance, string(payload)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("cluster: publish state of %s: %w", state.AuthID, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cluster: commit publish: %w", err)
+	}
+	return nil
+}
+
+// Changes implements coreauth.ClusterState. The cursor is the database time, in milliseconds,
+// of the latest publish seen.
+func (s *SQLState) Changes(ctx context.Context, cursor int64) ([]coreauth.SharedAuthState, int64, error) {
+	since := cursor - changesOverlap.Milliseconds()
+	rows, err := s.db.QueryContext(ctx, s.rebind(fmt.Sprintf(
+		"SELECT instance, state, published_at FROM %s WHERE published_at > ? ORDER BY published_at", s.stateTable,
+	)), since)
+	if err != nil {
+		return nil, cursor, fmt.Errorf("cluster: query auth states: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	next := cursor
+	var states []coreauth.SharedAuthState
+	for rows.Next() {
+		var (
+			instance    string
+			payload     string
+			publishedAt int64
+		)
+		if err = rows.Scan(&instance, &payload, &publishedAt); err != nil {
+			return nil, cursor, fmt.Errorf("cluster: scan auth state: %w", err)
+		}
+		var state coreauth.SharedAuthState
+		if err = json.Unmarshal([]byte(payload), &state); err != nil {
+			return nil, cursor, fmt.Errorf("cluster: decode auth state: %w", err)
+		}
+		state.Instance = instance
+		states = append(states, state)
+		if publishedAt > next {
+			next = publishedAt
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, cursor, fmt.Errorf("cluster: read auth states: %w", err)
+	}
+	return states, next, nil
+}
+
+// AcquireLease implements coreauth.ClusterState. The lease is taken when it is free, expired,
+// or already held by this instance, in which case it is renewed.
+func (s *SQLState) AcquireLease(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	target, current := s.leaseTable, s.leaseTable
+	if s.driver == "postgres" {
+		target, current = s.leaseTable+" AS lease", "lease"
+	}
+	now := s.nowMillis()
+	result, err := s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(`
+		INSERT INTO %s (name, holder, expires_at) VALUES (?, ?, %s + ?)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE %s.holder = excluded.holder OR %s.expires_at <= %s
+	`, target, now, current, current, now)), name, s.instance, ttl.Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("cluster: acquire lease %s: %w", name, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("cluster: acquire lease %s: %w", name, err)
+	}
+	return affected > 0, nil
+}
+
+// ReleaseLease implements coreauth.ClusterState.
+func (s *SQLState) ReleaseLease(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(
+		"DELETE FROM %s WHERE name = ? AND holder = ?", s.leaseTable,
+	)), name, s.instance); err != nil {
+		return fmt.Errorf("cluster: release lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close releases the connection when the state owns it.
+func (s *SQLState) Close() error {
+	if s == nil || s.db == nil || !s.ownsDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// nowMillis returns the SQL expression of the database clock in Unix milliseconds.
+func (s *SQLState) nowMillis() string {
+	if s.driver == "postgres" {
+		return "CAST(EXTRACT(EPOCH FROM clock_timestamp()) * 1000 AS BIGINT)"
+	}
+	return "CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)"
+}
+
+// rebind converts '?' placeholders into PostgreSQL positional parameters.
+func (s *SQLState) rebind(query string) string {
+	if s.driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func quoteSQLIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestSQLiteStateSharesStatesAndLeases(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cluster.db")
+	a, err := OpenSQLiteState(ctx, path, "a")
+	if err != nil {
+		t.Fatalf("OpenSQLiteState a: %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	b, err := OpenSQLiteState(ctx, path, "b")
+	if err != nil {
+		t.Fatalf("OpenSQLiteState b: %v", err)
+	}
+	defer func() { _ = b.Close() }()
+
+	if now, errNow := a.Now(ctx); errNow != nil || now.Sub(time.Now()).Abs() > 5*time.Second {
+		t.Fatalf("Now = %v, %v; want the database clock", now, errNow)
+	}
+
+	retry := time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond)
+	state := coreauth.SharedAuthState{
+		AuthID:      "auth-1",
+		ModelStates: map[string]*coreauth.ModelState{"gpt-5": {Unavailable: true, NextRetryAfter: retry}},
+		UpdatedAt:   time.Now(),
+	}
+	if err = a.Publish(ctx, []coreauth.SharedAuthState{state}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	changes, cursor, err := b.Changes(ctx, 0)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Instance != "a" || !changes[0].ModelStates["gpt-5"].NextRetryAfter.Equal(retry) {
+		t.Fatalf("changes = %+v, want the state published by a", changes)
+	}
+	if cursor <= 0 {
+		t.Fatalf("cursor = %d, want the publish time", cursor)
+	}
+
+	if held, errLease := a.AcquireLease(ctx, "refresh:auth-1", time.Minute); errLease != nil || !held {
+		t.Fatalf("a AcquireLease = %t, %v; want held", held, errLease)
+	}
+	if held, errLease := a.AcquireLease(ctx, "refresh:auth-1", time.Minute); errLease != nil || !held {
+		t.Fatalf("a renew = %t, %v; want held", held, errLease)
+	}
+	if held, errLease := b.AcquireLease(ctx, "refresh:auth-1", time.Minute); errLease != nil || held {
+		t.Fatalf("b AcquireLease while a holds it = %t, %v", held, errLease)
+	}
+	if err = b.ReleaseLease(ctx, "refresh:auth-1"); err != nil {
+		t.Fatalf("b ReleaseLease: %v", err)
+	}
+	if held, _ := b.AcquireLease(ctx, "refresh:auth-1", time.Minute); held {
+		t.Fatal("b released a lease it did not hold")
+	}
+	if err = a.ReleaseLease(ctx, "refresh:auth-1"); err != nil {
+		t.Fatalf("a ReleaseLease: %v", err)
+	}
+	if held, errLease := b.AcquireLease(ctx, "refresh:auth-1", -time.Second); errLease != nil || !held {
+		t.Fatalf("b AcquireLease after release = %t, %v", held, errLease)
+	}
+	if held, errLease := a.AcquireLease(ctx, "refresh:auth-1", time.Minute); errLease != nil || !held {
+		t.Fatalf("a AcquireLease of an expired lease = %t, %v", held, errLease)
+	}
+}
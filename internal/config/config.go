@@ -41,12 +41,18 @@ type Config struct {
 	// AuthDir is the directory where authentication token files are stored.
 	AuthDir string `yaml:"auth-dir" json:"-"`
 
+	// AuthEncryption encrypts auth files at rest in every token store.
+	AuthEncryption AuthEncryptionConfig `yaml:"auth-encryption" json:"-"`
+
 	// Debug enables or disables debug-level logging and other debug features.
 	Debug bool `yaml:"debug" json:"debug"`
 
 	// Pprof config controls the optional pprof HTTP debug server.
 	Pprof PprofConfig `yaml:"pprof" json:"pprof"`
 
+	// Metrics config controls the Prometheus metrics endpoint.
+	Metrics MetricsConfig `yaml:"metrics" json:"metrics"`
+
 	// CommercialMode disables high-overhead HTTP middleware features to minimize per-request memory usage.
 	CommercialMode bool `yaml:"commercial-mode" json:"commercial-mode"`
 
@@ -61,9 +67,24 @@ type Config struct {
 	// When exceeded, the oldest error log files are deleted. Default is 10. Set to 0 to disable cleanup.
 	ErrorLogsMaxFiles int `yaml:"error-logs-max-files" json:"error-logs-max-files"`
 
+	// AuditLog configures the structured JSONL request audit log.
+	AuditLog AuditLogConfig `yaml:"audit-log" json:"audit-log"`
+
+	// Tracing configures OpenTelemetry trace export.
+	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStore configures persistent storage of usage records.
+	UsageStore UsageStoreConfig `yaml:"usage-store" json:"usage-store"`
+
+	// ResponseStore keeps Responses API turns so HTTP clients can use previous_response_id on any backend.
+	ResponseStore ResponseStoreConfig `yaml:"response-store" json:"response-store"`
+
+	// Batch configures the emulated OpenAI Batch API (/v1/files and /v1/batches).
+	Batch BatchConfig `yaml:"batch" json:"batch"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -72,6 +93,16 @@ type Config struct {
 	// MaxRetryInterval defines the maximum wait time in seconds before retrying a cooled-down credential.
 	MaxRetryInterval int `yaml:"max-retry-interval" json:"max-retry-interval"`
 
+	// RequestQueue parks requests while every credential for their model is cooling down
+	// instead of failing them with 429.
+	RequestQueue RequestQueueConfig `yaml:"request-queue,omitempty" json:"request-queue,omitempty"`
+
+	// CircuitBreaker fails fast on openai-compatibility base URLs that keep failing as a whole.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit-breaker,omitempty" json:"circuit-breaker,omitempty"`
+
+	// Cluster shares cooldown, quota and disabled state between instances serving the same credentials.
+	Cluster ClusterConfig `yaml:"cluster,omitempty" json:"cluster,omitempty"`
+
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
 
@@ -115,6 +146,10 @@ type Config struct {
 	// Supported channels: gemini-cli, vertex, aistudio, antigravity, claude, codex, qwen, iflow, kiro, github-copilot.
 	OAuthExcludedModels map[string][]string `yaml:"oauth-excluded-models,omitempty" json:"oauth-excluded-models,omitempty"`
 
+	// ProviderLimits sets default per-credential limits keyed by provider (e.g. kiro, github-copilot).
+	// Limits configured on an API key entry or an auth file take precedence.
+	ProviderLimits map[string]CredentialLimits `yaml:"provider-limits,omitempty" json:"provider-limits,omitempty"`
+
 	// OAuthModelAlias defines global model name aliases for OAuth/file-backed auth channels.
 	// These aliases affect both model listing and model routing for supported channels:
 	// gemini-cli, vertex, aistudio, antigravity, claude, codex, qwen, iflow, kiro, github-copilot.
@@ -136,19 +171,32 @@ type Config struct {
 	Webhooks []WebhookEntry `yaml:"webhooks,omitempty" json:"webhooks,omitempty"`
 
 	legacyMigrationPending bool `yaml:"-" json:"-"`
+
+	// secretRefs maps the location of each value resolved at load time to the reference it was
+	// written as.
+	secretRefs map[string]secretRef `yaml:"-" json:"-"`
 }
 
 // WebhookEntry configures a single webhook destination for event alerts.
 type WebhookEntry struct {
 	// URL is the webhook endpoint (e.g. WeChat Work group bot URL).
 	URL string `yaml:"url" json:"url"`
-	// Type is the webhook provider type. Default: "wecom".
+	// Type is the webhook provider type: "wecom" or "http". Default: "wecom".
 	Type string `yaml:"type,omitempty" json:"type,omitempty"`
 	// Events lists the event types to send. Default: ["account_banned"].
 	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
 	// ThrottleMinutes is the minimum interval in minutes between alerts
 	// for the same auth+event combination. Default: 10.
 	ThrottleMinutes int `yaml:"throttle-minutes,omitempty" json:"throttle-minutes,omitempty"`
+	// Template is a Go text/template rendering the request body for "http" webhooks.
+	// Empty sends the event as a JSON object.
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+	// Headers are added to every request sent to this webhook.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Secret, when set, signs each request body with HMAC-SHA256.
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	// MaxRetries is the number of retries after a failed delivery. Default: 3. Negative disables retries.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
 }
 
 // ClaudeHeaderDefaults configures default header values injected into Claude API requests
@@ -178,6 +226,151 @@ type PprofConfig struct {
 	Addr string `yaml:"addr" json:"addr"`
 }
 
+// MetricsConfig holds Prometheus metrics endpoint settings.
+type MetricsConfig struct {
+	// Enable toggles metric collection and the /metrics endpoint on the main server.
+	Enable bool `yaml:"enable" json:"enable"`
+}
+
+// TracingConfig holds OpenTelemetry trace export settings.
+type TracingConfig struct {
+	// Enable exports spans for inbound requests, translation, auth attempts and upstream calls.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Endpoint is the OTLP/HTTP collector URL (e.g., "http://localhost:4318").
+	// The "/v1/traces" path is appended when the URL has no path. Empty uses the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment default.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// Headers are sent with every export request (e.g., collector authentication).
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ServiceName is reported as the service.name resource attribute. Defaults to "cli-proxy-api".
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+
+	// SampleRatio is the fraction of new traces recorded, between 0 and 1. <= 0 or >= 1 records all.
+	// Sampling decisions of incoming traceparent headers are always honored.
+	SampleRatio float64 `yaml:"sample-ratio,omitempty" json:"sample-ratio,omitempty"`
+}
+
+// AuditLogConfig holds the structured JSONL request audit log settings.
+type AuditLogConfig struct {
+	// Enable writes one JSON object per proxied request.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Path is the audit log file. Relative paths resolve against the request log directory.
+	// Defaults to "audit.jsonl".
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// IncludeBodies adds request and response bodies to each entry.
+	IncludeBodies bool `yaml:"include-bodies,omitempty" json:"include-bodies,omitempty"`
+
+	// MaxBodyBytes truncates logged bodies. <= 0 uses 65536.
+	MaxBodyBytes int `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+
+	// RedactHeaders lists additional header names whose values are replaced entirely.
+	// Credentials such as Authorization and API key headers are always masked.
+	RedactHeaders []string `yaml:"redact-headers,omitempty" json:"redact-headers,omitempty"`
+
+	// RedactJSONPaths lists dot-separated JSON paths redacted from logged bodies.
+	// A "*" segment matches every array element or object member (e.g., "messages.*.content").
+	RedactJSONPaths []string `yaml:"redact-json-paths,omitempty" json:"redact-json-paths,omitempty"`
+
+	// MaxSizeMB rotates the file once it reaches this size. <= 0 uses 100.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// MaxBackups limits the number of rotated files kept. 0 keeps all.
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+
+	// MaxAgeDays deletes rotated files older than this many days. 0 disables age-based cleanup.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+}
+
+// UsageStoreConfig holds persistent usage record storage settings.
+type UsageStoreConfig struct {
+	// Driver selects the backend: "sqlite", "postgres", or empty to disable persistence.
+	Driver string `yaml:"driver" json:"driver"`
+	// Path is the SQLite database file. Defaults to usage.db under the auth directory.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// DSN is the PostgreSQL connection string. When empty, the Postgres token store connection is reused.
+	DSN string `yaml:"dsn,omitempty" json:"-"`
+	// RetentionDays removes records older than the given number of days. Zero keeps records forever.
+	// At startup at most the last 30 days are loaded back into the in-memory statistics.
+	RetentionDays int `yaml:"retention-days" json:"retention-days"`
+}
+
+// ClusterConfig holds the settings of instances sharing credentials through a common database.
+// Members publish cooldowns, quota state and disabled flags, take turns refreshing each
+// credential under a lease, and merge each other's usage from a shared usage-store.
+type ClusterConfig struct {
+	// Enable turns on state sharing.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Driver selects the backend: "postgres" (the default) or "sqlite" for instances on one host.
+	Driver string `yaml:"driver,omitempty" json:"driver,omitempty"`
+	// Path is the SQLite database file. Defaults to cluster.db under the auth directory.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// DSN is the PostgreSQL connection string. When empty, the Postgres token store connection is reused.
+	DSN string `yaml:"dsn,omitempty" json:"-"`
+	// InstanceID names this instance. Defaults to the host name and process ID.
+	InstanceID string `yaml:"instance-id,omitempty" json:"instance-id,omitempty"`
+	// SyncIntervalSeconds is how often state is published and fetched. Defaults to 2.
+	SyncIntervalSeconds int `yaml:"sync-interval-seconds,omitempty" json:"sync-interval-seconds,omitempty"`
+	// LeaseSeconds bounds how long a refresh lease outlives a crashed holder. Defaults to 60.
+	LeaseSeconds int `yaml:"lease-seconds,omitempty" json:"lease-seconds,omitempty"`
+}
+
+// ResponseStoreConfig holds the Responses API conversation store settings.
+type ResponseStoreConfig struct {
+	// Driver selects the backend: "memory", "file", "postgres", or empty to disable the store.
+	Driver string `yaml:"driver" json:"driver"`
+	// Path is the directory of the "file" backend. Defaults to responses/ under WRITABLE_PATH or the
+	// working directory; never put it inside the auth directory, whose JSON files are loaded as auths.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// DSN is the PostgreSQL connection string. When empty, the Postgres token store connection is reused.
+	DSN string `yaml:"dsn,omitempty" json:"-"`
+	// TTLSeconds controls how long stored responses remain retrievable. <= 0 uses 30 days.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+	// MaxEntries bounds the "memory" backend; the oldest responses are evicted first. <= 0 uses 10000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// BatchConfig holds the OpenAI Batch API emulation settings.
+type BatchConfig struct {
+	// Enable registers /v1/files and /v1/batches and starts the background runner.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Concurrency bounds the batch requests in flight across all jobs. <= 0 uses 4.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	// Path is the directory for files and job state. Defaults to batches/ under WRITABLE_PATH or the
+	// working directory, outside the auth directory whose JSON files are loaded as auths; ignored
+	// when the Postgres token store is active, whose connection is used instead.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// MaxFileBytes limits uploaded input files. <= 0 uses 200 MiB.
+	MaxFileBytes int64 `yaml:"max-file-bytes,omitempty" json:"max-file-bytes,omitempty"`
+}
+
+// AuthEncryptionConfig holds the envelope encryption settings of auth files.
+type AuthEncryptionConfig struct {
+	// Enable encrypts auth files on every write. Files are decrypted whenever a key is
+	// configured, so disabling with keys kept writes them back as plaintext.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Keys lists the key-encryption keys. The first encrypts new writes and every key decrypts,
+	// so a key is rotated by prepending its replacement.
+	Keys []AuthEncryptionKey `yaml:"keys,omitempty" json:"-"`
+}
+
+// AuthEncryptionKey names a base64-encoded 32-byte AES key read from an env var or a file.
+type AuthEncryptionKey struct {
+	// ID is recorded in every file encrypted with the key.
+	ID string `yaml:"id" json:"id"`
+	// Env is the environment variable holding the key.
+	Env string `yaml:"env,omitempty" json:"env,omitempty"`
+	// File is the path of a file holding the key; used when Env is empty.
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+}
+
 // RemoteManagement holds management API configuration under 'remote-management'.
 type RemoteManagement struct {
 	// AllowRemote toggles remote (non-localhost) access to management API.
@@ -204,8 +397,79 @@ type QuotaExceeded struct {
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "health-weighted".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// HealthDecay is the EWMA weight (0-1] given to each new latency/error sample by the
+	// "health-weighted" strategy. Higher values react faster. Defaults to 0.2.
+	HealthDecay float64 `yaml:"health-decay,omitempty" json:"health-decay,omitempty"`
+
+	// Hedging fires a second streaming attempt at another credential when the first one is
+	// slow to produce its first chunk.
+	Hedging HedgingConfig `yaml:"hedging,omitempty" json:"hedging,omitempty"`
+}
+
+// RequestQueueConfig configures the per-model wait queue for models whose credentials are all
+// cooling down. Parked requests are released in round-robin order across client API keys.
+type RequestQueueConfig struct {
+	// Enable turns on queueing. When false, such requests fail with 429 as before.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxDepth caps the parked requests per model; requests beyond it fail with 429. Defaults to 100.
+	MaxDepth int `yaml:"max-depth,omitempty" json:"max-depth,omitempty"`
+
+	// MaxWaitSeconds bounds how long a request stays parked before failing with 429. Defaults to 300.
+	MaxWaitSeconds int `yaml:"max-wait-seconds,omitempty" json:"max-wait-seconds,omitempty"`
+}
+
+// CircuitBreakerConfig configures the breakers kept per openai-compatibility provider and base
+// URL. A breaker opens after consecutive connection errors or 5xx responses, skipping every
+// credential behind that base URL, then half-opens to let probe requests through.
+type CircuitBreakerConfig struct {
+	// Enable turns on the breakers.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// FailureThreshold is the number of consecutive failures that opens a breaker. Defaults to 5.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+
+	// OpenSeconds is how long a breaker stays open before admitting probes. Defaults to 30.
+	OpenSeconds int `yaml:"open-seconds,omitempty" json:"open-seconds,omitempty"`
+
+	// HalfOpenProbes caps the probe requests in flight while half-open. Defaults to 1.
+	HalfOpenProbes int `yaml:"half-open-probes,omitempty" json:"half-open-probes,omitempty"`
+}
+
+// CredentialLimits caps the load placed on a single upstream credential. Saturated credentials
+// are skipped by the selector. Zero values mean unlimited.
+type CredentialLimits struct {
+	// MaxInFlight caps the requests running at once on the credential.
+	MaxInFlight int `yaml:"max-in-flight,omitempty" json:"max-in-flight,omitempty"`
+
+	// RequestsPerMinute caps the requests started on the credential within a sliding minute.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// TokensPerMinute caps the tokens reported for the credential within a sliding minute.
+	TokensPerMinute int `yaml:"tokens-per-minute,omitempty" json:"tokens-per-minute,omitempty"`
+}
+
+// HedgingConfig configures speculative streaming requests. Hedging is disabled while no rule
+// matches the requested model.
+type HedgingConfig struct {
+	// MaxInFlight caps the hedge attempts running at once across all requests. Defaults to 8.
+	MaxInFlight int `yaml:"max-in-flight,omitempty" json:"max-in-flight,omitempty"`
+
+	// Rules selects the hedged models. The first rule matching the requested model applies.
+	Rules []HedgingRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// HedgingRule sets the hedge delay for a group of models.
+type HedgingRule struct {
+	// Models lists model name patterns; '*' matches any substring.
+	Models []string `yaml:"models" json:"models"`
+
+	// DelayMS is how long to wait for the first chunk before hedging. <= 0 disables hedging
+	// for the matching models.
+	DelayMS int `yaml:"delay-ms" json:"delay-ms"`
 }
 
 // OAuthModelAlias defines a model ID alias for a specific channel.
@@ -347,6 +611,9 @@ type ClaudeKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// CredentialLimits caps the concurrency and rates sent through this credential.
+	CredentialLimits `yaml:",inline"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/claude-sonnet-4").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -395,6 +662,9 @@ type CodexKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// CredentialLimits caps the concurrency and rates sent through this credential.
+	CredentialLimits `yaml:",inline"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gpt-5-codex").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -443,6 +713,9 @@ type GeminiKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// CredentialLimits caps the concurrency and rates sent through this credential.
+	CredentialLimits `yaml:",inline"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gemini-3-pro-preview").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -516,6 +789,9 @@ type OpenAICompatibility struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// CredentialLimits caps the concurrency and rates sent through each API key of this provider.
+	CredentialLimits `yaml:",inline"`
+
 	// Prefix optionally namespaces model aliases for this provider (e.g., "teamA/kimi-k2").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -549,6 +825,13 @@ type OpenAICompatibilityModel struct {
 
 	// Alias is the model name alias that clients will use to reference this model.
 	Alias string `yaml:"alias" json:"alias"`
+
+	// Kind optionally marks a non-chat model; "embedding" serves /v1/embeddings.
+	Kind string `yaml:"kind,omitempty" json:"kind,omitempty"`
+
+	// Tokenizer optionally selects the local tokenizer used to count prompt tokens,
+	// such as "o200k_base", "cl100k_base", "claude" or "gemini".
+	Tokenizer string `yaml:"tokenizer,omitempty" json:"tokenizer,omitempty"`
 }
 
 func (m OpenAICompatibilityModel) GetName() string  { return m.Name }
@@ -621,6 +904,11 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Resolve ${scheme:reference} provider keys before sanitizing, which compares key values.
+	if err = cfg.resolveSecretReferences(); err != nil {
+		return nil, fmt.Errorf("failed to resolve config secrets: %w", err)
+	}
+
 	// NOTE: Startup legacy key migration is intentionally disabled.
 	// Reason: avoid mutating config.yaml during server startup.
 	// Re-enable the block below if automatic startup migration is needed again.
@@ -690,12 +978,21 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Normalize OAuth provider model exclusion map.
 	cfg.OAuthExcludedModels = NormalizeOAuthExcludedModels(cfg.OAuthExcludedModels)
 
+	// Normalize per-provider credential limits.
+	cfg.ProviderLimits = NormalizeProviderLimits(cfg.ProviderLimits)
+
 	// Normalize global OAuth model name aliases.
 	cfg.SanitizeOAuthModelAlias()
 
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	// Normalize per-client API key limits.
+	cfg.SanitizeAPIKeyLimits()
+
+	// Drop incomplete model fallback chains.
+	cfg.SanitizeModelFallbacks()
+
 	// NOTE: Legacy migration persistence is intentionally disabled together with
 	// startup legacy migration to keep startup read-only for config.yaml.
 	// Re-enable the block below if automatic startup migration is needed again.
@@ -770,6 +1067,74 @@ func payloadRawString(value any) ([]byte, bool) {
 	}
 }
 
+// SanitizeAPIKeyLimits trims client key lists and model patterns for api-key-limits entries.
+// Entries without any client key are dropped; negative limits are treated as disabled.
+func (cfg *Config) SanitizeAPIKeyLimits() {
+	if cfg == nil || len(cfg.APIKeyLimits) == 0 {
+		return
+	}
+	out := make([]APIKeyLimit, 0, len(cfg.APIKeyLimits))
+	for i := range cfg.APIKeyLimits {
+		entry := cfg.APIKeyLimits[i]
+		keys := make([]string, 0, len(entry.APIKeys))
+		for _, key := range entry.APIKeys {
+			if trimmed := strings.TrimSpace(key); trimmed != "" {
+				keys = append(keys, trimmed)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		entry.APIKeys = keys
+		entry.AllowedModels = NormalizeExcludedModels(entry.AllowedModels)
+		if entry.RequestsPerMinute < 0 {
+			entry.RequestsPerMinute = 0
+		}
+		if entry.TokensPerDay < 0 {
+			entry.TokensPerDay = 0
+		}
+		if entry.MonthlyTokenBudget < 0 {
+			entry.MonthlyTokenBudget = 0
+		}
+		out = append(out, entry)
+	}
+	cfg.APIKeyLimits = out
+}
+
+// SanitizeModelFallbacks trims fallback chains and drops entries without a model or targets.
+// Targets equal to the chain's own model are removed to avoid retrying the primary.
+func (cfg *Config) SanitizeModelFallbacks() {
+	if cfg == nil || len(cfg.ModelFallbacks) == 0 {
+		return
+	}
+	out := make([]ModelFallback, 0, len(cfg.ModelFallbacks))
+	for _, entry := range cfg.ModelFallbacks {
+		model := strings.TrimSpace(entry.Model)
+		if model == "" {
+			continue
+		}
+		seen := make(map[string]struct{}, len(entry.Fallbacks))
+		targets := make([]string, 0, len(entry.Fallbacks))
+		for _, target := range entry.Fallbacks {
+			target = strings.TrimSpace(target)
+			key := strings.ToLower(target)
+			if target == "" || strings.EqualFold(target, model) {
+				continue
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			targets = append(targets, target)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		out = append(out, ModelFallback{Model: model, Fallbacks: targets})
+	}
+	cfg.ModelFallbacks = out
+}
+
 // SanitizeOAuthModelAlias normalizes and deduplicates global OAuth model name aliases.
 // It trims whitespace, normalizes channel keys to lower-case, drops empty entries,
 // allows multiple aliases per upstream name, and ensures aliases are unique within each channel.
@@ -1028,6 +1393,25 @@ func NormalizeOAuthExcludedModels(entries map[string][]string) map[string][]stri
 	return out
 }
 
+// NormalizeProviderLimits lower-cases provider keys and drops entries without any limit.
+func NormalizeProviderLimits(entries map[string]CredentialLimits) map[string]CredentialLimits {
+	if len(entries) == 0 {
+		return nil
+	}
+	out := make(map[string]CredentialLimits, len(entries))
+	for provider, limits := range entries {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if key == "" || limits == (CredentialLimits{}) {
+			continue
+		}
+		out[key] = limits
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
 // hashSecret hashes the given secret using bcrypt.
 func hashSecret(secret string) (string, error) {
 	// Use default cost for simplicity.
@@ -1041,7 +1425,8 @@ func hashSecret(secret string) (string, error) {
 // SaveConfigPreserveComments writes the config back to YAML while preserving existing comments
 // and key ordering by loading the original file into a yaml.Node tree and updating values in-place.
 func SaveConfigPreserveComments(configFile string, cfg *Config) error {
-	persistCfg := cfg
+	// Write secret references back rather than the secrets they resolved to.
+	persistCfg := cfg.WithSecretReferences()
 	// Load original YAML as a node tree to preserve comments and ordering.
 	data, err := os.ReadFile(configFile)
 	if err != nil {
@@ -20,16 +20,128 @@ type SDKConfig struct {
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// APIKeyLimits attaches request rates, token budgets and model allowlists to client API keys.
+	// Entries are matched in order; the first entry listing the key (or "*") applies.
+	// Token counters are kept in memory and restored from the usage store, when configured, at startup.
+	APIKeyLimits []APIKeyLimit `yaml:"api-key-limits,omitempty" json:"api-key-limits,omitempty"`
+
+	// APIKeyPolicies restricts the models and providers visible to and callable by client API keys.
+	// Explicit key matches take precedence over "*" entries. Allowed models combine with the
+	// allowed models of the key's APIKeyLimits entry; a model must pass both.
+	APIKeyPolicies []APIKeyPolicy `yaml:"api-key-policies,omitempty" json:"api-key-policies,omitempty"`
+
+	// ModelFallbacks defines ordered fallback chains tried when every credential for the requested
+	// model is exhausted or fails with a retryable error.
+	ModelFallbacks []ModelFallback `yaml:"model-fallbacks,omitempty" json:"model-fallbacks,omitempty"`
+
 	// PassthroughHeaders controls whether upstream response headers are forwarded to downstream clients.
 	// Default is false (disabled).
 	PassthroughHeaders bool `yaml:"passthrough-headers" json:"passthrough-headers"`
 
+	// LocalTokenCount answers count_tokens requests with the local tokenizer instead of an
+	// upstream call. Providers without a counting endpoint are always counted locally.
+	LocalTokenCount bool `yaml:"local-token-count" json:"local-token-count"`
+
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
 
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// ResponseCache configures replay of identical deterministic requests without contacting upstream.
+	ResponseCache ResponseCacheConfig `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// ContextGuard checks prompts against the target model's context window before dispatch.
+	ContextGuard ContextGuardConfig `yaml:"context-guard,omitempty" json:"context-guard,omitempty"`
+
+	// SessionAffinity keeps the turns of a conversation on the credential that served it,
+	// so upstream prompt caches stay warm.
+	SessionAffinity SessionAffinityConfig `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+}
+
+// Context guard strategies, applied in the configured order while a prompt does not fit.
+const (
+	// ContextGuardTruncateToolResults shortens tool results longer than MaxToolResultChars.
+	ContextGuardTruncateToolResults = "truncate-tool-results"
+	// ContextGuardDropOldestTurns removes the oldest conversation turns, keeping system prompts
+	// and the latest turn.
+	ContextGuardDropOldestTurns = "drop-oldest-turns"
+	// ContextGuardReroute switches to the first RerouteModels entry whose context window fits.
+	ContextGuardReroute = "reroute"
+)
+
+// ContextGuardConfig holds the pre-dispatch context-window guard settings. Prompts that still
+// do not fit after every strategy are rejected with a 400 error.
+type ContextGuardConfig struct {
+	// Enable turns on the guard for chat/messages/responses/generate requests.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Strategies lists the strategies tried in order. Empty rejects oversize prompts outright.
+	Strategies []string `yaml:"strategies,omitempty" json:"strategies,omitempty"`
+
+	// ReserveOutputTokens is kept free for the response on models that only declare a total
+	// context length, when the request does not set its own output limit.
+	ReserveOutputTokens int `yaml:"reserve-output-tokens,omitempty" json:"reserve-output-tokens,omitempty"`
+
+	// MaxToolResultChars is the length tool results are cut to. <= 0 uses 8000.
+	MaxToolResultChars int `yaml:"max-tool-result-chars,omitempty" json:"max-tool-result-chars,omitempty"`
+
+	// RerouteModels lists larger-context models considered by the reroute strategy.
+	RerouteModels []string `yaml:"reroute-models,omitempty" json:"reroute-models,omitempty"`
+}
+
+// Session affinity sources, tried in the configured order.
+const (
+	// SessionAffinityHeaderPrefix prefixes a source reading the named request header,
+	// e.g. "header:X-Session-Id".
+	SessionAffinityHeaderPrefix = "header:"
+	// SessionAffinityClaudeUserID reads Claude metadata.user_id.
+	SessionAffinityClaudeUserID = "claude-user-id"
+	// SessionAffinityPromptCacheKey reads the OpenAI and Responses prompt_cache_key.
+	SessionAffinityPromptCacheKey = "prompt-cache-key"
+	// SessionAffinityPromptHash hashes the system prompt together with the first user message.
+	SessionAffinityPromptHash = "prompt-hash"
+)
+
+// SessionAffinityConfig configures sticky credential routing per conversation. A conversation
+// falls back to the routing strategy while its credential is cooling down, saturated or gone,
+// and is then bound to the replacement.
+type SessionAffinityConfig struct {
+	// Enable turns on sticky routing for chat/messages/responses/generate requests.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Sources lists where the conversation identity is read from; the first one present wins.
+	// Empty uses header:X-Session-Id, claude-user-id, prompt-cache-key and prompt-hash.
+	Sources []string `yaml:"sources,omitempty" json:"sources,omitempty"`
+
+	// TTLSeconds is how long a conversation stays bound after its last request. <= 0 uses 3600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+}
+
+// ResponseCacheConfig holds the opt-in response cache settings.
+type ResponseCacheConfig struct {
+	// Enable turns on caching for chat/messages/generate requests.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// TTLSeconds controls how long a cached response may be replayed. <= 0 uses 300 seconds.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries bounds the number of cached responses. <= 0 uses 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// MaxEntryBytes skips caching responses larger than this many bytes. <= 0 uses 1 MiB.
+	MaxEntryBytes int64 `yaml:"max-entry-bytes,omitempty" json:"max-entry-bytes,omitempty"`
+
+	// MaxTotalBytes bounds the memory held by the cache. <= 0 uses 64 MiB.
+	MaxTotalBytes int64 `yaml:"max-total-bytes,omitempty" json:"max-total-bytes,omitempty"`
+
+	// IncludeNonDeterministic also caches requests that do not pin temperature to 0.
+	IncludeNonDeterministic bool `yaml:"include-non-deterministic,omitempty" json:"include-non-deterministic,omitempty"`
+
+	// SharedAcrossKeys lets different client API keys share cached responses.
+	// By default each client key has its own cache namespace.
+	SharedAcrossKeys bool `yaml:"shared-across-keys,omitempty" json:"shared-across-keys,omitempty"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -43,3 +155,54 @@ type StreamingConfig struct {
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
 }
+
+// APIKeyLimit describes usage limits enforced for a set of client API keys.
+type APIKeyLimit struct {
+	// APIKeys lists the client API keys (from top-level api-keys) governed by this entry.
+	// A single "*" entry matches every key that has no more specific entry.
+	APIKeys []string `yaml:"api-keys" json:"api-keys"`
+
+	// RequestsPerMinute caps the number of requests admitted in any rolling 60-second window.
+	// <= 0 disables the limit.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// TokensPerDay caps the total tokens consumed per calendar day (server local time).
+	// <= 0 disables the limit.
+	TokensPerDay int64 `yaml:"tokens-per-day,omitempty" json:"tokens-per-day,omitempty"`
+
+	// MonthlyTokenBudget caps the total tokens consumed per calendar month (server local time).
+	// <= 0 disables the limit.
+	MonthlyTokenBudget int64 `yaml:"monthly-token-budget,omitempty" json:"monthly-token-budget,omitempty"`
+
+	// AllowedModels restricts the models the keys may call. Supports '*' wildcards
+	// (e.g., "gpt-*", "claude-*-sonnet"). Empty allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+}
+
+// APIKeyPolicy restricts the models a set of client API keys may list and call.
+type APIKeyPolicy struct {
+	// APIKeys lists the client API keys (from top-level api-keys) governed by this entry.
+	// A single "*" entry matches every key that has no more specific entry.
+	APIKeys []string `yaml:"api-keys" json:"api-keys"`
+
+	// AllowedModels restricts models by '*' wildcard patterns (e.g., "gpt-*", "teamA/*").
+	// Patterns match the requested model including any prefix. Empty allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// AllowedProviders restricts the upstream providers that may serve the keys (e.g., "claude", "gemini-cli").
+	// Empty allows every provider.
+	AllowedProviders []string `yaml:"allowed-providers,omitempty" json:"allowed-providers,omitempty"`
+
+	// DisableThinkingSuffix rejects model names carrying a thinking suffix such as "model(high)".
+	DisableThinkingSuffix bool `yaml:"disable-thinking-suffix,omitempty" json:"disable-thinking-suffix,omitempty"`
+}
+
+// ModelFallback describes an ordered fallback chain for a client-requested model.
+type ModelFallback struct {
+	// Model is the client-requested model the chain applies to (case-insensitive, without thinking suffix).
+	Model string `yaml:"model" json:"model"`
+
+	// Fallbacks lists the targets tried in order after the requested model. A target is either a
+	// model name or "provider:model" to restrict the target to one provider (e.g., "kiro:claude-sonnet-4-5").
+	Fallbacks []string `yaml:"fallbacks" json:"fallbacks"`
+}
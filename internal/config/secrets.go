@@ -0,0 +1,307 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretResolveTimeout bounds how long resolving all secret references of one load may take.
+const secretResolveTimeout = 30 * time.Second
+
+// secretReferencePattern matches a whole value of the form ${scheme:reference}.
+var secretReferencePattern = regexp.MustCompile(`^\$\{([A-Za-z][A-Za-z0-9_-]*):(.*)\}$`)
+
+// SecretResolver resolves the reference part of a ${scheme:reference} value to the secret it
+// names. Implementations must be safe for concurrent use.
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, reference string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to SecretResolver.
+type SecretResolverFunc func(ctx context.Context, reference string) (string, error)
+
+// ResolveSecret calls f.
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, reference string) (string, error) {
+	return f(ctx, reference)
+}
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"env":   SecretResolverFunc(resolveEnvSecret),
+		"file":  SecretResolverFunc(resolveFileSecret),
+		"vault": &VaultResolver{},
+	}
+)
+
+// RegisterSecretResolver makes values of the form ${scheme:reference} resolve through resolver,
+// replacing any resolver registered for scheme before. A nil resolver unregisters the scheme.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	if resolver == nil {
+		delete(secretResolvers, scheme)
+		return
+	}
+	secretResolvers[scheme] = resolver
+}
+
+func resolveEnvSecret(_ context.Context, reference string) (string, error) {
+	value, ok := os.LookupEnv(strings.TrimSpace(reference))
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", reference)
+	}
+	return value, nil
+}
+
+func resolveFileSecret(_ context.Context, reference string) (string, error) {
+	data, err := os.ReadFile(strings.TrimSpace(reference))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// VaultResolver reads secrets from HashiCorp Vault. References take the form path#field, where
+// path is the API path below /v1/ (for example secret/data/llm on a KV v2 engine) and field the
+// key within the secret. Empty fields fall back to the VAULT_ADDR, VAULT_TOKEN and
+// VAULT_NAMESPACE environment variables.
+type VaultResolver struct {
+	Address   string
+	Token     string
+	Namespace string
+	Client    *http.Client
+}
+
+// ResolveSecret reads the field named by reference from Vault.
+func (v *VaultResolver) ResolveSecret(ctx context.Context, reference string) (string, error) {
+	path, field, ok := strings.Cut(strings.TrimSpace(reference), "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault reference %q must have the form path#field", reference)
+	}
+	address := firstNonEmpty(v.Address, os.Getenv("VAULT_ADDR"))
+	if address == "" {
+		return "", fmt.Errorf("vault address is not configured (set VAULT_ADDR)")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token := firstNonEmpty(v.Token, os.Getenv("VAULT_TOKEN")); token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := firstNonEmpty(v.Namespace, os.Getenv("VAULT_NAMESPACE")); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %d for %s: %s", resp.StatusCode, path, strings.TrimSpace(string(body)))
+	}
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err = json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("decode vault response for %s: %w", path, err)
+	}
+	data := secret.Data
+	// KV v2 nests the secret under data.data next to its metadata.
+	if inner, isKV2 := data["data"].(map[string]any); isKV2 && data["metadata"] != nil {
+		data = inner
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string field %q", path, field)
+	}
+	return value, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// secretField is a config value that may hold a secret reference, with its location in the
+// config file.
+type secretField struct {
+	// section is the top-level key the value sits under.
+	section string
+	// location is the path of the value, for example codex-api-key[2].api-key.
+	location string
+	value    *string
+}
+
+// secretRef records the reference a config value was written as and the secret it resolved to.
+type secretRef struct {
+	section   string
+	reference string
+	secret    string
+}
+
+// secretFields returns the config values that may hold secret references.
+func (cfg *Config) secretFields() []secretField {
+	var fields []secretField
+	add := func(section, location string, value *string) {
+		fields = append(fields, secretField{section: section, location: location, value: value})
+	}
+	for i := range cfg.GeminiKey {
+		add("gemini-api-key", fmt.Sprintf("gemini-api-key[%d].api-key", i), &cfg.GeminiKey[i].APIKey)
+	}
+	for i := range cfg.ClaudeKey {
+		add("claude-api-key", fmt.Sprintf("claude-api-key[%d].api-key", i), &cfg.ClaudeKey[i].APIKey)
+	}
+	for i := range cfg.CodexKey {
+		add("codex-api-key", fmt.Sprintf("codex-api-key[%d].api-key", i), &cfg.CodexKey[i].APIKey)
+	}
+	for i := range cfg.VertexCompatAPIKey {
+		add("vertex-api-key", fmt.Sprintf("vertex-api-key[%d].api-key", i), &cfg.VertexCompatAPIKey[i].APIKey)
+	}
+	for i := range cfg.OpenAICompatibility {
+		for j := range cfg.OpenAICompatibility[i].APIKeyEntries {
+			add("openai-compatibility", fmt.Sprintf("openai-compatibility[%d].api-key-entries[%d].api-key", i, j), &cfg.OpenAICompatibility[i].APIKeyEntries[j].APIKey)
+		}
+	}
+	add("ampcode", "ampcode.upstream-api-key", &cfg.AmpCode.UpstreamAPIKey)
+	for i := range cfg.AmpCode.UpstreamAPIKeys {
+		add("ampcode", fmt.Sprintf("ampcode.upstream-api-keys[%d].upstream-api-key", i), &cfg.AmpCode.UpstreamAPIKeys[i].UpstreamAPIKey)
+	}
+	return fields
+}
+
+// resolveSecretReferences replaces every secret reference among the provider keys with the
+// secret it names and remembers each reference by location so it can be written back and shown
+// instead.
+func (cfg *Config) resolveSecretReferences() error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretResolveTimeout)
+	defer cancel()
+	cfg.secretRefs = nil
+	for _, field := range cfg.secretFields() {
+		match := secretReferencePattern.FindStringSubmatch(strings.TrimSpace(*field.value))
+		if match == nil {
+			continue
+		}
+		scheme := strings.ToLower(match[1])
+		secretResolversMu.RLock()
+		resolver := secretResolvers[scheme]
+		secretResolversMu.RUnlock()
+		if resolver == nil {
+			return fmt.Errorf("unknown secret scheme %q in %s", scheme, match[0])
+		}
+		value, err := resolver.ResolveSecret(ctx, match[2])
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", match[0], err)
+		}
+		if value == "" {
+			return fmt.Errorf("resolve %s: secret is empty", match[0])
+		}
+		if cfg.secretRefs == nil {
+			cfg.secretRefs = make(map[string]secretRef)
+		}
+		cfg.secretRefs[field.location] = secretRef{section: field.section, reference: match[0], secret: value}
+		*field.value = value
+	}
+	return nil
+}
+
+// fieldReferences returns the reference each of fields was loaded from, or "" for values that
+// were written literally. A value keeps the reference of its location while it still holds the
+// secret resolved there. Management edits that remove entries shift later entries to new
+// locations; a value that no longer sits at its location takes the reference of a location in
+// the same section that lost its secret, so moved secrets are not written back in plaintext
+// while literal keys that happen to equal a secret stay literal.
+func (cfg *Config) fieldReferences(fields []secretField) []string {
+	references := make([]string, len(fields))
+	if len(cfg.secretRefs) == 0 {
+		return references
+	}
+	current := make(map[string]string, len(fields))
+	for _, field := range fields {
+		current[field.location] = *field.value
+	}
+	var vacated []string
+	for location, ref := range cfg.secretRefs {
+		if value, ok := current[location]; !ok || value != ref.secret {
+			vacated = append(vacated, location)
+		}
+	}
+	slices.Sort(vacated)
+	for i, field := range fields {
+		if ref, ok := cfg.secretRefs[field.location]; ok && ref.secret == *field.value {
+			references[i] = ref.reference
+			continue
+		}
+		for _, location := range vacated {
+			if ref := cfg.secretRefs[location]; ref.section == field.section && ref.secret == *field.value {
+				references[i] = ref.reference
+				break
+			}
+		}
+	}
+	return references
+}
+
+// ResolvedSecret returns the secret a reference resolved to when the config was loaded, or
+// value itself when it is not such a reference. Config values are searched in file order.
+func (cfg *Config) ResolvedSecret(value string) string {
+	if cfg == nil || len(cfg.secretRefs) == 0 {
+		return value
+	}
+	fields := cfg.secretFields()
+	for i, reference := range cfg.fieldReferences(fields) {
+		if reference != "" && reference == value {
+			return *fields[i].value
+		}
+	}
+	return value
+}
+
+// WithSecretReferences returns a copy of cfg in which the resolved secrets are replaced by the
+// references they were loaded from, for saving the config or showing it. cfg is not modified.
+func (cfg *Config) WithSecretReferences() *Config {
+	if cfg == nil || len(cfg.secretRefs) == 0 {
+		return cfg
+	}
+	out := new(*cfg)
+	out.GeminiKey = slices.Clone(cfg.GeminiKey)
+	out.ClaudeKey = slices.Clone(cfg.ClaudeKey)
+	out.CodexKey = slices.Clone(cfg.CodexKey)
+	out.VertexCompatAPIKey = slices.Clone(cfg.VertexCompatAPIKey)
+	out.OpenAICompatibility = slices.Clone(cfg.OpenAICompatibility)
+	for i := range out.OpenAICompatibility {
+		out.OpenAICompatibility[i].APIKeyEntries = slices.Clone(out.OpenAICompatibility[i].APIKeyEntries)
+	}
+	out.AmpCode.UpstreamAPIKeys = slices.Clone(cfg.AmpCode.UpstreamAPIKeys)
+	references := cfg.fieldReferences(cfg.secretFields())
+	for i, field := range out.secretFields() {
+		if references[i] != "" {
+			*field.value = references[i]
+		}
+	}
+	return out
+}
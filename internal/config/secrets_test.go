@@ -0,0 +1,141 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigResolvesSecretReferences(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" || r.URL.Path != "/v1/secret/data/llm" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"claude":"sk-claude-from-vault"},"metadata":{"version":3}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("TEST_CODEX_KEY", "sk-codex-from-env")
+
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "gemini-key")
+	if err := os.WriteFile(secretPath, []byte("gemini-from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	content := "# provider keys\n" +
+		"claude-api-key:\n  - api-key: ${vault:secret/data/llm#claude} # from vault\n" +
+		"codex-api-key:\n  - api-key: \"${env:TEST_CODEX_KEY}\"\n    base-url: https://codex.example.com\n" +
+		"gemini-api-key:\n  - api-key: ${file:" + secretPath + "}\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.ClaudeKey) != 1 || cfg.ClaudeKey[0].APIKey != "sk-claude-from-vault" {
+		t.Fatalf("claude keys = %+v, want the vault secret", cfg.ClaudeKey)
+	}
+	if len(cfg.CodexKey) != 1 || cfg.CodexKey[0].APIKey != "sk-codex-from-env" {
+		t.Fatalf("codex keys = %+v, want the env secret", cfg.CodexKey)
+	}
+	if len(cfg.GeminiKey) != 1 || cfg.GeminiKey[0].APIKey != "gemini-from-file" {
+		t.Fatalf("gemini keys = %+v, want the file secret", cfg.GeminiKey)
+	}
+
+	masked := cfg.WithSecretReferences()
+	if masked.ClaudeKey[0].APIKey != "${vault:secret/data/llm#claude}" {
+		t.Fatalf("masked claude key = %q, want the reference", masked.ClaudeKey[0].APIKey)
+	}
+	if cfg.ClaudeKey[0].APIKey != "sk-claude-from-vault" {
+		t.Fatal("masking modified the loaded config")
+	}
+	if got := cfg.ResolvedSecret("${env:TEST_CODEX_KEY}"); got != "sk-codex-from-env" {
+		t.Fatalf("ResolvedSecret = %q, want the env secret", got)
+	}
+
+	cfg.Port = 9000
+	if err = SaveConfigPreserveComments(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, _ := os.ReadFile(configPath)
+	for _, secret := range []string{"sk-claude-from-vault", "sk-codex-from-env", "gemini-from-file"} {
+		if strings.Contains(string(saved), secret) {
+			t.Fatalf("saved config leaks %q:\n%s", secret, saved)
+		}
+	}
+	if !strings.Contains(string(saved), "${vault:secret/data/llm#claude}") || !strings.Contains(string(saved), "# from vault") {
+		t.Fatalf("saved config lost the reference or its comment:\n%s", saved)
+	}
+
+	t.Setenv("VAULT_TOKEN", "revoked")
+	if _, err = LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("LoadConfig with a revoked token = %v, want the vault error", err)
+	}
+}
+
+func TestSecretReferencesAreTrackedPerField(t *testing.T) {
+	t.Setenv("TEST_SHARED_KEY_A", "sk-shared")
+	t.Setenv("TEST_SHARED_KEY_B", "sk-shared")
+	t.Setenv("TEST_MOVED_KEY", "sk-moved")
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	content := "claude-api-key:\n" +
+		"  - api-key: ${env:TEST_SHARED_KEY_A}\n" +
+		"  - api-key: sk-literal\n" +
+		"  - api-key: ${env:TEST_MOVED_KEY}\n" +
+		"codex-api-key:\n  - api-key: ${env:TEST_SHARED_KEY_B}\n    base-url: https://codex.example.com\n" +
+		"gemini-api-key:\n  - api-key: sk-shared\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	// Duplicate secrets keep their own references, and a literal key equal to a resolved
+	// secret stays literal.
+	masked := cfg.WithSecretReferences()
+	if got := masked.ClaudeKey[0].APIKey; got != "${env:TEST_SHARED_KEY_A}" {
+		t.Fatalf("masked claude key = %q, want its own reference", got)
+	}
+	if got := masked.CodexKey[0].APIKey; got != "${env:TEST_SHARED_KEY_B}" {
+		t.Fatalf("masked codex key = %q, want its own reference", got)
+	}
+	if got := masked.GeminiKey[0].APIKey; got != "sk-shared" {
+		t.Fatalf("masked gemini key = %q, want the literal key", got)
+	}
+	for _, reference := range []string{"${env:TEST_SHARED_KEY_A}", "${env:TEST_SHARED_KEY_B}"} {
+		if got := cfg.ResolvedSecret(reference); got != "sk-shared" {
+			t.Fatalf("ResolvedSecret(%s) = %q, want the shared secret", reference, got)
+		}
+	}
+
+	// Removing an entry shifts the later ones; the moved secret keeps its reference.
+	cfg.ClaudeKey = append(cfg.ClaudeKey[:1], cfg.ClaudeKey[2:]...)
+	masked = cfg.WithSecretReferences()
+	if got := masked.ClaudeKey[1].APIKey; got != "${env:TEST_MOVED_KEY}" {
+		t.Fatalf("masked moved claude key = %q, want its reference", got)
+	}
+	if got := masked.ClaudeKey[0].APIKey; got != "${env:TEST_SHARED_KEY_A}" {
+		t.Fatalf("masked claude key = %q, want its own reference", got)
+	}
+
+	if err = SaveConfigPreserveComments(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, _ := os.ReadFile(configPath)
+	if strings.Contains(string(saved), "sk-moved") || !strings.Contains(string(saved), "api-key: sk-shared") {
+		t.Fatalf("saved config = \n%s", saved)
+	}
+}
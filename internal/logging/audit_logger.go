@@ -0,0 +1,601 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultAuditLogFile      = "audit.jsonl"
+	defaultAuditMaxBodyBytes = 64 << 10
+	defaultAuditMaxSizeMB    = 100
+	auditRedacted            = "[REDACTED]"
+	// auditAnnotationTTL bounds how long unclaimed annotations are kept.
+	auditAnnotationTTL = 10 * time.Minute
+	// ginUsageTrailKey stores the usage records published while serving a request.
+	ginUsageTrailKey = "__usage_trail__"
+	// ginRequestNotesKey stores the decisions the proxy made while serving a request.
+	ginRequestNotesKey = "__request_notes__"
+)
+
+// alwaysRedactedHeaders are replaced in audit entries regardless of configuration.
+var alwaysRedactedHeaders = []string{"Cookie", "Set-Cookie", "Proxy-Authorization", "X-Management-Key"}
+
+// RequestAnnotation carries request metadata that is only known inside the handler chain.
+type RequestAnnotation struct {
+	// Principal is the authenticated client API key.
+	Principal string
+	// AccessProvider is the access provider that authenticated the client.
+	AccessProvider string
+	// Usage lists the usage records published while serving the request, in order.
+	Usage []coreusage.Record
+	// Notes lists the decisions the proxy made while serving the request, in order.
+	Notes []string
+}
+
+// RequestAnnotator is implemented by request loggers that accept per-request metadata.
+// Annotations are supplied before LogRequest or before the streaming writer is closed.
+type RequestAnnotator interface {
+	AnnotateRequest(requestID string, annotation RequestAnnotation)
+}
+
+// usageTrail collects usage records for a single request; executors may publish concurrently.
+type usageTrail struct {
+	mu      sync.Mutex
+	records []coreusage.Record
+}
+
+var usageTrailMu sync.Mutex
+
+// AttachUsageRecord remembers a usage record on the request's Gin context so request loggers
+// can report the provider, credential and token usage of the request.
+func AttachUsageRecord(ctx context.Context, record coreusage.Record) {
+	if ctx == nil {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	usageTrailMu.Lock()
+	trail, _ := ginCtx.Value(ginUsageTrailKey).(*usageTrail)
+	if trail == nil {
+		trail = &usageTrail{}
+		ginCtx.Set(ginUsageTrailKey, trail)
+	}
+	usageTrailMu.Unlock()
+
+	trail.mu.Lock()
+	trail.records = append(trail.records, record)
+	trail.mu.Unlock()
+}
+
+// AttachRequestNote records a decision the proxy made about the request, such as trimming
+// the prompt, so request loggers can report it.
+func AttachRequestNote(ctx context.Context, note string) {
+	if ctx == nil || strings.TrimSpace(note) == "" {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	usageTrailMu.Lock()
+	defer usageTrailMu.Unlock()
+	notes, _ := ginCtx.Value(ginRequestNotesKey).([]string)
+	ginCtx.Set(ginRequestNotesKey, append(notes, note))
+}
+
+// RequestNotes returns the notes recorded for the request served by c.
+func RequestNotes(c *gin.Context) []string {
+	if c == nil {
+		return nil
+	}
+	usageTrailMu.Lock()
+	defer usageTrailMu.Unlock()
+	notes, _ := c.Value(ginRequestNotesKey).([]string)
+	return append([]string(nil), notes...)
+}
+
+// RequestAnnotationFromGin collects the annotation for the request served by c.
+func RequestAnnotationFromGin(c *gin.Context) RequestAnnotation {
+	var annotation RequestAnnotation
+	if c == nil {
+		return annotation
+	}
+	if v, exists := c.Get("apiKey"); exists {
+		if key, ok := v.(string); ok {
+			annotation.Principal = key
+		}
+	}
+	if v, exists := c.Get("accessProvider"); exists {
+		if provider, ok := v.(string); ok {
+			annotation.AccessProvider = provider
+		}
+	}
+	if trail, ok := c.Value(ginUsageTrailKey).(*usageTrail); ok && trail != nil {
+		trail.mu.Lock()
+		annotation.Usage = append([]coreusage.Record(nil), trail.records...)
+		trail.mu.Unlock()
+	}
+	annotation.Notes = RequestNotes(c)
+	return annotation
+}
+
+// JSONLRequestLogger implements RequestLogger by appending one JSON object per request
+// to a size-rotated file. It complements FileRequestLogger for log pipelines.
+type JSONLRequestLogger struct {
+	mu            sync.Mutex
+	settings      config.AuditLogConfig
+	logsDir       string
+	writer        *lumberjack.Logger
+	redactHeaders map[string]struct{}
+	redactPaths   [][]string
+
+	pendingMu sync.Mutex
+	pending   map[string]pendingAnnotation
+}
+
+type pendingAnnotation struct {
+	annotation RequestAnnotation
+	storedAt   time.Time
+}
+
+// auditEntry is the JSON shape of a single audit log line.
+type auditEntry struct {
+	Timestamp       time.Time           `json:"timestamp"`
+	RequestID       string              `json:"request_id,omitempty"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	Status          int                 `json:"status"`
+	Stream          bool                `json:"stream"`
+	Principal       string              `json:"principal,omitempty"`
+	AccessProvider  string              `json:"access_provider,omitempty"`
+	Provider        string              `json:"provider,omitempty"`
+	Model           string              `json:"model,omitempty"`
+	AuthID          string              `json:"auth_id,omitempty"`
+	AuthIndex       string              `json:"auth_index,omitempty"`
+	Attempts        int                 `json:"attempts,omitempty"`
+	Timings         auditTimings        `json:"timings"`
+	Usage           *auditUsage         `json:"usage,omitempty"`
+	Errors          []string            `json:"errors,omitempty"`
+	Notes           []string            `json:"notes,omitempty"`
+	RequestHeaders  map[string][]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	RequestBody     json.RawMessage     `json:"request_body,omitempty"`
+	ResponseBody    json.RawMessage     `json:"response_body,omitempty"`
+}
+
+type auditTimings struct {
+	RequestAt    time.Time  `json:"request_at"`
+	FirstChunkAt *time.Time `json:"first_chunk_at,omitempty"`
+	DoneAt       time.Time  `json:"done_at"`
+	FirstChunkMs *int64     `json:"first_chunk_ms,omitempty"`
+	DurationMs   int64      `json:"duration_ms"`
+}
+
+type auditUsage struct {
+	InputTokens     int64 `json:"input_tokens"`
+	OutputTokens    int64 `json:"output_tokens"`
+	ReasoningTokens int64 `json:"reasoning_tokens"`
+	CachedTokens    int64 `json:"cached_tokens"`
+	TotalTokens     int64 `json:"total_tokens"`
+}
+
+// NewJSONLRequestLogger creates an audit logger. Relative paths in cfg resolve against logsDir.
+func NewJSONLRequestLogger(cfg config.AuditLogConfig, logsDir string) *JSONLRequestLogger {
+	l := &JSONLRequestLogger{logsDir: logsDir, pending: make(map[string]pendingAnnotation)}
+	l.Configure(cfg)
+	return l
+}
+
+// Configure applies new settings, reopening the output file when its location or rotation changes.
+func (l *JSONLRequestLogger) Configure(cfg config.AuditLogConfig) {
+	redactHeaders := make(map[string]struct{})
+	for _, name := range append(append([]string(nil), alwaysRedactedHeaders...), cfg.RedactHeaders...) {
+		if name = strings.TrimSpace(name); name != "" {
+			redactHeaders[http.CanonicalHeaderKey(name)] = struct{}{}
+		}
+	}
+	redactPaths := make([][]string, 0, len(cfg.RedactJSONPaths))
+	for _, path := range cfg.RedactJSONPaths {
+		if path = strings.TrimSpace(path); path != "" {
+			redactPaths = append(redactPaths, strings.Split(path, "."))
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous := l.settings
+	l.settings = cfg
+	l.redactHeaders = redactHeaders
+	l.redactPaths = redactPaths
+	if l.writer != nil && (!cfg.Enable || previous.Path != cfg.Path || previous.MaxSizeMB != cfg.MaxSizeMB ||
+		previous.MaxBackups != cfg.MaxBackups || previous.MaxAgeDays != cfg.MaxAgeDays || previous.Compress != cfg.Compress) {
+		_ = l.writer.Close()
+		l.writer = nil
+	}
+}
+
+// IsEnabled reports whether audit logging is enabled.
+func (l *JSONLRequestLogger) IsEnabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.settings.Enable
+}
+
+// Close flushes and closes the output file.
+func (l *JSONLRequestLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer == nil {
+		return nil
+	}
+	err := l.writer.Close()
+	l.writer = nil
+	return err
+}
+
+// AnnotateRequest implements RequestAnnotator.
+func (l *JSONLRequestLogger) AnnotateRequest(requestID string, annotation RequestAnnotation) {
+	if requestID == "" || !l.IsEnabled() {
+		return
+	}
+	now := time.Now()
+	l.pendingMu.Lock()
+	defer l.pendingMu.Unlock()
+	for id, entry := range l.pending {
+		if now.Sub(entry.storedAt) > auditAnnotationTTL {
+			delete(l.pending, id)
+		}
+	}
+	l.pending[requestID] = pendingAnnotation{annotation: annotation, storedAt: now}
+}
+
+func (l *JSONLRequestLogger) takeAnnotation(requestID string) RequestAnnotation {
+	if requestID == "" {
+		return RequestAnnotation{}
+	}
+	l.pendingMu.Lock()
+	defer l.pendingMu.Unlock()
+	entry := l.pending[requestID]
+	delete(l.pending, requestID)
+	return entry.annotation
+}
+
+// LogRequest implements RequestLogger.
+func (l *JSONLRequestLogger) LogRequest(url, method string, requestHeaders map[string][]string, body []byte, statusCode int, responseHeaders map[string][]string, response, apiRequest, apiResponse []byte, apiResponseErrors []*interfaces.ErrorMessage, requestID string, requestTimestamp, apiResponseTimestamp time.Time) error {
+	if !l.IsEnabled() {
+		return nil
+	}
+	entry := l.newEntry(url, method, requestHeaders, body, requestID, requestTimestamp)
+	entry.Status = statusCode
+	entry.ResponseHeaders = l.redactHeaderMap(responseHeaders)
+	entry.ResponseBody = l.encodeBody(response)
+	for _, apiErr := range apiResponseErrors {
+		if apiErr != nil && apiErr.Error != nil {
+			entry.Errors = append(entry.Errors, apiErr.Error.Error())
+		}
+	}
+	entry.finish(apiResponseTimestamp, time.Now())
+	return l.write(entry)
+}
+
+// LogStreamingRequest implements RequestLogger. The entry is written when the writer is closed.
+func (l *JSONLRequestLogger) LogStreamingRequest(url, method string, headers map[string][]string, body []byte, requestID string) (StreamingLogWriter, error) {
+	if !l.IsEnabled() {
+		return &NoOpStreamingLogWriter{}, nil
+	}
+	entry := l.newEntry(url, method, headers, body, requestID, time.Time{})
+	entry.Stream = true
+	l.mu.Lock()
+	includeBodies, limit := l.settings.IncludeBodies, l.maxBodyBytesLocked()
+	l.mu.Unlock()
+	return &jsonlStreamingLogWriter{logger: l, entry: entry, captureBody: includeBodies, limit: limit}, nil
+}
+
+func (l *JSONLRequestLogger) newEntry(url, method string, headers map[string][]string, body []byte, requestID string, requestTimestamp time.Time) *auditEntry {
+	if requestTimestamp.IsZero() {
+		requestTimestamp = time.Now()
+	}
+	return &auditEntry{
+		Timestamp:      requestTimestamp,
+		RequestID:      requestID,
+		Method:         method,
+		URL:            url,
+		Timings:        auditTimings{RequestAt: requestTimestamp},
+		RequestHeaders: l.redactHeaderMap(headers),
+		RequestBody:    l.encodeBody(body),
+	}
+}
+
+// finish fills the timing fields of an entry.
+func (e *auditEntry) finish(firstChunk, done time.Time) {
+	e.Timings.DoneAt = done
+	e.Timings.DurationMs = done.Sub(e.Timings.RequestAt).Milliseconds()
+	if !firstChunk.IsZero() {
+		firstChunkMs := firstChunk.Sub(e.Timings.RequestAt).Milliseconds()
+		e.Timings.FirstChunkAt = &firstChunk
+		e.Timings.FirstChunkMs = &firstChunkMs
+	}
+}
+
+// applyAnnotation copies request metadata into the entry. Token usage is summed across
+// attempts; the provider and credential come from the last successful attempt.
+func (e *auditEntry) applyAnnotation(annotation RequestAnnotation) {
+	if annotation.Principal != "" {
+		e.Principal = util.HideAPIKey(annotation.Principal)
+	}
+	e.AccessProvider = annotation.AccessProvider
+	e.Notes = annotation.Notes
+	if len(annotation.Usage) == 0 {
+		return
+	}
+	e.Attempts = len(annotation.Usage)
+	served := annotation.Usage[len(annotation.Usage)-1]
+	usage := &auditUsage{}
+	for _, record := range annotation.Usage {
+		if !record.Failed {
+			served = record
+		}
+		usage.InputTokens += record.Detail.InputTokens
+		usage.OutputTokens += record.Detail.OutputTokens
+		usage.ReasoningTokens += record.Detail.ReasoningTokens
+		usage.CachedTokens += record.Detail.CachedTokens
+		usage.TotalTokens += record.Detail.TotalTokens
+	}
+	e.Provider = served.Provider
+	e.Model = served.Model
+	e.AuthID = served.AuthID
+	e.AuthIndex = served.AuthIndex
+	e.Usage = usage
+}
+
+func (l *JSONLRequestLogger) write(entry *auditEntry) error {
+	entry.applyAnnotation(l.takeAnnotation(entry.RequestID))
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("audit log: failed to encode entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.settings.Enable {
+		return nil
+	}
+	if l.writer == nil {
+		path := strings.TrimSpace(l.settings.Path)
+		if path == "" {
+			path = defaultAuditLogFile
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(l.logsDir, path)
+		}
+		if errMkdir := os.MkdirAll(filepath.Dir(path), 0o755); errMkdir != nil {
+			return fmt.Errorf("audit log: failed to create directory: %w", errMkdir)
+		}
+		maxSize := l.settings.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = defaultAuditMaxSizeMB
+		}
+		l.writer = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSize,
+			MaxBackups: l.settings.MaxBackups,
+			MaxAge:     l.settings.MaxAgeDays,
+			Compress:   l.settings.Compress,
+		}
+	}
+	if _, errWrite := l.writer.Write(line); errWrite != nil {
+		return fmt.Errorf("audit log: failed to write entry: %w", errWrite)
+	}
+	return nil
+}
+
+func (l *JSONLRequestLogger) maxBodyBytesLocked() int {
+	if l.settings.MaxBodyBytes > 0 {
+		return l.settings.MaxBodyBytes
+	}
+	return defaultAuditMaxBodyBytes
+}
+
+// redactHeaderMap masks credentials and replaces configured headers entirely.
+func (l *JSONLRequestLogger) redactHeaderMap(headers map[string][]string) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	redact := l.redactHeaders
+	l.mu.Unlock()
+	out := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		masked := make([]string, len(values))
+		_, fullyRedacted := redact[http.CanonicalHeaderKey(key)]
+		for i, value := range values {
+			if fullyRedacted {
+				masked[i] = auditRedacted
+			} else {
+				masked[i] = util.MaskSensitiveHeaderValue(key, value)
+			}
+		}
+		out[key] = masked
+	}
+	return out
+}
+
+// encodeBody renders a body for the audit entry: redacted JSON when the body is JSON,
+// SSE payloads with each JSON data line redacted, and a plain string otherwise.
+func (l *JSONLRequestLogger) encodeBody(body []byte) json.RawMessage {
+	l.mu.Lock()
+	include, limit, paths := l.settings.IncludeBodies, l.maxBodyBytesLocked(), l.redactPaths
+	l.mu.Unlock()
+	body = bytes.TrimSpace(body)
+	if !include || len(body) == 0 {
+		return nil
+	}
+	if len(body) <= limit && json.Valid(body) {
+		return redactJSON(body, paths)
+	}
+
+	text := body
+	if bytes.Contains(text, []byte("data:")) {
+		text = redactSSE(text, paths)
+	}
+	truncated := false
+	if len(text) > limit {
+		text = text[:limit]
+		truncated = true
+	}
+	value := string(text)
+	if truncated {
+		value += "...[truncated]"
+	}
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
+
+func redactJSON(body []byte, paths [][]string) json.RawMessage {
+	if len(paths) == 0 {
+		return json.RawMessage(bytes.Clone(body))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return json.RawMessage(bytes.Clone(body))
+	}
+	for _, path := range paths {
+		value = redactPath(value, path)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return json.RawMessage(bytes.Clone(body))
+	}
+	return encoded
+}
+
+func redactSSE(body []byte, paths [][]string) []byte {
+	if len(paths) == 0 {
+		return body
+	}
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if !json.Valid(payload) {
+			continue
+		}
+		lines[i] = append([]byte("data: "), redactJSON(payload, paths)...)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// redactPath replaces the value at path with a redaction marker. "*" matches every element.
+func redactPath(value any, path []string) any {
+	if len(path) == 0 {
+		return auditRedacted
+	}
+	segment, rest := path[0], path[1:]
+	switch node := value.(type) {
+	case map[string]any:
+		if segment == "*" {
+			for key, child := range node {
+				node[key] = redactPath(child, rest)
+			}
+		} else if child, ok := node[segment]; ok {
+			node[segment] = redactPath(child, rest)
+		}
+	case []any:
+		for i, child := range node {
+			if segment == "*" || segment == fmt.Sprint(i) {
+				node[i] = redactPath(child, rest)
+			}
+		}
+	}
+	return value
+}
+
+// jsonlStreamingLogWriter buffers a streaming response and writes its audit entry on Close.
+type jsonlStreamingLogWriter struct {
+	logger      *JSONLRequestLogger
+	entry       *auditEntry
+	captureBody bool
+	limit       int
+
+	mu         sync.Mutex
+	body       bytes.Buffer
+	overflow   bool
+	firstChunk time.Time
+}
+
+// WriteChunkAsync implements StreamingLogWriter.
+func (w *jsonlStreamingLogWriter) WriteChunkAsync(chunk []byte) {
+	if !w.captureBody {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.overflow {
+		return
+	}
+	// Keep one byte past the limit so encodeBody marks the body as truncated.
+	if remaining := w.limit + 1 - w.body.Len(); len(chunk) > remaining {
+		chunk = chunk[:remaining]
+		w.overflow = true
+	}
+	w.body.Write(chunk)
+}
+
+// WriteStatus implements StreamingLogWriter.
+func (w *jsonlStreamingLogWriter) WriteStatus(status int, headers map[string][]string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entry.Status = status
+	w.entry.ResponseHeaders = w.logger.redactHeaderMap(headers)
+	return nil
+}
+
+// WriteAPIRequest implements StreamingLogWriter. Upstream dumps are not part of the audit log.
+func (w *jsonlStreamingLogWriter) WriteAPIRequest(_ []byte) error { return nil }
+
+// WriteAPIResponse implements StreamingLogWriter. Upstream dumps are not part of the audit log.
+func (w *jsonlStreamingLogWriter) WriteAPIResponse(_ []byte) error { return nil }
+
+// SetFirstChunkTimestamp implements StreamingLogWriter.
+func (w *jsonlStreamingLogWriter) SetFirstChunkTimestamp(timestamp time.Time) {
+	w.mu.Lock()
+	w.firstChunk = timestamp
+	w.mu.Unlock()
+}
+
+// Close implements StreamingLogWriter and writes the audit entry.
+func (w *jsonlStreamingLogWriter) Close() error {
+	w.mu.Lock()
+	entry := w.entry
+	entry.ResponseBody = w.logger.encodeBody(w.body.Bytes())
+	entry.finish(w.firstChunk, time.Now())
+	w.mu.Unlock()
+	return w.logger.write(entry)
+}
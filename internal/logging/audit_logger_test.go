@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func readAuditEntries(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var entries []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("invalid JSONL line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONLRequestLogger_WritesRedactedAnnotatedEntries(t *testing.T) {
+	dir := t.TempDir()
+	logger := NewJSONLRequestLogger(config.AuditLogConfig{
+		Enable:          true,
+		IncludeBodies:   true,
+		RedactHeaders:   []string{"X-Forwarded-For"},
+		RedactJSONPaths: []string{"messages.*.content"},
+	}, dir)
+	t.Cleanup(func() { _ = logger.Close() })
+
+	logger.AnnotateRequest("req-1", RequestAnnotation{
+		Principal: "sk-client-secret-key",
+		Usage: []coreusage.Record{
+			{Provider: "claude", Model: "m", AuthID: "a1", AuthIndex: "1", Failed: true},
+			{Provider: "gemini", Model: "m", AuthID: "a2", AuthIndex: "2", Detail: coreusage.Detail{InputTokens: 3, OutputTokens: 4, TotalTokens: 7}},
+		},
+	})
+	start := time.Now().Add(-time.Second)
+	headers := map[string][]string{
+		"Authorization":   {"Bearer sk-client-secret-key"},
+		"X-Forwarded-For": {"10.0.0.1"},
+	}
+	body := []byte(`{"model":"m","messages":[{"role":"user","content":"secret prompt"}]}`)
+	if err := logger.LogRequest("/v1/chat/completions", "POST", headers, body, 200, nil, []byte(`{"ok":true}`), nil, nil, nil, "req-1", start, start.Add(100*time.Millisecond)); err != nil {
+		t.Fatalf("LogRequest: %v", err)
+	}
+
+	stream, err := logger.LogStreamingRequest("/v1/messages", "POST", nil, []byte(`{"stream":true}`), "req-2")
+	if err != nil {
+		t.Fatalf("LogStreamingRequest: %v", err)
+	}
+	_ = stream.WriteStatus(200, map[string][]string{"Content-Type": {"text/event-stream"}})
+	stream.WriteChunkAsync([]byte("data: {\"messages\":[{\"content\":\"hidden\"}]}\n\n"))
+	stream.SetFirstChunkTimestamp(time.Now())
+	if err = stream.Close(); err != nil {
+		t.Fatalf("stream Close: %v", err)
+	}
+
+	entries := readAuditEntries(t, filepath.Join(dir, defaultAuditLogFile))
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(entries))
+	}
+	first := entries[0]
+	if first["provider"] != "gemini" || first["auth_index"] != "2" || first["attempts"] != float64(2) {
+		t.Fatalf("annotation not applied: %v", first)
+	}
+	if first["principal"] == "sk-client-secret-key" {
+		t.Fatal("principal must be masked")
+	}
+	if usage := first["usage"].(map[string]any); usage["total_tokens"] != float64(7) {
+		t.Fatalf("usage = %v", usage)
+	}
+	reqHeaders := first["request_headers"].(map[string]any)
+	if got := reqHeaders["X-Forwarded-For"].([]any)[0]; got != auditRedacted {
+		t.Fatalf("X-Forwarded-For = %v, want redacted", got)
+	}
+	if got := reqHeaders["Authorization"].([]any)[0]; got == "Bearer sk-client-secret-key" {
+		t.Fatal("Authorization must be masked")
+	}
+	content := first["request_body"].(map[string]any)["messages"].([]any)[0].(map[string]any)["content"]
+	if content != auditRedacted {
+		t.Fatalf("message content = %v, want redacted", content)
+	}
+	timings := first["timings"].(map[string]any)
+	if timings["first_chunk_ms"] != float64(100) {
+		t.Fatalf("timings = %v", timings)
+	}
+
+	second := entries[1]
+	if second["stream"] != true || second["status"] != float64(200) {
+		t.Fatalf("stream entry = %v", second)
+	}
+	if responseBody, _ := second["response_body"].(string); bytes.Contains([]byte(responseBody), []byte("hidden")) {
+		t.Fatalf("stream body not redacted: %q", responseBody)
+	}
+}
@@ -83,6 +83,9 @@ func GinLogrusLogger() gin.HandlerFunc {
 		}
 
 		entry := log.WithField("request_id", requestID)
+		if traceID := GetTraceID(c.Request.Context()); traceID != "" {
+			entry = entry.WithField("trace_id", traceID)
+		}
 
 		switch {
 		case statusCode >= http.StatusInternalServerError:
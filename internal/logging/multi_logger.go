@@ -0,0 +1,170 @@
+package logging
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+)
+
+// MultiRequestLogger fans request logging out to several RequestLogger sinks.
+// The first logger is the primary one: SetEnabled and SetErrorLogsMaxFiles apply to it only.
+type MultiRequestLogger struct {
+	loggers []RequestLogger
+}
+
+// NewMultiRequestLogger combines the non-nil loggers. It returns nil when none remain and the
+// logger itself when only one remains.
+func NewMultiRequestLogger(loggers ...RequestLogger) RequestLogger {
+	filtered := make([]RequestLogger, 0, len(loggers))
+	for _, logger := range loggers {
+		if logger != nil {
+			filtered = append(filtered, logger)
+		}
+	}
+	switch len(filtered) {
+	case 0:
+		return nil
+	case 1:
+		return filtered[0]
+	default:
+		return &MultiRequestLogger{loggers: filtered}
+	}
+}
+
+// IsEnabled reports whether any sink is enabled.
+func (m *MultiRequestLogger) IsEnabled() bool {
+	for _, logger := range m.loggers {
+		if logger.IsEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEnabled toggles the primary logger.
+func (m *MultiRequestLogger) SetEnabled(enabled bool) {
+	if setter, ok := m.loggers[0].(interface{ SetEnabled(bool) }); ok {
+		setter.SetEnabled(enabled)
+	}
+}
+
+// SetErrorLogsMaxFiles updates the primary logger's error log retention.
+func (m *MultiRequestLogger) SetErrorLogsMaxFiles(maxFiles int) {
+	if setter, ok := m.loggers[0].(interface{ SetErrorLogsMaxFiles(int) }); ok {
+		setter.SetErrorLogsMaxFiles(maxFiles)
+	}
+}
+
+// AnnotateRequest forwards request metadata to every sink that accepts it.
+func (m *MultiRequestLogger) AnnotateRequest(requestID string, annotation RequestAnnotation) {
+	for _, logger := range m.loggers {
+		if annotator, ok := logger.(RequestAnnotator); ok {
+			annotator.AnnotateRequest(requestID, annotation)
+		}
+	}
+}
+
+// LogRequest implements RequestLogger.
+func (m *MultiRequestLogger) LogRequest(url, method string, requestHeaders map[string][]string, body []byte, statusCode int, responseHeaders map[string][]string, response, apiRequest, apiResponse []byte, apiResponseErrors []*interfaces.ErrorMessage, requestID string, requestTimestamp, apiResponseTimestamp time.Time) error {
+	return m.LogRequestWithOptions(url, method, requestHeaders, body, statusCode, responseHeaders, response, apiRequest, apiResponse, apiResponseErrors, false, requestID, requestTimestamp, apiResponseTimestamp)
+}
+
+// LogRequestWithOptions forwards forced error logging to sinks that support it.
+// Because another sink may keep the combined logger enabled, a disabled sink is forced for
+// error responses exactly as the middleware would force it when used on its own.
+func (m *MultiRequestLogger) LogRequestWithOptions(url, method string, requestHeaders map[string][]string, body []byte, statusCode int, responseHeaders map[string][]string, response, apiRequest, apiResponse []byte, apiResponseErrors []*interfaces.ErrorMessage, force bool, requestID string, requestTimestamp, apiResponseTimestamp time.Time) error {
+	hasAPIError := len(apiResponseErrors) > 0 || statusCode >= http.StatusBadRequest
+	var errs []error
+	for _, logger := range m.loggers {
+		if withOptions, ok := logger.(interface {
+			LogRequestWithOptions(string, string, map[string][]string, []byte, int, map[string][]string, []byte, []byte, []byte, []*interfaces.ErrorMessage, bool, string, time.Time, time.Time) error
+		}); ok {
+			forceLogger := force || (hasAPIError && !logger.IsEnabled())
+			errs = append(errs, withOptions.LogRequestWithOptions(url, method, requestHeaders, body, statusCode, responseHeaders, response, apiRequest, apiResponse, apiResponseErrors, forceLogger, requestID, requestTimestamp, apiResponseTimestamp))
+			continue
+		}
+		if !logger.IsEnabled() {
+			continue
+		}
+		errs = append(errs, logger.LogRequest(url, method, requestHeaders, body, statusCode, responseHeaders, response, apiRequest, apiResponse, apiResponseErrors, requestID, requestTimestamp, apiResponseTimestamp))
+	}
+	return errors.Join(errs...)
+}
+
+// LogStreamingRequest opens a streaming writer on every enabled sink.
+func (m *MultiRequestLogger) LogStreamingRequest(url, method string, headers map[string][]string, body []byte, requestID string) (StreamingLogWriter, error) {
+	writers := make(multiStreamingLogWriter, 0, len(m.loggers))
+	var errs []error
+	for _, logger := range m.loggers {
+		if !logger.IsEnabled() {
+			continue
+		}
+		writer, err := logger.LogStreamingRequest(url, method, headers, body, requestID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if writer != nil {
+			writers = append(writers, writer)
+		}
+	}
+	switch {
+	case len(writers) == 0 && len(errs) > 0:
+		return nil, errors.Join(errs...)
+	case len(writers) == 0:
+		return &NoOpStreamingLogWriter{}, nil
+	case len(writers) == 1:
+		return writers[0], nil
+	default:
+		return writers, nil
+	}
+}
+
+// multiStreamingLogWriter fans streaming log calls out to several writers.
+type multiStreamingLogWriter []StreamingLogWriter
+
+func (w multiStreamingLogWriter) WriteChunkAsync(chunk []byte) {
+	for _, writer := range w {
+		writer.WriteChunkAsync(chunk)
+	}
+}
+
+func (w multiStreamingLogWriter) WriteStatus(status int, headers map[string][]string) error {
+	var errs []error
+	for _, writer := range w {
+		errs = append(errs, writer.WriteStatus(status, headers))
+	}
+	return errors.Join(errs...)
+}
+
+func (w multiStreamingLogWriter) WriteAPIRequest(apiRequest []byte) error {
+	var errs []error
+	for _, writer := range w {
+		errs = append(errs, writer.WriteAPIRequest(apiRequest))
+	}
+	return errors.Join(errs...)
+}
+
+func (w multiStreamingLogWriter) WriteAPIResponse(apiResponse []byte) error {
+	var errs []error
+	for _, writer := range w {
+		errs = append(errs, writer.WriteAPIResponse(apiResponse))
+	}
+	return errors.Join(errs...)
+}
+
+func (w multiStreamingLogWriter) SetFirstChunkTimestamp(timestamp time.Time) {
+	for _, writer := range w {
+		writer.SetFirstChunkTimestamp(timestamp)
+	}
+}
+
+func (w multiStreamingLogWriter) Close() error {
+	var errs []error
+	for _, writer := range w {
+		errs = append(errs, writer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // requestIDKey is the context key for storing/retrieving request IDs.
@@ -59,3 +60,15 @@ func GetGinRequestID(c *gin.Context) string {
 	}
 	return ""
 }
+
+// GetTraceID returns the OpenTelemetry trace ID of the span carried by ctx.
+// Returns empty string when tracing is disabled or no span is active.
+func GetTraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		return spanCtx.TraceID().String()
+	}
+	return ""
+}
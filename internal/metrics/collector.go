@@ -0,0 +1,295 @@
+// Package metrics exposes Prometheus text-format metrics for the CLI Proxy API server.
+// The collector observes the same usage records and auth execution results that the
+// statistics logger and webhook hook consume.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// ContentType is the media type of the exposition written by Collector.WriteTo.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// AuthSource provides the auth snapshots used for index lookups and state gauges.
+// *coreauth.Manager satisfies this interface.
+type AuthSource interface {
+	List() []*coreauth.Auth
+	GetByID(id string) (*coreauth.Auth, bool)
+}
+
+var defaultCollector = NewCollector()
+
+func init() {
+	coreusage.RegisterPlugin(defaultCollector)
+}
+
+// Default returns the process-wide collector registered as a usage plugin.
+func Default() *Collector { return defaultCollector }
+
+// Collector implements coreusage.Plugin and coreauth.Hook and renders the observed
+// events in the Prometheus text exposition format.
+type Collector struct {
+	enabled atomic.Bool
+
+	sourceMu sync.RWMutex
+	source   AuthSource
+
+	indexMu sync.RWMutex
+	indexes map[string]string
+
+	requests  *counterVec
+	tokens    *counterVec
+	attempts  *counterVec
+	retries   *counterVec
+	latency   *histogramVec
+	firstByte *histogramVec
+
+	now func() time.Time
+}
+
+// NewCollector constructs an empty, disabled collector.
+func NewCollector() *Collector {
+	return &Collector{
+		indexes: make(map[string]string),
+		requests: newCounterVec("cliproxy_requests_total",
+			"Requests completed per provider, model and auth index as reported by the usage pipeline.",
+			"provider", "model", "auth_index", "outcome"),
+		tokens: newCounterVec("cliproxy_tokens_total",
+			"Tokens consumed per provider, model and auth index by token type.",
+			"provider", "model", "auth_index", "type"),
+		attempts: newCounterVec("cliproxy_upstream_attempts_total",
+			"Upstream attempts per provider, model and auth index by HTTP status.",
+			"provider", "model", "auth_index", "status"),
+		retries: newCounterVec("cliproxy_upstream_retries_total",
+			"Upstream attempts that retried a request or failed over to another auth.",
+			"provider", "model"),
+		latency: newHistogramVec("cliproxy_upstream_request_duration_seconds",
+			"Upstream request duration in seconds; covers the whole stream for streaming requests.",
+			defaultBuckets, "provider", "model", "auth_index"),
+		firstByte: newHistogramVec("cliproxy_stream_first_byte_seconds",
+			"Time until the first chunk of a streaming upstream response in seconds.",
+			defaultBuckets, "provider", "model", "auth_index"),
+		now: time.Now,
+	}
+}
+
+// SetEnabled toggles whether events are recorded.
+func (c *Collector) SetEnabled(enabled bool) {
+	if c == nil {
+		return
+	}
+	c.enabled.Store(enabled)
+}
+
+// Enabled reports whether events are currently recorded.
+func (c *Collector) Enabled() bool { return c != nil && c.enabled.Load() }
+
+// SetAuthSource attaches the auth manager used for index lookups and state gauges.
+func (c *Collector) SetAuthSource(source AuthSource) {
+	if c == nil {
+		return
+	}
+	c.sourceMu.Lock()
+	c.source = source
+	c.sourceMu.Unlock()
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (c *Collector) HandleUsage(_ context.Context, record coreusage.Record) {
+	if !c.Enabled() {
+		return
+	}
+	provider := strings.TrimSpace(record.Provider)
+	model := strings.TrimSpace(record.Model)
+	authIndex := strings.TrimSpace(record.AuthIndex)
+	if authIndex == "" {
+		authIndex = c.authIndex(record.AuthID)
+	}
+	outcome := "success"
+	if record.Failed {
+		outcome = "failure"
+	}
+	c.requests.inc(provider, model, authIndex, outcome)
+
+	detail := record.Detail
+	c.tokens.add(float64(detail.InputTokens), provider, model, authIndex, "input")
+	c.tokens.add(float64(detail.OutputTokens), provider, model, authIndex, "output")
+	c.tokens.add(float64(detail.ReasoningTokens), provider, model, authIndex, "reasoning")
+	c.tokens.add(float64(detail.CachedTokens), provider, model, authIndex, "cached")
+}
+
+// OnAuthRegistered implements coreauth.Hook.
+func (c *Collector) OnAuthRegistered(_ context.Context, auth *coreauth.Auth) { c.rememberIndex(auth) }
+
+// OnAuthUpdated implements coreauth.Hook.
+func (c *Collector) OnAuthUpdated(_ context.Context, auth *coreauth.Auth) { c.rememberIndex(auth) }
+
+// OnResult implements coreauth.Hook.
+func (c *Collector) OnResult(_ context.Context, result coreauth.Result) {
+	if !c.Enabled() {
+		return
+	}
+	provider := strings.TrimSpace(result.Provider)
+	model := strings.TrimSpace(result.Model)
+	authIndex := c.authIndex(result.AuthID)
+
+	c.attempts.inc(provider, model, authIndex, resultStatus(result))
+	if result.Attempt > 0 {
+		c.retries.inc(provider, model)
+	}
+	if result.Latency > 0 {
+		c.latency.observe(result.Latency.Seconds(), provider, model, authIndex)
+	}
+	if result.FirstByte > 0 {
+		c.firstByte.observe(result.FirstByte.Seconds(), provider, model, authIndex)
+	}
+}
+
+// WriteTo renders all metric families in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if c != nil {
+		c.requests.write(&buf)
+		c.tokens.write(&buf)
+		c.attempts.write(&buf)
+		c.retries.write(&buf)
+		c.latency.write(&buf)
+		c.firstByte.write(&buf)
+		c.writeAuthGauges(&buf)
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// writeAuthGauges derives auth and model availability gauges from the current auth snapshots.
+func (c *Collector) writeAuthGauges(w io.Writer) {
+	c.sourceMu.RLock()
+	source := c.source
+	c.sourceMu.RUnlock()
+
+	authCounts := make(map[string]*gaugeSample)
+	modelCounts := make(map[string]*gaugeSample)
+	bump := func(counts map[string]*gaugeSample, labelValues ...string) {
+		key := seriesKey(labelValues)
+		sample, ok := counts[key]
+		if !ok {
+			sample = &gaugeSample{labelValues: labelValues}
+			counts[key] = sample
+		}
+		sample.value++
+	}
+
+	if source != nil {
+		now := c.now()
+		for _, auth := range source.List() {
+			if auth == nil {
+				continue
+			}
+			provider := strings.TrimSpace(auth.Provider)
+			bump(authCounts, provider, authState(auth, now))
+			if auth.Disabled || auth.Status == coreauth.StatusDisabled {
+				continue
+			}
+			for model, state := range auth.ModelStates {
+				if state == nil {
+					continue
+				}
+				if modelState := modelStateLabel(state, now); modelState != "" {
+					bump(modelCounts, provider, model, modelState)
+				}
+			}
+		}
+	}
+
+	writeGauge(w, "cliproxy_auths",
+		"Auths known to the conductor by provider and state (active, cooldown, unavailable, disabled).",
+		[]string{"provider", "state"}, collectSamples(authCounts))
+	writeGauge(w, "cliproxy_auth_model_states",
+		"Auths whose per-model state blocks a model, by provider, model and state (cooldown, unavailable).",
+		[]string{"provider", "model", "state"}, collectSamples(modelCounts))
+}
+
+func (c *Collector) rememberIndex(auth *coreauth.Auth) {
+	if c == nil || auth == nil || auth.ID == "" || auth.Index == "" {
+		return
+	}
+	c.indexMu.Lock()
+	c.indexes[auth.ID] = auth.Index
+	c.indexMu.Unlock()
+}
+
+func (c *Collector) authIndex(authID string) string {
+	if authID == "" {
+		return ""
+	}
+	c.indexMu.RLock()
+	index, ok := c.indexes[authID]
+	c.indexMu.RUnlock()
+	if ok {
+		return index
+	}
+	c.sourceMu.RLock()
+	source := c.source
+	c.sourceMu.RUnlock()
+	if source == nil {
+		return ""
+	}
+	auth, found := source.GetByID(authID)
+	if !found {
+		return ""
+	}
+	c.rememberIndex(auth)
+	return auth.Index
+}
+
+func authState(auth *coreauth.Auth, now time.Time) string {
+	switch {
+	case auth.Disabled || auth.Status == coreauth.StatusDisabled:
+		return "disabled"
+	case auth.NextRetryAfter.After(now):
+		return "cooldown"
+	case auth.Unavailable:
+		return "unavailable"
+	default:
+		return "active"
+	}
+}
+
+func modelStateLabel(state *coreauth.ModelState, now time.Time) string {
+	switch {
+	case state.NextRetryAfter.After(now):
+		return "cooldown"
+	case state.Unavailable:
+		return "unavailable"
+	default:
+		return ""
+	}
+}
+
+func resultStatus(result coreauth.Result) string {
+	if result.Success {
+		return "ok"
+	}
+	if result.Error != nil && result.Error.HTTPStatus > 0 {
+		return strconv.Itoa(result.Error.HTTPStatus)
+	}
+	return "error"
+}
+
+func collectSamples(counts map[string]*gaugeSample) []gaugeSample {
+	samples := make([]gaugeSample, 0, len(counts))
+	for _, sample := range counts {
+		samples = append(samples, *sample)
+	}
+	return samples
+}
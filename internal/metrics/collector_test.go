@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+type staticAuthSource []*coreauth.Auth
+
+func (s staticAuthSource) List() []*coreauth.Auth { return s }
+
+func (s staticAuthSource) GetByID(id string) (*coreauth.Auth, bool) {
+	for _, auth := range s {
+		if auth.ID == id {
+			return auth, true
+		}
+	}
+	return nil, false
+}
+
+func TestCollectorExposition(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.now = func() time.Time { return now }
+	c.SetEnabled(true)
+	c.SetAuthSource(staticAuthSource{
+		{ID: "a1", Index: "idx1", Provider: "codex", ModelStates: map[string]*coreauth.ModelState{
+			"gpt-5": {Unavailable: true, NextRetryAfter: now.Add(time.Minute)},
+		}},
+		{ID: "a2", Index: "idx2", Provider: "codex", Disabled: true},
+		{ID: "a3", Index: "idx3", Provider: "claude", NextRetryAfter: now.Add(time.Hour)},
+	})
+
+	ctx := context.Background()
+	c.HandleUsage(ctx, coreusage.Record{
+		Provider: "codex", Model: "gpt-5", AuthID: "a1",
+		Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 5, CachedTokens: 2},
+	})
+	c.OnResult(ctx, coreauth.Result{
+		AuthID: "a1", Provider: "codex", Model: "gpt-5",
+		Error: &coreauth.Error{HTTPStatus: 429}, Latency: 300 * time.Millisecond,
+	})
+	c.OnResult(ctx, coreauth.Result{
+		AuthID: "a1", Provider: "codex", Model: "gpt-5", Success: true, Attempt: 1,
+		Latency: 2 * time.Second, FirstByte: 400 * time.Millisecond,
+	})
+
+	var out strings.Builder
+	if _, err := c.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	body := out.String()
+	for _, want := range []string{
+		`cliproxy_requests_total{provider="codex",model="gpt-5",auth_index="idx1",outcome="success"} 1`,
+		`cliproxy_tokens_total{provider="codex",model="gpt-5",auth_index="idx1",type="input"} 10`,
+		`cliproxy_tokens_total{provider="codex",model="gpt-5",auth_index="idx1",type="cached"} 2`,
+		`cliproxy_upstream_attempts_total{provider="codex",model="gpt-5",auth_index="idx1",status="429"} 1`,
+		`cliproxy_upstream_attempts_total{provider="codex",model="gpt-5",auth_index="idx1",status="ok"} 1`,
+		`cliproxy_upstream_retries_total{provider="codex",model="gpt-5"} 1`,
+		`cliproxy_upstream_request_duration_seconds_bucket{provider="codex",model="gpt-5",auth_index="idx1",le="0.5"} 1`,
+		`cliproxy_upstream_request_duration_seconds_count{provider="codex",model="gpt-5",auth_index="idx1"} 2`,
+		`cliproxy_stream_first_byte_seconds_bucket{provider="codex",model="gpt-5",auth_index="idx1",le="+Inf"} 1`,
+		`cliproxy_auths{provider="codex",state="disabled"} 1`,
+		`cliproxy_auths{provider="claude",state="cooldown"} 1`,
+		`cliproxy_auth_model_states{provider="codex",model="gpt-5",state="cooldown"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition missing %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `type="reasoning"`) {
+		t.Errorf("zero-valued token series should be omitted\n%s", body)
+	}
+}
+
+func TestCollectorDisabledRecordsNothing(t *testing.T) {
+	c := NewCollector()
+	c.HandleUsage(context.Background(), coreusage.Record{Provider: "codex", Model: "gpt-5"})
+	c.OnResult(context.Background(), coreauth.Result{Provider: "codex", Model: "gpt-5", Success: true})
+
+	var out strings.Builder
+	_, _ = c.WriteTo(&out)
+	if strings.Contains(out.String(), `provider="codex"`) {
+		t.Fatalf("disabled collector recorded samples:\n%s", out.String())
+	}
+}
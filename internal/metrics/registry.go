@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultBuckets are the latency histogram upper bounds in seconds.
+var defaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+// counterVec is a labelled monotonically increasing counter family.
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]*counterSeries)}
+}
+
+func (c *counterVec) add(delta float64, labelValues ...string) {
+	if c == nil || delta <= 0 {
+		return
+	}
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	series, ok := c.values[key]
+	if !ok {
+		series = &counterSeries{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = series
+	}
+	series.value += delta
+	c.mu.Unlock()
+}
+
+func (c *counterVec) inc(labelValues ...string) { c.add(1, labelValues...) }
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		series := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, series.labelValues), formatFloat(series.value))
+	}
+}
+
+// histogramVec is a labelled cumulative histogram family.
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramSeries)}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	if h == nil || value < 0 || math.IsNaN(value) {
+		return
+	}
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	series, ok := h.values[key]
+	if !ok {
+		series = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+	h.mu.Unlock()
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		series := h.values[key]
+		for i, upper := range h.buckets {
+			values := append(append([]string(nil), series.labelValues...), formatFloat(upper))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), series.counts[i])
+		}
+		values := append(append([]string(nil), series.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, series.labelValues), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, series.labelValues), series.count)
+	}
+}
+
+// gaugeSample is a single point of a gauge family computed at scrape time.
+type gaugeSample struct {
+	labelValues []string
+	value       float64
+}
+
+func writeGauge(w io.Writer, name, help string, labels []string, samples []gaugeSample) {
+	writeHeader(w, name, help, "gauge")
+	sort.Slice(samples, func(i, j int) bool {
+		return seriesKey(samples[i].labelValues) < seriesKey(samples[j].labelValues)
+	})
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labels, sample.labelValues), formatFloat(sample.value))
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -241,6 +241,21 @@ func GetGeminiModels() []*ModelInfo {
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true, Levels: []string{"low", "high"}},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752019200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents", "countTokens"},
+			SupportedEndpoints:         []string{"/embeddings"},
+			Kind:                       ModelKindEmbedding,
+		},
 	}
 }
 
@@ -412,6 +427,51 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Imagen 4.0 fast image generation model",
 			SupportedGenerationMethods: []string{"predict"},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752019200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"predict"},
+			SupportedEndpoints:         []string{"/embeddings"},
+			Kind:                       ModelKindEmbedding,
+		},
+		{
+			ID:                         "text-embedding-005",
+			Object:                     "model",
+			Created:                    1752019200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-005",
+			Version:                    "005",
+			DisplayName:                "Text Embedding 005",
+			Description:                "Text embedding model for English and code.",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"predict"},
+			SupportedEndpoints:         []string{"/embeddings"},
+			Kind:                       ModelKindEmbedding,
+		},
+		{
+			ID:                         "text-multilingual-embedding-002",
+			Object:                     "model",
+			Created:                    1752019200,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-multilingual-embedding-002",
+			Version:                    "002",
+			DisplayName:                "Text Multilingual Embedding 002",
+			Description:                "Multilingual text embedding model.",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"predict"},
+			SupportedEndpoints:         []string{"/embeddings"},
+			Kind:                       ModelKindEmbedding,
+		},
 	}
 }
 
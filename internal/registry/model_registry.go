@@ -15,6 +15,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// ModelKindEmbedding marks models served by the embeddings endpoints.
+const ModelKindEmbedding = "embedding"
+
 // ModelInfo represents information about an available model
 type ModelInfo struct {
 	// ID is the unique identifier for the model
@@ -49,6 +52,11 @@ type ModelInfo struct {
 	SupportedParameters []string `json:"supported_parameters,omitempty"`
 	// SupportedEndpoints lists supported API endpoints (e.g., "/chat/completions", "/responses").
 	SupportedEndpoints []string `json:"supported_endpoints,omitempty"`
+	// Kind distinguishes non-chat models such as ModelKindEmbedding. Empty means a chat model.
+	Kind string `json:"kind,omitempty"`
+	// Tokenizer selects the local tokenizer used to count prompt tokens (e.g., "o200k_base",
+	// "cl100k_base", "claude", "gemini"). Empty infers one from the model ID.
+	Tokenizer string `json:"tokenizer,omitempty"`
 
 	// Thinking holds provider-specific reasoning/thinking budget capabilities.
 	// This is optional and currently used for Gemini thinking budget normalization.
@@ -1031,6 +1039,9 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if len(model.SupportedEndpoints) > 0 {
 			result["supported_endpoints"] = model.SupportedEndpoints
 		}
+		if model.Kind != "" {
+			result["kind"] = model.Kind
+		}
 		return result
 
 	case "claude", "kiro", "antigravity":
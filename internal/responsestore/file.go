@@ -0,0 +1,137 @@
+package responsestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileBackend stores one JSON file per response in a directory.
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend prepares dir (creating it when missing) for storing responses.
+func NewFileBackend(dir string) (*FileBackend, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("response store: file directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("response store: create directory: %w", err)
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+// path maps a response ID to its file, rejecting IDs that could escape the directory.
+func (b *FileBackend) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", ErrNotFound
+	}
+	return filepath.Join(b.dir, id+".json"), nil
+}
+
+// Put implements Backend.
+func (b *FileBackend) Put(_ context.Context, record *Record) error {
+	path, err := b.path(record.ID)
+	if err != nil {
+		return fmt.Errorf("response store: invalid response id %q", record.ID)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("response store: encode response: %w", err)
+	}
+	tmp, err := os.CreateTemp(b.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("response store: create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("response store: write response: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("response store: write response: %w", err)
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("response store: write response: %w", err)
+	}
+	return nil
+}
+
+// Get implements Backend.
+func (b *FileBackend) Get(_ context.Context, id string) (*Record, error) {
+	path, err := b.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return readRecordFile(path)
+}
+
+// Delete implements Backend.
+func (b *FileBackend) Delete(_ context.Context, id string) error {
+	path, err := b.path(id)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("response store: delete response: %w", err)
+	}
+	return nil
+}
+
+// Prune implements Backend.
+func (b *FileBackend) Prune(ctx context.Context, before time.Time) (int64, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return 0, fmt.Errorf("response store: list directory: %w", err)
+	}
+	var removed int64
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return removed, ctx.Err()
+		}
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		path := filepath.Join(b.dir, name)
+		record, errRead := readRecordFile(path)
+		if errRead != nil || record.ExpiresAt.IsZero() || !record.ExpiresAt.Before(before) {
+			continue
+		}
+		if errRemove := os.Remove(path); errRemove == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Close implements Backend.
+func (b *FileBackend) Close() error { return nil }
+
+func readRecordFile(path string) (*Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("response store: read response: %w", err)
+	}
+	var record Record
+	if err = json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("response store: decode response: %w", err)
+	}
+	return &record, nil
+}
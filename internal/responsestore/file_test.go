@@ -0,0 +1,45 @@
+package responsestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileBackendRoundTripAndPrune(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileBackend: %v", err)
+	}
+	now := time.Now().UTC()
+	live := &Record{ID: "resp_live", Owner: "o", Input: []byte(`[]`), Response: []byte(`{"id":"resp_live","output":[{"type":"message"}]}`), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	expired := &Record{ID: "resp_old", Input: []byte(`[]`), Response: []byte(`{}`), CreatedAt: now, ExpiresAt: now.Add(-time.Minute)}
+	for _, record := range []*Record{live, expired} {
+		if err = backend.Put(ctx, record); err != nil {
+			t.Fatalf("Put %s: %v", record.ID, err)
+		}
+	}
+	if err = backend.Put(ctx, &Record{ID: "../escape"}); err == nil {
+		t.Fatalf("Put should reject ids containing path separators")
+	}
+
+	got, err := backend.Get(ctx, "resp_live")
+	if err != nil || got.Owner != "o" || string(got.Output()) != `[{"type":"message"}]` {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	removed, err := backend.Prune(ctx, now)
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune = %d, %v; want 1", removed, err)
+	}
+	if _, err = backend.Get(ctx, "resp_old"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired record should be pruned, got %v", err)
+	}
+	if err = backend.Delete(ctx, "resp_live"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err = backend.Delete(ctx, "resp_live"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,96 @@
+package responsestore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps records in process memory, evicting the oldest beyond maxEntries.
+type MemoryBackend struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+// NewMemoryBackend constructs a memory backend. A non-positive maxEntries leaves it unbounded.
+func NewMemoryBackend(maxEntries int) *MemoryBackend {
+	return &MemoryBackend{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Put implements Backend.
+func (b *MemoryBackend) Put(_ context.Context, record *Record) error {
+	clone := *record
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elem, ok := b.items[record.ID]; ok {
+		elem.Value = &clone
+		b.order.MoveToBack(elem)
+		return nil
+	}
+	b.items[record.ID] = b.order.PushBack(&clone)
+	for b.maxEntries > 0 && b.order.Len() > b.maxEntries {
+		oldest := b.order.Front()
+		b.order.Remove(oldest)
+		delete(b.items, oldest.Value.(*Record).ID)
+	}
+	return nil
+}
+
+// Get implements Backend.
+func (b *MemoryBackend) Get(_ context.Context, id string) (*Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	clone := *elem.Value.(*Record)
+	return &clone, nil
+}
+
+// Delete implements Backend.
+func (b *MemoryBackend) Delete(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	b.order.Remove(elem)
+	delete(b.items, id)
+	return nil
+}
+
+// Prune implements Backend.
+func (b *MemoryBackend) Prune(_ context.Context, before time.Time) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var removed int64
+	for elem := b.order.Front(); elem != nil; {
+		next := elem.Next()
+		record := elem.Value.(*Record)
+		if !record.ExpiresAt.IsZero() && record.ExpiresAt.Before(before) {
+			b.order.Remove(elem)
+			delete(b.items, record.ID)
+			removed++
+		}
+		elem = next
+	}
+	return removed, nil
+}
+
+// Close implements Backend.
+func (b *MemoryBackend) Close() error {
+	b.mu.Lock()
+	b.order.Init()
+	b.items = make(map[string]*list.Element)
+	b.mu.Unlock()
+	return nil
+}
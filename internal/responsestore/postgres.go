@@ -0,0 +1,143 @@
+package responsestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const responsesTable = "response_store"
+
+// PostgresBackend stores responses in a PostgreSQL table.
+type PostgresBackend struct {
+	db     *sql.DB
+	table  string
+	ownsDB bool
+}
+
+// OpenPostgresBackend connects to PostgreSQL using dsn and prepares the responses table.
+func OpenPostgresBackend(ctx context.Context, dsn string) (*PostgresBackend, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("response store: open postgres connection: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("response store: ping postgres: %w", err)
+	}
+	backend, err := NewPostgresBackend(ctx, db, "", true)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// NewPostgresBackend prepares the responses table on an existing PostgreSQL connection.
+// When ownsDB is false, Close leaves the connection open for its other users.
+func NewPostgresBackend(ctx context.Context, db *sql.DB, schema string, ownsDB bool) (*PostgresBackend, error) {
+	if db == nil {
+		return nil, fmt.Errorf("response store: postgres connection is nil")
+	}
+	table := quoteIdentifier(responsesTable)
+	if schema = strings.TrimSpace(schema); schema != "" {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdentifier(schema))); err != nil {
+			return nil, fmt.Errorf("response store: create schema: %w", err)
+		}
+		table = quoteIdentifier(schema) + "." + table
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			model TEXT NOT NULL,
+			input JSONB NOT NULL,
+			response JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		)
+	`, table)); err != nil {
+		return nil, fmt.Errorf("response store: create responses table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s ON %s (expires_at)",
+		quoteIdentifier(responsesTable+"_expires_at_idx"), table,
+	)); err != nil {
+		return nil, fmt.Errorf("response store: create responses index: %w", err)
+	}
+	return &PostgresBackend{db: db, table: table, ownsDB: ownsDB}, nil
+}
+
+// Put implements Backend.
+func (b *PostgresBackend) Put(ctx context.Context, record *Record) error {
+	_, err := b.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (id, owner, model, input, response, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			owner = EXCLUDED.owner, model = EXCLUDED.model, input = EXCLUDED.input,
+			response = EXCLUDED.response, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+	`, b.table), record.ID, record.Owner, record.Model, string(record.Input), string(record.Response), record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("response store: insert response: %w", err)
+	}
+	return nil
+}
+
+// Get implements Backend.
+func (b *PostgresBackend) Get(ctx context.Context, id string) (*Record, error) {
+	var (
+		record          Record
+		input, response string
+	)
+	err := b.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT id, owner, model, input, response, created_at, expires_at FROM %s WHERE id = $1", b.table,
+	), id).Scan(&record.ID, &record.Owner, &record.Model, &input, &response, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("response store: query response: %w", err)
+	}
+	record.Input = []byte(input)
+	record.Response = []byte(response)
+	return &record, nil
+}
+
+// Delete implements Backend.
+func (b *PostgresBackend) Delete(ctx context.Context, id string) error {
+	res, err := b.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", b.table), id)
+	if err != nil {
+		return fmt.Errorf("response store: delete response: %w", err)
+	}
+	if n, errRows := res.RowsAffected(); errRows == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Prune implements Backend.
+func (b *PostgresBackend) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := b.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at < $1", b.table), before)
+	if err != nil {
+		return 0, fmt.Errorf("response store: prune responses: %w", err)
+	}
+	removed, _ := res.RowsAffected()
+	return removed, nil
+}
+
+// Close implements Backend.
+func (b *PostgresBackend) Close() error {
+	if !b.ownsDB {
+		return nil
+	}
+	return b.db.Close()
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
@@ -0,0 +1,301 @@
+// Package responsestore keeps completed Responses API turns keyed by response ID so that
+// stateless HTTP clients can continue a conversation with previous_response_id regardless of
+// which backend served the previous turn.
+package responsestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// ErrNotFound is returned when a response is unknown, expired, or owned by another client.
+var ErrNotFound = errors.New("response not found")
+
+const (
+	defaultTTL        = 30 * 24 * time.Hour
+	defaultMaxEntries = 10000
+	defaultDirName    = "responses"
+	pruneInterval     = time.Hour
+)
+
+// Record is one stored response turn.
+type Record struct {
+	// ID is the response ID ("resp_...").
+	ID string `json:"id"`
+	// Owner identifies the client API key that created the response.
+	Owner string `json:"owner,omitempty"`
+	Model string `json:"model,omitempty"`
+	// Input is the full input item array that produced the response, including earlier turns.
+	Input json.RawMessage `json:"input"`
+	// Response is the completed response object as returned to the client.
+	Response  json.RawMessage `json:"response"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// Output returns the output item array of the stored response.
+func (r *Record) Output() json.RawMessage {
+	if r == nil {
+		return json.RawMessage("[]")
+	}
+	output := gjson.GetBytes(r.Response, "output")
+	if !output.IsArray() {
+		return json.RawMessage("[]")
+	}
+	return json.RawMessage(output.Raw)
+}
+
+// Backend persists records. Implementations must be safe for concurrent use.
+type Backend interface {
+	// Put inserts or replaces a record.
+	Put(ctx context.Context, record *Record) error
+	// Get returns the record with id or ErrNotFound.
+	Get(ctx context.Context, id string) (*Record, error)
+	// Delete removes the record with id or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+	// Prune removes records that expired before the cutoff.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	// Close releases the backend.
+	Close() error
+}
+
+// OwnerKey derives the stored owner identifier from a client API key.
+func OwnerKey(apiKey string) string {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// store owns the active backend and its expiry worker.
+type store struct {
+	mu       sync.RWMutex
+	backend  Backend
+	settings config.ResponseStoreConfig
+	authDir  string
+	ttl      time.Duration
+	stop     chan struct{}
+
+	sharedDB     *sql.DB
+	sharedSchema string
+}
+
+var defaultStore = &store{}
+
+// UseSharedPostgres registers an existing PostgreSQL connection that the "postgres"
+// driver reuses when no DSN is configured.
+func UseSharedPostgres(db *sql.DB, schema string) {
+	defaultStore.mu.Lock()
+	defaultStore.sharedDB = db
+	defaultStore.sharedSchema = strings.TrimSpace(schema)
+	defaultStore.mu.Unlock()
+}
+
+// Configure opens, replaces, or closes the response store to match cfg.
+func Configure(cfg *config.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	return defaultStore.apply(cfg.ResponseStore, cfg.AuthDir)
+}
+
+// Close closes the active backend, if any.
+func Close() {
+	defaultStore.closeCurrent()
+}
+
+// Enabled reports whether a backend is configured.
+func Enabled() bool {
+	defaultStore.mu.RLock()
+	defer defaultStore.mu.RUnlock()
+	return defaultStore.backend != nil
+}
+
+// Save stores a completed response. CreatedAt and ExpiresAt are filled in when zero.
+func Save(ctx context.Context, record *Record) error {
+	backend, ttl := defaultStore.current()
+	if backend == nil || record == nil || strings.TrimSpace(record.ID) == "" {
+		return nil
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	if record.ExpiresAt.IsZero() {
+		record.ExpiresAt = record.CreatedAt.Add(ttl)
+	}
+	return backend.Put(ctx, record)
+}
+
+// Load returns the response with id when it exists, has not expired, and belongs to owner.
+func Load(ctx context.Context, id, owner string) (*Record, error) {
+	backend, _ := defaultStore.current()
+	if backend == nil {
+		return nil, ErrNotFound
+	}
+	record, err := backend.Get(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if record.Owner != owner || (!record.ExpiresAt.IsZero() && time.Now().After(record.ExpiresAt)) {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+// Remove deletes the response with id when it belongs to owner.
+func Remove(ctx context.Context, id, owner string) error {
+	if _, err := Load(ctx, id, owner); err != nil {
+		return err
+	}
+	backend, _ := defaultStore.current()
+	if backend == nil {
+		return ErrNotFound
+	}
+	return backend.Delete(ctx, strings.TrimSpace(id))
+}
+
+func (s *store) current() (Backend, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backend, s.ttl
+}
+
+func (s *store) apply(settings config.ResponseStoreConfig, authDir string) error {
+	settings.Driver = strings.ToLower(strings.TrimSpace(settings.Driver))
+	settings.Path = strings.TrimSpace(settings.Path)
+	settings.DSN = strings.TrimSpace(settings.DSN)
+	ttl := time.Duration(settings.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	s.mu.Lock()
+	if s.backend != nil && s.settings == settings && s.authDir == authDir {
+		s.mu.Unlock()
+		return nil
+	}
+	sharedDB, sharedSchema := s.sharedDB, s.sharedSchema
+	s.mu.Unlock()
+
+	s.closeCurrent()
+
+	var (
+		backend Backend
+		err     error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	switch settings.Driver {
+	case "":
+		return nil
+	case "memory":
+		maxEntries := settings.MaxEntries
+		if maxEntries <= 0 {
+			maxEntries = defaultMaxEntries
+		}
+		backend = NewMemoryBackend(maxEntries)
+	case "file":
+		dir := settings.Path
+		if dir == "" {
+			dir = util.DataPath(defaultDirName)
+			warnLegacyDir(authDir)
+		}
+		backend, err = NewFileBackend(dir)
+	case "postgres", "postgresql", "pgx":
+		if settings.DSN == "" {
+			if sharedDB == nil {
+				return fmt.Errorf("response store: postgres driver requires a dsn or the postgres token store")
+			}
+			backend, err = NewPostgresBackend(ctx, sharedDB, sharedSchema, false)
+		} else {
+			backend, err = OpenPostgresBackend(ctx, settings.DSN)
+		}
+	default:
+		return fmt.Errorf("response store: unsupported driver %q", settings.Driver)
+	}
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	s.mu.Lock()
+	s.backend = backend
+	s.settings = settings
+	s.authDir = authDir
+	s.ttl = ttl
+	s.stop = stop
+	s.mu.Unlock()
+
+	go s.runPrune(backend, stop)
+	log.Infof("response store enabled (driver: %s)", settings.Driver)
+	return nil
+}
+
+// warnLegacyDir reports responses left in auth-dir, the former default location, where every
+// token store loads them as auths.
+func warnLegacyDir(authDir string) {
+	resolved, err := util.ResolveAuthDir(authDir)
+	if err != nil || resolved == "" {
+		return
+	}
+	legacy := filepath.Join(resolved, defaultDirName)
+	if info, errStat := os.Stat(legacy); errStat == nil && info.IsDir() {
+		log.Warnf("response store: %s is inside auth-dir, where its files are loaded as auths; move it to %s or delete it", legacy, util.DataPath(defaultDirName))
+	}
+}
+
+func (s *store) closeCurrent() {
+	s.mu.Lock()
+	backend, stop := s.backend, s.stop
+	s.backend = nil
+	s.stop = nil
+	s.settings = config.ResponseStoreConfig{}
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	if backend != nil {
+		if err := backend.Close(); err != nil {
+			log.WithError(err).Warn("response store: failed to close")
+		}
+	}
+}
+
+func (s *store) runPrune(backend Backend, stop <-chan struct{}) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			removed, err := backend.Prune(ctx, time.Now())
+			cancel()
+			if err != nil {
+				log.WithError(err).Warn("response store: expiry cleanup failed")
+				continue
+			}
+			if removed > 0 {
+				log.Debugf("response store: removed %d expired responses", removed)
+			}
+		}
+	}
+}
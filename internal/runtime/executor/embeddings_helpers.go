@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	embeddingsconverter "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/embeddings"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+var formatOpenAI = sdktranslator.FromString("openai")
+
+// geminiEmbeddingsRequest builds a batchEmbedContents body for baseModel from the client payload
+// and returns the embedded texts for local token estimation.
+func geminiEmbeddingsRequest(from sdktranslator.Format, baseModel string, payload []byte) ([]byte, []string, error) {
+	if from == formatOpenAI {
+		body, err := embeddingsconverter.ConvertOpenAIEmbeddingsRequestToGemini(baseModel, payload)
+		if err != nil {
+			return nil, nil, statusErr{code: http.StatusBadRequest, msg: err.Error()}
+		}
+		return body, embeddingsconverter.GeminiInputTexts(body), nil
+	}
+	body := embeddingsconverter.NormalizeGeminiRequestToBatch(baseModel, payload)
+	return body, embeddingsconverter.GeminiInputTexts(body), nil
+}
+
+// geminiEmbeddingsResponse converts a batchEmbedContents response back to the client format.
+func geminiEmbeddingsResponse(from sdktranslator.Format, model string, originalRequest, data []byte, inputTokens int64) ([]byte, error) {
+	if from == formatOpenAI {
+		out, err := embeddingsconverter.ConvertGeminiEmbeddingsResponseToOpenAI(model, originalRequest, data, inputTokens)
+		if err != nil {
+			return nil, statusErr{code: http.StatusBadGateway, msg: err.Error()}
+		}
+		return out, nil
+	}
+	if !embeddingsconverter.IsGeminiBatchRequest(originalRequest) {
+		return embeddingsconverter.ConvertGeminiBatchResponseToSingle(data), nil
+	}
+	return data, nil
+}
+
+// estimateEmbeddingTokens counts the input tokens of texts locally for upstreams that do not
+// report embedding usage.
+func estimateEmbeddingTokens(model string, texts []string) int64 {
+	enc, err := getTokenizer(model)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, text := range texts {
+		if count, errCount := enc.Count(text); errCount == nil {
+			total += int64(count)
+		}
+	}
+	return total
+}
+
+// embeddingUsage reports the input tokens of an embedding request; embeddings produce no output tokens.
+func embeddingUsage(inputTokens int64) usage.Detail {
+	return usage.Detail{InputTokens: inputTokens, TotalTokens: inputTokens}
+}
+
+// doEmbeddingsRequest posts body to url with the request logging shared by the executors.
+// prepare sets the credentials on the outgoing request.
+func doEmbeddingsRequest(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, provider, url string, body []byte, prepare func(*http.Request)) ([]byte, http.Header, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		prepare(httpReq)
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  provider,
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("%s executor: close embeddings response body error: %v", provider, errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, nil, err
+	}
+	appendAPIResponseChunk(ctx, cfg, data)
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		logWithRequestID(ctx).Debugf("embeddings request error, error status: %d, error message: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), data))
+		return nil, nil, statusErr{code: httpResp.StatusCode, msg: string(data)}
+	}
+	return data, httpResp.Header.Clone(), nil
+}
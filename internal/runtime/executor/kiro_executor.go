@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/uuid"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
@@ -313,13 +314,13 @@ func newKiroHTTPClientWithPooling(ctx context.Context, cfg *config.Config, auth
 
 	// If timeout is specified, we need to wrap the pooled transport with timeout
 	if timeout > 0 {
-		return &http.Client{
+		return withTracingTransport(&http.Client{
 			Transport: pooledClient.Transport,
 			Timeout:   timeout,
-		}
+		})
 	}
 
-	return pooledClient
+	return withTracingTransport(pooledClient)
 }
 
 // kiroEndpointConfig bundles endpoint URL with its compatible Origin and AmzTarget values.
@@ -689,7 +690,7 @@ func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequestWithContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 
@@ -1122,7 +1123,7 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequestWithContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 
@@ -3741,48 +3742,10 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 // NOTE: Claude SSE event builders moved to internal/translator/kiro/claude/kiro_claude_stream.go
 // The executor now uses kiroclaude.BuildClaude*Event() functions instead
 
-// CountTokens counts tokens locally using tiktoken since Kiro API doesn't expose a token counting endpoint.
+// CountTokens counts tokens locally since Kiro API doesn't expose a token counting endpoint.
 // This provides approximate token counts for client requests.
 func (e *KiroExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	// Use tiktoken for local token counting
-	enc, err := getTokenizer(req.Model)
-	if err != nil {
-		log.Warnf("kiro: CountTokens failed to get tokenizer: %v, falling back to estimate", err)
-		// Fallback: estimate from payload size (roughly 4 chars per token)
-		estimatedTokens := len(req.Payload) / 4
-		if estimatedTokens == 0 && len(req.Payload) > 0 {
-			estimatedTokens = 1
-		}
-		return cliproxyexecutor.Response{
-			Payload: []byte(fmt.Sprintf(`{"count":%d}`, estimatedTokens)),
-		}, nil
-	}
-
-	// Try to count tokens from the request payload
-	var totalTokens int64
-
-	// Try OpenAI chat format first
-	if tokens, countErr := countOpenAIChatTokens(enc, req.Payload); countErr == nil && tokens > 0 {
-		totalTokens = tokens
-		log.Debugf("kiro: CountTokens counted %d tokens using OpenAI chat format", totalTokens)
-	} else {
-		// Fallback: count raw payload tokens
-		if tokenCount, countErr := enc.Count(string(req.Payload)); countErr == nil {
-			totalTokens = int64(tokenCount)
-			log.Debugf("kiro: CountTokens counted %d tokens from raw payload", totalTokens)
-		} else {
-			// Final fallback: estimate from payload size
-			totalTokens = int64(len(req.Payload) / 4)
-			if totalTokens == 0 && len(req.Payload) > 0 {
-				totalTokens = 1
-			}
-			log.Debugf("kiro: CountTokens estimated %d tokens from payload size", totalTokens)
-		}
-	}
-
-	return cliproxyexecutor.Response{
-		Payload: []byte(fmt.Sprintf(`{"count":%d}`, totalTokens)),
-	}, nil
+	return countTokensLocally(req.Model, opts.SourceFormat, req.Payload)
 }
 
 // Refresh refreshes the Kiro OAuth token.
@@ -3989,7 +3952,7 @@ func (e *KiroExecutor) persistRefreshedAuth(auth *cliproxyauth.Auth) error {
 
 	// Write to temp file first, then rename (atomic write)
 	tmp := authPath + ".tmp"
-	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+	if err := authcrypt.WriteFile(tmp, raw, 0o600); err != nil {
 		return fmt.Errorf("kiro executor: write temp auth file failed: %w", err)
 	}
 	if err := os.Rename(tmp, authPath); err != nil {
@@ -4030,7 +3993,7 @@ func (e *KiroExecutor) reloadAuthFromFile(auth *cliproxyauth.Auth) (*cliproxyaut
 	}
 
 	// 读取文件
-	raw, err := os.ReadFile(authPath)
+	raw, err := authcrypt.ReadFile(authPath)
 	if err != nil {
 		return nil, fmt.Errorf("kiro executor: failed to read auth file %s: %w", authPath, err)
 	}
@@ -4722,7 +4685,7 @@ func (e *KiroExecutor) callKiroAndBuffer(
 ) ([][]byte, error) {
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequestWithContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 	log.Debugf("kiro/websearch GAR request: %d bytes", len(body))
 
 	kiroModelID := e.mapModelToKiro(req.Model)
@@ -4765,7 +4728,7 @@ func (e *KiroExecutor) callKiroDirectStream(
 ) (<-chan cliproxyexecutor.StreamChunk, error) {
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequestWithContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
@@ -4815,7 +4778,7 @@ func (e *KiroExecutor) executeNonStreamFallback(
 ) (cliproxyexecutor.Response, error) {
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := sdktranslator.TranslateRequestWithContext(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
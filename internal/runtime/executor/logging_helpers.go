@@ -383,9 +383,12 @@ func logWithRequestID(ctx context.Context) *log.Entry {
 	if ctx == nil {
 		return log.NewEntry(log.StandardLogger())
 	}
-	requestID := logging.GetRequestID(ctx)
-	if requestID == "" {
-		return log.NewEntry(log.StandardLogger())
+	entry := log.NewEntry(log.StandardLogger())
+	if requestID := logging.GetRequestID(ctx); requestID != "" {
+		entry = entry.WithField("request_id", requestID)
+	}
+	if traceID := logging.GetTraceID(ctx); traceID != "" {
+		entry = entry.WithField("trace_id", traceID)
 	}
-	return log.WithField("request_id", requestID)
+	return entry
 }
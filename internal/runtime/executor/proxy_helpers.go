@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
@@ -37,6 +38,21 @@ var (
 // Returns:
 //   - *http.Client: An HTTP client with configured proxy or transport
 func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
+	return withTracingTransport(proxyAwareHTTPClient(ctx, cfg, auth, timeout))
+}
+
+// withTracingTransport returns a copy of client whose transport records upstream spans.
+// The client is returned unchanged while tracing is disabled so cached clients are never mutated.
+func withTracingTransport(client *http.Client) *http.Client {
+	if client == nil || !tracing.Enabled() {
+		return client
+	}
+	traced := *client
+	traced.Transport = tracing.Transport(client.Transport)
+	return &traced
+}
+
+func proxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
 	// Priority 1: Use auth.ProxyURL if configured
 	var proxyURL string
 	if auth != nil {
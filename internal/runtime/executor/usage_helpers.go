@@ -9,8 +9,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,6 +28,11 @@ type usageReporter struct {
 	source      string
 	requestedAt time.Time
 	once        sync.Once
+
+	// promptFormat and prompt hold the client request for back-filling input tokens
+	// when the upstream reports no usage.
+	promptFormat sdktranslator.Format
+	prompt       []byte
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -42,6 +51,16 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 	return reporter
 }
 
+// estimatePrompt records the client request so that ensurePublished can count its
+// input tokens locally when the upstream response carries no usage.
+func (r *usageReporter) estimatePrompt(from sdktranslator.Format, payload []byte) {
+	if r == nil {
+		return
+	}
+	r.promptFormat = from
+	r.prompt = payload
+}
+
 func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 	r.publishWithOutcome(ctx, detail, false)
 }
@@ -73,7 +92,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
+		r.publishRecord(ctx, usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
 			Source:      r.source,
@@ -90,13 +109,14 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 // ensurePublished guarantees that a usage record is emitted exactly once.
 // It is safe to call multiple times; only the first call wins due to once.Do.
 // This is used to ensure request counting even when upstream responses do not
-// include any usage fields (tokens), especially for streaming paths.
+// include any usage fields (tokens), especially for streaming paths. The input
+// tokens of a prompt recorded through estimatePrompt are then counted locally.
 func (r *usageReporter) ensurePublished(ctx context.Context) {
 	if r == nil {
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
+		r.publishRecord(ctx, usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
 			Source:      r.source,
@@ -105,18 +125,43 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
 			Failed:      false,
-			Detail:      usage.Detail{},
+			Detail:      r.estimatedDetail(),
 		})
 	})
 }
 
+// estimatedDetail counts the input tokens of the recorded prompt with the local tokenizer.
+func (r *usageReporter) estimatedDetail() usage.Detail {
+	if len(r.prompt) == 0 {
+		return usage.Detail{}
+	}
+	enc, err := getTokenizer(r.model)
+	if err != nil {
+		return usage.Detail{}
+	}
+	count, err := tokenizer.CountRequest(enc, r.promptFormat.String(), r.prompt)
+	if err != nil || count <= 0 {
+		return usage.Detail{}
+	}
+	return usage.Detail{InputTokens: count, TotalTokens: count}
+}
+
+// publishRecord attaches the record to the request for audit logging and publishes it.
+// Records of hedged attempts wait for the usage gate so only the winner is counted.
+func (r *usageReporter) publishRecord(ctx context.Context, record usage.Record) {
+	usage.GateFromContext(ctx).Do(func() {
+		logging.AttachUsageRecord(ctx, record)
+		usage.PublishRecord(ctx, record)
+	})
+}
+
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
 	}
 	ginCtx, ok := ctx.Value("gin").(*gin.Context)
 	if !ok || ginCtx == nil {
-		return ""
+		return cliproxyexecutor.ClientAPIKey(ctx)
 	}
 	if v, exists := ginCtx.Get("apiKey"); exists {
 		switch value := v.(type) {
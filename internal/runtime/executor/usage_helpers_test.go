@@ -1,6 +1,10 @@
 package executor
 
-import "testing"
+import (
+	"testing"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
 
 func TestParseOpenAIUsageChatCompletions(t *testing.T) {
 	data := []byte(`{"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":5}}}`)
@@ -41,3 +45,15 @@ func TestParseOpenAIUsageResponses(t *testing.T) {
 		t.Fatalf("reasoning tokens = %d, want %d", detail.ReasoningTokens, 9)
 	}
 }
+
+func TestUsageReporterEstimatesPromptWithoutUpstreamUsage(t *testing.T) {
+	reporter := &usageReporter{model: "gpt-4o"}
+	if detail := reporter.estimatedDetail(); detail.InputTokens != 0 {
+		t.Fatalf("without a prompt input tokens = %d, want 0", detail.InputTokens)
+	}
+	reporter.estimatePrompt(sdktranslator.FromString("claude"), []byte(`{"messages":[{"role":"user","content":"count these words please"}]}`))
+	detail := reporter.estimatedDetail()
+	if detail.InputTokens <= 0 || detail.TotalTokens != detail.InputTokens {
+		t.Fatalf("detail = %+v, want estimated input tokens", detail)
+	}
+}
@@ -15,8 +15,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -25,8 +27,22 @@ import (
 const (
 	objectStoreConfigKey  = "config/config.yaml"
 	objectStoreAuthPrefix = "auths"
+	objectStoreLockPrefix = "locks"
+
+	// objectLeaseTTL bounds how long a refresh lease outlives a process that died holding it.
+	objectLeaseTTL = 5 * time.Minute
+	// objectLeasePoll is how often a held refresh lease is checked for release.
+	objectLeasePoll = time.Second
+	// objectLeaseSettle is how long a written lease is left before it is read back.
+	objectLeaseSettle = 500 * time.Millisecond
 )
 
+// objectLease is the content of a refresh lease object.
+type objectLease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 // ObjectStoreConfig captures configuration for the object storage-backed token store.
 type ObjectStoreConfig struct {
 	Endpoint  string
@@ -184,23 +200,29 @@ func (s *ObjectTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (s
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
-			return "", err
+		if err = authcrypt.WriteStorage(path, auth.Storage, auth.Provider); err != nil {
+			return "", fmt.Errorf("object store: write auth file: %w", err)
 		}
 	case auth.Metadata != nil:
 		raw, errMarshal := json.Marshal(auth.Metadata)
 		if errMarshal != nil {
 			return "", fmt.Errorf("object store: marshal metadata: %w", errMarshal)
 		}
+		sealed, errSeal := authcrypt.Seal(raw)
+		if errSeal != nil {
+			return "", fmt.Errorf("object store: encrypt metadata: %w", errSeal)
+		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
+			if authcrypt.Current(existing) {
+				if plain, errOpen := authcrypt.Open(existing); errOpen == nil && jsonEqual(plain, raw) {
+					return path, nil
+				}
 			}
 		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
 			return "", fmt.Errorf("object store: read existing metadata: %w", errRead)
 		}
 		tmp := path + ".tmp"
-		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
+		if errWrite := os.WriteFile(tmp, sealed, 0o600); errWrite != nil {
 			return "", fmt.Errorf("object store: write temp auth file: %w", errWrite)
 		}
 		if errRename := os.Rename(tmp, path); errRename != nil {
@@ -281,6 +303,122 @@ func (s *ObjectTokenStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// Read implements cliproxyauth.AuthReader by downloading the auth object, where other
+// processes sharing the bucket upload their refreshed credentials.
+func (s *ObjectTokenStore) Read(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	path, err := s.resolveAuthPath(auth)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(s.authDir, path)
+	if err != nil {
+		return nil, fmt.Errorf("object store: resolve auth relative path: %w", err)
+	}
+	fullKey := s.prefixedKey(objectStoreAuthPrefix + "/" + filepath.ToSlash(rel))
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, fullKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("object store: download auth %s: %w", fullKey, err)
+	}
+	defer func() { _ = object.Close() }()
+	info, err := object.Stat()
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: stat auth %s: %w", fullKey, err)
+	}
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("object store: read auth %s: %w", fullKey, err)
+	}
+	plain, err := authcrypt.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("object store: decrypt auth %s: %w", fullKey, err)
+	}
+	return s.authFromData(plain, path, s.authDir, info.LastModified)
+}
+
+// LockRefresh implements cliproxyauth.RefreshLocker with a lease object under locks/. Object
+// storage has no portable compare-and-swap, so a new lease is read back after
+// objectLeaseSettle: of several processes racing for a free lease, the last write wins and only
+// its writer finds its own token.
+func (s *ObjectTokenStore) LockRefresh(ctx context.Context, auth *cliproxyauth.Auth) (func(), error) {
+	path, err := s.resolveAuthPath(auth)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(s.authDir, path)
+	if err != nil {
+		return nil, fmt.Errorf("object store: resolve auth relative path: %w", err)
+	}
+	key := objectStoreLockPrefix + "/" + filepath.ToSlash(rel) + ".lock"
+	token := uuid.NewString()
+	release := func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if current, errRead := s.readLease(releaseCtx, key); errRead == nil && current != nil && current.Holder == token {
+			if errDelete := s.deleteObject(releaseCtx, key); errDelete != nil {
+				log.WithError(errDelete).Warnf("object store: failed to release refresh lease %s", key)
+			}
+		}
+	}
+	for {
+		current, errRead := s.readLease(ctx, key)
+		if errRead != nil {
+			return nil, errRead
+		}
+		if current == nil || time.Now().After(current.ExpiresAt) {
+			lease, errMarshal := json.Marshal(objectLease{Holder: token, ExpiresAt: time.Now().Add(objectLeaseTTL)})
+			if errMarshal != nil {
+				return nil, fmt.Errorf("object store: encode refresh lease: %w", errMarshal)
+			}
+			if err = s.putObject(ctx, key, lease, "application/json"); err != nil {
+				return nil, err
+			}
+			select {
+			case <-ctx.Done():
+				release()
+				return nil, fmt.Errorf("object store: wait for refresh lease %s: %w", key, ctx.Err())
+			case <-time.After(objectLeaseSettle):
+			}
+			if current, errRead = s.readLease(ctx, key); errRead != nil {
+				return nil, errRead
+			}
+			if current != nil && current.Holder == token {
+				return release, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("object store: wait for refresh lease %s: %w", key, ctx.Err())
+		case <-time.After(objectLeasePoll):
+		}
+	}
+}
+
+// readLease returns the lease stored at key, or nil when there is none.
+func (s *ObjectTokenStore) readLease(ctx context.Context, key string) (*objectLease, error) {
+	fullKey := s.prefixedKey(key)
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, fullKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("object store: get refresh lease %s: %w", fullKey, err)
+	}
+	defer func() { _ = object.Close() }()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: read refresh lease %s: %w", fullKey, err)
+	}
+	var lease objectLease
+	if err = json.Unmarshal(data, &lease); err != nil {
+		// An unreadable lease cannot be honoured; treat it as free.
+		return nil, nil
+	}
+	return &lease, nil
+}
+
 // PersistAuthFiles uploads the provided auth files to the object storage backend.
 func (s *ObjectTokenStore) PersistAuthFiles(ctx context.Context, _ string, paths ...string) error {
 	if len(paths) == 0 {
@@ -554,25 +692,30 @@ func (s *ObjectTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
 	if len(data) == 0 {
 		return nil, nil
 	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat auth file: %w", err)
+	}
+	return s.authFromData(data, path, baseDir, info.ModTime())
+}
+
+// authFromData builds the auth stored at path from its decrypted content.
+func (s *ObjectTokenStore) authFromData(data []byte, path, baseDir string, modTime time.Time) (*cliproxyauth.Auth, error) {
 	metadata := make(map[string]any)
-	if err = json.Unmarshal(data, &metadata); err != nil {
+	if err := json.Unmarshal(data, &metadata); err != nil {
 		return nil, fmt.Errorf("unmarshal auth json: %w", err)
 	}
 	provider := strings.TrimSpace(valueAsString(metadata["type"]))
 	if provider == "" {
 		provider = "unknown"
 	}
-	info, err := os.Stat(path)
-	if err != nil {
-		return nil, fmt.Errorf("stat auth file: %w", err)
-	}
 	rel, errRel := filepath.Rel(baseDir, path)
 	if errRel != nil {
 		rel = filepath.Base(path)
@@ -590,8 +733,8 @@ func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Aut
 		Status:           cliproxyauth.StatusActive,
 		Attributes:       attr,
 		Metadata:         metadata,
-		CreatedAt:        info.ModTime(),
-		UpdatedAt:        info.ModTime(),
+		CreatedAt:        modTime,
+		UpdatedAt:        modTime,
 		LastRefreshedAt:  time.Time{},
 		NextRefreshAfter: time.Time{},
 	}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -99,6 +100,14 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 	return store, nil
 }
 
+// DB exposes the underlying connection so other subsystems can share it.
+func (s *PostgresStore) DB() *sql.DB {
+	if s == nil {
+		return nil
+	}
+	return s.db
+}
+
 // Close releases the underlying database connection.
 func (s *PostgresStore) Close() error {
 	if s == nil || s.db == nil {
@@ -214,23 +223,29 @@ func (s *PostgresStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
-			return "", err
+		if err = authcrypt.WriteStorage(path, auth.Storage, auth.Provider); err != nil {
+			return "", fmt.Errorf("postgres store: write auth file: %w", err)
 		}
 	case auth.Metadata != nil:
 		raw, errMarshal := json.Marshal(auth.Metadata)
 		if errMarshal != nil {
 			return "", fmt.Errorf("postgres store: marshal metadata: %w", errMarshal)
 		}
+		sealed, errSeal := authcrypt.Seal(raw)
+		if errSeal != nil {
+			return "", fmt.Errorf("postgres store: encrypt metadata: %w", errSeal)
+		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
+			if authcrypt.Current(existing) {
+				if plain, errOpen := authcrypt.Open(existing); errOpen == nil && jsonEqual(plain, raw) {
+					return path, nil
+				}
 			}
 		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
 			return "", fmt.Errorf("postgres store: read existing metadata: %w", errRead)
 		}
 		tmp := path + ".tmp"
-		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
+		if errWrite := os.WriteFile(tmp, sealed, 0o600); errWrite != nil {
 			return "", fmt.Errorf("postgres store: write temp auth file: %w", errWrite)
 		}
 		if errRename := os.Rename(tmp, path); errRename != nil {
@@ -279,37 +294,11 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 		if err = rows.Scan(&id, &payload, &createdAt, &updatedAt); err != nil {
 			return nil, fmt.Errorf("postgres store: scan auth row: %w", err)
 		}
-		path, errPath := s.absoluteAuthPath(id)
-		if errPath != nil {
-			log.WithError(errPath).Warnf("postgres store: skipping auth %s outside spool", id)
+		auth, errRecord := s.authFromRecord(id, payload, createdAt, updatedAt)
+		if errRecord != nil {
+			log.WithError(errRecord).Warnf("postgres store: skipping auth %s", id)
 			continue
 		}
-		metadata := make(map[string]any)
-		if err = json.Unmarshal([]byte(payload), &metadata); err != nil {
-			log.WithError(err).Warnf("postgres store: skipping auth %s with invalid json", id)
-			continue
-		}
-		provider := strings.TrimSpace(valueAsString(metadata["type"]))
-		if provider == "" {
-			provider = "unknown"
-		}
-		attr := map[string]string{"path": path}
-		if email := strings.TrimSpace(valueAsString(metadata["email"])); email != "" {
-			attr["email"] = email
-		}
-		auth := &cliproxyauth.Auth{
-			ID:               normalizeAuthID(id),
-			Provider:         provider,
-			FileName:         normalizeAuthID(id),
-			Label:            labelFor(metadata),
-			Status:           cliproxyauth.StatusActive,
-			Attributes:       attr,
-			Metadata:         metadata,
-			CreatedAt:        createdAt,
-			UpdatedAt:        updatedAt,
-			LastRefreshedAt:  time.Time{},
-			NextRefreshAfter: time.Time{},
-		}
 		auths = append(auths, auth)
 	}
 	if err = rows.Err(); err != nil {
@@ -318,6 +307,42 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 	return auths, nil
 }
 
+// Read implements cliproxyauth.AuthReader by loading the auth record from PostgreSQL, where
+// other processes sharing the database store their refreshed credentials.
+func (s *PostgresStore) Read(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	relID, err := s.authRecordID(auth)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		payload   string
+		createdAt time.Time
+		updatedAt time.Time
+	)
+	query := fmt.Sprintf("SELECT content, created_at, updated_at FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
+	if err = s.db.QueryRowContext(ctx, query, relID).Scan(&payload, &createdAt, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres store: read auth: %w", err)
+	}
+	return s.authFromRecord(relID, payload, createdAt, updatedAt)
+}
+
+// LockRefresh implements cliproxyauth.RefreshLocker with a session advisory lock keyed on the
+// auth record, held on a dedicated connection until released.
+func (s *PostgresStore) LockRefresh(ctx context.Context, auth *cliproxyauth.Auth) (func(), error) {
+	relID, err := s.authRecordID(auth)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := advisoryLock(ctx, s.db, s.fullTableName(s.cfg.AuthTable)+"/"+relID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: %w", err)
+	}
+	return unlock, nil
+}
+
 // Delete removes an auth file and the corresponding database record.
 func (s *PostgresStore) Delete(ctx context.Context, id string) error {
 	id = strings.TrimSpace(id)
@@ -568,6 +593,51 @@ func (s *PostgresStore) resolveAuthPath(auth *cliproxyauth.Auth) (string, error)
 	return filepath.Join(s.authDir, filepath.FromSlash(auth.ID)), nil
 }
 
+func (s *PostgresStore) authRecordID(auth *cliproxyauth.Auth) (string, error) {
+	path, err := s.resolveAuthPath(auth)
+	if err != nil {
+		return "", err
+	}
+	return s.relativeAuthID(path)
+}
+
+// authFromRecord builds the auth stored in the record id.
+func (s *PostgresStore) authFromRecord(id, payload string, createdAt, updatedAt time.Time) (*cliproxyauth.Auth, error) {
+	path, err := s.absoluteAuthPath(id)
+	if err != nil {
+		return nil, fmt.Errorf("outside spool: %w", err)
+	}
+	plain, err := authcrypt.Open([]byte(payload))
+	if err != nil {
+		return nil, fmt.Errorf("cannot be decrypted: %w", err)
+	}
+	metadata := make(map[string]any)
+	if err = json.Unmarshal(plain, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	provider := strings.TrimSpace(valueAsString(metadata["type"]))
+	if provider == "" {
+		provider = "unknown"
+	}
+	attr := map[string]string{"path": path}
+	if email := strings.TrimSpace(valueAsString(metadata["email"])); email != "" {
+		attr["email"] = email
+	}
+	return &cliproxyauth.Auth{
+		ID:               normalizeAuthID(id),
+		Provider:         provider,
+		FileName:         normalizeAuthID(id),
+		Label:            labelFor(metadata),
+		Status:           cliproxyauth.StatusActive,
+		Attributes:       attr,
+		Metadata:         metadata,
+		CreatedAt:        createdAt,
+		UpdatedAt:        updatedAt,
+		LastRefreshedAt:  time.Time{},
+		NextRefreshAfter: time.Time{},
+	}, nil
+}
+
 func (s *PostgresStore) resolveDeletePath(id string) (string, error) {
 	if strings.ContainsRune(id, os.PathSeparator) || filepath.IsAbs(id) {
 		return id, nil
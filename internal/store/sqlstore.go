@@ -0,0 +1,963 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	sqlStoreAuthTable   = "auth_records"
+	sqlStoreConfigTable = "config_records"
+	sqlStoreLockTable   = "auth_refresh_locks"
+	// sqlStoreChannel is the PostgreSQL notification channel writers signal after a change.
+	sqlStoreChannel = "cliproxy_auth_records"
+
+	// defaultSQLStorePoll is how often a SQLite store scans for changes.
+	defaultSQLStorePoll = 2 * time.Second
+	// defaultSQLStoreListenPoll is how often a PostgreSQL store scans for changes it was not
+	// notified of, such as those made while its listening connection was down.
+	defaultSQLStoreListenPoll = 30 * time.Second
+	// sqlStoreLockTTL bounds how long a SQLite refresh lock outlives a process that died holding it.
+	sqlStoreLockTTL = 5 * time.Minute
+	// sqlStoreLockPoll is how often a held SQLite refresh lock is checked for release.
+	sqlStoreLockPoll = 250 * time.Millisecond
+	// sqlStoreMergeAttempts bounds how often rotated credentials are merged into a record that
+	// keeps changing underneath.
+	sqlStoreMergeAttempts = 3
+)
+
+// ErrVersionConflict reports a write rejected because another writer changed the record since
+// this process last read it. The newer record reaches the process through Watch.
+var ErrVersionConflict = errors.New("sql store: record changed by another writer")
+
+// SQLStoreConfig configures a SQLStore.
+type SQLStoreConfig struct {
+	// Driver selects the database: "postgres" or "sqlite".
+	Driver string
+	// DSN is the PostgreSQL connection string.
+	DSN string
+	// Path is the SQLite database file.
+	Path string
+	// Schema is the PostgreSQL schema holding the tables; empty uses the search path.
+	Schema string
+	// ConfigPath is the configuration file kept in step with the stored config. The config is
+	// the one thing mirrored to disk: the file watcher and the management API read and write
+	// config.yaml, so the stored config is written there and edits to it are stored back.
+	// Empty leaves configuration on the local file only.
+	ConfigPath string
+	// PollInterval overrides how often the database is scanned for changes.
+	PollInterval time.Duration
+}
+
+// SQLStore keeps auths and configuration in PostgreSQL or SQLite as the source of truth. Unlike
+// PostgresStore it spools nothing to disk for auths: they are read and written as rows, encoded
+// in memory, and changes made by other writers are reported through Watch. Only the config is
+// mirrored to ConfigPath, which the file watcher requires. Every row carries a version, and a write based
+// on an outdated version fails with ErrVersionConflict instead of overwriting the newer record.
+type SQLStore struct {
+	db          *sql.DB
+	cfg         SQLStoreConfig
+	authTable   string
+	configTable string
+	lockTable   string
+
+	mu sync.Mutex
+	// known holds the version and content digest of each auth as this process last saw it.
+	known         map[string]sqlRecordState
+	config        sqlRecordState
+	configLoaded  bool
+	baseDir       string
+	changeSignals chan struct{}
+}
+
+type sqlRecordState struct {
+	version int64
+	digest  string
+}
+
+var (
+	_ cliproxyauth.Store         = (*SQLStore)(nil)
+	_ cliproxyauth.AuthReader    = (*SQLStore)(nil)
+	_ cliproxyauth.RefreshLocker = (*SQLStore)(nil)
+	_ cliproxyauth.ChangeWatcher = (*SQLStore)(nil)
+)
+
+// NewSQLStore opens the database described by cfg and prepares its tables.
+func NewSQLStore(ctx context.Context, cfg SQLStoreConfig) (*SQLStore, error) {
+	cfg.Driver = strings.ToLower(strings.TrimSpace(cfg.Driver))
+	var (
+		db  *sql.DB
+		err error
+	)
+	prefix := ""
+	switch cfg.Driver {
+	case "postgres", "postgresql", "pg":
+		cfg.Driver = "postgres"
+		if cfg.DSN = strings.TrimSpace(cfg.DSN); cfg.DSN == "" {
+			return nil, fmt.Errorf("sql store: DSN is required")
+		}
+		if db, err = sql.Open("pgx", cfg.DSN); err != nil {
+			return nil, fmt.Errorf("sql store: open postgres connection: %w", err)
+		}
+		if schema := strings.TrimSpace(cfg.Schema); schema != "" {
+			prefix = quoteIdentifier(schema) + "."
+		}
+	case "sqlite", "sqlite3":
+		cfg.Driver = "sqlite"
+		if cfg.Path = strings.TrimSpace(cfg.Path); cfg.Path == "" {
+			return nil, fmt.Errorf("sql store: sqlite path is required")
+		}
+		if err = os.MkdirAll(filepath.Dir(cfg.Path), 0o700); err != nil {
+			return nil, fmt.Errorf("sql store: create sqlite directory: %w", err)
+		}
+		if db, err = sql.Open("sqlite", "file:"+cfg.Path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"); err != nil {
+			return nil, fmt.Errorf("sql store: open sqlite database: %w", err)
+		}
+		// SQLite serialises writers; a single connection avoids SQLITE_BUSY churn.
+		db.SetMaxOpenConns(1)
+	default:
+		return nil, fmt.Errorf("sql store: unsupported driver %q", cfg.Driver)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sql store: ping database: %w", err)
+	}
+	store := &SQLStore{
+		db:            db,
+		cfg:           cfg,
+		authTable:     prefix + quoteIdentifier(sqlStoreAuthTable),
+		configTable:   prefix + quoteIdentifier(sqlStoreConfigTable),
+		lockTable:     prefix + quoteIdentifier(sqlStoreLockTable),
+		known:         make(map[string]sqlRecordState),
+		changeSignals: make(chan struct{}, 1),
+	}
+	if err = store.ensureSchema(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// DB exposes the underlying connection so other subsystems can share it.
+func (s *SQLStore) DB() *sql.DB {
+	if s == nil {
+		return nil
+	}
+	return s.db
+}
+
+// Driver reports the database driver, "postgres" or "sqlite".
+func (s *SQLStore) Driver() string {
+	if s == nil {
+		return ""
+	}
+	return s.cfg.Driver
+}
+
+// Close releases the underlying database connection.
+func (s *SQLStore) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *SQLStore) ensureSchema(ctx context.Context) error {
+	if schema := strings.TrimSpace(s.cfg.Schema); schema != "" && s.cfg.Driver == "postgres" {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdentifier(schema))); err != nil {
+			return fmt.Errorf("sql store: create schema: %w", err)
+		}
+	}
+	for _, table := range []string{s.authTable, s.configTable} {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				id TEXT PRIMARY KEY,
+				content TEXT NOT NULL,
+				version BIGINT NOT NULL,
+				created_at BIGINT NOT NULL,
+				updated_at BIGINT NOT NULL
+			)
+		`, table)); err != nil {
+			return fmt.Errorf("sql store: create table %s: %w", table, err)
+		}
+	}
+	if s.cfg.Driver == "sqlite" {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				id TEXT PRIMARY KEY,
+				holder TEXT NOT NULL,
+				expires_at BIGINT NOT NULL
+			)
+		`, s.lockTable)); err != nil {
+			return fmt.Errorf("sql store: create refresh lock table: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetBaseDir records the auth directory callers derive auth paths from, so Delete accepts the
+// paths they pass as well as auth IDs.
+func (s *SQLStore) SetBaseDir(dir string) {
+	s.mu.Lock()
+	s.baseDir = strings.TrimSpace(dir)
+	s.mu.Unlock()
+}
+
+// Bootstrap brings the configuration file in step with the stored config, seeding the database
+// from the local file, or from the example config when there is none, on first use. The file is
+// required: the watcher reloads configuration from it and the management API edits it.
+func (s *SQLStore) Bootstrap(ctx context.Context, exampleConfigPath string) error {
+	path := s.cfg.ConfigPath
+	if path == "" {
+		return nil
+	}
+	content, version, err := s.readConfigRecord(ctx)
+	if err != nil {
+		return err
+	}
+	if version > 0 {
+		return s.applyConfigRecord(content, version)
+	}
+	if _, errStat := os.Stat(path); errors.Is(errStat, fs.ErrNotExist) {
+		if exampleConfigPath != "" {
+			if errCopy := misc.CopyConfigTemplate(exampleConfigPath, path); errCopy != nil {
+				return fmt.Errorf("sql store: copy example config: %w", errCopy)
+			}
+		} else {
+			if errCreate := os.MkdirAll(filepath.Dir(path), 0o700); errCreate != nil {
+				return fmt.Errorf("sql store: prepare config directory: %w", errCreate)
+			}
+			if errWrite := os.WriteFile(path, []byte{}, 0o600); errWrite != nil {
+				return fmt.Errorf("sql store: create empty config: %w", errWrite)
+			}
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("sql store: read local config: %w", err)
+	}
+	content = normalizeLineEndings(string(data))
+	now := time.Now().UnixMilli()
+	result, err := s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(
+		"INSERT INTO %s (id, content, version, created_at, updated_at) VALUES (?, ?, 1, ?, ?) ON CONFLICT (id) DO NOTHING",
+		s.configTable,
+	)), defaultConfigKey, content, now, now)
+	if err != nil {
+		return fmt.Errorf("sql store: seed config: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		// Another instance seeded the config first; take its copy.
+		if content, version, err = s.readConfigRecord(ctx); err != nil {
+			return err
+		}
+		return s.applyConfigRecord(content, version)
+	}
+	s.mu.Lock()
+	s.config = sqlRecordState{version: 1, digest: contentDigest([]byte(content))}
+	s.configLoaded = true
+	s.mu.Unlock()
+	return nil
+}
+
+// Save writes auth to the database. The write is skipped when the content is unchanged, and
+// fails with ErrVersionConflict when another writer changed the record first, unless ctx marks
+// the save as carrying rotated credentials: those are merged into the newer record.
+func (s *SQLStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (string, error) {
+	if auth == nil {
+		return "", fmt.Errorf("sql store: auth is nil")
+	}
+	id, err := s.recordID(auth.ID, auth.FileName)
+	if err != nil {
+		return "", err
+	}
+	raw, err := authContent(auth)
+	if err != nil {
+		return "", err
+	}
+	digest := contentDigest(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, known := s.known[id]
+	if auth.Disabled && !known {
+		return "", nil
+	}
+	if known && state.digest == digest {
+		return id, nil
+	}
+	sealed, err := authcrypt.Seal(raw)
+	if err != nil {
+		return "", fmt.Errorf("sql store: encrypt auth %s: %w", id, err)
+	}
+	now := time.Now().UnixMilli()
+	var result sql.Result
+	if known {
+		result, err = s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(
+			"UPDATE %s SET content = ?, version = version + 1, updated_at = ? WHERE id = ? AND version = ?",
+			s.authTable,
+		)), string(sealed), now, id, state.version)
+	} else {
+		result, err = s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(
+			"INSERT INTO %s (id, content, version, created_at, updated_at) VALUES (?, ?, 1, ?, ?) ON CONFLICT (id) DO NOTHING",
+			s.authTable,
+		)), id, string(sealed), now, now)
+	}
+	if err != nil {
+		return "", fmt.Errorf("sql store: save auth %s: %w", id, err)
+	}
+	if affected, errAffected := result.RowsAffected(); errAffected != nil || affected == 0 {
+		if !cliproxyauth.RotatedCredentials(ctx) {
+			s.signalChange()
+			return "", fmt.Errorf("sql store: save auth %s: %w", id, ErrVersionConflict)
+		}
+		if err = s.mergeRotated(ctx, id, raw); err != nil {
+			s.signalChange()
+			return "", err
+		}
+	} else {
+		s.known[id] = sqlRecordState{version: state.version + 1, digest: digest}
+	}
+	s.notifyChange(ctx)
+
+	if strings.TrimSpace(auth.FileName) == "" {
+		auth.FileName = id
+	}
+	return id, nil
+}
+
+// mergeRotated writes the freshly rotated credentials in raw over the fields of the record
+// another writer changed, keeping that writer's disabled flag. The caller holds s.mu.
+func (s *SQLStore) mergeRotated(ctx context.Context, id string, raw []byte) error {
+	var rotated map[string]any
+	if err := json.Unmarshal(raw, &rotated); err != nil {
+		return fmt.Errorf("sql store: decode auth %s: %w", id, err)
+	}
+	for attempt := 0; attempt < sqlStoreMergeAttempts; attempt++ {
+		record, err := s.readAuthRecord(ctx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			// Removed by another writer; the removal wins.
+			return fmt.Errorf("sql store: save auth %s: %w", id, ErrVersionConflict)
+		}
+		stored, _, err := record.auth()
+		if err != nil {
+			return fmt.Errorf("sql store: auth %s: %w", id, err)
+		}
+		merged := stored.Metadata
+		for key, value := range rotated {
+			merged[key] = value
+		}
+		merged["disabled"] = stored.Disabled
+		content, err := json.Marshal(merged)
+		if err != nil {
+			return fmt.Errorf("sql store: marshal metadata of %s: %w", id, err)
+		}
+		sealed, err := authcrypt.Seal(content)
+		if err != nil {
+			return fmt.Errorf("sql store: encrypt auth %s: %w", id, err)
+		}
+		result, err := s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(
+			"UPDATE %s SET content = ?, version = version + 1, updated_at = ? WHERE id = ? AND version = ?",
+			s.authTable,
+		)), string(sealed), time.Now().UnixMilli(), id, record.version)
+		if err != nil {
+			return fmt.Errorf("sql store: save auth %s: %w", id, err)
+		}
+		if affected, errAffected := result.RowsAffected(); errAffected == nil && affected > 0 {
+			s.known[id] = sqlRecordState{version: record.version + 1, digest: contentDigest(content)}
+			log.Debugf("sql store: merged rotated credentials of %s into version %d", id, record.version)
+			return nil
+		}
+	}
+	return fmt.Errorf("sql store: save auth %s: %w", id, ErrVersionConflict)
+}
+
+// List returns every stored auth.
+func (s *SQLStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, content, version, created_at, updated_at FROM %s ORDER BY id", s.authTable,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("sql store: list auth: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	auths := make([]*cliproxyauth.Auth, 0, 32)
+	known := make(map[string]sqlRecordState)
+	for rows.Next() {
+		var record sqlAuthRecord
+		if err = rows.Scan(&record.id, &record.content, &record.version, &record.createdAt, &record.updatedAt); err != nil {
+			return nil, fmt.Errorf("sql store: scan auth row: %w", err)
+		}
+		auth, digest, errRecord := record.auth()
+		if errRecord != nil {
+			log.WithError(errRecord).Warnf("sql store: skipping auth %s", record.id)
+			continue
+		}
+		known[record.id] = sqlRecordState{version: record.version, digest: digest}
+		auths = append(auths, auth)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("sql store: iterate auth rows: %w", err)
+	}
+	s.mu.Lock()
+	s.known = known
+	s.mu.Unlock()
+	return auths, nil
+}
+
+// Read implements cliproxyauth.AuthReader. The caller is expected to adopt the returned auth:
+// later saves are checked against its version.
+func (s *SQLStore) Read(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	if auth == nil {
+		return nil, fmt.Errorf("sql store: auth is nil")
+	}
+	id, err := s.recordID(auth.ID, auth.FileName)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.readAuthRecord(ctx, id)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	stored, digest, err := record.auth()
+	if err != nil {
+		return nil, fmt.Errorf("sql store: auth %s: %w", id, err)
+	}
+	s.mu.Lock()
+	s.known[id] = sqlRecordState{version: record.version, digest: digest}
+	s.mu.Unlock()
+	return stored, nil
+}
+
+// Delete removes the auth identified by id, which may also be its path under the base dir.
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	id, err := s.recordID(id, "")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.db.ExecContext(ctx, s.rebind(fmt.Sprintf("DELETE FROM %s WHERE id = ?", s.authTable)), id); err != nil {
+		return fmt.Errorf("sql store: delete auth %s: %w", id, err)
+	}
+	delete(s.known, id)
+	s.notifyChange(ctx)
+	return nil
+}
+
+// LockRefresh implements cliproxyauth.RefreshLocker: PostgreSQL takes a session advisory lock,
+// SQLite a lock row that expires after sqlStoreLockTTL.
+func (s *SQLStore) LockRefresh(ctx context.Context, auth *cliproxyauth.Auth) (func(), error) {
+	if auth == nil {
+		return nil, fmt.Errorf("sql store: auth is nil")
+	}
+	id, err := s.recordID(auth.ID, auth.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Driver == "postgres" {
+		return advisoryLock(ctx, s.db, s.authTable+"/"+id)
+	}
+	holder := uuid.NewString()
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (id, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE %[1]s.expires_at <= ?
+	`, s.lockTable)
+	for {
+		now := time.Now()
+		result, errLock := s.db.ExecContext(ctx, query, id, holder, now.Add(sqlStoreLockTTL).UnixMilli(), now.UnixMilli())
+		if errLock != nil {
+			return nil, fmt.Errorf("sql store: refresh lock of %s: %w", id, errLock)
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			return func() {
+				if _, errRelease := s.db.ExecContext(context.Background(), fmt.Sprintf(
+					"DELETE FROM %s WHERE id = ? AND holder = ?", s.lockTable,
+				), id, holder); errRelease != nil {
+					log.WithError(errRelease).Warnf("sql store: failed to release refresh lock of %s", id)
+				}
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("sql store: wait for refresh lock of %s: %w", id, ctx.Err())
+		case <-time.After(sqlStoreLockPoll):
+		}
+	}
+}
+
+// Watch implements cliproxyauth.ChangeWatcher. PostgreSQL stores wake on notifications from
+// other writers and scan every PollInterval in case one was missed; SQLite stores scan every
+// PollInterval. Each scan compares row versions with those this process last saw, and also
+// rewrites the configuration file when the stored config changed.
+func (s *SQLStore) Watch(ctx context.Context, onChange func(id string, auth *cliproxyauth.Auth)) error {
+	if s.cfg.Driver == "postgres" {
+		go s.listen(ctx)
+	}
+	ticker := time.NewTicker(s.pollInterval())
+	defer ticker.Stop()
+	initial := true
+	for {
+		if err := s.syncChanges(ctx, initial, onChange); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warnf("sql store: failed to sync changes: %v", err)
+		} else {
+			initial = false
+		}
+		if err := s.syncConfig(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("sql store: failed to sync config: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-s.changeSignals:
+		}
+	}
+}
+
+// PersistConfig writes the configuration file to the database after a local edit.
+func (s *SQLStore) PersistConfig(ctx context.Context) error {
+	if s.cfg.ConfigPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.cfg.ConfigPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("sql store: read config file: %w", err)
+	}
+	content := normalizeLineEndings(string(data))
+	digest := contentDigest([]byte(content))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.configLoaded && s.config.digest == digest {
+		return nil
+	}
+	result, err := s.db.ExecContext(ctx, s.rebind(fmt.Sprintf(
+		"UPDATE %s SET content = ?, version = version + 1, updated_at = ? WHERE id = ? AND version = ?",
+		s.configTable,
+	)), content, time.Now().UnixMilli(), defaultConfigKey, s.config.version)
+	if err != nil {
+		return fmt.Errorf("sql store: save config: %w", err)
+	}
+	if affected, errAffected := result.RowsAffected(); errAffected != nil || affected == 0 {
+		s.signalChange()
+		return fmt.Errorf("sql store: save config: %w", ErrVersionConflict)
+	}
+	s.config = sqlRecordState{version: s.config.version + 1, digest: digest}
+	s.configLoaded = true
+	s.notifyChange(ctx)
+	return nil
+}
+
+// PersistAuthFiles is a no-op: auths reach the database through Save, never through files.
+func (s *SQLStore) PersistAuthFiles(context.Context, string, ...string) error {
+	return nil
+}
+
+// syncChanges reports the auths whose version differs from the one this process last saw, or
+// every auth when all is set, and the auths no longer stored.
+func (s *SQLStore) syncChanges(ctx context.Context, all bool, onChange func(id string, auth *cliproxyauth.Auth)) error {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT id, version FROM %s", s.authTable))
+	if err != nil {
+		return fmt.Errorf("query auth versions: %w", err)
+	}
+	versions := make(map[string]int64)
+	for rows.Next() {
+		var (
+			id      string
+			version int64
+		)
+		if err = rows.Scan(&id, &version); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan auth version: %w", err)
+		}
+		versions[id] = version
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("iterate auth versions: %w", err)
+	}
+
+	var changed, removed []string
+	s.mu.Lock()
+	for id, version := range versions {
+		if state, ok := s.known[id]; all || !ok || state.version != version {
+			changed = append(changed, id)
+		}
+	}
+	for id := range s.known {
+		if _, ok := versions[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range changed {
+		record, errRead := s.readAuthRecord(ctx, id)
+		if errRead != nil {
+			return errRead
+		}
+		if record == nil {
+			continue
+		}
+		auth, digest, errRecord := record.auth()
+		if errRecord != nil {
+			log.WithError(errRecord).Warnf("sql store: skipping auth %s", id)
+			continue
+		}
+		s.mu.Lock()
+		// A local save may have moved past the version read here.
+		if state, ok := s.known[id]; ok && state.version > record.version {
+			s.mu.Unlock()
+			continue
+		}
+		s.known[id] = sqlRecordState{version: record.version, digest: digest}
+		s.mu.Unlock()
+		onChange(id, auth)
+	}
+	for _, id := range removed {
+		// The auth may have been saved locally after the versions were read.
+		record, errRead := s.readAuthRecord(ctx, id)
+		if errRead != nil {
+			return errRead
+		}
+		if record != nil {
+			continue
+		}
+		s.mu.Lock()
+		delete(s.known, id)
+		s.mu.Unlock()
+		onChange(id, nil)
+	}
+	return nil
+}
+
+// syncConfig rewrites the configuration file when another writer changed the stored config;
+// the file watcher then reloads it.
+func (s *SQLStore) syncConfig(ctx context.Context) error {
+	if s.cfg.ConfigPath == "" {
+		return nil
+	}
+	var version int64
+	err := s.db.QueryRowContext(ctx, s.rebind(fmt.Sprintf(
+		"SELECT version FROM %s WHERE id = ?", s.configTable,
+	)), defaultConfigKey).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("query config version: %w", err)
+	}
+	s.mu.Lock()
+	current := s.config.version
+	s.mu.Unlock()
+	if version == current {
+		return nil
+	}
+	content, version, err := s.readConfigRecord(ctx)
+	if err != nil || version == 0 {
+		return err
+	}
+	return s.applyConfigRecord(content, version)
+}
+
+// applyConfigRecord writes the stored config to the configuration file unless it already holds it.
+func (s *SQLStore) applyConfigRecord(content string, version int64) error {
+	content = normalizeLineEndings(content)
+	digest := contentDigest([]byte(content))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.configLoaded && version < s.config.version {
+		return nil
+	}
+	existing, err := os.ReadFile(s.cfg.ConfigPath)
+	if err != nil || contentDigest([]byte(normalizeLineEndings(string(existing)))) != digest {
+		if err = os.MkdirAll(filepath.Dir(s.cfg.ConfigPath), 0o700); err != nil {
+			return fmt.Errorf("sql store: prepare config directory: %w", err)
+		}
+		tmp := s.cfg.ConfigPath + ".tmp"
+		if err = os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			return fmt.Errorf("sql store: write config: %w", err)
+		}
+		if err = os.Rename(tmp, s.cfg.ConfigPath); err != nil {
+			return fmt.Errorf("sql store: replace config: %w", err)
+		}
+	}
+	s.config = sqlRecordState{version: version, digest: digest}
+	s.configLoaded = true
+	return nil
+}
+
+func (s *SQLStore) readConfigRecord(ctx context.Context) (string, int64, error) {
+	var (
+		content string
+		version int64
+	)
+	err := s.db.QueryRowContext(ctx, s.rebind(fmt.Sprintf(
+		"SELECT content, version FROM %s WHERE id = ?", s.configTable,
+	)), defaultConfigKey).Scan(&content, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("sql store: load config: %w", err)
+	}
+	return content, version, nil
+}
+
+func (s *SQLStore) readAuthRecord(ctx context.Context, id string) (*sqlAuthRecord, error) {
+	record := &sqlAuthRecord{id: id}
+	err := s.db.QueryRowContext(ctx, s.rebind(fmt.Sprintf(
+		"SELECT content, version, created_at, updated_at FROM %s WHERE id = ?", s.authTable,
+	)), id).Scan(&record.content, &record.version, &record.createdAt, &record.updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sql store: read auth %s: %w", id, err)
+	}
+	return record, nil
+}
+
+// listen wakes Watch on every change notification, reconnecting after connection failures.
+func (s *SQLStore) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := s.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warnf("sql store: change notifications interrupted, polling until reconnected: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollInterval()):
+		}
+	}
+}
+
+func (s *SQLStore) listenOnce(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.Raw(func(driverConn any) error {
+		pgConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		if _, errListen := pgConn.Conn().Exec(ctx, "LISTEN "+quoteIdentifier(sqlStoreChannel)); errListen != nil {
+			return errListen
+		}
+		defer func() { _, _ = pgConn.Conn().Exec(context.Background(), "UNLISTEN *") }()
+		// Changes made before LISTEN took effect are picked up by this scan.
+		s.signalChange()
+		for {
+			if _, errWait := pgConn.Conn().WaitForNotification(ctx); errWait != nil {
+				return errWait
+			}
+			s.signalChange()
+		}
+	})
+}
+
+// notifyChange tells other PostgreSQL listeners that a record changed.
+func (s *SQLStore) notifyChange(ctx context.Context) {
+	if s.cfg.Driver != "postgres" {
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, "SELECT pg_notify($1, '')", sqlStoreChannel); err != nil {
+		log.Debugf("sql store: failed to notify change: %v", err)
+	}
+}
+
+func (s *SQLStore) signalChange() {
+	select {
+	case s.changeSignals <- struct{}{}:
+	default:
+	}
+}
+
+func (s *SQLStore) pollInterval() time.Duration {
+	if s.cfg.PollInterval > 0 {
+		return s.cfg.PollInterval
+	}
+	if s.cfg.Driver == "postgres" {
+		return defaultSQLStoreListenPoll
+	}
+	return defaultSQLStorePoll
+}
+
+// recordID returns the row ID of an auth, accepting paths under the base dir as well.
+func (s *SQLStore) recordID(id, fileName string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		id = strings.TrimSpace(fileName)
+	}
+	if id == "" {
+		return "", fmt.Errorf("sql store: missing auth id")
+	}
+	if filepath.IsAbs(id) {
+		s.mu.Lock()
+		baseDir := s.baseDir
+		s.mu.Unlock()
+		rel, err := filepath.Rel(baseDir, id)
+		if baseDir == "" || err != nil || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(id)
+		}
+		id = rel
+	}
+	return normalizeAuthID(id), nil
+}
+
+// rebind converts '?' placeholders into PostgreSQL positional parameters.
+func (s *SQLStore) rebind(query string) string {
+	if s.cfg.Driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sqlAuthRecord is an auth row as stored.
+type sqlAuthRecord struct {
+	id        string
+	content   string
+	version   int64
+	createdAt int64
+	updatedAt int64
+}
+
+// auth decodes the record, returning the digest of its plain content alongside.
+func (r *sqlAuthRecord) auth() (*cliproxyauth.Auth, string, error) {
+	plain, err := authcrypt.Open([]byte(r.content))
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot be decrypted: %w", err)
+	}
+	metadata := make(map[string]any)
+	if err = json.Unmarshal(plain, &metadata); err != nil {
+		return nil, "", fmt.Errorf("invalid json: %w", err)
+	}
+	provider := strings.TrimSpace(valueAsString(metadata["type"]))
+	if provider == "" {
+		provider = "unknown"
+	}
+	disabled, _ := metadata["disabled"].(bool)
+	status := cliproxyauth.StatusActive
+	if disabled {
+		status = cliproxyauth.StatusDisabled
+	}
+	attr := map[string]string{"source": "sql:" + r.id}
+	if email := strings.TrimSpace(valueAsString(metadata["email"])); email != "" {
+		attr["email"] = email
+	}
+	return &cliproxyauth.Auth{
+		ID:         r.id,
+		Provider:   provider,
+		FileName:   r.id,
+		Label:      labelFor(metadata),
+		Status:     status,
+		Disabled:   disabled,
+		Attributes: attr,
+		Metadata:   metadata,
+		CreatedAt:  time.UnixMilli(r.createdAt),
+		UpdatedAt:  time.UnixMilli(r.updatedAt),
+	}, contentDigest(plain), nil
+}
+
+// authContent returns the JSON stored for auth, recording its disabled flag the way the file
+// store does.
+func authContent(auth *cliproxyauth.Auth) ([]byte, error) {
+	metadata := make(map[string]any)
+	switch {
+	case auth.Storage != nil:
+		data, err := authcrypt.EncodeStorage(auth.Storage, auth.Provider)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("sql store: decode token storage of %s: %w", auth.ID, err)
+		}
+	case auth.Metadata != nil:
+		for key, value := range auth.Metadata {
+			metadata[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("sql store: nothing to persist for %s", auth.ID)
+	}
+	metadata["disabled"] = auth.Disabled
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("sql store: marshal metadata of %s: %w", auth.ID, err)
+	}
+	return raw, nil
+}
+
+// advisoryLock takes a PostgreSQL session advisory lock keyed on name, held on a dedicated
+// connection until released.
+func advisoryLock(ctx context.Context, db *sql.DB, name string) (func(), error) {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name))
+	key := int64(hash.Sum64())
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection for refresh lock: %w", err)
+	}
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("refresh lock: %w", err)
+	}
+	return func() {
+		if _, errUnlock := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); errUnlock != nil {
+			log.WithError(errUnlock).Warnf("failed to release refresh lock %s", name)
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+func contentDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
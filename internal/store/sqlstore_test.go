@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestSQLiteStoreVersionsChangesAndConfig(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "cliproxy.db")
+	open := func(name string) *SQLStore {
+		configPath := filepath.Join(dir, name, "config.yaml")
+		if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		s, err := NewSQLStore(ctx, SQLStoreConfig{Driver: "sqlite", Path: dbPath, ConfigPath: configPath})
+		if err != nil {
+			t.Fatalf("NewSQLStore %s: %v", name, err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		if err = s.Bootstrap(ctx, ""); err != nil {
+			t.Fatalf("Bootstrap %s: %v", name, err)
+		}
+		return s
+	}
+	a, b := open("a"), open("b")
+	changes := func(s *SQLStore) map[string]*cliproxyauth.Auth {
+		seen := make(map[string]*cliproxyauth.Auth)
+		if err := s.syncChanges(ctx, false, func(id string, auth *cliproxyauth.Auth) { seen[id] = auth }); err != nil {
+			t.Fatalf("syncChanges: %v", err)
+		}
+		return seen
+	}
+
+	auth := &cliproxyauth.Auth{ID: "codex-user.json", Provider: "codex", Metadata: map[string]any{"type": "codex", "refresh_token": "rt-1"}}
+	if _, err := a.Save(ctx, auth); err != nil {
+		t.Fatalf("a Save: %v", err)
+	}
+	if seen := changes(b); seen["codex-user.json"] == nil || seen["codex-user.json"].Metadata["refresh_token"] != "rt-1" {
+		t.Fatalf("b changes = %v, want the auth saved by a", seen)
+	}
+	if seen := changes(a); len(seen) != 0 {
+		t.Fatalf("a changes = %v, want its own save skipped", seen)
+	}
+
+	rotated := auth.Clone()
+	rotated.Metadata["refresh_token"] = "rt-2"
+	if _, err := b.Save(ctx, rotated); err != nil {
+		t.Fatalf("b Save: %v", err)
+	}
+	stale := auth.Clone()
+	stale.Metadata["refresh_token"] = "rt-stale"
+	if _, err := a.Save(ctx, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("a Save over a newer version = %v, want ErrVersionConflict", err)
+	}
+	if seen := changes(a); seen["codex-user.json"] == nil || seen["codex-user.json"].Metadata["refresh_token"] != "rt-2" {
+		t.Fatalf("a changes = %v, want the rotation saved by b", seen)
+	}
+	if _, err := a.Save(ctx, stale); err != nil {
+		t.Fatalf("a Save after catching up: %v", err)
+	}
+
+	if err := b.Delete(ctx, filepath.Join(dir, "codex-user.json")); err != nil {
+		t.Fatalf("b Delete: %v", err)
+	}
+	if seen := changes(a); len(seen) != 1 || seen["codex-user.json"] != nil {
+		t.Fatalf("a changes = %v, want the removal", seen)
+	}
+
+	unlock, err := a.LockRefresh(ctx, auth)
+	if err != nil {
+		t.Fatalf("a LockRefresh: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if _, err = b.LockRefresh(waitCtx, auth); err == nil {
+		t.Fatal("b took a refresh lock a holds")
+	}
+	unlock()
+	if unlock, err = b.LockRefresh(ctx, auth); err != nil {
+		t.Fatalf("b LockRefresh after release: %v", err)
+	}
+	unlock()
+
+	if err = os.WriteFile(a.cfg.ConfigPath, []byte("port: 9000\n"), 0o600); err != nil {
+		t.Fatalf("edit config: %v", err)
+	}
+	if err = a.PersistConfig(ctx); err != nil {
+		t.Fatalf("a PersistConfig: %v", err)
+	}
+	if err = b.syncConfig(ctx); err != nil {
+		t.Fatalf("b syncConfig: %v", err)
+	}
+	if data, _ := os.ReadFile(b.cfg.ConfigPath); string(data) != "port: 9000\n" {
+		t.Fatalf("b config = %q, want the edit made through a", data)
+	}
+	if err = os.WriteFile(b.cfg.ConfigPath, []byte("port: 9001\n"), 0o600); err != nil {
+		t.Fatalf("edit config: %v", err)
+	}
+	if err = a.PersistConfig(ctx); err != nil {
+		t.Fatalf("a PersistConfig unchanged: %v", err)
+	}
+	if err = b.PersistConfig(ctx); err != nil {
+		t.Fatalf("b PersistConfig: %v", err)
+	}
+}
+
+func TestSQLiteStoreSavesTokenStorageWithoutSpooling(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	s, err := NewSQLStore(ctx, SQLStoreConfig{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "cliproxy.db")})
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	storage := &codex.CodexTokenStorage{RefreshToken: "rt-login", Email: "user@example.com"}
+	auth := &cliproxyauth.Auth{ID: "codex-login.json", Provider: "codex", Storage: storage}
+	if _, err = s.Save(ctx, auth); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	auths, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(auths) != 1 || auths[0].Provider != "codex" || auths[0].Metadata["refresh_token"] != "rt-login" {
+		t.Fatalf("auths = %+v, want the codex login", auths)
+	}
+}
+
+func TestSQLiteStoreMergesRotatedCredentialsOnConflict(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "cliproxy.db")
+	open := func() *SQLStore {
+		s, err := NewSQLStore(ctx, SQLStoreConfig{Driver: "sqlite", Path: dbPath})
+		if err != nil {
+			t.Fatalf("NewSQLStore: %v", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	}
+	a, b := open(), open()
+
+	auth := &cliproxyauth.Auth{ID: "codex-user.json", Provider: "codex", Metadata: map[string]any{"type": "codex", "refresh_token": "rt-1"}}
+	if _, err := a.Save(ctx, auth); err != nil {
+		t.Fatalf("a Save: %v", err)
+	}
+	if _, err := b.List(ctx); err != nil {
+		t.Fatalf("b List: %v", err)
+	}
+	edited := auth.Clone()
+	edited.Disabled = true
+	edited.Metadata["label"] = "edited on b"
+	if _, err := b.Save(ctx, edited); err != nil {
+		t.Fatalf("b Save: %v", err)
+	}
+
+	// a refreshes from its outdated copy; the rotated token must not be dropped.
+	refreshed := auth.Clone()
+	refreshed.Metadata["refresh_token"] = "rt-2"
+	if _, err := a.Save(ctx, refreshed); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("plain Save over a newer version = %v, want ErrVersionConflict", err)
+	}
+	if _, err := a.Save(cliproxyauth.WithRotatedCredentials(ctx), refreshed); err != nil {
+		t.Fatalf("Save of rotated credentials: %v", err)
+	}
+	stored, err := b.Read(ctx, auth)
+	if err != nil || stored == nil {
+		t.Fatalf("b Read = %v, %v", stored, err)
+	}
+	if stored.Metadata["refresh_token"] != "rt-2" || stored.Metadata["label"] != "edited on b" || !stored.Disabled {
+		t.Fatalf("stored = %+v (disabled %t), want the rotated token merged into b's edit", stored.Metadata, stored.Disabled)
+	}
+	seen := make(map[string]*cliproxyauth.Auth)
+	if err = a.syncChanges(ctx, false, func(id string, auth *cliproxyauth.Auth) { seen[id] = auth }); err != nil {
+		t.Fatalf("syncChanges: %v", err)
+	}
+	if len(seen) != 0 {
+		t.Fatalf("a changes = %v, want its merged save not replayed over the refreshed auth", seen)
+	}
+}
@@ -0,0 +1,536 @@
+package tokenizer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// CountRequest approximates the prompt tokens of a request in the given source format
+// ("openai", "openai-response", "codex", "claude", "gemini" or "gemini-cli"). Unknown formats
+// are counted as OpenAI chat completions.
+func CountRequest(t *Tokenizer, format string, payload []byte) (int64, error) {
+	switch format {
+	case "claude":
+		return CountClaude(t, payload)
+	case "openai-response", "codex":
+		return CountResponses(t, payload)
+	case "gemini":
+		return CountGemini(t, payload)
+	case "gemini-cli":
+		return CountGemini(t, []byte(gjson.GetBytes(payload, "request").Raw))
+	default:
+		return CountOpenAIChat(t, payload)
+	}
+}
+
+// CountResponse renders a token count as the count_tokens response of the given source format.
+func CountResponse(format string, count int64) []byte {
+	switch format {
+	case "claude":
+		return []byte(fmt.Sprintf(`{"input_tokens":%d}`, count))
+	case "gemini", "gemini-cli":
+		return []byte(fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count))
+	default:
+		return []byte(fmt.Sprintf(`{"object":"response.input_tokens","input_tokens":%d}`, count))
+	}
+}
+
+// CountOpenAIChat approximates prompt tokens for OpenAI chat completions payloads.
+func CountOpenAIChat(t *Tokenizer, payload []byte) (int64, error) {
+	if t == nil {
+		return 0, fmt.Errorf("encoder is nil")
+	}
+	if len(payload) == 0 {
+		return 0, nil
+	}
+
+	root := gjson.ParseBytes(payload)
+	segments := make([]string, 0, 32)
+
+	collectOpenAIMessages(root.Get("messages"), &segments)
+	collectOpenAITools(root.Get("tools"), &segments)
+	collectOpenAIFunctions(root.Get("functions"), &segments)
+	collectOpenAIToolChoice(root.Get("tool_choice"), &segments)
+	collectOpenAIResponseFormat(root.Get("response_format"), &segments)
+	addIfNotEmpty(&segments, root.Get("input").String())
+	addIfNotEmpty(&segments, root.Get("prompt").String())
+
+	return countSegments(t, segments)
+}
+
+// CountClaude approximates prompt tokens for Claude API chat completions payloads.
+// This handles Claude's message format with system, messages, and tools.
+// Image tokens are estimated based on image dimensions when available.
+func CountClaude(t *Tokenizer, payload []byte) (int64, error) {
+	if t == nil {
+		return 0, fmt.Errorf("encoder is nil")
+	}
+	if len(payload) == 0 {
+		return 0, nil
+	}
+
+	root := gjson.ParseBytes(payload)
+	segments := make([]string, 0, 32)
+
+	// Collect system prompt (can be string or array of content blocks)
+	collectClaudeSystem(root.Get("system"), &segments)
+
+	// Collect messages
+	collectClaudeMessages(root.Get("messages"), &segments)
+
+	// Collect tools
+	collectClaudeTools(root.Get("tools"), &segments)
+
+	return countSegments(t, segments)
+}
+
+// CountResponses approximates prompt tokens for OpenAI Responses (and Codex) payloads.
+func CountResponses(t *Tokenizer, payload []byte) (int64, error) {
+	if t == nil {
+		return 0, fmt.Errorf("encoder is nil")
+	}
+	if len(payload) == 0 {
+		return 0, nil
+	}
+
+	root := gjson.ParseBytes(payload)
+	segments := make([]string, 0, 32)
+
+	addIfNotEmpty(&segments, root.Get("instructions").String())
+
+	input := root.Get("input")
+	if input.Type == gjson.String {
+		addIfNotEmpty(&segments, input.String())
+	} else if input.IsArray() {
+		input.ForEach(func(_, item gjson.Result) bool {
+			switch item.Get("type").String() {
+			case "message", "":
+				addIfNotEmpty(&segments, item.Get("role").String())
+				collectOpenAIContent(item.Get("content"), &segments)
+			case "function_call":
+				addIfNotEmpty(&segments, item.Get("name").String())
+				addIfNotEmpty(&segments, item.Get("arguments").String())
+			case "function_call_output":
+				addIfNotEmpty(&segments, item.Get("output").String())
+			default:
+				addIfNotEmpty(&segments, item.Get("text").String())
+			}
+			return true
+		})
+	}
+
+	collectOpenAITools(root.Get("tools"), &segments)
+	if tools := root.Get("tools"); tools.IsArray() {
+		// Responses tools keep parameters at the top level instead of under "function".
+		tools.ForEach(func(_, tool gjson.Result) bool {
+			if params := tool.Get("parameters"); params.Exists() {
+				addIfNotEmpty(&segments, params.Raw)
+			}
+			return true
+		})
+	}
+	collectOpenAIToolChoice(root.Get("tool_choice"), &segments)
+	if format := root.Get("text.format"); format.Exists() {
+		collectOpenAIResponseFormat(format, &segments)
+	}
+
+	return countSegments(t, segments)
+}
+
+// CountGemini approximates prompt tokens for Gemini generateContent payloads. The countTokens
+// request shape, which wraps the request in "generateContentRequest", is accepted too.
+func CountGemini(t *Tokenizer, payload []byte) (int64, error) {
+	if t == nil {
+		return 0, fmt.Errorf("encoder is nil")
+	}
+	if len(payload) == 0 {
+		return 0, nil
+	}
+
+	root := gjson.ParseBytes(payload)
+	if wrapped := root.Get("generateContentRequest"); wrapped.IsObject() {
+		root = wrapped
+	}
+	segments := make([]string, 0, 32)
+
+	systemInstruction := root.Get("systemInstruction")
+	if !systemInstruction.Exists() {
+		systemInstruction = root.Get("system_instruction")
+	}
+	collectGeminiParts(systemInstruction.Get("parts"), &segments)
+	root.Get("contents").ForEach(func(_, content gjson.Result) bool {
+		addIfNotEmpty(&segments, content.Get("role").String())
+		collectGeminiParts(content.Get("parts"), &segments)
+		return true
+	})
+	root.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+		declarations := tool.Get("functionDeclarations")
+		if !declarations.Exists() {
+			declarations = tool.Get("function_declarations")
+		}
+		if declarations.Exists() {
+			addIfNotEmpty(&segments, declarations.Raw)
+		}
+		return true
+	})
+
+	return countSegments(t, segments)
+}
+
+// geminiImageTokens is the flat token cost Gemini charges for an image.
+const geminiImageTokens = 258
+
+func collectGeminiParts(parts gjson.Result, segments *[]string) {
+	if !parts.IsArray() {
+		return
+	}
+	parts.ForEach(func(_, part gjson.Result) bool {
+		switch {
+		case part.Get("text").Exists():
+			addIfNotEmpty(segments, part.Get("text").String())
+		case part.Get("functionCall").Exists():
+			addIfNotEmpty(segments, part.Get("functionCall.name").String())
+			addIfNotEmpty(segments, part.Get("functionCall.args").Raw)
+		case part.Get("functionResponse").Exists():
+			addIfNotEmpty(segments, part.Get("functionResponse.name").String())
+			addIfNotEmpty(segments, part.Get("functionResponse.response").Raw)
+		case part.Get("inlineData").Exists(), part.Get("inline_data").Exists(), part.Get("fileData").Exists():
+			addIfNotEmpty(segments, fmt.Sprintf("[IMAGE:%d tokens]", geminiImageTokens))
+		}
+		return true
+	})
+}
+
+// countSegments counts the joined text segments plus the estimated image tokens.
+func countSegments(t *Tokenizer, segments []string) (int64, error) {
+	joined := strings.TrimSpace(strings.Join(segments, "\n"))
+	if joined == "" {
+		return 0, nil
+	}
+
+	// Count text tokens
+	count, err := t.Count(joined)
+	if err != nil {
+		return 0, err
+	}
+
+	// Extract and add image tokens from placeholders
+	imageTokens := extractImageTokens(joined)
+
+	return int64(count) + int64(imageTokens), nil
+}
+
+// imageTokenPattern matches [IMAGE:xxx tokens] format for extracting estimated image tokens
+var imageTokenPattern = regexp.MustCompile(`\[IMAGE:(\d+) tokens\]`)
+
+// extractImageTokens extracts image token estimates from placeholder text.
+// Placeholders are in the format [IMAGE:xxx tokens] where xxx is the estimated token count.
+func extractImageTokens(text string) int {
+	matches := imageTokenPattern.FindAllStringSubmatch(text, -1)
+	total := 0
+	for _, match := range matches {
+		if len(match) > 1 {
+			if tokens, err := strconv.Atoi(match[1]); err == nil {
+				total += tokens
+			}
+		}
+	}
+	return total
+}
+
+// estimateImageTokens calculates estimated tokens for an image based on dimensions.
+// Based on Claude's image token calculation: tokens ≈ (width * height) / 750
+// Minimum 85 tokens, maximum 1590 tokens (for 1568x1568 images).
+func estimateImageTokens(width, height float64) int {
+	if width <= 0 || height <= 0 {
+		// No valid dimensions, use default estimate (medium-sized image)
+		return 1000
+	}
+
+	tokens := int(width * height / 750)
+
+	// Apply bounds
+	if tokens < 85 {
+		tokens = 85
+	}
+	if tokens > 1590 {
+		tokens = 1590
+	}
+
+	return tokens
+}
+
+// collectClaudeSystem extracts text from Claude's system field.
+// System can be a string or an array of content blocks.
+func collectClaudeSystem(system gjson.Result, segments *[]string) {
+	if !system.Exists() {
+		return
+	}
+	if system.Type == gjson.String {
+		addIfNotEmpty(segments, system.String())
+		return
+	}
+	if system.IsArray() {
+		system.ForEach(func(_, block gjson.Result) bool {
+			blockType := block.Get("type").String()
+			if blockType == "text" || blockType == "" {
+				addIfNotEmpty(segments, block.Get("text").String())
+			}
+			// Also handle plain string blocks
+			if block.Type == gjson.String {
+				addIfNotEmpty(segments, block.String())
+			}
+			return true
+		})
+	}
+}
+
+// collectClaudeMessages extracts text from Claude's messages array.
+func collectClaudeMessages(messages gjson.Result, segments *[]string) {
+	if !messages.Exists() || !messages.IsArray() {
+		return
+	}
+	messages.ForEach(func(_, message gjson.Result) bool {
+		addIfNotEmpty(segments, message.Get("role").String())
+		collectClaudeContent(message.Get("content"), segments)
+		return true
+	})
+}
+
+// collectClaudeContent extracts text from Claude's content field.
+// Content can be a string or an array of content blocks.
+// For images, estimates token count based on dimensions when available.
+func collectClaudeContent(content gjson.Result, segments *[]string) {
+	if !content.Exists() {
+		return
+	}
+	if content.Type == gjson.String {
+		addIfNotEmpty(segments, content.String())
+		return
+	}
+	if content.IsArray() {
+		content.ForEach(func(_, part gjson.Result) bool {
+			partType := part.Get("type").String()
+			switch partType {
+			case "text":
+				addIfNotEmpty(segments, part.Get("text").String())
+			case "image":
+				// Estimate image tokens based on dimensions if available
+				source := part.Get("source")
+				if source.Exists() {
+					width := source.Get("width").Float()
+					height := source.Get("height").Float()
+					if width > 0 && height > 0 {
+						tokens := estimateImageTokens(width, height)
+						addIfNotEmpty(segments, fmt.Sprintf("[IMAGE:%d tokens]", tokens))
+					} else {
+						// No dimensions available, use default estimate
+						addIfNotEmpty(segments, "[IMAGE:1000 tokens]")
+					}
+				} else {
+					// No source info, use default estimate
+					addIfNotEmpty(segments, "[IMAGE:1000 tokens]")
+				}
+			case "tool_use":
+				addIfNotEmpty(segments, part.Get("id").String())
+				addIfNotEmpty(segments, part.Get("name").String())
+				if input := part.Get("input"); input.Exists() {
+					addIfNotEmpty(segments, input.Raw)
+				}
+			case "tool_result":
+				addIfNotEmpty(segments, part.Get("tool_use_id").String())
+				collectClaudeContent(part.Get("content"), segments)
+			case "thinking":
+				addIfNotEmpty(segments, part.Get("thinking").String())
+			default:
+				// For unknown types, try to extract any text content
+				if part.Type == gjson.String {
+					addIfNotEmpty(segments, part.String())
+				} else if part.Type == gjson.JSON {
+					addIfNotEmpty(segments, part.Raw)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// collectClaudeTools extracts text from Claude's tools array.
+func collectClaudeTools(tools gjson.Result, segments *[]string) {
+	if !tools.Exists() || !tools.IsArray() {
+		return
+	}
+	tools.ForEach(func(_, tool gjson.Result) bool {
+		addIfNotEmpty(segments, tool.Get("name").String())
+		addIfNotEmpty(segments, tool.Get("description").String())
+		if inputSchema := tool.Get("input_schema"); inputSchema.Exists() {
+			addIfNotEmpty(segments, inputSchema.Raw)
+		}
+		return true
+	})
+}
+
+func collectOpenAIMessages(messages gjson.Result, segments *[]string) {
+	if !messages.Exists() || !messages.IsArray() {
+		return
+	}
+	messages.ForEach(func(_, message gjson.Result) bool {
+		addIfNotEmpty(segments, message.Get("role").String())
+		addIfNotEmpty(segments, message.Get("name").String())
+		collectOpenAIContent(message.Get("content"), segments)
+		collectOpenAIToolCalls(message.Get("tool_calls"), segments)
+		collectOpenAIFunctionCall(message.Get("function_call"), segments)
+		return true
+	})
+}
+
+func collectOpenAIContent(content gjson.Result, segments *[]string) {
+	if !content.Exists() {
+		return
+	}
+	if content.Type == gjson.String {
+		addIfNotEmpty(segments, content.String())
+		return
+	}
+	if content.IsArray() {
+		content.ForEach(func(_, part gjson.Result) bool {
+			partType := part.Get("type").String()
+			switch partType {
+			case "text", "input_text", "output_text":
+				addIfNotEmpty(segments, part.Get("text").String())
+			case "image_url":
+				addIfNotEmpty(segments, part.Get("image_url.url").String())
+			case "input_audio", "output_audio", "audio":
+				addIfNotEmpty(segments, part.Get("id").String())
+			case "tool_result":
+				addIfNotEmpty(segments, part.Get("name").String())
+				collectOpenAIContent(part.Get("content"), segments)
+			default:
+				if part.IsArray() {
+					collectOpenAIContent(part, segments)
+					return true
+				}
+				if part.Type == gjson.JSON {
+					addIfNotEmpty(segments, part.Raw)
+					return true
+				}
+				addIfNotEmpty(segments, part.String())
+			}
+			return true
+		})
+		return
+	}
+	if content.Type == gjson.JSON {
+		addIfNotEmpty(segments, content.Raw)
+	}
+}
+
+func collectOpenAIToolCalls(calls gjson.Result, segments *[]string) {
+	if !calls.Exists() || !calls.IsArray() {
+		return
+	}
+	calls.ForEach(func(_, call gjson.Result) bool {
+		addIfNotEmpty(segments, call.Get("id").String())
+		addIfNotEmpty(segments, call.Get("type").String())
+		function := call.Get("function")
+		if function.Exists() {
+			addIfNotEmpty(segments, function.Get("name").String())
+			addIfNotEmpty(segments, function.Get("description").String())
+			addIfNotEmpty(segments, function.Get("arguments").String())
+			if params := function.Get("parameters"); params.Exists() {
+				addIfNotEmpty(segments, params.Raw)
+			}
+		}
+		return true
+	})
+}
+
+func collectOpenAIFunctionCall(call gjson.Result, segments *[]string) {
+	if !call.Exists() {
+		return
+	}
+	addIfNotEmpty(segments, call.Get("name").String())
+	addIfNotEmpty(segments, call.Get("arguments").String())
+}
+
+func collectOpenAITools(tools gjson.Result, segments *[]string) {
+	if !tools.Exists() {
+		return
+	}
+	if tools.IsArray() {
+		tools.ForEach(func(_, tool gjson.Result) bool {
+			appendToolPayload(tool, segments)
+			return true
+		})
+		return
+	}
+	appendToolPayload(tools, segments)
+}
+
+func collectOpenAIFunctions(functions gjson.Result, segments *[]string) {
+	if !functions.Exists() || !functions.IsArray() {
+		return
+	}
+	functions.ForEach(func(_, function gjson.Result) bool {
+		addIfNotEmpty(segments, function.Get("name").String())
+		addIfNotEmpty(segments, function.Get("description").String())
+		if params := function.Get("parameters"); params.Exists() {
+			addIfNotEmpty(segments, params.Raw)
+		}
+		return true
+	})
+}
+
+func collectOpenAIToolChoice(choice gjson.Result, segments *[]string) {
+	if !choice.Exists() {
+		return
+	}
+	if choice.Type == gjson.String {
+		addIfNotEmpty(segments, choice.String())
+		return
+	}
+	addIfNotEmpty(segments, choice.Raw)
+}
+
+func collectOpenAIResponseFormat(format gjson.Result, segments *[]string) {
+	if !format.Exists() {
+		return
+	}
+	addIfNotEmpty(segments, format.Get("type").String())
+	addIfNotEmpty(segments, format.Get("name").String())
+	if schema := format.Get("json_schema"); schema.Exists() {
+		addIfNotEmpty(segments, schema.Raw)
+	}
+	if schema := format.Get("schema"); schema.Exists() {
+		addIfNotEmpty(segments, schema.Raw)
+	}
+}
+
+func appendToolPayload(tool gjson.Result, segments *[]string) {
+	if !tool.Exists() {
+		return
+	}
+	addIfNotEmpty(segments, tool.Get("type").String())
+	addIfNotEmpty(segments, tool.Get("name").String())
+	addIfNotEmpty(segments, tool.Get("description").String())
+	if function := tool.Get("function"); function.Exists() {
+		addIfNotEmpty(segments, function.Get("name").String())
+		addIfNotEmpty(segments, function.Get("description").String())
+		if params := function.Get("parameters"); params.Exists() {
+			addIfNotEmpty(segments, params.Raw)
+		}
+	}
+}
+
+func addIfNotEmpty(segments *[]string, value string) {
+	if segments == nil {
+		return
+	}
+	if trimmed := strings.TrimSpace(value); trimmed != "" {
+		*segments = append(*segments, trimmed)
+	}
+}
@@ -0,0 +1,102 @@
+// Package tokenizer counts prompt tokens locally. OpenAI and Codex models use their tiktoken
+// BPE encodings; Claude and Gemini models use tiktoken approximations. A model can pick its
+// tokenizer explicitly through registry.ModelInfo.Tokenizer.
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	tiktoken "github.com/tiktoken-go/tokenizer"
+)
+
+// Tokenizer names accepted by ModelInfo.Tokenizer. Any other tiktoken encoding name such as
+// "p50k_base" is accepted as well.
+const (
+	// O200kBase is the encoding of GPT-4o, GPT-4.1, GPT-5 and the o-series models.
+	O200kBase = "o200k_base"
+	// Cl100kBase is the encoding of GPT-4 and GPT-3.5.
+	Cl100kBase = "cl100k_base"
+	// Claude approximates Claude models with cl100k_base scaled up by 10%, since tiktoken
+	// underestimates Claude's token counts.
+	Claude = "claude"
+	// Gemini approximates Gemini's SentencePiece vocabulary with o200k_base.
+	Gemini = "gemini"
+)
+
+// Tokenizer wraps a tokenizer codec with an adjustment factor for models
+// where tiktoken may not accurately estimate token counts (e.g., Claude models)
+type Tokenizer struct {
+	// Name is the tokenizer name the codec was resolved from.
+	Name             string
+	Codec            tiktoken.Codec
+	AdjustmentFactor float64 // 1.0 means no adjustment, >1.0 means tiktoken underestimates
+}
+
+// Count returns the token count with adjustment factor applied
+func (t *Tokenizer) Count(text string) (int, error) {
+	count, err := t.Codec.Count(text)
+	if err != nil {
+		return 0, err
+	}
+	if t.AdjustmentFactor != 1.0 && t.AdjustmentFactor > 0 {
+		return int(float64(count) * t.AdjustmentFactor), nil
+	}
+	return count, nil
+}
+
+// cache stores tokenizers by name to avoid reloading encodings.
+var cache sync.Map
+
+// Get returns the cached tokenizer with the given name.
+func Get(name string) (*Tokenizer, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if cached, ok := cache.Load(name); ok {
+		return cached.(*Tokenizer), nil
+	}
+	encoding, factor := tiktoken.Encoding(name), 1.0
+	switch name {
+	case Claude:
+		encoding, factor = tiktoken.Cl100kBase, 1.1
+	case Gemini:
+		encoding = tiktoken.O200kBase
+	}
+	codec, err := tiktoken.Get(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: unknown tokenizer %q: %w", name, err)
+	}
+	actual, _ := cache.LoadOrStore(name, &Tokenizer{Name: name, Codec: codec, AdjustmentFactor: factor})
+	return actual.(*Tokenizer), nil
+}
+
+// ForModel returns the tokenizer for a model: the one configured in the model registry when
+// set, otherwise the one NameForModel infers from the model name.
+func ForModel(model string) (*Tokenizer, error) {
+	if info := registry.GetGlobalRegistry().GetModelInfo(strings.TrimSpace(model), ""); info != nil && strings.TrimSpace(info.Tokenizer) != "" {
+		if tok, err := Get(info.Tokenizer); err == nil {
+			return tok, nil
+		}
+	}
+	return Get(NameForModel(model))
+}
+
+// NameForModel infers a tokenizer name from a model name.
+func NameForModel(model string) string {
+	sanitized := strings.ToLower(strings.TrimSpace(model))
+	switch {
+	case sanitized == "":
+		return Cl100kBase
+	case strings.Contains(sanitized, "claude"), strings.HasPrefix(sanitized, "kiro-"), strings.HasPrefix(sanitized, "amazonq-"):
+		return Claude
+	case strings.HasPrefix(sanitized, "gemini"), strings.HasPrefix(sanitized, "gemma"):
+		return Gemini
+	case strings.HasPrefix(sanitized, "gpt-4.1"), strings.HasPrefix(sanitized, "gpt-4o"):
+		return O200kBase
+	case strings.HasPrefix(sanitized, "gpt-4"), strings.HasPrefix(sanitized, "gpt-3"):
+		return Cl100kBase
+	default:
+		return O200kBase
+	}
+}
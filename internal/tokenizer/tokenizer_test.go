@@ -0,0 +1,84 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+func TestForModelPrefersRegistryTokenizer(t *testing.T) {
+	cases := map[string]string{
+		"claude-sonnet-4-5": Claude,
+		"kiro-auto":         Claude,
+		"gemini-2.5-pro":    Gemini,
+		"gpt-4o-mini":       O200kBase,
+		"gpt-4-turbo":       Cl100kBase,
+		"gpt-5-codex":       O200kBase,
+		"some-local-model":  O200kBase,
+	}
+	for model, want := range cases {
+		if got := NameForModel(model); got != want {
+			t.Errorf("NameForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+
+	registry.GetGlobalRegistry().RegisterClient("tokenizer-test", "openai-compatibility", []*registry.ModelInfo{{ID: "tokenizer-test-model", Tokenizer: Cl100kBase}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("tokenizer-test") })
+	tok, err := ForModel("tokenizer-test-model")
+	if err != nil {
+		t.Fatalf("ForModel: %v", err)
+	}
+	if tok.Name != Cl100kBase {
+		t.Fatalf("tokenizer = %q, want the registry's %q", tok.Name, Cl100kBase)
+	}
+	if _, err = Get("not-a-tokenizer"); err == nil {
+		t.Fatalf("unknown tokenizer names should fail")
+	}
+}
+
+func TestCountRequestFormats(t *testing.T) {
+	tok, err := Get(O200kBase)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	text, err := tok.Count("Tell me a joke about tokenizers.")
+	if err != nil || text == 0 {
+		t.Fatalf("Count = %d, %v", text, err)
+	}
+	payloads := map[string]string{
+		"openai":          `{"model":"m","messages":[{"role":"user","content":"Tell me a joke about tokenizers."}]}`,
+		"openai-response": `{"model":"m","input":[{"type":"message","role":"user","content":[{"type":"input_text","text":"Tell me a joke about tokenizers."}]}]}`,
+		"claude":          `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"Tell me a joke about tokenizers."}]}]}`,
+		"gemini":          `{"contents":[{"role":"user","parts":[{"text":"Tell me a joke about tokenizers."}]}]}`,
+		"gemini-cli":      `{"request":{"contents":[{"role":"user","parts":[{"text":"Tell me a joke about tokenizers."}]}]}}`,
+	}
+	for format, payload := range payloads {
+		count, errCount := CountRequest(tok, format, []byte(payload))
+		if errCount != nil {
+			t.Fatalf("%s: %v", format, errCount)
+		}
+		// Every format adds the role to the prompt text.
+		if count <= int64(text) || count > int64(text)+4 {
+			t.Errorf("%s: count = %d, text alone = %d", format, count, text)
+		}
+	}
+
+	withImage := `{"contents":[{"parts":[{"text":"hi"},{"inlineData":{"mimeType":"image/png","data":"AAAA"}}]}]}`
+	if count, _ := CountRequest(tok, "gemini", []byte(withImage)); count < geminiImageTokens {
+		t.Errorf("gemini image count = %d, want at least %d", count, geminiImageTokens)
+	}
+}
+
+func TestCountResponseShapes(t *testing.T) {
+	if got := gjson.GetBytes(CountResponse("claude", 7), "input_tokens").Int(); got != 7 {
+		t.Errorf("claude input_tokens = %d", got)
+	}
+	if got := gjson.GetBytes(CountResponse("gemini", 7), "totalTokens").Int(); got != 7 {
+		t.Errorf("gemini totalTokens = %d", got)
+	}
+	openai := CountResponse("openai-response", 7)
+	if gjson.GetBytes(openai, "object").String() != "response.input_tokens" || gjson.GetBytes(openai, "input_tokens").Int() != 7 {
+		t.Errorf("openai response = %s", openai)
+	}
+}
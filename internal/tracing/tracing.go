@@ -0,0 +1,199 @@
+// Package tracing exports OpenTelemetry spans for proxied requests over OTLP/HTTP.
+// A request produces a server span for the inbound handler with child spans for
+// request translation, each auth attempt made by the conductor, the upstream HTTP
+// call and response translation. Tracing is disabled by default; while disabled,
+// Start returns the context unchanged and a no-op span.
+package tracing
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the spans produced by this module.
+const InstrumentationName = "github.com/router-for-me/CLIProxyAPI/v6"
+
+const (
+	defaultServiceName = "cli-proxy-api"
+	defaultTracesPath  = "/v1/traces"
+	shutdownTimeout    = 5 * time.Second
+)
+
+var (
+	mu       sync.Mutex
+	provider *sdktrace.TracerProvider
+	current  config.TracingConfig
+
+	tracer  atomic.Pointer[trace.Tracer]
+	enabled atomic.Bool
+)
+
+// noopSpan is returned by Start while tracing is disabled.
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// Configure applies cfg, replacing the active exporter when the settings changed.
+// Disabling tracing flushes and shuts down the previous exporter in the background.
+func Configure(cfg config.TracingConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if provider != nil && cfg.Enable && reflect.DeepEqual(cfg, current) {
+		return nil
+	}
+
+	var next *sdktrace.TracerProvider
+	if cfg.Enable {
+		var err error
+		next, err = newProvider(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := provider
+	provider = next
+	current = cfg
+	if next != nil {
+		t := next.Tracer(InstrumentationName)
+		tracer.Store(&t)
+		enabled.Store(true)
+	} else {
+		enabled.Store(false)
+		tracer.Store(nil)
+	}
+
+	if previous != nil {
+		go shutdownProvider(previous)
+	}
+	return nil
+}
+
+// Shutdown flushes pending spans and stops the exporter.
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	previous := provider
+	provider = nil
+	current = config.TracingConfig{}
+	enabled.Store(false)
+	tracer.Store(nil)
+	mu.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+	return previous.Shutdown(ctx)
+}
+
+// Enabled reports whether spans are currently exported.
+func Enabled() bool { return enabled.Load() }
+
+// Start begins a span named name as a child of the span in ctx.
+// While tracing is disabled it returns ctx unchanged and a no-op span.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !enabled.Load() {
+		return ctx, noopSpan
+	}
+	t := tracer.Load()
+	if t == nil {
+		return ctx, noopSpan
+	}
+	return (*t).Start(ctx, name, opts...)
+}
+
+// InheritSpan returns parent carrying the span active in from, so work derived from a detached
+// context (for example one created from context.Background) stays within the request trace.
+func InheritSpan(parent, from context.Context) context.Context {
+	if parent == nil || from == nil {
+		return parent
+	}
+	span := trace.SpanFromContext(from)
+	if !span.SpanContext().IsValid() {
+		return parent
+	}
+	return trace.ContextWithSpan(parent, span)
+}
+
+// EndSpan records err on span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func newProvider(cfg config.TracingConfig) (*sdktrace.TracerProvider, error) {
+	opts := make([]otlptracehttp.Option, 0, 2)
+	if endpoint := normalizeEndpoint(cfg.Endpoint); endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := strings.TrimSpace(cfg.ServiceName)
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	), nil
+}
+
+// normalizeEndpoint adds a scheme and the default traces path when they are missing.
+func normalizeEndpoint(endpoint string) string {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return ""
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if parsed.Path == "" || parsed.Path == "/" {
+		parsed.Path = defaultTracesPath
+	}
+	return parsed.String()
+}
+
+func shutdownProvider(p *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		log.Debugf("tracing: shutdown previous exporter: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpCollector is a minimal OTLP/HTTP trace receiver.
+type otlpCollector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collectortrace.ExportTraceServiceRequest
+	if err = proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	for _, resourceSpans := range req.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			c.spans = append(c.spans, scopeSpans.GetSpans()...)
+		}
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(nil)
+}
+
+func (c *otlpCollector) byName() map[string]*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]*tracepb.Span, len(c.spans))
+	for _, span := range c.spans {
+		out[span.GetName()] = span
+	}
+	return out
+}
+
+func TestTracingExportsRequestSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	collector := &otlpCollector{}
+	collectorServer := httptest.NewServer(collector)
+	defer collectorServer.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer upstream.Close()
+
+	if err := Configure(config.TracingConfig{Enable: true, Endpoint: collectorServer.URL, ServiceName: "test"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	defer func() { _ = Shutdown(context.Background()) }()
+
+	client := &http.Client{Transport: Transport(nil)}
+	var traceID string
+	engine := gin.New()
+	engine.Use(Middleware(func(*gin.Context) string { return "req-1" }))
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		ctx := InheritSpan(context.Background(), c.Request.Context())
+		ctx, span := Start(ctx, "auth.execute")
+		traceID = span.SpanContext().TraceID().String()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL+"/v1/messages", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			EndSpan(span, err)
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace id = %s, want the incoming traceparent trace", traceID)
+	}
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	spans := collector.byName()
+	server := spans["POST /v1/chat/completions"]
+	attempt := spans["auth.execute"]
+	upstreamSpan := spans["HTTP POST "+upstream.Listener.Addr().String()]
+	if server == nil || attempt == nil || upstreamSpan == nil {
+		t.Fatalf("missing spans, got %v", spans)
+	}
+	if string(attempt.GetParentSpanId()) != string(server.GetSpanId()) {
+		t.Fatalf("attempt span is not a child of the server span")
+	}
+	if string(upstreamSpan.GetParentSpanId()) != string(attempt.GetSpanId()) {
+		t.Fatalf("upstream span is not a child of the attempt span")
+	}
+	var requestID string
+	for _, attr := range server.GetAttributes() {
+		if attr.GetKey() == "cliproxy.request_id" {
+			requestID = attr.GetValue().GetStringValue()
+		}
+	}
+	if requestID != "req-1" {
+		t.Fatalf("request id attribute = %q", requestID)
+	}
+
+	ctx, span := Start(context.Background(), "disabled")
+	if span.SpanContext().IsValid() || ctx != context.Background() {
+		t.Fatalf("Start should be a no-op after Shutdown")
+	}
+}
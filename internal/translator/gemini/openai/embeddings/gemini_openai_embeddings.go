@@ -0,0 +1,259 @@
+// Package embeddings converts embedding requests and responses between the OpenAI
+// /v1/embeddings format and the Gemini embedContent/batchEmbedContents formats.
+// Vertex AI's predict format is handled as a variant of the Gemini batch format.
+//
+// Embeddings do not go through the chat translator registry because they share the
+// source/target format pairs of chat requests; executors call these helpers directly.
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// IsGeminiBatchRequest reports whether a Gemini-format request is a batchEmbedContents request.
+func IsGeminiBatchRequest(rawJSON []byte) bool {
+	return gjson.GetBytes(rawJSON, "requests").IsArray()
+}
+
+// OpenAIInputTexts returns the texts of an OpenAI embeddings request.
+// Token-array inputs are rejected because Gemini embeds text only.
+func OpenAIInputTexts(rawJSON []byte) ([]string, error) {
+	input := gjson.GetBytes(rawJSON, "input")
+	switch {
+	case input.Type == gjson.String:
+		return []string{input.String()}, nil
+	case input.IsArray():
+		items := input.Array()
+		if len(items) == 0 {
+			return nil, fmt.Errorf("input must not be empty")
+		}
+		texts := make([]string, 0, len(items))
+		for _, item := range items {
+			if item.Type != gjson.String {
+				return nil, fmt.Errorf("token array inputs are not supported by this model")
+			}
+			texts = append(texts, item.String())
+		}
+		return texts, nil
+	default:
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+}
+
+// GeminiInputTexts returns the texts of a Gemini embedContent or batchEmbedContents request.
+func GeminiInputTexts(rawJSON []byte) []string {
+	var texts []string
+	collect := func(content gjson.Result) {
+		var parts []string
+		content.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			if text := part.Get("text"); text.Exists() {
+				parts = append(parts, text.String())
+			}
+			return true
+		})
+		texts = append(texts, strings.Join(parts, "\n"))
+	}
+	if IsGeminiBatchRequest(rawJSON) {
+		gjson.GetBytes(rawJSON, "requests").ForEach(func(_, request gjson.Result) bool {
+			collect(request.Get("content"))
+			return true
+		})
+		return texts
+	}
+	collect(gjson.GetBytes(rawJSON, "content"))
+	return texts
+}
+
+// ConvertOpenAIEmbeddingsRequestToGemini converts an OpenAI embeddings request into a
+// Gemini batchEmbedContents request for modelName.
+func ConvertOpenAIEmbeddingsRequestToGemini(modelName string, rawJSON []byte) ([]byte, error) {
+	texts, err := OpenAIInputTexts(rawJSON)
+	if err != nil {
+		return nil, err
+	}
+	dimensions := gjson.GetBytes(rawJSON, "dimensions")
+	out := []byte(`{"requests":[]}`)
+	for i, text := range texts {
+		request := []byte(`{"content":{"parts":[{"text":""}]}}`)
+		request, _ = sjson.SetBytes(request, "model", "models/"+modelName)
+		request, _ = sjson.SetBytes(request, "content.parts.0.text", text)
+		if dimensions.Exists() && dimensions.Int() > 0 {
+			request, _ = sjson.SetBytes(request, "outputDimensionality", dimensions.Int())
+		}
+		out, _ = sjson.SetRawBytes(out, fmt.Sprintf("requests.%d", i), request)
+	}
+	return out, nil
+}
+
+// NormalizeGeminiRequestToBatch converts a Gemini embedContent request into a single-entry
+// batchEmbedContents request and pins every entry to modelName.
+func NormalizeGeminiRequestToBatch(modelName string, rawJSON []byte) []byte {
+	out := rawJSON
+	if !IsGeminiBatchRequest(rawJSON) {
+		single, _ := sjson.DeleteBytes(rawJSON, "model")
+		out, _ = sjson.SetRawBytes([]byte(`{"requests":[]}`), "requests.0", single)
+	}
+	count := len(gjson.GetBytes(out, "requests").Array())
+	for i := 0; i < count; i++ {
+		out, _ = sjson.SetBytes(out, fmt.Sprintf("requests.%d.model", i), "models/"+modelName)
+	}
+	return out
+}
+
+// ConvertGeminiBatchResponseToSingle converts a batchEmbedContents response into the
+// embedContent response shape.
+func ConvertGeminiBatchResponseToSingle(rawJSON []byte) []byte {
+	first := gjson.GetBytes(rawJSON, "embeddings.0")
+	if !first.Exists() {
+		return []byte(`{"embedding":{"values":[]}}`)
+	}
+	out, _ := sjson.SetRawBytes([]byte(`{}`), "embedding", []byte(first.Raw))
+	return out
+}
+
+// ConvertGeminiEmbeddingsResponseToOpenAI converts a Gemini batchEmbedContents response into
+// an OpenAI embeddings response. originalRequest selects the encoding format.
+func ConvertGeminiEmbeddingsResponseToOpenAI(modelName string, originalRequest, rawJSON []byte, inputTokens int64) ([]byte, error) {
+	base64Encoding := strings.EqualFold(gjson.GetBytes(originalRequest, "encoding_format").String(), "base64")
+	out := []byte(`{"object":"list","data":[],"model":"","usage":{"prompt_tokens":0,"total_tokens":0}}`)
+	out, _ = sjson.SetBytes(out, "model", modelName)
+	var convErr error
+	gjson.GetBytes(rawJSON, "embeddings").ForEach(func(key, embedding gjson.Result) bool {
+		item := []byte(`{"object":"embedding","index":0,"embedding":[]}`)
+		item, _ = sjson.SetBytes(item, "index", key.Int())
+		values := embedding.Get("values")
+		if base64Encoding {
+			encoded, err := encodeBase64Floats(values)
+			if err != nil {
+				convErr = err
+				return false
+			}
+			item, _ = sjson.SetBytes(item, "embedding", encoded)
+		} else if values.IsArray() {
+			item, _ = sjson.SetRawBytes(item, "embedding", []byte(values.Raw))
+		}
+		out, _ = sjson.SetRawBytes(out, fmt.Sprintf("data.%d", key.Int()), item)
+		return true
+	})
+	if convErr != nil {
+		return nil, convErr
+	}
+	out, _ = sjson.SetBytes(out, "usage.prompt_tokens", inputTokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", inputTokens)
+	return out, nil
+}
+
+// ConvertGeminiEmbeddingsRequestToOpenAI converts a Gemini embedContent or batchEmbedContents
+// request into an OpenAI embeddings request for modelName.
+func ConvertGeminiEmbeddingsRequestToOpenAI(modelName string, rawJSON []byte) []byte {
+	out := []byte(`{"model":"","input":[]}`)
+	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = sjson.SetBytes(out, "input", GeminiInputTexts(rawJSON))
+	dimensions := gjson.GetBytes(rawJSON, "outputDimensionality")
+	if !dimensions.Exists() {
+		dimensions = gjson.GetBytes(rawJSON, "requests.0.outputDimensionality")
+	}
+	if dimensions.Exists() && dimensions.Int() > 0 {
+		out, _ = sjson.SetBytes(out, "dimensions", dimensions.Int())
+	}
+	return out
+}
+
+// ConvertOpenAIEmbeddingsResponseToGemini converts an OpenAI embeddings response into a
+// Gemini batchEmbedContents response.
+func ConvertOpenAIEmbeddingsResponseToGemini(rawJSON []byte) []byte {
+	out := []byte(`{"embeddings":[]}`)
+	gjson.GetBytes(rawJSON, "data").ForEach(func(key, item gjson.Result) bool {
+		index := key.Int()
+		if idx := item.Get("index"); idx.Exists() {
+			index = idx.Int()
+		}
+		values := item.Get("embedding")
+		raw := values.Raw
+		if values.Type == gjson.String {
+			raw = decodeBase64Floats(values.String())
+		}
+		out, _ = sjson.SetRawBytes(out, fmt.Sprintf("embeddings.%d.values", index), []byte(raw))
+		return true
+	})
+	return out
+}
+
+// ConvertGeminiBatchRequestToVertexPredict converts a batchEmbedContents request into a
+// Vertex AI predict request for text embedding models.
+func ConvertGeminiBatchRequestToVertexPredict(rawJSON []byte) []byte {
+	out := []byte(`{"instances":[]}`)
+	texts := GeminiInputTexts(rawJSON)
+	requests := gjson.GetBytes(rawJSON, "requests").Array()
+	for i, text := range texts {
+		instance := []byte(`{"content":""}`)
+		instance, _ = sjson.SetBytes(instance, "content", text)
+		if i < len(requests) {
+			if taskType := requests[i].Get("taskType").String(); taskType != "" {
+				instance, _ = sjson.SetBytes(instance, "task_type", taskType)
+			}
+			if title := requests[i].Get("title").String(); title != "" {
+				instance, _ = sjson.SetBytes(instance, "title", title)
+			}
+		}
+		out, _ = sjson.SetRawBytes(out, fmt.Sprintf("instances.%d", i), instance)
+	}
+	if dimensions := gjson.GetBytes(rawJSON, "requests.0.outputDimensionality"); dimensions.Exists() && dimensions.Int() > 0 {
+		out, _ = sjson.SetBytes(out, "parameters.outputDimensionality", dimensions.Int())
+	}
+	return out
+}
+
+// ConvertVertexPredictResponseToGemini converts a Vertex AI predict response into a Gemini
+// batchEmbedContents response and returns the token count Vertex reported.
+func ConvertVertexPredictResponseToGemini(rawJSON []byte) ([]byte, int64) {
+	out := []byte(`{"embeddings":[]}`)
+	var tokens int64
+	gjson.GetBytes(rawJSON, "predictions").ForEach(func(key, prediction gjson.Result) bool {
+		values := prediction.Get("embeddings.values")
+		if !values.IsArray() {
+			values = gjson.Parse("[]")
+		}
+		out, _ = sjson.SetRawBytes(out, fmt.Sprintf("embeddings.%d.values", key.Int()), []byte(values.Raw))
+		tokens += prediction.Get("embeddings.statistics.token_count").Int()
+		return true
+	})
+	return out, tokens
+}
+
+func encodeBase64Floats(values gjson.Result) (string, error) {
+	if !values.IsArray() {
+		return "", fmt.Errorf("embedding values are missing")
+	}
+	items := values.Array()
+	buf := make([]byte, 4*len(items))
+	for i, value := range items {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(value.Float())))
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func decodeBase64Floats(encoded string) string {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data)%4 != 0 {
+		return "[]"
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	for i := 0; i < len(data); i += 4 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := math.Float32frombits(binary.LittleEndian.Uint32(data[i:]))
+		fmt.Fprintf(&b, "%g", value)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package embeddings
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIEmbeddingsRoundTrip(t *testing.T) {
+	request := []byte(`{"model":"embed","input":["a","b"],"dimensions":8,"encoding_format":"base64"}`)
+	body, err := ConvertOpenAIEmbeddingsRequestToGemini("gemini-embedding-001", request)
+	if err != nil {
+		t.Fatalf("convert request: %v", err)
+	}
+	requests := gjson.GetBytes(body, "requests").Array()
+	if len(requests) != 2 || requests[1].Get("model").String() != "models/gemini-embedding-001" ||
+		requests[1].Get("content.parts.0.text").String() != "b" || requests[0].Get("outputDimensionality").Int() != 8 {
+		t.Fatalf("unexpected gemini request: %s", body)
+	}
+	if _, err = ConvertOpenAIEmbeddingsRequestToGemini("m", []byte(`{"input":[[1,2]]}`)); err == nil {
+		t.Fatalf("token array input should be rejected")
+	}
+
+	upstream := []byte(`{"embeddings":[{"values":[0.5,-1]},{"values":[0.25,2]}]}`)
+	out, err := ConvertGeminiEmbeddingsResponseToOpenAI("embed", request, upstream, 3)
+	if err != nil {
+		t.Fatalf("convert response: %v", err)
+	}
+	if gjson.GetBytes(out, "usage.prompt_tokens").Int() != 3 || gjson.GetBytes(out, "data.1.index").Int() != 1 {
+		t.Fatalf("unexpected openai response: %s", out)
+	}
+	encoded := gjson.GetBytes(out, "data.0.embedding")
+	if encoded.Type != gjson.String {
+		t.Fatalf("base64 encoding_format should produce a string embedding: %s", out)
+	}
+	if got := gjson.Parse(decodeBase64Floats(encoded.String())).Array(); len(got) != 2 || got[0].Float() != 0.5 || got[1].Float() != -1 {
+		t.Fatalf("decoded embedding = %v", got)
+	}
+
+	back := ConvertOpenAIEmbeddingsResponseToGemini(out)
+	if gjson.GetBytes(back, "embeddings.1.values.1").Float() != 2 {
+		t.Fatalf("unexpected gemini response: %s", back)
+	}
+	if single := ConvertGeminiBatchResponseToSingle(back); gjson.GetBytes(single, "embedding.values.0").Float() != 0.5 {
+		t.Fatalf("unexpected embedContent response: %s", single)
+	}
+}
+
+func TestNormalizeGeminiRequestToBatchAndVertexPredict(t *testing.T) {
+	single := []byte(`{"model":"models/other","content":{"parts":[{"text":"hello"}]},"taskType":"RETRIEVAL_QUERY","outputDimensionality":4}`)
+	batch := NormalizeGeminiRequestToBatch("text-embedding-005", single)
+	if gjson.GetBytes(batch, "requests.0.model").String() != "models/text-embedding-005" || gjson.GetBytes(batch, "requests.0.content.parts.0.text").String() != "hello" {
+		t.Fatalf("unexpected batch request: %s", batch)
+	}
+	predict := ConvertGeminiBatchRequestToVertexPredict(batch)
+	if gjson.GetBytes(predict, "instances.0.task_type").String() != "RETRIEVAL_QUERY" || gjson.GetBytes(predict, "parameters.outputDimensionality").Int() != 4 {
+		t.Fatalf("unexpected predict request: %s", predict)
+	}
+	out, tokens := ConvertVertexPredictResponseToGemini([]byte(`{"predictions":[{"embeddings":{"values":[1,2],"statistics":{"token_count":5}}}]}`))
+	if tokens != 5 || gjson.GetBytes(out, "embeddings.0.values.1").Int() != 2 {
+		t.Fatalf("predict response = %s, tokens = %d", out, tokens)
+	}
+}
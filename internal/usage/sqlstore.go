@@ -0,0 +1,484 @@
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const usageRecordsTable = "usage_records"
+
+// sqlDialect captures the few differences between the SQLite and PostgreSQL backends.
+type sqlDialect int
+
+const (
+	dialectSQLite sqlDialect = iota
+	dialectPostgres
+)
+
+// sqlStore implements Store on top of database/sql.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+	table   string
+	ownsDB  bool
+}
+
+// OpenSQLiteStore opens (creating when missing) a SQLite usage database at path.
+func OpenSQLiteStore(ctx context.Context, path string) (Store, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("usage store: sqlite path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("usage store: create sqlite directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("usage store: open sqlite database: %w", err)
+	}
+	// SQLite serialises writers; a single connection avoids SQLITE_BUSY churn.
+	db.SetMaxOpenConns(1)
+	store := &sqlStore{db: db, dialect: dialectSQLite, table: usageRecordsTable, ownsDB: true}
+	if err = store.ensureSchema(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// OpenPostgresStore connects to PostgreSQL using dsn and prepares the usage table.
+func OpenPostgresStore(ctx context.Context, dsn string) (Store, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("usage store: open postgres connection: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("usage store: ping postgres: %w", err)
+	}
+	store, err := NewPostgresUsageStore(ctx, db, "", true)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewPostgresUsageStore prepares the usage table on an existing PostgreSQL connection.
+// When ownsDB is false, Close leaves the connection open for its other users.
+func NewPostgresUsageStore(ctx context.Context, db *sql.DB, schema string, ownsDB bool) (Store, error) {
+	if db == nil {
+		return nil, fmt.Errorf("usage store: postgres connection is nil")
+	}
+	table := quoteSQLIdentifier(usageRecordsTable)
+	if schema = strings.TrimSpace(schema); schema != "" {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteSQLIdentifier(schema))); err != nil {
+			return nil, fmt.Errorf("usage store: create schema: %w", err)
+		}
+		table = quoteSQLIdentifier(schema) + "." + table
+	}
+	store := &sqlStore{db: db, dialect: dialectPostgres, table: table, ownsDB: ownsDB}
+	if err := store.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *sqlStore) ensureSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			requested_at BIGINT NOT NULL,
+			api_key TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			auth_id TEXT NOT NULL,
+			auth_index TEXT NOT NULL,
+			source TEXT NOT NULL,
+			failed INTEGER NOT NULL,
+			input_tokens BIGINT NOT NULL,
+			output_tokens BIGINT NOT NULL,
+			reasoning_tokens BIGINT NOT NULL,
+			cached_tokens BIGINT NOT NULL,
+			total_tokens BIGINT NOT NULL,
+			stored_at BIGINT NOT NULL DEFAULT 0
+		)
+	`, s.table)); err != nil {
+		return fmt.Errorf("usage store: create usage table: %w", err)
+	}
+	if err := s.ensureStoredAtColumn(ctx); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s ON %s (requested_at)",
+		quoteSQLIdentifier(usageRecordsTable+"_requested_at_idx"), s.table,
+	)); err != nil {
+		return fmt.Errorf("usage store: create usage index: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s ON %s (stored_at, id)",
+		quoteSQLIdentifier(usageRecordsTable+"_stored_at_idx"), s.table,
+	)); err != nil {
+		return fmt.Errorf("usage store: create usage index: %w", err)
+	}
+	return nil
+}
+
+// ensureStoredAtColumn adds the stored_at column to usage tables created before it existed.
+// Records stored earlier keep 0 and are never returned by Changes.
+func (s *sqlStore) ensureStoredAtColumn(ctx context.Context) error {
+	if s.dialect == dialectPostgres {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+			"ALTER TABLE %s ADD COLUMN IF NOT EXISTS stored_at BIGINT NOT NULL DEFAULT 0", s.table,
+		)); err != nil {
+			return fmt.Errorf("usage store: add stored_at column: %w", err)
+		}
+		return nil
+	}
+	var present int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'stored_at'", usageRecordsTable,
+	).Scan(&present); err != nil {
+		return fmt.Errorf("usage store: inspect usage table: %w", err)
+	}
+	if present > 0 {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"ALTER TABLE %s ADD COLUMN stored_at BIGINT NOT NULL DEFAULT 0", s.table,
+	)); err != nil {
+		return fmt.Errorf("usage store: add stored_at column: %w", err)
+	}
+	return nil
+}
+
+// Append implements Store.
+func (s *sqlStore) Append(ctx context.Context, records []StoredRecord) (int64, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("usage store: begin transaction: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, s.rebind(fmt.Sprintf(`
+		INSERT INTO %s (
+			id, requested_at, api_key, provider, model, auth_id, auth_index, source, failed,
+			input_tokens, output_tokens, reasoning_tokens, cached_tokens, total_tokens, stored_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, %s)
+		ON CONFLICT (id) DO NOTHING
+	`, s.table, s.nowMillis())))
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, fmt.Errorf("usage store: prepare insert: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	var added int64
+	for _, record := range records {
+		failed := 0
+		if record.Failed {
+			failed = 1
+		}
+		res, errExec := stmt.ExecContext(ctx,
+			record.ID, record.RequestedAt.UnixNano(), record.APIKey, record.Provider, record.Model,
+			record.AuthID, record.AuthIndex, record.Source, failed,
+			record.Tokens.InputTokens, record.Tokens.OutputTokens, record.Tokens.ReasoningTokens,
+			record.Tokens.CachedTokens, record.Tokens.TotalTokens,
+		)
+		if errExec != nil {
+			_ = tx.Rollback()
+			return 0, fmt.Errorf("usage store: insert record: %w", errExec)
+		}
+		if n, errRows := res.RowsAffected(); errRows == nil {
+			added += n
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("usage store: commit: %w", err)
+	}
+	return added, nil
+}
+
+// Query implements Store.
+func (s *sqlStore) Query(ctx context.Context, q StoreQuery) ([]StoredRecord, error) {
+	where, args := buildWhere(q)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s%s
+		ORDER BY requested_at DESC`, recordColumns, s.table, where)
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("usage store: query records: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	records := make([]StoredRecord, 0)
+	for rows.Next() {
+		record, errScan := scanRecord(rows)
+		if errScan != nil {
+			return nil, errScan
+		}
+		records = append(records, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("usage store: iterate records: %w", err)
+	}
+	return records, nil
+}
+
+// Changes implements Store. Records are ordered by the database time they were stored at, so
+// records of long requests appended late are still returned after the cursor.
+func (s *sqlStore) Changes(ctx context.Context, after StoreCursor, limit int) ([]StoredRecord, StoreCursor, error) {
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(fmt.Sprintf(`
+		SELECT %s, stored_at
+		FROM %s
+		WHERE stored_at > ? OR (stored_at = ? AND id > ?)
+		ORDER BY stored_at, id
+		LIMIT %d`, recordColumns, s.table, limit)), after.StoredAt, after.StoredAt, after.ID)
+	if err != nil {
+		return nil, after, fmt.Errorf("usage store: query changes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	next := after
+	records := make([]StoredRecord, 0)
+	for rows.Next() {
+		var storedAt int64
+		record, errScan := scanRecord(rows, &storedAt)
+		if errScan != nil {
+			return nil, after, errScan
+		}
+		records = append(records, record)
+		next = StoreCursor{StoredAt: storedAt, ID: record.ID}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, after, fmt.Errorf("usage store: iterate changes: %w", err)
+	}
+	return records, next, nil
+}
+
+// recordColumns lists the columns scanRecord reads, in order.
+const recordColumns = `id, requested_at, api_key, provider, model, auth_id, auth_index, source, failed,
+			input_tokens, output_tokens, reasoning_tokens, cached_tokens, total_tokens`
+
+// scanRecord reads the recordColumns of the current row, followed by the columns in extra.
+func scanRecord(rows *sql.Rows, extra ...any) (StoredRecord, error) {
+	var (
+		record      StoredRecord
+		requestedAt int64
+		failed      int64
+	)
+	dest := []any{
+		&record.ID, &requestedAt, &record.APIKey, &record.Provider, &record.Model,
+		&record.AuthID, &record.AuthIndex, &record.Source, &failed,
+		&record.Tokens.InputTokens, &record.Tokens.OutputTokens, &record.Tokens.ReasoningTokens,
+		&record.Tokens.CachedTokens, &record.Tokens.TotalTokens,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return StoredRecord{}, fmt.Errorf("usage store: scan record: %w", err)
+	}
+	record.RequestedAt = time.Unix(0, requestedAt).UTC()
+	record.Failed = failed != 0
+	return record, nil
+}
+
+// Rollup implements Store.
+func (s *sqlStore) Rollup(ctx context.Context, q RollupQuery) ([]RollupRow, error) {
+	var bucketWidth int64
+	switch strings.ToLower(strings.TrimSpace(q.Bucket)) {
+	case "":
+	case "hour":
+		bucketWidth = int64(time.Hour)
+	case "day":
+		bucketWidth = int64(24 * time.Hour)
+	default:
+		return nil, fmt.Errorf("usage store: unsupported bucket %q", q.Bucket)
+	}
+	groups := make([]string, 0, len(q.GroupBy))
+	seen := make(map[string]struct{}, len(q.GroupBy))
+	for _, dimension := range q.GroupBy {
+		dimension = strings.ToLower(strings.TrimSpace(dimension))
+		switch dimension {
+		case "api_key", "model", "provider", "auth_index":
+		default:
+			return nil, fmt.Errorf("usage store: unsupported group_by dimension %q", dimension)
+		}
+		if _, dup := seen[dimension]; dup {
+			continue
+		}
+		seen[dimension] = struct{}{}
+		groups = append(groups, dimension)
+	}
+
+	selectCols := make([]string, 0, len(groups)+1)
+	if bucketWidth > 0 {
+		selectCols = append(selectCols, fmt.Sprintf("(requested_at / %d) * %d AS bucket", bucketWidth, bucketWidth))
+	}
+	selectCols = append(selectCols, groups...)
+	groupCols := make([]string, 0, len(selectCols))
+	for i := range selectCols {
+		groupCols = append(groupCols, strconv.Itoa(i+1))
+	}
+
+	where, args := buildWhere(q.StoreQuery)
+	// Sums are cast back to BIGINT because PostgreSQL widens SUM(bigint) to NUMERIC.
+	aggregates := `COUNT(*), CAST(COALESCE(SUM(failed), 0) AS BIGINT),
+		CAST(COALESCE(SUM(input_tokens), 0) AS BIGINT), CAST(COALESCE(SUM(output_tokens), 0) AS BIGINT),
+		CAST(COALESCE(SUM(reasoning_tokens), 0) AS BIGINT), CAST(COALESCE(SUM(cached_tokens), 0) AS BIGINT),
+		CAST(COALESCE(SUM(total_tokens), 0) AS BIGINT)`
+	query := "SELECT "
+	if len(selectCols) > 0 {
+		query += strings.Join(selectCols, ", ") + ", "
+	}
+	query += aggregates + " FROM " + s.table + where
+	if len(groupCols) > 0 {
+		query += " GROUP BY " + strings.Join(groupCols, ", ") + " ORDER BY " + strings.Join(groupCols, ", ")
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("usage store: rollup: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]RollupRow, 0)
+	for rows.Next() {
+		var (
+			row    RollupRow
+			bucket int64
+		)
+		dest := make([]any, 0, len(selectCols)+7)
+		if bucketWidth > 0 {
+			dest = append(dest, &bucket)
+		}
+		for _, dimension := range groups {
+			switch dimension {
+			case "api_key":
+				dest = append(dest, &row.APIKey)
+			case "model":
+				dest = append(dest, &row.Model)
+			case "provider":
+				dest = append(dest, &row.Provider)
+			case "auth_index":
+				dest = append(dest, &row.AuthIndex)
+			}
+		}
+		dest = append(dest, &row.Requests, &row.Failed,
+			&row.Tokens.InputTokens, &row.Tokens.OutputTokens, &row.Tokens.ReasoningTokens,
+			&row.Tokens.CachedTokens, &row.Tokens.TotalTokens)
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("usage store: scan rollup: %w", err)
+		}
+		if bucketWidth > 0 {
+			ts := time.Unix(0, bucket).UTC()
+			row.Bucket = &ts
+		}
+		out = append(out, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("usage store: iterate rollup: %w", err)
+	}
+	return out, nil
+}
+
+// Prune implements Store.
+func (s *sqlStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.rebind(fmt.Sprintf("DELETE FROM %s WHERE requested_at < ?", s.table)), before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("usage store: prune: %w", err)
+	}
+	removed, _ := res.RowsAffected()
+	return removed, nil
+}
+
+// Close implements Store.
+func (s *sqlStore) Close() error {
+	if s == nil || s.db == nil || !s.ownsDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// nowMillis returns the SQL expression of the database clock in Unix milliseconds.
+func (s *sqlStore) nowMillis() string {
+	if s.dialect == dialectPostgres {
+		return "CAST(EXTRACT(EPOCH FROM clock_timestamp()) * 1000 AS BIGINT)"
+	}
+	return "CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)"
+}
+
+// rebind converts '?' placeholders into PostgreSQL positional parameters.
+func (s *sqlStore) rebind(query string) string {
+	if s.dialect != dialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func buildWhere(q StoreQuery) (string, []any) {
+	clauses := make([]string, 0, 4)
+	args := make([]any, 0, 4)
+	if !q.From.IsZero() {
+		clauses = append(clauses, "requested_at >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		clauses = append(clauses, "requested_at < ?")
+		args = append(args, q.To.UnixNano())
+	}
+	if apiKey := strings.TrimSpace(q.APIKey); apiKey != "" {
+		clauses = append(clauses, "api_key = ?")
+		args = append(args, apiKey)
+	}
+	if model := strings.TrimSpace(q.Model); model != "" {
+		clauses = append(clauses, "model = ?")
+		args = append(args, model)
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+func recordID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func quoteSQLIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
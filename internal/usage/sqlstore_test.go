@@ -0,0 +1,178 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/limits"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestSQLiteStoreQueryRollupAndPrune(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenSQLiteStore(ctx, filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	records := []StoredRecord{
+		storedRecordFromDetail("k1", "gpt-5", "codex", "a1", RequestDetail{
+			Timestamp: day.Add(1 * time.Hour), AuthIndex: "1", Tokens: TokenStats{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		}),
+		storedRecordFromDetail("k1", "gpt-5", "codex", "a1", RequestDetail{
+			Timestamp: day.Add(2 * time.Hour), AuthIndex: "1", Failed: true, Tokens: TokenStats{TotalTokens: 0},
+		}),
+		storedRecordFromDetail("k2", "claude-sonnet-4", "claude", "a2", RequestDetail{
+			Timestamp: day.Add(26 * time.Hour), AuthIndex: "2", Tokens: TokenStats{InputTokens: 100, TotalTokens: 100},
+		}),
+	}
+	added, err := store.Append(ctx, records)
+	if err != nil || added != 3 {
+		t.Fatalf("Append = %d, %v; want 3", added, err)
+	}
+	if added, err = store.Append(ctx, records[:1]); err != nil || added != 0 {
+		t.Fatalf("duplicate Append = %d, %v; want 0", added, err)
+	}
+
+	got, err := store.Query(ctx, StoreQuery{From: day, To: day.Add(24 * time.Hour), APIKey: "k1"})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) != 2 || !got[0].Failed || got[1].Tokens.TotalTokens != 15 || !got[1].RequestedAt.Equal(day.Add(time.Hour)) {
+		t.Fatalf("unexpected query result: %+v", got)
+	}
+
+	rows, err := store.Rollup(ctx, RollupQuery{GroupBy: []string{"api_key"}, Bucket: "day"})
+	if err != nil {
+		t.Fatalf("Rollup: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rollup rows = %d, want 2: %+v", len(rows), rows)
+	}
+	if rows[0].APIKey != "k1" || rows[0].Requests != 2 || rows[0].Failed != 1 || rows[0].Tokens.TotalTokens != 15 || !rows[0].Bucket.Equal(day) {
+		t.Fatalf("unexpected k1 rollup: %+v", rows[0])
+	}
+	if _, err = store.Rollup(ctx, RollupQuery{GroupBy: []string{"api_key; DROP TABLE usage_records"}}); err == nil {
+		t.Fatal("expected unsupported group_by to be rejected")
+	}
+
+	removed, err := store.Prune(ctx, day.Add(24*time.Hour))
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune = %d, %v; want 2", removed, err)
+	}
+	if got, _ = store.Query(ctx, StoreQuery{Limit: -1}); len(got) != 1 || got[0].APIKey != "k2" {
+		t.Fatalf("unexpected records after prune: %+v", got)
+	}
+}
+
+func TestStorePersistenceQueuesWritesAndFlushesOnClose(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "usage.db")
+	p := &persistence{}
+	if err := p.apply(config.UsageStoreConfig{Driver: "sqlite", Path: path}, ""); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		p.enqueue(ctx, coreusage.Record{
+			APIKey:      "queued-key",
+			Model:       "gpt-5",
+			RequestedAt: now.Add(time.Duration(i) * time.Second),
+			Detail:      coreusage.Detail{InputTokens: 10},
+		})
+	}
+	p.closeCurrent()
+	p.enqueue(ctx, coreusage.Record{APIKey: "after-close", Model: "gpt-5", RequestedAt: now})
+
+	store, err := OpenSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	got, err := store.Query(ctx, StoreQuery{Limit: -1})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) != 3 || got[0].APIKey != "queued-key" {
+		t.Fatalf("persisted records = %+v, want the 3 queued records", got)
+	}
+}
+
+func TestStorePersistenceRestoresLimitCounters(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "usage.db")
+	store, err := OpenSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	now := time.Now()
+	record := storedRecordFromDetail("restore-limits-key", "gpt-5", "codex", "a1", RequestDetail{
+		Timestamp: now, Tokens: TokenStats{InputTokens: 40, OutputTokens: 2, TotalTokens: 42},
+	})
+	if _, err = store.Append(ctx, []StoredRecord{record}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	_ = store.Close()
+	t.Cleanup(func() { limits.Default().Reset("restore-limits-key") })
+
+	p := &persistence{}
+	if err = p.apply(config.UsageStoreConfig{Driver: "sqlite", Path: path}, ""); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	defer p.closeCurrent()
+	if usage := limits.Default().Usage("restore-limits-key"); usage.TokensToday != 42 || usage.TokensThisMonth != 42 {
+		t.Fatalf("restored counters = %+v, want 42 tokens today and this month", usage)
+	}
+}
+
+func TestSQLiteStoreChangesFollowStoreOrder(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenSQLiteStore(ctx, filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().UTC()
+	record := func(key string, requestedAt time.Time) StoredRecord {
+		return storedRecordFromDetail(key, "gpt-5", "codex", "a1", RequestDetail{Timestamp: requestedAt, Tokens: TokenStats{TotalTokens: 1}})
+	}
+	if _, err = store.Append(ctx, []StoredRecord{record("k1", now), record("k2", now.Add(-time.Minute))}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	var seen []string
+	cursor := StoreCursor{}
+	for page := 0; page < 3; page++ {
+		records, next, errChanges := store.Changes(ctx, cursor, 1)
+		if errChanges != nil {
+			t.Fatalf("Changes: %v", errChanges)
+		}
+		for _, r := range records {
+			seen = append(seen, r.APIKey)
+		}
+		cursor = next
+	}
+	if len(seen) != 2 {
+		t.Fatalf("paged changes = %v, want both records once", seen)
+	}
+
+	// A long request stored late is still returned after the cursor. Records stored within the
+	// cursor's millisecond are left to the overlap PullStore re-reads.
+	time.Sleep(5 * time.Millisecond)
+	if _, err = store.Append(ctx, []StoredRecord{record("k3", now.Add(-10*time.Minute))}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	records, next, err := store.Changes(ctx, cursor, 10)
+	if err != nil || len(records) != 1 || records[0].APIKey != "k3" || next.StoredAt < cursor.StoredAt {
+		t.Fatalf("Changes after a late append = %+v, %+v, %v", records, next, err)
+	}
+	if records, _, err = store.Changes(ctx, next, 10); err != nil || len(records) != 0 {
+		t.Fatalf("Changes past the last record = %+v, %v", records, err)
+	}
+}
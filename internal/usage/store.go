@@ -0,0 +1,521 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/limits"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrStoreDisabled is returned by persistent queries when no usage store is configured.
+var ErrStoreDisabled = errors.New("usage store is not configured")
+
+const (
+	defaultUsageStoreFile = "usage.db"
+	retentionInterval     = time.Hour
+	defaultQueryLimit     = 1000
+
+	// hydrateWindow bounds the persisted history loaded into the in-memory statistics at
+	// startup; older records stay queryable through the store.
+	hydrateWindow = 30 * 24 * time.Hour
+
+	// Usage records are queued and written in batches so requests never wait on the database.
+	writeQueueSize     = 4096
+	writeBatchSize     = 256
+	writeFlushInterval = time.Second
+
+	// PullStore reads the records of other instances in pages of pullPageSize, re-reading those
+	// stored within pullOverlap before its cursor because concurrent writes commit out of order.
+	pullPageSize = 1000
+	pullOverlap  = 5 * time.Second
+)
+
+// StoredRecord is a single persisted usage record.
+type StoredRecord struct {
+	ID          string     `json:"id"`
+	RequestedAt time.Time  `json:"requested_at"`
+	APIKey      string     `json:"api_key"`
+	Provider    string     `json:"provider"`
+	Model       string     `json:"model"`
+	AuthID      string     `json:"auth_id"`
+	AuthIndex   string     `json:"auth_index"`
+	Source      string     `json:"source"`
+	Failed      bool       `json:"failed"`
+	Tokens      TokenStats `json:"tokens"`
+}
+
+// StoreQuery filters persisted usage records. Zero values leave a dimension unfiltered.
+type StoreQuery struct {
+	From   time.Time
+	To     time.Time
+	APIKey string
+	Model  string
+	Limit  int
+}
+
+// StoreCursor is a position in the order records were stored in, as returned by Store.Changes.
+type StoreCursor struct {
+	// StoredAt is when the record was stored, in Unix milliseconds of the backend's clock.
+	StoredAt int64
+	// ID orders records stored in the same millisecond.
+	ID string
+}
+
+// RollupQuery aggregates persisted usage records.
+type RollupQuery struct {
+	StoreQuery
+	// GroupBy lists the dimensions to group on: "api_key", "model", "provider", "auth_index".
+	GroupBy []string
+	// Bucket optionally splits the rollup into "hour" or "day" time buckets (UTC).
+	Bucket string
+}
+
+// RollupRow is one aggregated row of a rollup query.
+type RollupRow struct {
+	Bucket    *time.Time `json:"bucket,omitempty"`
+	APIKey    string     `json:"api_key,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Provider  string     `json:"provider,omitempty"`
+	AuthIndex string     `json:"auth_index,omitempty"`
+	Requests  int64      `json:"requests"`
+	Failed    int64      `json:"failed"`
+	Tokens    TokenStats `json:"tokens"`
+}
+
+// Store persists usage records and answers time-range and rollup queries.
+type Store interface {
+	// Append inserts records, skipping those already stored.
+	Append(ctx context.Context, records []StoredRecord) (int64, error)
+	// Query returns records matching q ordered by time, newest first.
+	Query(ctx context.Context, q StoreQuery) ([]StoredRecord, error)
+	// Changes returns up to limit records stored after cursor, in the order they were stored,
+	// and the cursor of the last one returned.
+	Changes(ctx context.Context, after StoreCursor, limit int) ([]StoredRecord, StoreCursor, error)
+	// Rollup aggregates records matching q.
+	Rollup(ctx context.Context, q RollupQuery) ([]RollupRow, error)
+	// Prune deletes records requested before the cutoff.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	// Close releases the backend.
+	Close() error
+}
+
+// persistence owns the active store and its retention worker.
+type persistence struct {
+	mu        sync.RWMutex
+	store     Store
+	settings  config.UsageStoreConfig
+	authDir   string
+	retention time.Duration
+	stop      chan struct{}
+	writes    chan StoredRecord
+	written   chan struct{}
+
+	sharedDB     *sql.DB
+	sharedSchema string
+}
+
+var defaultPersistence = &persistence{}
+
+func init() {
+	coreusage.RegisterPlugin(&StorePlugin{})
+}
+
+// StorePlugin queues every usage record for the configured persistent store, which writes
+// them in batches. It implements coreusage.Plugin.
+type StorePlugin struct{}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *StorePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	defaultPersistence.enqueue(ctx, record)
+}
+
+// UseSharedPostgres registers an existing PostgreSQL connection that the "postgres"
+// usage store driver reuses when no DSN is configured.
+func UseSharedPostgres(db *sql.DB, schema string) {
+	defaultPersistence.mu.Lock()
+	defaultPersistence.sharedDB = db
+	defaultPersistence.sharedSchema = strings.TrimSpace(schema)
+	defaultPersistence.mu.Unlock()
+}
+
+// ConfigureStore opens, replaces, or closes the persistent usage store to match cfg.
+// Records already persisted are merged into the in-memory statistics when a store is opened.
+func ConfigureStore(cfg *config.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	return defaultPersistence.apply(cfg.UsageStore, cfg.AuthDir)
+}
+
+// CloseStore closes the persistent usage store, if any.
+func CloseStore() {
+	defaultPersistence.closeCurrent()
+}
+
+// GetStore returns the active persistent usage store or nil when persistence is disabled.
+func GetStore() Store { return defaultPersistence.current() }
+
+// PersistSnapshot writes the request details of an imported snapshot to the active store.
+func PersistSnapshot(ctx context.Context, snapshot StatisticsSnapshot) (int64, error) {
+	store := defaultPersistence.current()
+	if store == nil {
+		return 0, nil
+	}
+	records := make([]StoredRecord, 0)
+	for apiName, apiSnapshot := range snapshot.APIs {
+		apiName = strings.TrimSpace(apiName)
+		if apiName == "" {
+			continue
+		}
+		for modelName, modelSnapshot := range apiSnapshot.Models {
+			modelName = strings.TrimSpace(modelName)
+			if modelName == "" {
+				modelName = "unknown"
+			}
+			for _, detail := range modelSnapshot.Details {
+				detail.Tokens = normaliseTokenStats(detail.Tokens)
+				if detail.Timestamp.IsZero() {
+					continue
+				}
+				records = append(records, storedRecordFromDetail(apiName, modelName, "", "", detail))
+			}
+		}
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return store.Append(ctx, records)
+}
+
+func (p *persistence) current() Store {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.store
+}
+
+func (p *persistence) apply(settings config.UsageStoreConfig, authDir string) error {
+	settings.Driver = strings.ToLower(strings.TrimSpace(settings.Driver))
+	settings.Path = strings.TrimSpace(settings.Path)
+	settings.DSN = strings.TrimSpace(settings.DSN)
+	if settings.RetentionDays < 0 {
+		settings.RetentionDays = 0
+	}
+
+	p.mu.Lock()
+	if p.store != nil && p.settings == settings && p.authDir == authDir {
+		p.mu.Unlock()
+		return nil
+	}
+	sharedDB, sharedSchema := p.sharedDB, p.sharedSchema
+	p.mu.Unlock()
+
+	p.closeCurrent()
+
+	var (
+		store Store
+		err   error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	switch settings.Driver {
+	case "":
+		return nil
+	case "sqlite", "sqlite3":
+		path := settings.Path
+		if path == "" {
+			resolved, errResolve := util.ResolveAuthDir(authDir)
+			if errResolve != nil {
+				return fmt.Errorf("usage store: %w", errResolve)
+			}
+			path = filepath.Join(resolved, defaultUsageStoreFile)
+		}
+		store, err = OpenSQLiteStore(ctx, path)
+	case "postgres", "postgresql", "pgx":
+		if settings.DSN == "" {
+			if sharedDB == nil {
+				return fmt.Errorf("usage store: postgres driver requires a dsn or the postgres token store")
+			}
+			store, err = NewPostgresUsageStore(ctx, sharedDB, sharedSchema, false)
+		} else {
+			store, err = OpenPostgresStore(ctx, settings.DSN)
+		}
+	default:
+		return fmt.Errorf("usage store: unsupported driver %q", settings.Driver)
+	}
+	if err != nil {
+		return err
+	}
+
+	retention := time.Duration(settings.RetentionDays) * 24 * time.Hour
+	stop := make(chan struct{})
+	writes := make(chan StoredRecord, writeQueueSize)
+	written := make(chan struct{})
+	p.mu.Lock()
+	p.store = store
+	p.settings = settings
+	p.authDir = authDir
+	p.retention = retention
+	p.stop = stop
+	p.writes = writes
+	p.written = written
+	p.mu.Unlock()
+
+	go runWriter(store, writes, written)
+	if retention > 0 {
+		go p.runRetention(store, retention, stop)
+	}
+	p.hydrate(ctx, store, retention)
+	restoreLimits(ctx, store)
+	log.Infof("usage store enabled (driver: %s)", settings.Driver)
+	return nil
+}
+
+func (p *persistence) closeCurrent() {
+	p.mu.Lock()
+	store, stop, writes, written := p.store, p.stop, p.writes, p.written
+	p.store = nil
+	p.stop = nil
+	p.writes = nil
+	p.written = nil
+	p.settings = config.UsageStoreConfig{}
+	p.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	if writes != nil {
+		// Senders hold the read lock, so none is left once the store was swapped out above.
+		close(writes)
+		<-written
+	}
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.WithError(err).Warn("usage store: failed to close")
+		}
+	}
+}
+
+// enqueue queues record for the active store. Records are dropped with a warning when the
+// queue is full rather than blocking the request that produced them.
+func (p *persistence) enqueue(ctx context.Context, record coreusage.Record) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.writes == nil {
+		return
+	}
+	select {
+	case p.writes <- newStoredRecord(ctx, record):
+	default:
+		log.Warn("usage store: write queue is full, dropping usage record")
+	}
+}
+
+// runWriter appends queued records to store in batches until writes is closed, then flushes
+// what is left and closes written.
+func runWriter(store Store, writes <-chan StoredRecord, written chan<- struct{}) {
+	defer close(written)
+	batch := make([]StoredRecord, 0, writeBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := store.Append(ctx, batch); err != nil {
+			log.WithError(err).Warnf("usage store: failed to persist %d usage records", len(batch))
+		}
+		batch = batch[:0]
+	}
+	ticker := time.NewTicker(writeFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case record, ok := <-writes:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= writeBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// hydrate merges persisted records into the in-memory statistics so /usage survives restarts.
+// Only the retention window, capped at hydrateWindow, is loaded.
+func (p *persistence) hydrate(ctx context.Context, store Store, retention time.Duration) {
+	window := hydrateWindow
+	if retention > 0 && retention < window {
+		window = retention
+	}
+	q := StoreQuery{From: time.Now().Add(-window), Limit: -1}
+	records, err := store.Query(ctx, q)
+	if err != nil {
+		log.WithError(err).Warn("usage store: failed to load persisted records")
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+	result := defaultRequestStatistics.MergeSnapshot(snapshotFromRecords(records))
+	log.Debugf("usage store: loaded %d persisted records (%d already present)", result.Added, result.Skipped)
+}
+
+// restoreLimits seeds the api-key-limits token counters with the usage persisted for the
+// current day and month, so daily limits and monthly budgets are not reset by a restart.
+func restoreLimits(ctx context.Context, store Store) {
+	now := time.Now()
+	year, month, day := now.Date()
+	monthStart := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	monthRows, err := store.Rollup(ctx, RollupQuery{StoreQuery: StoreQuery{From: monthStart}, GroupBy: []string{"api_key"}})
+	if err != nil {
+		log.WithError(err).Warn("usage store: failed to restore api-key-limits counters")
+		return
+	}
+	dayRows, err := store.Rollup(ctx, RollupQuery{StoreQuery: StoreQuery{From: dayStart}, GroupBy: []string{"api_key"}})
+	if err != nil {
+		log.WithError(err).Warn("usage store: failed to restore api-key-limits counters")
+		return
+	}
+	today := make(map[string]int64, len(dayRows))
+	for _, row := range dayRows {
+		today[row.APIKey] = row.Tokens.TotalTokens
+	}
+	for _, row := range monthRows {
+		limits.Default().Restore(row.APIKey, today[row.APIKey], row.Tokens.TotalTokens)
+	}
+}
+
+// PullStore merges the records stored after cursor into the in-memory statistics and returns
+// the cursor to pass next. Instances sharing a store call it periodically to see the usage
+// recorded by each other. Each pull re-reads the records stored within pullOverlap before
+// cursor, which may have committed after the previous pull read past them; records already
+// present are skipped.
+func PullStore(ctx context.Context, cursor StoreCursor) (MergeResult, StoreCursor, error) {
+	store := defaultPersistence.current()
+	if store == nil {
+		return MergeResult{}, cursor, ErrStoreDisabled
+	}
+	var result MergeResult
+	after := StoreCursor{StoredAt: cursor.StoredAt - pullOverlap.Milliseconds()}
+	for {
+		records, next, err := store.Changes(ctx, after, pullPageSize)
+		if err != nil {
+			return result, cursor, err
+		}
+		if len(records) > 0 {
+			merged := defaultRequestStatistics.MergeSnapshot(snapshotFromRecords(records))
+			result.Added += merged.Added
+			result.Skipped += merged.Skipped
+		}
+		if next.StoredAt > cursor.StoredAt {
+			cursor = next
+		}
+		if len(records) < pullPageSize {
+			return result, cursor, nil
+		}
+		after = next
+	}
+}
+
+func (p *persistence) runRetention(store Store, retention time.Duration, stop <-chan struct{}) {
+	prune := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		removed, err := store.Prune(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.WithError(err).Warn("usage store: retention cleanup failed")
+			return
+		}
+		if removed > 0 {
+			log.Debugf("usage store: removed %d records past retention", removed)
+		}
+	}
+	prune()
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
+
+func newStoredRecord(ctx context.Context, record coreusage.Record) StoredRecord {
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	apiName := record.APIKey
+	if apiName == "" {
+		apiName = resolveAPIIdentifier(ctx, record)
+	}
+	failed := record.Failed
+	if !failed {
+		failed = !resolveSuccess(ctx)
+	}
+	modelName := record.Model
+	if modelName == "" {
+		modelName = "unknown"
+	}
+	return storedRecordFromDetail(apiName, modelName, record.Provider, record.AuthID, RequestDetail{
+		Timestamp: timestamp,
+		Source:    record.Source,
+		AuthIndex: record.AuthIndex,
+		Tokens:    normaliseDetail(record.Detail),
+		Failed:    failed,
+	})
+}
+
+func storedRecordFromDetail(apiName, modelName, provider, authID string, detail RequestDetail) StoredRecord {
+	return StoredRecord{
+		ID:          recordID(dedupKey(apiName, modelName, detail)),
+		RequestedAt: detail.Timestamp.UTC(),
+		APIKey:      apiName,
+		Provider:    provider,
+		Model:       modelName,
+		AuthID:      authID,
+		AuthIndex:   detail.AuthIndex,
+		Source:      detail.Source,
+		Failed:      detail.Failed,
+		Tokens:      detail.Tokens,
+	}
+}
+
+func snapshotFromRecords(records []StoredRecord) StatisticsSnapshot {
+	snapshot := StatisticsSnapshot{APIs: make(map[string]APISnapshot)}
+	for _, record := range records {
+		apiSnapshot, ok := snapshot.APIs[record.APIKey]
+		if !ok {
+			apiSnapshot = APISnapshot{Models: make(map[string]ModelSnapshot)}
+		}
+		modelSnapshot := apiSnapshot.Models[record.Model]
+		modelSnapshot.Details = append(modelSnapshot.Details, RequestDetail{
+			Timestamp: record.RequestedAt,
+			Source:    record.Source,
+			AuthIndex: record.AuthIndex,
+			Tokens:    record.Tokens,
+			Failed:    record.Failed,
+		})
+		apiSnapshot.Models[record.Model] = modelSnapshot
+		snapshot.APIs[record.APIKey] = apiSnapshot
+	}
+	return snapshot
+}
@@ -0,0 +1,49 @@
+package util
+
+import "strings"
+
+// MatchModelPattern performs simple wildcard matching where '*' matches zero or more characters.
+// Matching is case-sensitive; callers lower-case both sides when they need otherwise.
+// Examples:
+//
+//	"*-5" matches "gpt-5"
+//	"gpt-*" matches "gpt-5" and "gpt-4"
+//	"gemini-*-pro" matches "gemini-2.5-pro" and "gemini-3-pro".
+func MatchModelPattern(pattern, model string) bool {
+	pattern = strings.TrimSpace(pattern)
+	model = strings.TrimSpace(model)
+	if pattern == "" {
+		return false
+	}
+	if pattern == "*" {
+		return true
+	}
+	// Iterative glob-style matcher supporting only '*' wildcard.
+	pi, si := 0, 0
+	starIdx := -1
+	matchIdx := 0
+	for si < len(model) {
+		if pi < len(pattern) && (pattern[pi] == model[si]) {
+			pi++
+			si++
+			continue
+		}
+		if pi < len(pattern) && pattern[pi] == '*' {
+			starIdx = pi
+			matchIdx = si
+			pi++
+			continue
+		}
+		if starIdx != -1 {
+			pi = starIdx + 1
+			matchIdx++
+			si = matchIdx
+			continue
+		}
+		return false
+	}
+	for pi < len(pattern) && pattern[pi] == '*' {
+		pi++
+	}
+	return pi == len(pattern)
+}
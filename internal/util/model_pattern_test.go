@@ -0,0 +1,27 @@
+package util
+
+import "testing"
+
+func TestMatchModelPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		model    string
+		expected bool
+	}{
+		{"*", "gpt-5", true},
+		{"*-5", "gpt-5", true},
+		{"gpt-*", "gpt-4", true},
+		{"gemini-*-pro", "gemini-2.5-pro", true},
+		{"claude-*-sonnet-*", "claude-4-sonnet-20250514", true},
+		{"gpt-5", "gpt-5", true},
+		{"gpt-5", "gpt-5-mini", false},
+		{"gemini-*-pro", "gemini-2.5-flash", false},
+		{"", "gpt-5", false},
+		{"GPT-*", "gpt-5", false},
+	}
+	for _, tt := range tests {
+		if got := MatchModelPattern(tt.pattern, tt.model); got != tt.expected {
+			t.Errorf("MatchModelPattern(%q, %q) = %v, want %v", tt.pattern, tt.model, got, tt.expected)
+		}
+	}
+}
@@ -125,3 +125,14 @@ func WritablePath() string {
 	}
 	return ""
 }
+
+// DataPath returns where local data named name is kept when no path is configured for it:
+// under WRITABLE_PATH when set, otherwise relative to the working directory, next to the
+// default config file. It is never inside auth-dir, whose JSON files the token stores load as
+// auths.
+func DataPath(name string) string {
+	if base := WritablePath(); base != "" {
+		return filepath.Join(base, name)
+	}
+	return name
+}
@@ -24,6 +24,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.AuthDir != newCfg.AuthDir {
 		changes = append(changes, fmt.Sprintf("auth-dir: %s -> %s", oldCfg.AuthDir, newCfg.AuthDir))
 	}
+	if oldCfg.AuthEncryption.Enable != newCfg.AuthEncryption.Enable {
+		changes = append(changes, fmt.Sprintf("auth-encryption.enable: %t -> %t", oldCfg.AuthEncryption.Enable, newCfg.AuthEncryption.Enable))
+	}
+	if !reflect.DeepEqual(oldCfg.AuthEncryption.Keys, newCfg.AuthEncryption.Keys) {
+		changes = append(changes, fmt.Sprintf("auth-encryption.keys: %d -> %d entries", len(oldCfg.AuthEncryption.Keys), len(newCfg.AuthEncryption.Keys)))
+	}
 	if oldCfg.Debug != newCfg.Debug {
 		changes = append(changes, fmt.Sprintf("debug: %t -> %t", oldCfg.Debug, newCfg.Debug))
 	}
@@ -33,12 +39,72 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if strings.TrimSpace(oldCfg.Pprof.Addr) != strings.TrimSpace(newCfg.Pprof.Addr) {
 		changes = append(changes, fmt.Sprintf("pprof.addr: %s -> %s", strings.TrimSpace(oldCfg.Pprof.Addr), strings.TrimSpace(newCfg.Pprof.Addr)))
 	}
+	if oldCfg.Metrics.Enable != newCfg.Metrics.Enable {
+		changes = append(changes, fmt.Sprintf("metrics.enable: %t -> %t", oldCfg.Metrics.Enable, newCfg.Metrics.Enable))
+	}
 	if oldCfg.LoggingToFile != newCfg.LoggingToFile {
 		changes = append(changes, fmt.Sprintf("logging-to-file: %t -> %t", oldCfg.LoggingToFile, newCfg.LoggingToFile))
 	}
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
+	if oldCfg.UsageStore.Driver != newCfg.UsageStore.Driver {
+		changes = append(changes, fmt.Sprintf("usage-store.driver: %s -> %s", oldCfg.UsageStore.Driver, newCfg.UsageStore.Driver))
+	}
+	if oldCfg.UsageStore.Path != newCfg.UsageStore.Path {
+		changes = append(changes, fmt.Sprintf("usage-store.path: %s -> %s", oldCfg.UsageStore.Path, newCfg.UsageStore.Path))
+	}
+	if oldCfg.UsageStore.DSN != newCfg.UsageStore.DSN {
+		changes = append(changes, "usage-store.dsn: updated")
+	}
+	if oldCfg.UsageStore.RetentionDays != newCfg.UsageStore.RetentionDays {
+		changes = append(changes, fmt.Sprintf("usage-store.retention-days: %d -> %d", oldCfg.UsageStore.RetentionDays, newCfg.UsageStore.RetentionDays))
+	}
+	if oldCfg.ResponseStore.Driver != newCfg.ResponseStore.Driver {
+		changes = append(changes, fmt.Sprintf("response-store.driver: %s -> %s", oldCfg.ResponseStore.Driver, newCfg.ResponseStore.Driver))
+	}
+	if oldCfg.ResponseStore.Path != newCfg.ResponseStore.Path {
+		changes = append(changes, fmt.Sprintf("response-store.path: %s -> %s", oldCfg.ResponseStore.Path, newCfg.ResponseStore.Path))
+	}
+	if oldCfg.ResponseStore.DSN != newCfg.ResponseStore.DSN {
+		changes = append(changes, "response-store.dsn: updated")
+	}
+	if oldCfg.ResponseStore.TTLSeconds != newCfg.ResponseStore.TTLSeconds || oldCfg.ResponseStore.MaxEntries != newCfg.ResponseStore.MaxEntries {
+		changes = append(changes, fmt.Sprintf("response-store: updated (ttl-seconds %d -> %d, max-entries %d -> %d)", oldCfg.ResponseStore.TTLSeconds, newCfg.ResponseStore.TTLSeconds, oldCfg.ResponseStore.MaxEntries, newCfg.ResponseStore.MaxEntries))
+	}
+	if oldCfg.Batch.Enable != newCfg.Batch.Enable {
+		changes = append(changes, fmt.Sprintf("batch.enable: %t -> %t", oldCfg.Batch.Enable, newCfg.Batch.Enable))
+	}
+	if oldCfg.Batch.Concurrency != newCfg.Batch.Concurrency {
+		changes = append(changes, fmt.Sprintf("batch.concurrency: %d -> %d", oldCfg.Batch.Concurrency, newCfg.Batch.Concurrency))
+	}
+	if oldCfg.Batch.Path != newCfg.Batch.Path {
+		changes = append(changes, fmt.Sprintf("batch.path: %s -> %s", oldCfg.Batch.Path, newCfg.Batch.Path))
+	}
+	if oldCfg.Batch.MaxFileBytes != newCfg.Batch.MaxFileBytes {
+		changes = append(changes, fmt.Sprintf("batch.max-file-bytes: %d -> %d", oldCfg.Batch.MaxFileBytes, newCfg.Batch.MaxFileBytes))
+	}
+	if oldCfg.ResponseCache != newCfg.ResponseCache {
+		changes = append(changes, fmt.Sprintf("response-cache: updated (enable %t -> %t, ttl-seconds %d -> %d)", oldCfg.ResponseCache.Enable, newCfg.ResponseCache.Enable, oldCfg.ResponseCache.TTLSeconds, newCfg.ResponseCache.TTLSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.SessionAffinity, newCfg.SessionAffinity) {
+		changes = append(changes, fmt.Sprintf("session-affinity: updated (enable %t -> %t, sources %v -> %v, ttl-seconds %d -> %d)", oldCfg.SessionAffinity.Enable, newCfg.SessionAffinity.Enable, oldCfg.SessionAffinity.Sources, newCfg.SessionAffinity.Sources, oldCfg.SessionAffinity.TTLSeconds, newCfg.SessionAffinity.TTLSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.ContextGuard, newCfg.ContextGuard) {
+		changes = append(changes, fmt.Sprintf("context-guard: updated (enable %t -> %t, strategies %v -> %v)", oldCfg.ContextGuard.Enable, newCfg.ContextGuard.Enable, oldCfg.ContextGuard.Strategies, newCfg.ContextGuard.Strategies))
+	}
+	if oldCfg.CircuitBreaker != newCfg.CircuitBreaker {
+		changes = append(changes, fmt.Sprintf("circuit-breaker: updated (enable %t -> %t, failure-threshold %d -> %d, open-seconds %d -> %d, half-open-probes %d -> %d)", oldCfg.CircuitBreaker.Enable, newCfg.CircuitBreaker.Enable, oldCfg.CircuitBreaker.FailureThreshold, newCfg.CircuitBreaker.FailureThreshold, oldCfg.CircuitBreaker.OpenSeconds, newCfg.CircuitBreaker.OpenSeconds, oldCfg.CircuitBreaker.HalfOpenProbes, newCfg.CircuitBreaker.HalfOpenProbes))
+	}
+	if oldCfg.Cluster != newCfg.Cluster {
+		changes = append(changes, fmt.Sprintf("cluster: updated (enable %t -> %t, driver %s -> %s, instance-id %s -> %s, sync-interval-seconds %d -> %d, lease-seconds %d -> %d)", oldCfg.Cluster.Enable, newCfg.Cluster.Enable, oldCfg.Cluster.Driver, newCfg.Cluster.Driver, oldCfg.Cluster.InstanceID, newCfg.Cluster.InstanceID, oldCfg.Cluster.SyncIntervalSeconds, newCfg.Cluster.SyncIntervalSeconds, oldCfg.Cluster.LeaseSeconds, newCfg.Cluster.LeaseSeconds))
+	}
+	if oldCfg.RequestQueue != newCfg.RequestQueue {
+		changes = append(changes, fmt.Sprintf("request-queue: updated (enable %t -> %t, max-depth %d -> %d, max-wait-seconds %d -> %d)", oldCfg.RequestQueue.Enable, newCfg.RequestQueue.Enable, oldCfg.RequestQueue.MaxDepth, newCfg.RequestQueue.MaxDepth, oldCfg.RequestQueue.MaxWaitSeconds, newCfg.RequestQueue.MaxWaitSeconds))
+	}
+	if oldCfg.LocalTokenCount != newCfg.LocalTokenCount {
+		changes = append(changes, fmt.Sprintf("local-token-count: %t -> %t", oldCfg.LocalTokenCount, newCfg.LocalTokenCount))
+	}
 	if oldCfg.DisableCooling != newCfg.DisableCooling {
 		changes = append(changes, fmt.Sprintf("disable-cooling: %t -> %t", oldCfg.DisableCooling, newCfg.DisableCooling))
 	}
@@ -51,6 +117,33 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.ErrorLogsMaxFiles != newCfg.ErrorLogsMaxFiles {
 		changes = append(changes, fmt.Sprintf("error-logs-max-files: %d -> %d", oldCfg.ErrorLogsMaxFiles, newCfg.ErrorLogsMaxFiles))
 	}
+	if oldCfg.AuditLog.Enable != newCfg.AuditLog.Enable {
+		changes = append(changes, fmt.Sprintf("audit-log.enable: %t -> %t", oldCfg.AuditLog.Enable, newCfg.AuditLog.Enable))
+	}
+	if oldCfg.AuditLog.Path != newCfg.AuditLog.Path {
+		changes = append(changes, fmt.Sprintf("audit-log.path: %s -> %s", oldCfg.AuditLog.Path, newCfg.AuditLog.Path))
+	}
+	if oldCfg.AuditLog.IncludeBodies != newCfg.AuditLog.IncludeBodies {
+		changes = append(changes, fmt.Sprintf("audit-log.include-bodies: %t -> %t", oldCfg.AuditLog.IncludeBodies, newCfg.AuditLog.IncludeBodies))
+	}
+	if !reflect.DeepEqual(oldCfg.AuditLog.RedactHeaders, newCfg.AuditLog.RedactHeaders) || !reflect.DeepEqual(oldCfg.AuditLog.RedactJSONPaths, newCfg.AuditLog.RedactJSONPaths) {
+		changes = append(changes, "audit-log: redaction rules updated")
+	}
+	if oldCfg.Tracing.Enable != newCfg.Tracing.Enable {
+		changes = append(changes, fmt.Sprintf("tracing.enable: %t -> %t", oldCfg.Tracing.Enable, newCfg.Tracing.Enable))
+	}
+	if oldCfg.Tracing.Endpoint != newCfg.Tracing.Endpoint {
+		changes = append(changes, fmt.Sprintf("tracing.endpoint: %s -> %s", oldCfg.Tracing.Endpoint, newCfg.Tracing.Endpoint))
+	}
+	if oldCfg.Tracing.ServiceName != newCfg.Tracing.ServiceName {
+		changes = append(changes, fmt.Sprintf("tracing.service-name: %s -> %s", oldCfg.Tracing.ServiceName, newCfg.Tracing.ServiceName))
+	}
+	if oldCfg.Tracing.SampleRatio != newCfg.Tracing.SampleRatio {
+		changes = append(changes, fmt.Sprintf("tracing.sample-ratio: %g -> %g", oldCfg.Tracing.SampleRatio, newCfg.Tracing.SampleRatio))
+	}
+	if !reflect.DeepEqual(oldCfg.Tracing.Headers, newCfg.Tracing.Headers) {
+		changes = append(changes, "tracing.headers: updated")
+	}
 	if oldCfg.RequestRetry != newCfg.RequestRetry {
 		changes = append(changes, fmt.Sprintf("request-retry: %d -> %d", oldCfg.RequestRetry, newCfg.RequestRetry))
 	}
@@ -81,6 +174,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.Routing.Strategy != newCfg.Routing.Strategy {
 		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", oldCfg.Routing.Strategy, newCfg.Routing.Strategy))
 	}
+	if oldCfg.Routing.HealthDecay != newCfg.Routing.HealthDecay {
+		changes = append(changes, fmt.Sprintf("routing.health-decay: %g -> %g", oldCfg.Routing.HealthDecay, newCfg.Routing.HealthDecay))
+	}
+	if !reflect.DeepEqual(oldCfg.Routing.Hedging, newCfg.Routing.Hedging) {
+		changes = append(changes, fmt.Sprintf("routing.hedging: updated (max-in-flight %d -> %d, rules %d -> %d)", oldCfg.Routing.Hedging.MaxInFlight, newCfg.Routing.Hedging.MaxInFlight, len(oldCfg.Routing.Hedging.Rules), len(newCfg.Routing.Hedging.Rules)))
+	}
 
 	// API keys (redacted) and counts
 	if len(oldCfg.APIKeys) != len(newCfg.APIKeys) {
@@ -88,6 +187,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if !reflect.DeepEqual(oldCfg.APIKeyLimits, newCfg.APIKeyLimits) {
+		changes = append(changes, fmt.Sprintf("api-key-limits: updated (%d -> %d entries)", len(oldCfg.APIKeyLimits), len(newCfg.APIKeyLimits)))
+	}
+	if !reflect.DeepEqual(oldCfg.APIKeyPolicies, newCfg.APIKeyPolicies) {
+		changes = append(changes, fmt.Sprintf("api-key-policies: updated (%d -> %d entries)", len(oldCfg.APIKeyPolicies), len(newCfg.APIKeyPolicies)))
+	}
+	if !reflect.DeepEqual(oldCfg.ModelFallbacks, newCfg.ModelFallbacks) {
+		changes = append(changes, fmt.Sprintf("model-fallbacks: updated (%d -> %d entries)", len(oldCfg.ModelFallbacks), len(newCfg.ModelFallbacks)))
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -106,6 +214,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].api-key: updated", i))
 			}
+			if o.CredentialLimits != n.CredentialLimits {
+				changes = append(changes, fmt.Sprintf("gemini[%d].limits: updated", i))
+			}
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].headers: updated", i))
 			}
@@ -141,6 +252,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("claude[%d].api-key: updated", i))
 			}
+			if o.CredentialLimits != n.CredentialLimits {
+				changes = append(changes, fmt.Sprintf("claude[%d].limits: updated", i))
+			}
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("claude[%d].headers: updated", i))
 			}
@@ -190,6 +304,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("codex[%d].api-key: updated", i))
 			}
+			if o.CredentialLimits != n.CredentialLimits {
+				changes = append(changes, fmt.Sprintf("codex[%d].limits: updated", i))
+			}
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("codex[%d].headers: updated", i))
 			}
@@ -239,6 +356,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 		changes = append(changes, fmt.Sprintf("ampcode.upstream-api-keys: updated (%d -> %d entries)", oldUpstreamAPIKeysCount, newUpstreamAPIKeysCount))
 	}
 
+	if !reflect.DeepEqual(oldCfg.ProviderLimits, newCfg.ProviderLimits) {
+		changes = append(changes, fmt.Sprintf("provider-limits: updated (%d -> %d providers)", len(oldCfg.ProviderLimits), len(newCfg.ProviderLimits)))
+	}
 	if entries, _ := DiffOAuthExcludedModelChanges(oldCfg.OAuthExcludedModels, newCfg.OAuthExcludedModels); len(entries) > 0 {
 		changes = append(changes, entries...)
 	}
@@ -296,6 +416,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].api-key: updated", i))
 			}
+			if o.CredentialLimits != n.CredentialLimits {
+				changes = append(changes, fmt.Sprintf("vertex[%d].limits: updated", i))
+			}
 			oldModels := SummarizeVertexModels(o.Models)
 			newModels := SummarizeVertexModels(n.Models)
 			if oldModels.hash != newModels.hash {
@@ -65,7 +65,7 @@ func describeOpenAICompatibilityUpdate(oldEntry, newEntry config.OpenAICompatibi
 	newKeyCount := countAPIKeys(newEntry)
 	oldModelCount := countOpenAIModels(oldEntry.Models)
 	newModelCount := countOpenAIModels(newEntry.Models)
-	details := make([]string, 0, 3)
+	details := make([]string, 0, 4)
 	if oldKeyCount != newKeyCount {
 		details = append(details, fmt.Sprintf("api-keys %d -> %d", oldKeyCount, newKeyCount))
 	}
@@ -75,6 +75,9 @@ func describeOpenAICompatibilityUpdate(oldEntry, newEntry config.OpenAICompatibi
 	if !equalStringMap(oldEntry.Headers, newEntry.Headers) {
 		details = append(details, "headers updated")
 	}
+	if oldEntry.CredentialLimits != newEntry.CredentialLimits {
+		details = append(details, "limits updated")
+	}
 	if len(details) == 0 {
 		return ""
 	}
@@ -150,7 +153,14 @@ func openAICompatSignature(entry config.OpenAICompatibility) string {
 		if name == "" && alias == "" {
 			continue
 		}
-		models = append(models, strings.ToLower(name)+"|"+strings.ToLower(alias))
+		sig := strings.ToLower(name) + "|" + strings.ToLower(alias)
+		if kind := strings.TrimSpace(model.Kind); kind != "" {
+			sig += "|" + strings.ToLower(kind)
+		}
+		if tok := strings.TrimSpace(model.Tokenizer); tok != "" {
+			sig += "|tokenizer=" + strings.ToLower(tok)
+		}
+		models = append(models, sig)
 	}
 	if len(models) > 0 {
 		sort.Strings(models)
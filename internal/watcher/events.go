@@ -14,6 +14,7 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -193,7 +194,7 @@ func (w *Watcher) handleKiroIDETokenChange(event fsnotify.Event) {
 }
 
 func (w *Watcher) authFileUnchanged(path string) (bool, error) {
-	data, errRead := os.ReadFile(path)
+	data, errRead := authcrypt.ReadFile(path)
 	if errRead != nil {
 		return false, errRead
 	}
@@ -67,6 +67,7 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 		if entry.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(entry.Priority)
 		}
+		addCredentialLimitsToAttrs(entry.CredentialLimits, attrs)
 		if base != "" {
 			attrs["base_url"] = base
 		}
@@ -114,6 +115,7 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 		if ck.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(ck.Priority)
 		}
+		addCredentialLimitsToAttrs(ck.CredentialLimits, attrs)
 		if base != "" {
 			attrs["base_url"] = base
 		}
@@ -161,6 +163,7 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 		if ck.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(ck.Priority)
 		}
+		addCredentialLimitsToAttrs(ck.CredentialLimits, attrs)
 		if ck.BaseURL != "" {
 			attrs["base_url"] = ck.BaseURL
 		}
@@ -222,6 +225,7 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if compat.Priority != 0 {
 				attrs["priority"] = strconv.Itoa(compat.Priority)
 			}
+			addCredentialLimitsToAttrs(compat.CredentialLimits, attrs)
 			if key != "" {
 				attrs["api_key"] = key
 			}
@@ -256,6 +260,7 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if compat.Priority != 0 {
 				attrs["priority"] = strconv.Itoa(compat.Priority)
 			}
+			addCredentialLimitsToAttrs(compat.CredentialLimits, attrs)
 			if hash := diff.ComputeOpenAICompatModelsHash(compat.Models); hash != "" {
 				attrs["models_hash"] = hash
 			}
@@ -301,6 +306,7 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 		if compat.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(compat.Priority)
 		}
+		addCredentialLimitsToAttrs(compat.CredentialLimits, attrs)
 		if key != "" {
 			attrs["api_key"] = key
 		}
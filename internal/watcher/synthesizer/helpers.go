@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -118,3 +119,38 @@ func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string)
 		attrs["header:"+key] = val
 	}
 }
+
+// credentialLimitAttributes lists the auth attributes carrying per-credential limits.
+var credentialLimitAttributes = []string{"max_in_flight", "requests_per_minute", "tokens_per_minute"}
+
+// addCredentialLimitsToAttrs records the configured per-credential limits as auth attributes.
+func addCredentialLimitsToAttrs(limits config.CredentialLimits, attrs map[string]string) {
+	if attrs == nil {
+		return
+	}
+	values := []int{limits.MaxInFlight, limits.RequestsPerMinute, limits.TokensPerMinute}
+	for i, key := range credentialLimitAttributes {
+		if values[i] > 0 {
+			attrs[key] = strconv.Itoa(values[i])
+		}
+	}
+}
+
+// addMetadataLimitsToAttrs copies per-credential limits set in an auth file into its attributes.
+func addMetadataLimitsToAttrs(metadata map[string]any, attrs map[string]string) {
+	if len(metadata) == 0 || attrs == nil {
+		return
+	}
+	for _, key := range credentialLimitAttributes {
+		switch v := metadata[key].(type) {
+		case float64:
+			if v > 0 {
+				attrs[key] = strconv.Itoa(int(v))
+			}
+		case string:
+			if n, errAtoi := strconv.Atoi(strings.TrimSpace(v)); errAtoi == nil && n > 0 {
+				attrs[key] = strconv.Itoa(n)
+			}
+		}
+	}
+}
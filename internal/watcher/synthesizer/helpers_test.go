@@ -287,3 +287,19 @@ func TestAddConfigHeadersToAttrs(t *testing.T) {
 		})
 	}
 }
+
+func TestCredentialLimitsToAttrs(t *testing.T) {
+	attrs := map[string]string{}
+	addCredentialLimitsToAttrs(config.CredentialLimits{MaxInFlight: 2, TokensPerMinute: 5000}, attrs)
+	want := map[string]string{"max_in_flight": "2", "tokens_per_minute": "5000"}
+	if !reflect.DeepEqual(attrs, want) {
+		t.Fatalf("config limits attrs = %v, want %v", attrs, want)
+	}
+
+	attrs = map[string]string{}
+	addMetadataLimitsToAttrs(map[string]any{"max_in_flight": float64(3), "requests_per_minute": " 20 ", "tokens_per_minute": "lots"}, attrs)
+	want = map[string]string{"max_in_flight": "3", "requests_per_minute": "20"}
+	if !reflect.DeepEqual(attrs, want) {
+		t.Fatalf("metadata limits attrs = %v, want %v", attrs, want)
+	}
+}
@@ -4,17 +4,26 @@ import "time"
 
 // Event types for webhook alerting.
 const (
-	EventAccountBanned = "account_banned"
-	EventRateLimited   = "rate_limited"
+	EventAccountBanned  = "account_banned"
+	EventRateLimited    = "rate_limited"
+	EventRefreshFailed  = "refresh_failed"
+	EventAuthDisabled   = "auth_disabled"
+	EventAuthRecovered  = "auth_recovered"
+	EventQuotaRecovered = "quota_recovered"
+	EventAuthAdded      = "auth_added"
+	EventBreakerOpened  = "breaker_opened"
+	EventBreakerClosed  = "breaker_closed"
 )
 
 // Webhook provider types.
 const (
 	TypeWecom = "wecom"
+	TypeHTTP  = "http"
 )
 
 const (
 	defaultThrottleMinutes = 10
+	defaultMaxRetries      = 3
 )
 
 // resolvedType returns the webhook type, defaulting to wecom.
@@ -40,3 +49,15 @@ func resolvedThrottle(minutes int) time.Duration {
 	}
 	return time.Duration(minutes) * time.Minute
 }
+
+// resolvedRetries returns the retry count, defaulting to 3. Negative values disable retries.
+func resolvedRetries(retries int) int {
+	switch {
+	case retries < 0:
+		return 0
+	case retries == 0:
+		return defaultMaxRetries
+	default:
+		return retries
+	}
+}
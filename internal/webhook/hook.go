@@ -3,32 +3,102 @@ package webhook
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
 // WebhookHook implements coreauth.Hook and dispatches webhook alerts
-// for ban and rate-limiting events.
+// for credential lifecycle, ban, rate-limiting and circuit breaker events.
 type WebhookHook struct {
 	sender *Sender
+
+	mu sync.Mutex
+	// auths holds the last observed state of each auth to detect transitions.
+	auths map[string]authSnapshot
+	// limited tracks auth+model pairs that were rate limited and have not succeeded since.
+	limited map[limitKey]struct{}
+	// failing tracks auths whose last result was an authorization failure or ban.
+	failing map[string]struct{}
+}
+
+// authSnapshot is the subset of auth state used to detect transitions.
+type authSnapshot struct {
+	disabled      bool
+	unavailable   bool
+	quotaExceeded bool
+	refreshFailed bool
+	status        coreauth.Status
 }
 
+type limitKey struct {
+	authID string
+	model  string
+}
+
+// refreshFailedCode is the coreauth.Error code recorded when a token refresh fails.
+const refreshFailedCode = "refresh_failed"
+
 // NewWebhookHook creates a hook backed by the given webhook configurations.
 func NewWebhookHook(entries []config.WebhookEntry) *WebhookHook {
-	return &WebhookHook{sender: NewSender(entries)}
+	return &WebhookHook{
+		sender:  NewSender(entries),
+		auths:   make(map[string]authSnapshot),
+		limited: make(map[limitKey]struct{}),
+		failing: make(map[string]struct{}),
+	}
+}
+
+// OnAuthRegistered records the auth and alerts when a new auth file was added.
+func (h *WebhookHook) OnAuthRegistered(_ context.Context, auth *coreauth.Auth) {
+	if auth == nil || auth.ID == "" {
+		return
+	}
+	h.mu.Lock()
+	_, known := h.auths[auth.ID]
+	h.auths[auth.ID] = snapshotAuth(auth)
+	h.mu.Unlock()
+
+	// Only file-backed credentials count as added; config API keys are registered on every start.
+	if !known && auth.Attributes["path"] != "" {
+		h.sender.Dispatch(authEvent(EventAuthAdded, auth))
+	}
 }
 
-// OnAuthRegistered is a no-op for webhook alerting.
-func (h *WebhookHook) OnAuthRegistered(_ context.Context, _ *coreauth.Auth) {}
+// OnAuthUpdated compares the auth with its previous state and alerts on refresh failures,
+// disabling, recovery and quota recovery.
+func (h *WebhookHook) OnAuthUpdated(_ context.Context, auth *coreauth.Auth) {
+	if auth == nil || auth.ID == "" {
+		return
+	}
+	current := snapshotAuth(auth)
+	h.mu.Lock()
+	previous, known := h.auths[auth.ID]
+	h.auths[auth.ID] = current
+	h.mu.Unlock()
 
-// OnAuthUpdated is a no-op for webhook alerting.
-func (h *WebhookHook) OnAuthUpdated(_ context.Context, _ *coreauth.Auth) {}
+	for _, event := range classifyAuthUpdate(previous, known, current) {
+		h.sender.Dispatch(authEvent(event, auth))
+	}
+}
 
 // OnResult inspects the execution result and dispatches a webhook if
-// the error indicates an account ban or rate-limiting event.
+// the error indicates an account ban or rate-limiting event, or if a
+// previously failing or rate-limited credential succeeds again.
 func (h *WebhookHook) OnResult(_ context.Context, result coreauth.Result) {
+	if result.AuthID == "" {
+		return
+	}
+	if result.Success {
+		for _, event := range h.recoveredEvents(result) {
+			h.sender.Dispatch(Event{Type: event, AuthID: result.AuthID, Provider: result.Provider, Model: result.Model})
+		}
+		return
+	}
+
 	event := classifyEvent(result)
+	h.trackFailure(result, event)
 	if event == "" {
 		return
 	}
@@ -42,6 +112,54 @@ func (h *WebhookHook) OnResult(_ context.Context, result coreauth.Result) {
 	h.sender.TrySend(event, result.AuthID, result.Provider, result.Model, httpStatus, errMsg)
 }
 
+// trackFailure remembers rate limits and authorization failures so a later success can be reported.
+func (h *WebhookHook) trackFailure(result coreauth.Result, event string) {
+	status := 0
+	if result.Error != nil {
+		status = result.Error.HTTPStatus
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case event == EventRateLimited:
+		h.limited[limitKey{authID: result.AuthID, model: result.Model}] = struct{}{}
+	case event == EventAccountBanned, status == 401, status == 403:
+		h.failing[result.AuthID] = struct{}{}
+	}
+}
+
+// recoveredEvents clears failure tracking for a successful result and returns the recovery events.
+func (h *WebhookHook) recoveredEvents(result coreauth.Result) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var events []string
+	if _, ok := h.failing[result.AuthID]; ok {
+		delete(h.failing, result.AuthID)
+		events = append(events, EventAuthRecovered)
+	}
+	key := limitKey{authID: result.AuthID, model: result.Model}
+	if _, ok := h.limited[key]; ok {
+		delete(h.limited, key)
+		events = append(events, EventQuotaRecovered)
+	}
+	return events
+}
+
+// OnBreakerStateChange implements coreauth.BreakerHook, alerting when an upstream base URL
+// trips its circuit breaker and when it recovers.
+func (h *WebhookHook) OnBreakerStateChange(_ context.Context, status coreauth.BreakerStatus) {
+	var event string
+	switch status.State {
+	case coreauth.BreakerOpen:
+		event = EventBreakerOpened
+	case coreauth.BreakerClosed:
+		event = EventBreakerClosed
+	default:
+		return
+	}
+	h.sender.Dispatch(Event{Type: event, Provider: status.Provider, BaseURL: status.BaseURL, Error: status.LastError})
+}
+
 // UpdateConfig replaces the webhook configuration for hot-reload.
 func (h *WebhookHook) UpdateConfig(entries []config.WebhookEntry) {
 	h.sender.UpdateConfig(entries)
@@ -64,6 +182,49 @@ func classifyEvent(result coreauth.Result) string {
 	return ""
 }
 
+// classifyAuthUpdate returns the events implied by an auth moving from previous to current.
+// A refresh failure is reported once when it starts, not again on every later update of an
+// auth still carrying it.
+func classifyAuthUpdate(previous authSnapshot, known bool, current authSnapshot) []string {
+	var events []string
+	if current.refreshFailed && (!known || !previous.refreshFailed) {
+		events = append(events, EventRefreshFailed)
+	}
+	if !known {
+		return events
+	}
+	switch {
+	case current.disabled && !previous.disabled:
+		events = append(events, EventAuthDisabled)
+	case !current.disabled && current.status == coreauth.StatusActive && !current.unavailable &&
+		(previous.disabled || previous.unavailable || previous.status == coreauth.StatusError):
+		events = append(events, EventAuthRecovered)
+	}
+	if previous.quotaExceeded && !current.quotaExceeded {
+		events = append(events, EventQuotaRecovered)
+	}
+	return events
+}
+
+func snapshotAuth(auth *coreauth.Auth) authSnapshot {
+	return authSnapshot{
+		disabled:      auth.Disabled || auth.Status == coreauth.StatusDisabled,
+		unavailable:   auth.Unavailable,
+		quotaExceeded: auth.Quota.Exceeded,
+		refreshFailed: auth.LastError != nil && auth.LastError.Code == refreshFailedCode,
+		status:        auth.Status,
+	}
+}
+
+func authEvent(event string, auth *coreauth.Auth) Event {
+	ev := Event{Type: event, AuthID: auth.ID, Provider: auth.Provider}
+	if auth.LastError != nil {
+		ev.Error = auth.LastError.Message
+		ev.HTTPStatus = auth.LastError.HTTPStatus
+	}
+	return ev
+}
+
 // isAccountBanned mirrors the conductor's isAccountBannedError heuristic.
 func isAccountBanned(msg string) bool {
 	if msg == "" {
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex>" computed over "<timestamp>.<body>" with the webhook secret.
+	SignatureHeader = "X-CPA-Signature"
+	// TimestampHeader carries the Unix timestamp included in the signature.
+	TimestampHeader = "X-CPA-Timestamp"
+	// EventHeader names the event type of the delivery.
+	EventHeader = "X-CPA-Event"
+)
+
+// Event is the data passed to webhook formatters and to user templates of "http" webhooks.
+type Event struct {
+	Type       string    `json:"event"`
+	AuthID     string    `json:"auth_id"`
+	Account    string    `json:"account"`
+	Provider   string    `json:"provider,omitempty"`
+	BaseURL    string    `json:"base_url,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// accountName strips the directory and .json suffix from an auth ID for readability.
+func accountName(authID string) string {
+	return strings.TrimSuffix(filepath.Base(authID), ".json")
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value as JSON, so strings can be embedded in JSON templates safely.
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"truncate": func(n int, s string) string {
+		if n >= 0 && len(s) > n {
+			return s[:n] + "..."
+		}
+		return s
+	},
+}
+
+// formatHTTPMessage renders the body of an "http" webhook. An empty template sends the event as JSON.
+func formatHTTPMessage(tmpl string, event Event) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return json.Marshal(event)
+	}
+	parsed, err := template.New("webhook").Funcs(templateFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = parsed.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// signPayload returns the signature header value for body sent at ts.
+func signPayload(secret string, ts time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts.Unix(), 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
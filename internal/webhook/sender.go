@@ -2,7 +2,10 @@ package webhook
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -11,11 +14,12 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
-// throttleKey uniquely identifies a (auth, event, webhook URL) combination.
+// throttleKey uniquely identifies a (auth or upstream base URL, event, webhook URL) combination.
 type throttleKey struct {
-	AuthID string
-	Event  string
-	URL    string
+	AuthID  string
+	BaseURL string
+	Event   string
+	URL     string
 }
 
 // Sender dispatches webhook notifications with per-key throttling.
@@ -24,37 +28,58 @@ type Sender struct {
 	entries  []config.WebhookEntry
 	lastSent map[throttleKey]time.Time
 	client   *http.Client
+	// retryBackoff is the delay before the first retry; it doubles on every further attempt.
+	retryBackoff time.Duration
 }
 
 // NewSender creates a Sender for the given webhook configurations.
 func NewSender(entries []config.WebhookEntry) *Sender {
 	return &Sender{
-		entries:  entries,
-		lastSent: make(map[throttleKey]time.Time),
-		client:   &http.Client{Timeout: 10 * time.Second},
+		entries:      entries,
+		lastSent:     make(map[throttleKey]time.Time),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		retryBackoff: time.Second,
 	}
 }
 
 // TrySend attempts to dispatch a webhook for the given event. It checks the
 // throttle window and, if not throttled, sends asynchronously.
 func (s *Sender) TrySend(event string, authID, provider, model string, httpStatus int, errMsg string) {
+	s.Dispatch(Event{
+		Type:       event,
+		AuthID:     authID,
+		Provider:   provider,
+		Model:      model,
+		HTTPStatus: httpStatus,
+		Error:      errMsg,
+	})
+}
+
+// Dispatch sends event to every subscribed webhook outside its throttle window.
+// Deliveries run asynchronously.
+func (s *Sender) Dispatch(event Event) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
+	if event.Time.IsZero() {
+		event.Time = now
+	}
+	if event.AuthID != "" {
+		event.Account = accountName(event.AuthID)
+	}
 	for _, entry := range s.entries {
-		if !eventMatches(entry, event) {
+		if !eventMatches(entry, event.Type) {
 			continue
 		}
-		key := throttleKey{AuthID: authID, Event: event, URL: entry.URL}
+		key := throttleKey{AuthID: event.AuthID, BaseURL: event.BaseURL, Event: event.Type, URL: entry.URL}
 		throttle := resolvedThrottle(entry.ThrottleMinutes)
 		if last, ok := s.lastSent[key]; ok && now.Sub(last) < throttle {
 			continue
 		}
 		s.lastSent[key] = now
 
-		typ := resolvedType(entry.Type)
-		go s.send(typ, entry.URL, event, authID, provider, model, httpStatus, errMsg, now)
+		go s.send(entry, event)
 	}
 }
 
@@ -76,13 +101,20 @@ func (s *Sender) UpdateConfig(entries []config.WebhookEntry) {
 	}
 }
 
-func (s *Sender) send(typ, url, event, authID, provider, model string, httpStatus int, errMsg string, ts time.Time) {
+func (s *Sender) send(entry config.WebhookEntry, event Event) {
 	var body []byte
 	var err error
 
+	typ := resolvedType(entry.Type)
 	switch typ {
 	case TypeWecom:
-		body, err = formatWecomMessage(event, authID, provider, model, httpStatus, errMsg, ts)
+		if event.BaseURL != "" {
+			body, err = formatWecomBreakerMessage(event)
+			break
+		}
+		body, err = formatWecomMessage(event.Type, event.AuthID, event.Provider, event.Model, event.HTTPStatus, event.Error, event.Time)
+	case TypeHTTP:
+		body, err = formatHTTPMessage(entry.Template, event)
 	default:
 		log.Warnf("webhook: unsupported type %q", typ)
 		return
@@ -92,17 +124,52 @@ func (s *Sender) send(typ, url, event, authID, provider, model string, httpStatu
 		return
 	}
 
-	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	retries := resolvedRetries(entry.MaxRetries)
+	backoff := s.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, errPost := s.post(entry, event, body)
+		if errPost == nil {
+			log.Debugf("webhook: sent %s alert for %s to %s", event.Type, event.AuthID, entry.URL)
+			return
+		}
+		if !retry || attempt >= retries {
+			log.Warnf("webhook: %v", errPost)
+			return
+		}
+		log.Debugf("webhook: %v, retrying in %s", errPost, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post delivers body once. It reports whether a failed delivery is worth retrying.
+func (s *Sender) post(entry config.WebhookEntry, event Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, entry.URL, bytes.NewReader(body))
 	if err != nil {
-		log.Warnf("webhook: POST to %s failed: %v", url, err)
-		return
+		return false, fmt.Errorf("invalid request for %s: %w", entry.URL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	for name, value := range entry.Headers {
+		req.Header.Set(name, value)
+	}
+	if entry.Secret != "" {
+		ts := time.Now()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+		req.Header.Set(SignatureHeader, signPayload(entry.Secret, ts, body))
 	}
-	defer resp.Body.Close()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("POST to %s failed: %w", entry.URL, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		log.Warnf("webhook: POST to %s returned status %d", url, resp.StatusCode)
-		return
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return retry, fmt.Errorf("POST to %s returned status %d", entry.URL, resp.StatusCode)
 	}
-	log.Debugf("webhook: sent %s alert for %s to %s", event, authID, url)
+	return false, nil
 }
 
 // eventMatches checks if the webhook entry is subscribed to the given event.
@@ -1,8 +1,14 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -168,3 +174,136 @@ func TestResolvedDefaults(t *testing.T) {
 		t.Errorf("resolvedThrottle(0) = %v, want 10m", d)
 	}
 }
+
+func TestHTTPWebhook_TemplateHeadersSignatureAndRetry(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	sender := NewSender([]config.WebhookEntry{{
+		URL:      server.URL,
+		Type:     TypeHTTP,
+		Events:   []string{EventRefreshFailed},
+		Template: `{"text":{{json (printf "%s failed for %s" .Type .Account)}},"provider":"{{.Provider}}"}`,
+		Headers:  map[string]string{"X-Team": "infra"},
+		Secret:   "s3cret",
+	}})
+	sender.retryBackoff = time.Millisecond
+	sender.Dispatch(Event{Type: EventRefreshFailed, AuthID: "/auths/user@example.com.json", Provider: "claude"})
+
+	var req *http.Request
+	var body []byte
+	select {
+	case req = <-received:
+		body = <-bodies
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered after retry")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("calls = %d, want 2", got)
+	}
+	if want := `{"text":"refresh_failed failed for user@example.com","provider":"claude"}`; string(body) != want {
+		t.Fatalf("body = %s, want %s", body, want)
+	}
+	if req.Header.Get("X-Team") != "infra" || req.Header.Get(EventHeader) != EventRefreshFailed {
+		t.Fatalf("missing headers: %v", req.Header)
+	}
+	ts, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp header: %v", err)
+	}
+	if want := signPayload("s3cret", time.Unix(ts, 0), body); req.Header.Get(SignatureHeader) != want {
+		t.Fatalf("signature = %s, want %s", req.Header.Get(SignatureHeader), want)
+	}
+}
+
+func TestWebhookHook_LifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	hook := NewWebhookHook(nil)
+	auth := &coreauth.Auth{ID: "a.json", Provider: "codex", Status: coreauth.StatusActive, Attributes: map[string]string{"path": "/auths/a.json"}}
+	hook.OnAuthRegistered(ctx, auth)
+
+	failed := auth.Clone()
+	failed.LastError = &coreauth.Error{Code: refreshFailedCode, Message: "invalid_grant"}
+	failed.Status = coreauth.StatusError
+	failed.Quota.Exceeded = true
+	hook.OnAuthUpdated(ctx, failed)
+	previous := snapshotAuth(failed)
+
+	disabled := failed.Clone()
+	disabled.LastError = nil
+	disabled.Disabled = true
+	if got := classifyAuthUpdate(previous, true, snapshotAuth(disabled)); len(got) != 1 || got[0] != EventAuthDisabled {
+		t.Fatalf("disable events = %v", got)
+	}
+
+	recovered := auth.Clone()
+	if got := classifyAuthUpdate(previous, true, snapshotAuth(recovered)); len(got) != 2 || got[0] != EventAuthRecovered || got[1] != EventQuotaRecovered {
+		t.Fatalf("recovery events = %v", got)
+	}
+	if got := classifyAuthUpdate(authSnapshot{}, false, snapshotAuth(failed)); len(got) != 1 || got[0] != EventRefreshFailed {
+		t.Fatalf("refresh events = %v", got)
+	}
+	if got := classifyAuthUpdate(previous, true, snapshotAuth(failed)); len(got) != 0 {
+		t.Fatalf("repeated refresh failure events = %v, want none", got)
+	}
+
+	hook.OnResult(ctx, coreauth.Result{AuthID: "a.json", Model: "gpt-5", Error: &coreauth.Error{HTTPStatus: 429}})
+	if got := hook.recoveredEvents(coreauth.Result{AuthID: "a.json", Model: "gpt-5", Success: true}); len(got) != 1 || got[0] != EventQuotaRecovered {
+		t.Fatalf("result recovery events = %v", got)
+	}
+	if got := hook.recoveredEvents(coreauth.Result{AuthID: "a.json", Model: "gpt-5", Success: true}); len(got) != 0 {
+		t.Fatalf("recovery reported twice: %v", got)
+	}
+}
+
+func TestWebhookHook_BreakerEvents(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	hook := NewWebhookHook([]config.WebhookEntry{{
+		URL:      server.URL,
+		Type:     TypeHTTP,
+		Events:   []string{EventBreakerOpened},
+		Template: `{{.Type}} {{.Provider}} {{.BaseURL}} [{{.Account}}]`,
+	}})
+	ctx := context.Background()
+	hook.OnBreakerStateChange(ctx, coreauth.BreakerStatus{Provider: "openrouter", BaseURL: "https://openrouter.ai/api/v1", State: coreauth.BreakerHalfOpen})
+	hook.OnBreakerStateChange(ctx, coreauth.BreakerStatus{Provider: "openrouter", BaseURL: "https://openrouter.ai/api/v1", State: coreauth.BreakerOpen})
+
+	select {
+	case body := <-bodies:
+		if want := "breaker_opened openrouter https://openrouter.ai/api/v1 []"; string(body) != want {
+			t.Fatalf("body = %s, want %s", body, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("breaker_opened was not delivered")
+	}
+	select {
+	case body := <-bodies:
+		t.Fatalf("unexpected delivery: %s", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	data, err := formatWecomBreakerMessage(Event{Type: EventBreakerOpened, Provider: "openrouter", BaseURL: "https://openrouter.ai/api/v1", Time: time.Now()})
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if !strings.Contains(string(data), "Circuit Breaker Opened") || !strings.Contains(string(data), "Base URL: https://openrouter.ai/api/v1") {
+		t.Fatalf("wecom breaker message = %s", data)
+	}
+}
@@ -3,8 +3,6 @@ package webhook
 import (
 	"encoding/json"
 	"fmt"
-	"path/filepath"
-	"strings"
 	"time"
 )
 
@@ -22,19 +20,8 @@ type wecomMarkdown struct {
 
 // formatWecomMessage builds a WeChat Work markdown webhook payload.
 func formatWecomMessage(event string, authID, provider, model string, httpStatus int, errMsg string, ts time.Time) ([]byte, error) {
-	var title string
-	switch event {
-	case EventAccountBanned:
-		title = `<font color="warning">Account Banned</font>`
-	case EventRateLimited:
-		title = `<font color="comment">Rate Limited</font>`
-	default:
-		title = event
-	}
-
-	// Strip directory and .json suffix from auth ID for readability.
-	account := filepath.Base(authID)
-	account = strings.TrimSuffix(account, ".json")
+	title := wecomTitle(event)
+	account := accountName(authID)
 
 	if len(errMsg) > maxErrorLen {
 		errMsg = errMsg[:maxErrorLen] + "..."
@@ -56,3 +43,54 @@ func formatWecomMessage(event string, authID, provider, model string, httpStatus
 	}
 	return json.Marshal(payload)
 }
+
+// formatWecomBreakerMessage builds a WeChat Work markdown payload for a circuit breaker event,
+// which concerns an upstream base URL rather than an account.
+func formatWecomBreakerMessage(event Event) ([]byte, error) {
+	errMsg := event.Error
+	if len(errMsg) > maxErrorLen {
+		errMsg = errMsg[:maxErrorLen] + "..."
+	}
+
+	content := fmt.Sprintf("## %s\n> Provider: %s\n> Base URL: %s\n> Error: %s\n> Time: %s",
+		wecomTitle(event.Type),
+		event.Provider,
+		event.BaseURL,
+		errMsg,
+		event.Time.Format("2006-01-02 15:04:05 MST"),
+	)
+
+	payload := wecomPayload{
+		MsgType:  "markdown",
+		Markdown: wecomMarkdown{Content: content},
+	}
+	return json.Marshal(payload)
+}
+
+// wecomTitle returns the colored markdown title of an event.
+func wecomTitle(event string) string {
+	var title string
+	switch event {
+	case EventAccountBanned:
+		title = `<font color="warning">Account Banned</font>`
+	case EventRateLimited:
+		title = `<font color="comment">Rate Limited</font>`
+	case EventRefreshFailed:
+		title = `<font color="warning">Token Refresh Failed</font>`
+	case EventAuthDisabled:
+		title = `<font color="comment">Auth Disabled</font>`
+	case EventAuthRecovered:
+		title = `<font color="info">Auth Recovered</font>`
+	case EventQuotaRecovered:
+		title = `<font color="info">Quota Recovered</font>`
+	case EventAuthAdded:
+		title = `<font color="info">Auth Added</font>`
+	case EventBreakerOpened:
+		title = `<font color="warning">Circuit Breaker Opened</font>`
+	case EventBreakerClosed:
+		title = `<font color="info">Circuit Breaker Closed</font>`
+	default:
+		title = event
+	}
+	return title
+}
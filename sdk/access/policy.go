@@ -0,0 +1,70 @@
+package access
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Result metadata keys describing the model policy of an authenticated client.
+// Providers attach them to Result.Metadata; values are comma-separated lists.
+const (
+	MetadataAllowedModels         = "allowed-models"
+	MetadataAllowedProviders      = "allowed-providers"
+	MetadataDisableThinkingSuffix = "disable-thinking-suffix"
+)
+
+// ModelPolicy restricts the models an authenticated client may list and call.
+// The zero value allows everything.
+type ModelPolicy struct {
+	// AllowedModels holds '*' wildcard patterns matched against the requested model.
+	AllowedModels []string
+	// AllowedProviders lists the upstream providers that may serve the client.
+	AllowedProviders []string
+	// DisableThinkingSuffix rejects model names carrying a thinking suffix.
+	DisableThinkingSuffix bool
+}
+
+// IsZero reports whether the policy imposes no restriction.
+func (p ModelPolicy) IsZero() bool {
+	return len(p.AllowedModels) == 0 && len(p.AllowedProviders) == 0 && !p.DisableThinkingSuffix
+}
+
+// WriteMetadata stores the policy in metadata, which must be non-nil.
+func (p ModelPolicy) WriteMetadata(metadata map[string]string) {
+	if len(p.AllowedModels) > 0 {
+		metadata[MetadataAllowedModels] = strings.Join(p.AllowedModels, ",")
+	}
+	if len(p.AllowedProviders) > 0 {
+		metadata[MetadataAllowedProviders] = strings.Join(p.AllowedProviders, ",")
+	}
+	if p.DisableThinkingSuffix {
+		metadata[MetadataDisableThinkingSuffix] = "true"
+	}
+}
+
+// ModelPolicyFromMetadata reads the policy attached to Result.Metadata.
+func ModelPolicyFromMetadata(metadata map[string]string) ModelPolicy {
+	if len(metadata) == 0 {
+		return ModelPolicy{}
+	}
+	disable, _ := strconv.ParseBool(strings.TrimSpace(metadata[MetadataDisableThinkingSuffix]))
+	return ModelPolicy{
+		AllowedModels:         splitList(metadata[MetadataAllowedModels]),
+		AllowedProviders:      splitList(metadata[MetadataAllowedProviders]),
+		DisableThinkingSuffix: disable,
+	}
+}
+
+func splitList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	parts := strings.Split(value, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/limits"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"golang.org/x/net/context"
+)
+
+// clientLimitError wraps a limits.LimitError with a response body shaped like the
+// error payload of the client's source API format.
+type clientLimitError struct {
+	body  string
+	cause *limits.LimitError
+}
+
+func (e *clientLimitError) Error() string { return e.body }
+
+func (e *clientLimitError) Unwrap() error { return e.cause }
+
+func (e *clientLimitError) StatusCode() int { return e.cause.StatusCode() }
+
+func (e *clientLimitError) Headers() http.Header { return e.cause.Headers() }
+
+// checkClientLimits enforces the api-key-limits entry matching the authenticated client key.
+// It returns nil when the request may be dispatched.
+func (h *BaseAPIHandler) checkClientLimits(ctx context.Context, handlerType, modelName string) *interfaces.ErrorMessage {
+	return h.evaluateClientLimits(ctx, handlerType, modelName, true)
+}
+
+// clientLimitsAllow runs the checks of checkClientLimits without admitting a request.
+func (h *BaseAPIHandler) clientLimitsAllow(ctx context.Context, handlerType, modelName string) *interfaces.ErrorMessage {
+	return h.evaluateClientLimits(ctx, handlerType, modelName, false)
+}
+
+func (h *BaseAPIHandler) evaluateClientLimits(ctx context.Context, handlerType, modelName string, admit bool) *interfaces.ErrorMessage {
+	if h == nil || h.Cfg == nil || len(h.Cfg.APIKeyLimits) == 0 {
+		return nil
+	}
+	apiKey := clientAPIKeyFromContext(ctx)
+	if apiKey == "" {
+		return nil
+	}
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(modelName).ModelName)
+	var limitErr *limits.LimitError
+	if admit {
+		limitErr = limits.Default().Check(apiKey, baseModel, h.Cfg.APIKeyLimits)
+	} else {
+		limitErr = limits.Default().Allows(apiKey, baseModel, h.Cfg.APIKeyLimits)
+	}
+	if limitErr == nil {
+		return nil
+	}
+	err := &clientLimitError{body: string(buildClientLimitBody(handlerType, limitErr)), cause: limitErr}
+	return &interfaces.ErrorMessage{StatusCode: limitErr.StatusCode(), Error: err, Addon: limitErr.Headers()}
+}
+
+func clientAPIKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return strings.TrimSpace(cliproxyexecutor.ClientAPIKey(ctx))
+	}
+	return clientAPIKeyFromGin(ginCtx)
+}
+
+// clientAPIKeyFromGin returns the client key the access middleware stored on the request.
+func clientAPIKeyFromGin(ginCtx *gin.Context) string {
+	if ginCtx == nil {
+		return ""
+	}
+	if v, exists := ginCtx.Get("apiKey"); exists {
+		if key, isString := v.(string); isString {
+			return strings.TrimSpace(key)
+		}
+	}
+	return ""
+}
+
+// isClientLimitError reports whether err originated from local client key enforcement.
+func isClientLimitError(err error) bool {
+	var limitErr *limits.LimitError
+	return errors.As(err, &limitErr)
+}
+
+// buildClientLimitBody renders the rejection in the error format of the handler's source API.
+func buildClientLimitBody(handlerType string, limitErr *limits.LimitError) []byte {
+	status := limitErr.StatusCode()
+	message := limitErr.Error()
+	var payload any
+	switch handlerType {
+	case "claude":
+		errType := "rate_limit_error"
+		if status == http.StatusForbidden {
+			errType = "permission_error"
+		}
+		payload = map[string]any{
+			"type":  "error",
+			"error": map[string]any{"type": errType, "message": message},
+		}
+	case "gemini", "gemini-cli":
+		statusText := "RESOURCE_EXHAUSTED"
+		if status == http.StatusForbidden {
+			statusText = "PERMISSION_DENIED"
+		}
+		payload = map[string]any{
+			"error": map[string]any{"code": status, "message": message, "status": statusText},
+		}
+	default:
+		detail := ErrorDetail{Message: message, Type: "rate_limit_error", Code: "rate_limit_exceeded"}
+		switch limitErr.Reason {
+		case limits.ReasonModelNotAllowed, limits.ReasonProviderNotAllowed, limits.ReasonThinkingNotAllowed:
+			detail.Type = "permission_error"
+			detail.Code = string(limitErr.Reason)
+		case limits.ReasonTokensPerDay, limits.ReasonMonthlyTokenBudget:
+			detail.Code = "insufficient_quota"
+		}
+		payload = ErrorResponse{Error: detail}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return BuildErrorResponseBody(status, message)
+	}
+	return body
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/access/limits"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// ClientModelPolicy returns the model policy the access provider attached to the request.
+func ClientModelPolicy(c *gin.Context) sdkaccess.ModelPolicy {
+	if c == nil {
+		return sdkaccess.ModelPolicy{}
+	}
+	value, exists := c.Get("accessMetadata")
+	if !exists {
+		return sdkaccess.ModelPolicy{}
+	}
+	metadata, _ := value.(map[string]string)
+	return sdkaccess.ModelPolicyFromMetadata(metadata)
+}
+
+type accessMetadataContextKey struct{}
+
+// WithAccessMetadata attaches access metadata to ctx for work that runs outside the original
+// request, such as batch jobs, so the client's model policy still applies.
+func WithAccessMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(metadata) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, accessMetadataContextKey{}, metadata)
+}
+
+// clientModelPolicyFromContext returns the policy of the gin request in ctx, falling back to
+// metadata attached with WithAccessMetadata.
+func clientModelPolicyFromContext(ctx context.Context) sdkaccess.ModelPolicy {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ClientModelPolicy(ginCtx)
+	}
+	metadata, _ := ctx.Value(accessMetadataContextKey{}).(map[string]string)
+	return sdkaccess.ModelPolicyFromMetadata(metadata)
+}
+
+// FilterModelsForClient drops the models the client may not use from a model listing, applying
+// both its model policy and the allowed-models of its api-key-limits entry.
+// Entries are identified by their "id" field, or by "name" for Gemini listings.
+func (h *BaseAPIHandler) FilterModelsForClient(c *gin.Context, models []map[string]any) []map[string]any {
+	policy := ClientModelPolicy(c)
+	limitEntry := h.clientLimitEntry(clientAPIKeyFromGin(c))
+	if policy.IsZero() && limitEntry == nil {
+		return models
+	}
+	filtered := make([]map[string]any, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			name, _ := model["name"].(string)
+			id = strings.TrimPrefix(name, "models/")
+		}
+		if id == "" || !clientModelAllowed(policy, limitEntry, id) {
+			continue
+		}
+		if len(policy.AllowedProviders) > 0 && len(allowedProviders(policy, util.GetProviderName(id))) == 0 {
+			continue
+		}
+		filtered = append(filtered, model)
+	}
+	return filtered
+}
+
+// clientLimitEntry returns the api-key-limits entry that applies to apiKey, or nil.
+func (h *BaseAPIHandler) clientLimitEntry(apiKey string) *config.APIKeyLimit {
+	if h == nil || h.Cfg == nil || apiKey == "" {
+		return nil
+	}
+	return limits.MatchEntry(h.Cfg.APIKeyLimits, apiKey)
+}
+
+// clientModelAllowed reports whether model passes both per-key allowlists: the model policy from
+// api-key-policies and the allowed-models of the api-key-limits entry. Listings and execution
+// both decide through it so a key never sees a model it is then refused.
+func clientModelAllowed(policy sdkaccess.ModelPolicy, limitEntry *config.APIKeyLimit, model string) bool {
+	if !limits.ModelAllowed(policy.AllowedModels, model) {
+		return false
+	}
+	return limitEntry == nil || limits.ModelAllowed(limitEntry.AllowedModels, model)
+}
+
+// applyClientPolicy enforces the client's model policy and allowed models on a resolved request
+// and returns the
+// providers the client may use. It returns an error when nothing remains.
+func (h *BaseAPIHandler) applyClientPolicy(ctx context.Context, handlerType, normalizedModel string, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if ctx == nil {
+		return providers, nil
+	}
+	policy := clientModelPolicyFromContext(ctx)
+	limitEntry := h.clientLimitEntry(clientAPIKeyFromContext(ctx))
+	if policy.IsZero() && limitEntry == nil {
+		return providers, nil
+	}
+	parsed := thinking.ParseSuffix(normalizedModel)
+	if policy.DisableThinkingSuffix && parsed.HasSuffix {
+		return nil, clientPolicyError(handlerType, limits.ReasonThinkingNotAllowed,
+			fmt.Sprintf("API key is not allowed to use thinking suffixes (%s)", normalizedModel))
+	}
+	baseModel := strings.TrimSpace(parsed.ModelName)
+	if !clientModelAllowed(policy, limitEntry, baseModel) {
+		return nil, clientPolicyError(handlerType, limits.ReasonModelNotAllowed,
+			fmt.Sprintf("API key is not allowed to use model %s", baseModel))
+	}
+	if len(policy.AllowedProviders) == 0 {
+		return providers, nil
+	}
+	allowed := allowedProviders(policy, providers)
+	if len(allowed) == 0 {
+		return nil, clientPolicyError(handlerType, limits.ReasonProviderNotAllowed,
+			fmt.Sprintf("API key is not allowed to use any provider serving model %s", baseModel))
+	}
+	return allowed, nil
+}
+
+// allowedProviders returns the providers permitted by the policy, preserving order.
+func allowedProviders(policy sdkaccess.ModelPolicy, providers []string) []string {
+	allowed := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		for _, candidate := range policy.AllowedProviders {
+			if strings.EqualFold(strings.TrimSpace(candidate), provider) {
+				allowed = append(allowed, provider)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+func clientPolicyError(handlerType string, reason limits.Reason, message string) *interfaces.ErrorMessage {
+	limitErr := limits.NewPolicyError(reason, message)
+	err := &clientLimitError{body: string(buildClientLimitBody(handlerType, limitErr)), cause: limitErr}
+	return &interfaces.ErrorMessage{StatusCode: limitErr.StatusCode(), Error: err}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestClientModelPolicy_FromAccessMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modelRegistry := registry.GetGlobalRegistry()
+	now := time.Now().Unix()
+	modelRegistry.RegisterClient("test-policy-claude", "claude", []*registry.ModelInfo{{ID: "policy-claude-sonnet", Created: now}})
+	modelRegistry.RegisterClient("test-policy-kiro", "kiro", []*registry.ModelInfo{{ID: "policy-claude-sonnet", Created: now}})
+	modelRegistry.RegisterClient("test-policy-gemini", "gemini", []*registry.ModelInfo{{ID: "policy-gemini-pro", Created: now}})
+	t.Cleanup(func() {
+		for _, id := range []string{"test-policy-claude", "test-policy-kiro", "test-policy-gemini"} {
+			modelRegistry.UnregisterClient(id)
+		}
+	})
+
+	cfg := &sdkconfig.SDKConfig{
+		APIKeys: []string{"restricted", "open"},
+		APIKeyPolicies: []sdkconfig.APIKeyPolicy{{
+			APIKeys:               []string{"restricted"},
+			AllowedModels:         []string{"policy-claude-*"},
+			AllowedProviders:      []string{"kiro"},
+			DisableThinkingSuffix: true,
+		}},
+	}
+	configaccess.Register(cfg)
+	t.Cleanup(func() { configaccess.Register(nil) })
+	manager := sdkaccess.NewManager()
+	manager.SetProviders(sdkaccess.RegisteredProviders())
+
+	authenticate := func(key string) context.Context {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+key)
+		result, err := manager.Authenticate(context.Background(), c.Request)
+		if err != nil {
+			t.Fatalf("authenticate %s: %v", key, err)
+		}
+		c.Set("accessMetadata", result.Metadata)
+		return context.WithValue(context.Background(), "gin", c)
+	}
+
+	handler := NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil))
+	restricted := authenticate("restricted")
+
+	providers, errMsg := handler.applyClientPolicy(restricted, "openai", "policy-claude-sonnet", []string{"claude", "kiro"})
+	if errMsg != nil || !reflect.DeepEqual(providers, []string{"kiro"}) {
+		t.Fatalf("providers = %v, err = %v; want [kiro]", providers, errMsg)
+	}
+	if _, errMsg = handler.applyClientPolicy(restricted, "openai", "policy-gemini-pro", []string{"gemini"}); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for disallowed model, got %v", errMsg)
+	}
+	if _, errMsg = handler.applyClientPolicy(restricted, "openai", "policy-claude-sonnet(high)", []string{"kiro"}); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for thinking suffix, got %v", errMsg)
+	}
+	if _, _, errMsg = handler.ExecuteWithAuthManager(restricted, "openai", "policy-gemini-pro", []byte(`{}`), ""); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected execution path to reject disallowed model, got %v", errMsg)
+	}
+
+	models := []map[string]any{{"id": "policy-claude-sonnet"}, {"name": "models/policy-gemini-pro"}}
+	restrictedGin := restricted.Value("gin").(*gin.Context)
+	if got := handler.FilterModelsForClient(restrictedGin, models); len(got) != 1 || got[0]["id"] != "policy-claude-sonnet" {
+		t.Fatalf("filtered models = %v", got)
+	}
+	openGin := authenticate("open").Value("gin").(*gin.Context)
+	if got := handler.FilterModelsForClient(openGin, models); len(got) != 2 {
+		t.Fatalf("unrestricted key should see every model, got %v", got)
+	}
+}
+
+func TestFilterModelsForClient_HonorsAPIKeyLimitsAllowedModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &sdkconfig.SDKConfig{APIKeyLimits: []sdkconfig.APIKeyLimit{{APIKeys: []string{"limited"}, AllowedModels: []string{"limits-claude-*"}}}}
+	handler := NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil))
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	c.Set("apiKey", "limited")
+
+	models := []map[string]any{{"id": "limits-claude-sonnet"}, {"id": "limits-gemini-pro"}}
+	if got := handler.FilterModelsForClient(c, models); len(got) != 1 || got[0]["id"] != "limits-claude-sonnet" {
+		t.Fatalf("filtered models = %v, want only the allowed model", got)
+	}
+	ctx := context.WithValue(context.Background(), "gin", c)
+	if _, errMsg := handler.applyClientPolicy(ctx, "openai", "limits-gemini-pro", []string{"gemini"}); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a model outside api-key-limits, got %v", errMsg)
+	}
+	if _, errMsg := handler.applyClientPolicy(ctx, "openai", "limits-claude-sonnet", []string{"claude"}); errMsg != nil {
+		t.Fatalf("allowed model rejected: %v", errMsg)
+	}
+}
@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultMaxToolResultChars is the length tool results are cut to when max-tool-result-chars is unset.
+const defaultMaxToolResultChars = 8000
+
+// contextWindowError rejects a prompt that does not fit the target model's context window.
+// Its body is shaped like the error payload of the client's source API format.
+type contextWindowError struct {
+	body string
+}
+
+func (e *contextWindowError) Error() string { return e.body }
+
+func (e *contextWindowError) StatusCode() int { return http.StatusBadRequest }
+
+// contextGuard checks one request against the context windows of its candidate models.
+type contextGuard struct {
+	h           *BaseAPIHandler
+	ctx         context.Context
+	handlerType string
+	cfg         config.ContextGuardConfig
+	// counts caches prompt estimates by tokenizer name for the current payload.
+	counts map[string]int64
+}
+
+// ContextGuardEnabled returns whether prompts are checked against the context window of the
+// target model before dispatch. Default is false.
+func ContextGuardEnabled(cfg *config.SDKConfig) bool {
+	return cfg != nil && cfg.ContextGuard.Enable
+}
+
+// newContextGuard returns the guard for a request, or nil when the request is not checked.
+// Embeddings and compaction requests are never guarded.
+func (h *BaseAPIHandler) newContextGuard(ctx context.Context, handlerType, alt string) *contextGuard {
+	if h == nil || !ContextGuardEnabled(h.Cfg) {
+		return nil
+	}
+	if alt == coreexecutor.AltEmbeddings || alt == "responses/compact" {
+		return nil
+	}
+	return &contextGuard{h: h, ctx: ctx, handlerType: handlerType, cfg: h.Cfg.ContextGuard, counts: make(map[string]int64)}
+}
+
+// guardContextWindow applies the context guard to a request before dispatch. It returns the
+// model, providers and payload to dispatch, which differ from the inputs when a strategy
+// trimmed the prompt or rerouted it, or an error when the prompt cannot be made to fit.
+func (h *BaseAPIHandler) guardContextWindow(ctx context.Context, handlerType, model string, providers []string, rawJSON []byte, alt string) (string, []string, []byte, *interfaces.ErrorMessage) {
+	g := h.newContextGuard(ctx, handlerType, alt)
+	if g == nil || len(rawJSON) == 0 {
+		return model, providers, rawJSON, nil
+	}
+	limit := g.limit(model, providers, rawJSON)
+	if limit <= 0 {
+		return model, providers, rawJSON, nil
+	}
+	estimate := g.estimate(model, rawJSON)
+	if estimate <= int64(limit) {
+		return model, providers, rawJSON, nil
+	}
+	g.note("prompt of about %d tokens exceeds the %d token window of %s", estimate, limit, model)
+
+	for _, strategy := range g.cfg.Strategies {
+		switch strings.ToLower(strings.TrimSpace(strategy)) {
+		case config.ContextGuardTruncateToolResults:
+			if updated, truncated := g.truncateToolResults(rawJSON); truncated > 0 {
+				g.setPayload(&rawJSON, updated)
+				estimate = g.estimate(model, rawJSON)
+				g.note("truncated %d tool results to %d characters, prompt is now about %d tokens", truncated, g.maxToolResultChars(), estimate)
+			}
+		case config.ContextGuardDropOldestTurns:
+			if updated, dropped := g.dropOldestTurns(model, rawJSON, limit); dropped > 0 {
+				g.setPayload(&rawJSON, updated)
+				estimate = g.estimate(model, rawJSON)
+				g.note("dropped the %d oldest messages, prompt is now about %d tokens", dropped, estimate)
+			}
+		case config.ContextGuardReroute:
+			if rerouted, reroutedProviders, ok := g.reroute(rawJSON); ok {
+				g.note("rerouted from %s to %s", model, rerouted)
+				return rerouted, reroutedProviders, rawJSON, nil
+			}
+		default:
+			log.Warnf("context guard: unknown strategy %q", strategy)
+			continue
+		}
+		if estimate <= int64(limit) {
+			return model, providers, rawJSON, nil
+		}
+	}
+
+	g.note("rejected: prompt of about %d tokens exceeds the %d token window of %s", estimate, limit, model)
+	return "", nil, nil, &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadRequest,
+		Error:      &contextWindowError{body: string(buildContextWindowBody(handlerType, model, estimate, limit))},
+	}
+}
+
+// fittingTargets drops fallback targets whose context window cannot hold rawJSON. The first
+// target has already been checked by guardContextWindow and is always kept.
+func (h *BaseAPIHandler) fittingTargets(ctx context.Context, handlerType string, targets []executionTarget, rawJSON []byte, alt string) []executionTarget {
+	g := h.newContextGuard(ctx, handlerType, alt)
+	if g == nil || len(targets) < 2 || len(rawJSON) == 0 {
+		return targets
+	}
+	kept := targets[:1:1]
+	for _, target := range targets[1:] {
+		if g.fits(target.model, target.providers, rawJSON) {
+			kept = append(kept, target)
+			continue
+		}
+		g.note("skipped fallback %s: prompt exceeds its context window", target.label)
+	}
+	return kept
+}
+
+// note records a guard decision in the server log and the request log.
+func (g *contextGuard) note(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Infof("context guard: %s", message)
+	logging.AttachRequestNote(g.ctx, "context-guard: "+message)
+}
+
+func (g *contextGuard) setPayload(rawJSON *[]byte, updated []byte) {
+	*rawJSON = updated
+	g.counts = make(map[string]int64)
+}
+
+func (g *contextGuard) maxToolResultChars() int {
+	if g.cfg.MaxToolResultChars > 0 {
+		return g.cfg.MaxToolResultChars
+	}
+	return defaultMaxToolResultChars
+}
+
+// fits reports whether rawJSON fits the context window of model. Models without a known
+// context window always fit.
+func (g *contextGuard) fits(model string, providers []string, rawJSON []byte) bool {
+	limit := g.limit(model, providers, rawJSON)
+	return limit <= 0 || g.estimate(model, rawJSON) <= int64(limit)
+}
+
+// limit returns the smallest prompt budget the providers of model declare, or 0 when none is known.
+// Models that only declare a total context length keep room for the requested output tokens,
+// or for reserve-output-tokens when the request sets none.
+func (g *contextGuard) limit(model string, providers []string, rawJSON []byte) int {
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if len(providers) == 0 {
+		providers = []string{""}
+	}
+	reserve := requestedOutputTokens(rawJSON)
+	if reserve <= 0 {
+		reserve = g.cfg.ReserveOutputTokens
+	}
+	limit := 0
+	for _, provider := range providers {
+		info := registry.GetGlobalRegistry().GetModelInfo(baseModel, provider)
+		if info == nil {
+			continue
+		}
+		candidate := info.InputTokenLimit
+		if candidate <= 0 && info.ContextLength > 0 {
+			candidate = info.ContextLength - reserve
+			if candidate <= 0 {
+				candidate = info.ContextLength
+			}
+		}
+		if candidate > 0 && (limit == 0 || candidate < limit) {
+			limit = candidate
+		}
+	}
+	return limit
+}
+
+// estimate counts the prompt tokens of rawJSON with the local tokenizer of model. Payloads the
+// tokenizer cannot parse are estimated at zero so the guard never blocks them.
+func (g *contextGuard) estimate(model string, rawJSON []byte) int64 {
+	enc, err := tokenizer.ForModel(thinking.ParseSuffix(model).ModelName)
+	if err != nil {
+		return 0
+	}
+	if count, ok := g.counts[enc.Name]; ok {
+		return count
+	}
+	count, err := tokenizer.CountRequest(enc, g.handlerType, rawJSON)
+	if err != nil {
+		log.Debugf("context guard: token count failed: %v", err)
+		count = 0
+	}
+	g.counts[enc.Name] = count
+	return count
+}
+
+// reroute returns the first reroute-models entry whose window fits rawJSON and that the client's
+// model policy and api-key-limits entry permit.
+func (g *contextGuard) reroute(rawJSON []byte) (string, []string, bool) {
+	for _, candidate := range g.cfg.RerouteModels {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		providers, normalized, errMsg := g.h.getRequestDetails(candidate)
+		if errMsg == nil {
+			providers, errMsg = g.h.applyClientPolicy(g.ctx, g.handlerType, normalized, providers)
+		}
+		if errMsg == nil {
+			// The request was already admitted under the original model, so only vet the target.
+			errMsg = g.h.clientLimitsAllow(g.ctx, g.handlerType, normalized)
+		}
+		if errMsg != nil {
+			log.Debugf("context guard: skipping reroute target %q: unavailable for client", candidate)
+			continue
+		}
+		if g.limit(normalized, providers, rawJSON) > 0 && g.fits(normalized, providers, rawJSON) {
+			return normalized, providers, true
+		}
+	}
+	return "", nil, false
+}
+
+// requestedOutputTokens returns the output token limit set by the request, in any format.
+func requestedOutputTokens(rawJSON []byte) int {
+	for _, path := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens", "request.generationConfig.maxOutputTokens"} {
+		if value := gjson.GetBytes(rawJSON, path); value.Exists() && value.Int() > 0 {
+			return int(value.Int())
+		}
+	}
+	return 0
+}
+
+// conversationPath returns the JSON path of the conversation turns in the handlerType format.
+func conversationPath(handlerType string) string {
+	switch handlerType {
+	case "openai-response", "codex":
+		return "input"
+	case "gemini":
+		return "contents"
+	case "gemini-cli":
+		return "request.contents"
+	default:
+		return "messages"
+	}
+}
+
+// dropOldestTurns removes the oldest turns of the conversation until rawJSON fits limit or only
+// the latest turn is left. System and developer messages are always kept, and the remaining
+// conversation always starts at a user turn so tool calls are never split from their results.
+// It returns the updated payload and the number of removed messages.
+func (g *contextGuard) dropOldestTurns(model string, rawJSON []byte, limit int) ([]byte, int) {
+	path := conversationPath(g.handlerType)
+	items := gjson.GetBytes(rawJSON, path)
+	if !items.IsArray() {
+		return rawJSON, 0
+	}
+	var pinned, turns []gjson.Result
+	for _, item := range items.Array() {
+		if g.isPinned(item) {
+			pinned = append(pinned, item)
+			continue
+		}
+		turns = append(turns, item)
+	}
+	var starts []int
+	for i := 1; i < len(turns); i++ {
+		if g.isTurnStart(turns[i]) {
+			starts = append(starts, i)
+		}
+	}
+	if len(starts) == 0 {
+		return rawJSON, 0
+	}
+	build := func(from int) []byte {
+		parts := make([]string, 0, len(pinned)+len(turns)-from)
+		for _, item := range pinned {
+			parts = append(parts, item.Raw)
+		}
+		for _, item := range turns[from:] {
+			parts = append(parts, item.Raw)
+		}
+		updated, err := sjson.SetRawBytes(rawJSON, path, []byte("["+strings.Join(parts, ",")+"]"))
+		if err != nil {
+			return nil
+		}
+		return updated
+	}
+	// Fewer turns never count more tokens, so search for the smallest cut that fits.
+	cut := sort.Search(len(starts), func(i int) bool {
+		updated := build(starts[i])
+		if updated == nil {
+			return false
+		}
+		probe := &contextGuard{handlerType: g.handlerType, counts: make(map[string]int64)}
+		return probe.estimate(model, updated) <= int64(limit)
+	})
+	if cut == len(starts) {
+		cut = len(starts) - 1
+	}
+	updated := build(starts[cut])
+	if updated == nil {
+		return rawJSON, 0
+	}
+	return updated, starts[cut]
+}
+
+// isPinned reports whether a conversation item must survive trimming.
+func (g *contextGuard) isPinned(item gjson.Result) bool {
+	switch g.handlerType {
+	case "openai", "openai-response", "codex":
+		role := item.Get("role").String()
+		return role == "system" || role == "developer"
+	default:
+		return false
+	}
+}
+
+// isTurnStart reports whether a conversation item is a user message that is not a tool result.
+func (g *contextGuard) isTurnStart(item gjson.Result) bool {
+	if item.Get("role").String() != "user" {
+		return false
+	}
+	switch g.handlerType {
+	case "claude":
+		for _, block := range item.Get("content").Array() {
+			if block.Get("type").String() == "tool_result" {
+				return false
+			}
+		}
+	case "gemini", "gemini-cli":
+		for _, part := range item.Get("parts").Array() {
+			if part.Get("functionResponse").Exists() {
+				return false
+			}
+		}
+	case "openai-response", "codex":
+		if itemType := item.Get("type").String(); itemType != "" && itemType != "message" {
+			return false
+		}
+	}
+	return true
+}
+
+// truncateToolResults shortens every tool result longer than max-tool-result-chars, keeping
+// its beginning and end. It returns the updated payload and the number of truncated results.
+func (g *contextGuard) truncateToolResults(rawJSON []byte) ([]byte, int) {
+	path := conversationPath(g.handlerType)
+	items := gjson.GetBytes(rawJSON, path)
+	if !items.IsArray() {
+		return rawJSON, 0
+	}
+	maxChars := g.maxToolResultChars()
+	truncated := 0
+	truncate := func(valuePath string) {
+		var changed bool
+		rawJSON, changed = truncateTextValue(rawJSON, valuePath, maxChars)
+		if changed {
+			truncated++
+		}
+	}
+	for i, item := range items.Array() {
+		itemPath := fmt.Sprintf("%s.%d", path, i)
+		switch g.handlerType {
+		case "claude":
+			for j, block := range item.Get("content").Array() {
+				if block.Get("type").String() == "tool_result" {
+					truncate(fmt.Sprintf("%s.content.%d.content", itemPath, j))
+				}
+			}
+		case "openai-response", "codex":
+			if strings.HasSuffix(item.Get("type").String(), "_call_output") {
+				truncate(itemPath + ".output")
+			}
+		case "gemini", "gemini-cli":
+			for j, part := range item.Get("parts").Array() {
+				response := part.Get("functionResponse.response")
+				if !response.Exists() || len(response.Raw) <= maxChars {
+					continue
+				}
+				responsePath := fmt.Sprintf("%s.parts.%d.functionResponse.response", itemPath, j)
+				if updated, err := sjson.SetBytes(rawJSON, responsePath, map[string]string{"output": truncateMiddle(response.Raw, maxChars)}); err == nil {
+					rawJSON = updated
+					truncated++
+				}
+			}
+		default:
+			if item.Get("role").String() == "tool" {
+				truncate(itemPath + ".content")
+			}
+		}
+	}
+	return rawJSON, truncated
+}
+
+// truncateTextValue truncates the string at path, or the text of every text block when path
+// holds an array of content blocks. It reports whether anything was truncated.
+func truncateTextValue(rawJSON []byte, path string, maxChars int) ([]byte, bool) {
+	value := gjson.GetBytes(rawJSON, path)
+	changed := false
+	set := func(target, text string) {
+		if len([]rune(text)) <= maxChars {
+			return
+		}
+		if updated, err := sjson.SetBytes(rawJSON, target, truncateMiddle(text, maxChars)); err == nil {
+			rawJSON = updated
+			changed = true
+		}
+	}
+	switch {
+	case value.Type == gjson.String:
+		set(path, value.String())
+	case value.IsArray():
+		for i, block := range value.Array() {
+			if text := block.Get("text"); text.Type == gjson.String {
+				set(fmt.Sprintf("%s.%d.text", path, i), text.String())
+			}
+		}
+	}
+	return rawJSON, changed
+}
+
+// truncateMiddle keeps the first and last parts of text within maxChars characters and marks
+// the removed middle.
+func truncateMiddle(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	head := maxChars * 3 / 4
+	tail := maxChars - head
+	return string(runes[:head]) + fmt.Sprintf("\n[... %d characters truncated ...]\n", len(runes)-head-tail) + string(runes[len(runes)-tail:])
+}
+
+// buildContextWindowBody renders the rejection in the error format of the handler's source API.
+// The Claude message matches Anthropic's wording so clients can trigger their own compaction.
+func buildContextWindowBody(handlerType, model string, estimate int64, limit int) []byte {
+	var payload any
+	switch handlerType {
+	case "claude":
+		payload = map[string]any{
+			"type": "error",
+			"error": map[string]any{
+				"type":    "invalid_request_error",
+				"message": fmt.Sprintf("prompt is too long: %d tokens > %d maximum", estimate, limit),
+			},
+		}
+	case "gemini", "gemini-cli":
+		payload = map[string]any{
+			"error": map[string]any{
+				"code":    http.StatusBadRequest,
+				"message": fmt.Sprintf("The input token count (%d) exceeds the maximum number of tokens allowed (%d).", estimate, limit),
+				"status":  "INVALID_ARGUMENT",
+			},
+		}
+	default:
+		payload = ErrorResponse{Error: ErrorDetail{
+			Message: fmt.Sprintf("This model's maximum context length is %d tokens. However, your messages resulted in about %d tokens. Please reduce the length of the messages. (model: %s)", limit, estimate, model),
+			Type:    "invalid_request_error",
+			Code:    "context_length_exceeded",
+		}}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return BuildErrorResponseBody(http.StatusBadRequest, fmt.Sprintf("prompt of about %d tokens exceeds the %d token context window of %s", estimate, limit, model))
+	}
+	return body
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// payloadTestExecutor records the payloads it receives.
+type payloadTestExecutor struct {
+	fallbackTestExecutor
+	payloads [][]byte
+}
+
+func (e *payloadTestExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.payloads = append(e.payloads, append([]byte(nil), req.Payload...))
+	e.mu.Unlock()
+	return e.fallbackTestExecutor.Execute(ctx, auth, req, opts)
+}
+
+func registerContextGuardTestAuth(t *testing.T, manager *coreauth.Manager, id, provider, model string, contextLength int) {
+	t.Helper()
+	auth := &coreauth.Auth{ID: id, Provider: provider, Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register(%s): %v", id, err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(id, provider, []*registry.ModelInfo{{ID: model, ContextLength: contextLength}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+}
+
+func contextGuardTestPayload() []byte {
+	filler := strings.Repeat("lorem ipsum dolor sit amet ", 200)
+	return []byte(`{"model":"guard-small","max_tokens":100,"messages":[` +
+		`{"role":"user","content":"read the file"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"` + filler + `"}]},` +
+		`{"role":"assistant","content":"done"},` +
+		`{"role":"user","content":"latest question"}]}`)
+}
+
+func newContextGuardTestHandler(t *testing.T, guard sdkconfig.ContextGuardConfig) (*BaseAPIHandler, *payloadTestExecutor, *payloadTestExecutor) {
+	t.Helper()
+	small := &payloadTestExecutor{fallbackTestExecutor: fallbackTestExecutor{provider: "guard-small-provider"}}
+	large := &payloadTestExecutor{fallbackTestExecutor: fallbackTestExecutor{provider: "guard-large-provider"}}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(small)
+	manager.RegisterExecutor(large)
+	registerContextGuardTestAuth(t, manager, "guard-small-auth", "guard-small-provider", "guard-small", 1000)
+	registerContextGuardTestAuth(t, manager, "guard-large-auth", "guard-large-provider", "guard-large", 100000)
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{ContextGuard: guard}, manager), small, large
+}
+
+func TestContextGuard_RejectsOversizePrompt(t *testing.T) {
+	handler, small, _ := newContextGuardTestHandler(t, sdkconfig.ContextGuardConfig{Enable: true})
+
+	_, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "claude", "guard-small", contextGuardTestPayload(), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("errMsg = %+v, want 400", errMsg)
+	}
+	if message := gjson.Get(errMsg.Error.Error(), "error.message").String(); !strings.HasPrefix(message, "prompt is too long:") {
+		t.Fatalf("message = %q, want Claude prompt-too-long message", message)
+	}
+	if len(small.Models()) != 0 {
+		t.Fatalf("oversize prompt was dispatched")
+	}
+}
+
+func TestContextGuard_TruncatesToolResults(t *testing.T) {
+	handler, small, _ := newContextGuardTestHandler(t, sdkconfig.ContextGuardConfig{
+		Enable:             true,
+		Strategies:         []string{sdkconfig.ContextGuardTruncateToolResults, sdkconfig.ContextGuardDropOldestTurns},
+		MaxToolResultChars: 200,
+	})
+
+	if _, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "claude", "guard-small", contextGuardTestPayload(), ""); errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if len(small.payloads) != 1 {
+		t.Fatalf("payloads = %d, want 1", len(small.payloads))
+	}
+	payload := small.payloads[0]
+	result := gjson.GetBytes(payload, "messages.2.content.0.content").String()
+	if len([]rune(result)) > 300 || !strings.Contains(result, "characters truncated") {
+		t.Fatalf("tool result was not truncated: %q", result)
+	}
+	if got := len(gjson.GetBytes(payload, "messages").Array()); got != 5 {
+		t.Fatalf("messages = %d, want 5 after truncation alone", got)
+	}
+}
+
+func TestContextGuard_DropsOldestTurns(t *testing.T) {
+	handler, small, _ := newContextGuardTestHandler(t, sdkconfig.ContextGuardConfig{
+		Enable:     true,
+		Strategies: []string{sdkconfig.ContextGuardDropOldestTurns},
+	})
+
+	if _, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "claude", "guard-small", contextGuardTestPayload(), ""); errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	messages := gjson.GetBytes(small.payloads[0], "messages").Array()
+	if len(messages) != 1 || messages[0].Get("content").String() != "latest question" {
+		t.Fatalf("messages = %v, want only the latest turn", messages)
+	}
+}
+
+func TestContextGuard_Reroutes(t *testing.T) {
+	handler, small, large := newContextGuardTestHandler(t, sdkconfig.ContextGuardConfig{
+		Enable:        true,
+		Strategies:    []string{sdkconfig.ContextGuardReroute},
+		RerouteModels: []string{"unknown-model", "guard-large"},
+	})
+
+	body, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "claude", "guard-small", contextGuardTestPayload(), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if string(body) != `{"served":"guard-large-provider"}` {
+		t.Fatalf("body = %s, want large model response", body)
+	}
+	if len(small.Models()) != 0 {
+		t.Fatalf("small model calls = %v, want none", small.Models())
+	}
+	if got := large.Models(); len(got) != 1 || got[0] != "guard-large" {
+		t.Fatalf("large model calls = %v, want single guard-large call", got)
+	}
+}
+
+func TestContextGuard_RerouteHonorsClientLimits(t *testing.T) {
+	handler, _, large := newContextGuardTestHandler(t, sdkconfig.ContextGuardConfig{
+		Enable:        true,
+		Strategies:    []string{sdkconfig.ContextGuardReroute},
+		RerouteModels: []string{"guard-large"},
+	})
+	handler.Cfg.APIKeyLimits = []sdkconfig.APIKeyLimit{{APIKeys: []string{"guard-limited-key"}, AllowedModels: []string{"guard-small"}}}
+	ctx := coreexecutor.WithClientAPIKey(context.Background(), "guard-limited-key")
+
+	if _, _, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "guard-small", contextGuardTestPayload(), ""); errMsg == nil {
+		t.Fatal("expected the oversize prompt to be rejected when the reroute target is not allowed")
+	}
+	if got := large.Models(); len(got) != 0 {
+		t.Fatalf("reroute target outside the key's allowed models was called: %v", got)
+	}
+}
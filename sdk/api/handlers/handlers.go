@@ -5,6 +5,8 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,9 +16,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -208,9 +213,18 @@ func requestExecutionMetadata(ctx context.Context) map[string]any {
 	if executionSessionID := executionSessionIDFromContext(ctx); executionSessionID != "" {
 		meta[coreexecutor.ExecutionSessionMetadataKey] = executionSessionID
 	}
+	if apiKey := clientAPIKeyFromContext(ctx); apiKey != "" {
+		meta[coreexecutor.ClientIDMetadataKey] = clientID(apiKey)
+	}
 	return meta
 }
 
+// clientID derives a stable identifier for a client API key that does not reveal the key.
+func clientID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
 func pinnedAuthIDFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -277,6 +291,7 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *B
 		Cfg:         cfg,
 		AuthManager: authManager,
 	}
+	configureResponseCache(cfg)
 	return h
 }
 
@@ -286,7 +301,10 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *B
 // Parameters:
 //   - clients: The new slice of AI service clients
 //   - cfg: The new application configuration
-func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) { h.Cfg = cfg }
+func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) {
+	h.Cfg = cfg
+	configureResponseCache(cfg)
+}
 
 // GetAlt extracts the 'alt' parameter from the request query string.
 // It checks both 'alt' and '$alt' parameters and returns the appropriate value.
@@ -339,6 +357,9 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			parentCtx = logging.WithRequestID(parentCtx, requestID)
 		}
 	}
+	if requestCtx != nil {
+		parentCtx = tracing.InheritSpan(parentCtx, requestCtx)
+	}
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
 		go func() {
@@ -470,11 +491,32 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, http.Header, *interfaces.ErrorMessage) {
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg == nil {
+		providers, errMsg = h.applyClientPolicy(ctx, handlerType, normalizedModel, providers)
+	}
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	if errMsg = h.checkClientLimits(ctx, handlerType, normalizedModel); errMsg != nil {
+		return nil, nil, errMsg
+	}
+	guardedModel, providers, rawJSON, errMsg := h.guardContextWindow(ctx, handlerType, normalizedModel, providers, rawJSON, alt)
 	if errMsg != nil {
 		return nil, nil, errMsg
 	}
+	if guardedModel != normalizedModel {
+		modelName, normalizedModel = guardedModel, guardedModel
+	}
+	cacheLookup := h.responseCacheFor(ctx, handlerType, normalizedModel, alt, false, providers, rawJSON)
+	if entry, hit := cacheLookup.load(ctx, normalizedModel); hit {
+		if !PassthroughHeadersEnabled(h.Cfg) {
+			return cloneBytes(entry.Body), nil, nil
+		}
+		return cloneBytes(entry.Body), cloneHeader(entry.Headers), nil
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	h.attachSessionAffinity(ctx, reqMeta, handlerType, rawJSON, alt)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -490,7 +532,29 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	targets := h.fittingTargets(ctx, handlerType, h.executionTargets(ctx, modelName, providers, normalizedModel), rawJSON, alt)
+	var (
+		resp coreexecutor.Response
+		err  error
+	)
+	for i, target := range targets {
+		req.Model = target.model
+		reqMeta[coreexecutor.RequestedModelMetadataKey] = target.model
+		reqMeta[coreexecutor.SkipQueueMetadataKey] = i < len(targets)-1
+		resp, err = h.AuthManager.Execute(ctx, target.providers, req, opts)
+		if err == nil {
+			recordServedTarget(ctx, targets, i)
+			cacheLookup.store(&cache.ResponseEntry{
+				Body:    cloneBytes(resp.Payload),
+				Headers: cloneHeader(FilterUpstreamHeaders(resp.Headers)),
+				Model:   target.model,
+			})
+			break
+		}
+		if !shouldTryNextTarget(ctx, err) {
+			break
+		}
+	}
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -513,12 +577,21 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. Requests are counted by the local
+// tokenizer instead when local-token-count is enabled, for OpenAI formats, and when the
+// provider cannot count tokens.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, http.Header, *interfaces.ErrorMessage) {
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg == nil {
+		providers, errMsg = h.applyClientPolicy(ctx, handlerType, normalizedModel, providers)
+	}
 	if errMsg != nil {
 		return nil, nil, errMsg
 	}
+	if h.countsTokensLocally(handlerType) {
+		resp, errMsg := countTokensLocally(handlerType, normalizedModel, rawJSON)
+		return resp, nil, errMsg
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
 	payload := rawJSON
@@ -536,7 +609,24 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	targets := h.executionTargets(ctx, modelName, providers, normalizedModel)
+	var (
+		resp coreexecutor.Response
+		err  error
+	)
+	for i, target := range targets {
+		req.Model = target.model
+		reqMeta[coreexecutor.RequestedModelMetadataKey] = target.model
+		reqMeta[coreexecutor.SkipQueueMetadataKey] = i < len(targets)-1
+		resp, err = h.AuthManager.ExecuteCount(ctx, target.providers, req, opts)
+		if err == nil {
+			recordServedTarget(ctx, targets, i)
+			break
+		}
+		if !shouldTryNextTarget(ctx, err) {
+			break
+		}
+	}
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -544,6 +634,11 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 				status = code
 			}
 		}
+		if status == http.StatusNotImplemented {
+			// The provider cannot count tokens; fall back to the local tokenizer.
+			local, errLocal := countTokensLocally(handlerType, req.Model, rawJSON)
+			return local, nil, errLocal
+		}
 		var addon http.Header
 		if he, ok := err.(interface{ Headers() http.Header }); ok && he != nil {
 			if hdr := he.Headers(); hdr != nil {
@@ -563,14 +658,36 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // The returned http.Header carries upstream response headers captured before streaming begins.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, http.Header, <-chan *interfaces.ErrorMessage) {
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg == nil {
+		providers, errMsg = h.applyClientPolicy(ctx, handlerType, normalizedModel, providers)
+	}
+	if errMsg == nil {
+		errMsg = h.checkClientLimits(ctx, handlerType, normalizedModel)
+	}
+	if errMsg == nil {
+		var guardedModel string
+		guardedModel, providers, rawJSON, errMsg = h.guardContextWindow(ctx, handlerType, normalizedModel, providers, rawJSON, alt)
+		if errMsg == nil && guardedModel != normalizedModel {
+			modelName, normalizedModel = guardedModel, guardedModel
+		}
+	}
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
 		return nil, nil, errChan
 	}
+	cacheLookup := h.responseCacheFor(ctx, handlerType, normalizedModel, alt, true, providers, rawJSON)
+	if entry, hit := cacheLookup.load(ctx, normalizedModel); hit {
+		dataChan, errChan := replayCachedStream(ctx, entry)
+		if !PassthroughHeadersEnabled(h.Cfg) {
+			return dataChan, nil, errChan
+		}
+		return dataChan, cloneHeader(entry.Headers), errChan
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	h.attachSessionAffinity(ctx, reqMeta, handlerType, rawJSON, alt)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -586,7 +703,31 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	streamResult, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	// Walk the fallback chain until a target opens a stream. Bootstrap retries below stay on
+	// the target that succeeded.
+	targets := h.fittingTargets(ctx, handlerType, h.executionTargets(ctx, modelName, providers, normalizedModel), rawJSON, alt)
+	var (
+		streamResult *coreexecutor.StreamResult
+		err          error
+		servedModel  = normalizedModel
+	)
+	for i, target := range targets {
+		req.Model = target.model
+		reqMeta[coreexecutor.RequestedModelMetadataKey] = target.model
+		reqMeta[coreexecutor.SkipQueueMetadataKey] = i < len(targets)-1
+		streamResult, err = h.AuthManager.ExecuteStream(ctx, target.providers, req, opts)
+		if err == nil {
+			providers = target.providers
+			servedModel = target.model
+			recordServedTarget(ctx, targets, i)
+			break
+		}
+		if !shouldTryNextTarget(ctx, err) {
+			break
+		}
+	}
+	// Bootstrap retries have no fallback left, so they may wait in the request queue.
+	delete(reqMeta, coreexecutor.SkipQueueMetadataKey)
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
@@ -616,6 +757,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		}
 	}
 	chunks := streamResult.Chunks
+	cacheHeaders := FilterUpstreamHeaders(streamResult.Headers)
+	recorder := cacheLookup.newStreamRecorder()
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
 	go func() {
@@ -680,6 +823,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					chunk, ok = <-chunks
 				}
 				if !ok {
+					// Only streams that ran to completion are cached.
+					recorder.commit(cacheHeaders, servedModel)
 					return
 				}
 				if chunk.Err != nil {
@@ -691,8 +836,9 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							bootstrapRetries++
 							retryResult, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 							if retryErr == nil {
+								cacheHeaders = FilterUpstreamHeaders(retryResult.Headers)
 								if passthroughHeadersEnabled {
-									replaceHeader(upstreamHeaders, FilterUpstreamHeaders(retryResult.Headers))
+									replaceHeader(upstreamHeaders, cacheHeaders)
 								}
 								chunks = retryResult.Chunks
 								continue outer
@@ -718,6 +864,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				}
 				if len(chunk.Payload) > 0 {
 					sentPayload = true
+					recorder.add(chunk.Payload)
 					if okSendData := sendData(cloneBytes(chunk.Payload)); !okSendData {
 						return
 					}
@@ -776,6 +923,24 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	return providers, resolvedModelName, nil
 }
 
+// CheckEmbeddingModel rejects models the registry does not list as embedding models for any of
+// their providers, so a chat model is never sent an embedding payload its executor would read
+// as a chat request. Unknown models are left to ExecuteWithAuthManager to report.
+func (h *BaseAPIHandler) CheckEmbeddingModel(modelName string) *interfaces.ErrorMessage {
+	providers, resolvedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg != nil {
+		return nil
+	}
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(resolvedModel).ModelName)
+	reg := registry.GetGlobalRegistry()
+	for _, provider := range providers {
+		if info := reg.GetModelInfo(baseModel, provider); info != nil && info.Kind == registry.ModelKindEmbedding {
+			return nil
+		}
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("model %s does not support embeddings", modelName)}
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -811,7 +976,8 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 	if msg != nil && msg.StatusCode > 0 {
 		status = msg.StatusCode
 	}
-	if msg != nil && msg.Addon != nil && PassthroughHeadersEnabled(h.Cfg) {
+	// Headers produced by local client key limits (e.g. Retry-After) are always forwarded.
+	if msg != nil && msg.Addon != nil && (PassthroughHeadersEnabled(h.Cfg) || isClientLimitError(msg.Error)) {
 		for key, values := range msg.Addon {
 			if len(values) == 0 {
 				continue
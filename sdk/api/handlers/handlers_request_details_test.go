@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
@@ -116,3 +119,20 @@ func TestGetRequestDetails_PreservesSuffix(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestExecutionMetadata_HashesClientKey(t *testing.T) {
+	ctx := coreexecutor.WithClientAPIKey(context.Background(), "sk-client-secret")
+	meta := requestExecutionMetadata(ctx)
+	id, _ := meta[coreexecutor.ClientIDMetadataKey].(string)
+	if id == "" || strings.Contains(id, "sk-client-secret") {
+		t.Fatalf("client id = %q, want a hash of the key", id)
+	}
+	if other := requestExecutionMetadata(ctx)[coreexecutor.ClientIDMetadataKey]; other != id {
+		t.Fatalf("client id is not stable: %q != %q", other, id)
+	}
+	for key, value := range meta {
+		if s, ok := value.(string); ok && strings.Contains(s, "sk-client-secret") {
+			t.Fatalf("metadata %s exposes the client key", key)
+		}
+	}
+}
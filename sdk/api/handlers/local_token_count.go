@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// LocalTokenCountEnabled returns whether count_tokens requests are answered by the local
+// tokenizer instead of the upstream provider. Default is false.
+func LocalTokenCountEnabled(cfg *config.SDKConfig) bool {
+	return cfg != nil && cfg.LocalTokenCount
+}
+
+// countsTokensLocally reports whether a count_tokens request in the handlerType format is
+// answered locally. OpenAI formats have no upstream counting endpoint, so they always are.
+func (h *BaseAPIHandler) countsTokensLocally(handlerType string) bool {
+	switch handlerType {
+	case "openai", "openai-response":
+		return true
+	default:
+		return LocalTokenCountEnabled(h.Cfg)
+	}
+}
+
+// countTokensLocally counts the prompt tokens of rawJSON with the model's local tokenizer and
+// renders the count_tokens response of the handlerType format.
+func countTokensLocally(handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	enc, err := tokenizer.ForModel(thinking.ParseSuffix(modelName).ModelName)
+	if err != nil {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: fmt.Errorf("local token count: %w", err)}
+	}
+	count, err := tokenizer.CountRequest(enc, handlerType, rawJSON)
+	if err != nil {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("local token count: %w", err)}
+	}
+	return tokenizer.CountResponse(handlerType, count), nil
+}
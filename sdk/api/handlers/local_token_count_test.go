@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type notImplementedCountExecutor struct {
+	fallbackTestExecutor
+}
+
+func (e *notImplementedCountExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "count tokens not supported", HTTPStatus: http.StatusNotImplemented}
+}
+
+func TestExecuteCountWithAuthManager_FallsBackToLocalCount(t *testing.T) {
+	executor := &notImplementedCountExecutor{fallbackTestExecutor{provider: "nocount", status: http.StatusNotImplemented}}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	registerFallbackTestAuth(t, manager, "nocount-auth", "nocount", "nocount-model")
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+
+	raw := []byte(`{"model":"nocount-model","messages":[{"role":"user","content":"hello there"}]}`)
+	resp, _, errMsg := h.ExecuteCountWithAuthManager(context.Background(), "claude", "nocount-model", raw, "")
+	if errMsg != nil {
+		t.Fatalf("ExecuteCountWithAuthManager: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(resp, "input_tokens").Int() <= 0 {
+		t.Fatalf("response = %s, want a local Claude count", resp)
+	}
+
+	// Only count requests fall back; a 501 from a regular request is returned as an error.
+	if _, _, errMsg = h.ExecuteWithAuthManager(context.Background(), "claude", "nocount-model", raw, ""); errMsg == nil || errMsg.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("ExecuteWithAuthManager error = %+v, want 501", errMsg)
+	}
+}
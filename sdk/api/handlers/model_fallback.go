@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	log "github.com/sirupsen/logrus"
+)
+
+// ServedModelHeader reports which fallback chain target served a request.
+const ServedModelHeader = "X-CPA-Served-Model"
+
+// executionTarget is one step of a model fallback chain.
+type executionTarget struct {
+	// model is the normalized model name passed to the auth manager.
+	model string
+	// providers are the providers eligible to serve model.
+	providers []string
+	// label identifies the target in logs and the served-model header.
+	label string
+}
+
+// executionTargets returns the requested model followed by its configured fallback targets.
+// Targets that no registered provider can serve, or that the client's model policy or
+// api-key-limits entry forbids, are skipped.
+func (h *BaseAPIHandler) executionTargets(ctx context.Context, requestedModel string, providers []string, normalizedModel string) []executionTarget {
+	targets := []executionTarget{{model: normalizedModel, providers: providers, label: normalizedModel}}
+	if h == nil || h.Cfg == nil || len(h.Cfg.ModelFallbacks) == 0 {
+		return targets
+	}
+	parsed := thinking.ParseSuffix(normalizedModel)
+	baseModel := strings.TrimSpace(parsed.ModelName)
+	requestedBase := strings.TrimSpace(thinking.ParseSuffix(requestedModel).ModelName)
+
+	for _, entry := range h.Cfg.ModelFallbacks {
+		chainModel := strings.TrimSpace(entry.Model)
+		if !strings.EqualFold(chainModel, baseModel) && !strings.EqualFold(chainModel, requestedBase) {
+			continue
+		}
+		for _, raw := range entry.Fallbacks {
+			target, ok := h.resolveFallbackTarget(raw, parsed)
+			if !ok {
+				log.Debugf("model fallback: skipping target %q for %s: no provider available", raw, chainModel)
+				continue
+			}
+			allowed, errMsg := h.applyClientPolicy(ctx, "", target.model, target.providers)
+			if errMsg != nil {
+				log.Debugf("model fallback: skipping target %q for %s: not allowed for client", raw, chainModel)
+				continue
+			}
+			if errMsg = h.clientLimitsAllow(ctx, "", target.model); errMsg != nil {
+				log.Debugf("model fallback: skipping target %q for %s: client limits deny it", raw, chainModel)
+				continue
+			}
+			target.providers = allowed
+			targets = append(targets, target)
+		}
+		break
+	}
+	return targets
+}
+
+// resolveFallbackTarget parses "model" or "provider:model" and resolves its providers.
+// The thinking suffix of the original request is carried over when the target has none.
+func (h *BaseAPIHandler) resolveFallbackTarget(raw string, original thinking.SuffixResult) (executionTarget, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return executionTarget{}, false
+	}
+	withSuffix := func(model string) string {
+		if original.HasSuffix && !thinking.ParseSuffix(model).HasSuffix {
+			return fmt.Sprintf("%s(%s)", model, original.RawSuffix)
+		}
+		return model
+	}
+
+	if idx := strings.Index(raw, ":"); idx > 0 {
+		pinned := strings.ToLower(strings.TrimSpace(raw[:idx]))
+		model := strings.TrimSpace(raw[idx+1:])
+		if model != "" {
+			if providers, normalized, errMsg := h.getRequestDetails(withSuffix(model)); errMsg == nil {
+				for _, provider := range providers {
+					if strings.EqualFold(provider, pinned) {
+						return executionTarget{model: normalized, providers: []string{provider}, label: pinned + ":" + normalized}, true
+					}
+				}
+			}
+		}
+		// The colon may belong to the model name itself; fall through and resolve it verbatim.
+	}
+
+	providers, normalized, errMsg := h.getRequestDetails(withSuffix(raw))
+	if errMsg != nil {
+		return executionTarget{}, false
+	}
+	return executionTarget{model: normalized, providers: providers, label: normalized}, true
+}
+
+// shouldTryNextTarget reports whether err means the current target is exhausted or transiently failing.
+func shouldTryNextTarget(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isClientLimitError(err) {
+		return false
+	}
+	status := statusFromError(err)
+	switch {
+	case status == 0:
+		return true
+	case status == http.StatusUnauthorized, status == http.StatusPaymentRequired, status == http.StatusForbidden,
+		status == http.StatusNotFound, status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= http.StatusInternalServerError
+	}
+}
+
+// recordServedTarget exposes the target that served the request when a fallback chain applies.
+func recordServedTarget(ctx context.Context, targets []executionTarget, index int) {
+	if len(targets) < 2 || index < 0 || index >= len(targets) {
+		return
+	}
+	if index > 0 {
+		log.Infof("model fallback: %s served by %s", targets[0].label, targets[index].label)
+	}
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(ServedModelHeader, targets[index].label)
+	}
+}
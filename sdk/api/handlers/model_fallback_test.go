@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type fallbackTestExecutor struct {
+	provider string
+	status   int
+
+	mu     sync.Mutex
+	models []string
+}
+
+func (e *fallbackTestExecutor) Identifier() string { return e.provider }
+
+func (e *fallbackTestExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.models = append(e.models, req.Model)
+	e.mu.Unlock()
+	if e.status != 0 {
+		return coreexecutor.Response{}, &coreauth.Error{Code: "upstream", Message: "upstream failure", HTTPStatus: e.status}
+	}
+	return coreexecutor.Response{Payload: []byte(`{"served":"` + e.provider + `"}`)}, nil
+}
+
+func (e *fallbackTestExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *fallbackTestExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *fallbackTestExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *fallbackTestExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func (e *fallbackTestExecutor) Models() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.models...)
+}
+
+func registerFallbackTestAuth(t *testing.T, manager *coreauth.Manager, id, provider, model string) {
+	t.Helper()
+	auth := &coreauth.Auth{ID: id, Provider: provider, Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register(%s): %v", id, err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(id, provider, []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+}
+
+func TestExecuteWithAuthManager_WalksFallbackChain(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "claude", status: http.StatusTooManyRequests}
+	pinned := &fallbackTestExecutor{provider: "kiro", status: http.StatusServiceUnavailable}
+	secondary := &fallbackTestExecutor{provider: "gemini"}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(pinned)
+	manager.RegisterExecutor(secondary)
+	registerFallbackTestAuth(t, manager, "fallback-claude", "claude", "fallback-sonnet")
+	registerFallbackTestAuth(t, manager, "fallback-kiro", "kiro", "fallback-opus")
+	registerFallbackTestAuth(t, manager, "fallback-gemini", "gemini", "fallback-pro")
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ModelFallbacks: []sdkconfig.ModelFallback{{
+			Model:     "fallback-sonnet",
+			Fallbacks: []string{"kiro:fallback-opus", "unknown-model", "fallback-pro"},
+		}},
+	}, manager)
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	body, _, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "fallback-sonnet", []byte(`{"model":"fallback-sonnet"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if string(body) != `{"served":"gemini"}` {
+		t.Fatalf("body = %s, want gemini response", body)
+	}
+	if got := recorder.Header().Get(ServedModelHeader); got != "fallback-pro" {
+		t.Fatalf("%s = %q, want %q", ServedModelHeader, got, "fallback-pro")
+	}
+	if got := primary.Models(); len(got) != 1 || got[0] != "fallback-sonnet" {
+		t.Fatalf("claude calls = %v, want single fallback-sonnet call", got)
+	}
+	if got := pinned.Models(); len(got) != 1 || got[0] != "fallback-opus" {
+		t.Fatalf("kiro calls = %v, want single fallback-opus call", got)
+	}
+	if got := secondary.Models(); len(got) != 1 || got[0] != "fallback-pro" {
+		t.Fatalf("gemini calls = %v, want single fallback-pro call", got)
+	}
+}
+
+func TestExecuteWithAuthManager_FallbackSkipsNonRetryableErrors(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "codex", status: http.StatusBadRequest}
+	secondary := &fallbackTestExecutor{provider: "qwen"}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(secondary)
+	registerFallbackTestAuth(t, manager, "fallback-codex", "codex", "fallback-gpt")
+	registerFallbackTestAuth(t, manager, "fallback-qwen", "qwen", "fallback-coder")
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ModelFallbacks: []sdkconfig.ModelFallback{{Model: "fallback-gpt", Fallbacks: []string{"fallback-coder"}}},
+	}, manager)
+
+	_, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "fallback-gpt", []byte(`{}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the 400 from the primary, got %+v", errMsg)
+	}
+	if got := secondary.Models(); len(got) != 0 {
+		t.Fatalf("fallback called for a non-retryable error: %v", got)
+	}
+}
+
+func TestExecuteWithAuthManager_FallbackSkipsRequestQueue(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "claude", status: http.StatusTooManyRequests}
+	secondary := &fallbackTestExecutor{provider: "gemini", status: http.StatusTooManyRequests}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(secondary)
+	cfg := &sdkconfig.Config{}
+	cfg.RequestQueue.Enable = true
+	cfg.RequestQueue.MaxWaitSeconds = 1
+	manager.SetConfig(cfg)
+	registerFallbackTestAuth(t, manager, "queue-fallback-claude", "claude", "queue-sonnet")
+	registerFallbackTestAuth(t, manager, "queue-fallback-gemini", "gemini", "queue-pro")
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ModelFallbacks: []sdkconfig.ModelFallback{{Model: "queue-sonnet", Fallbacks: []string{"queue-pro"}}},
+	}, manager)
+
+	// The primary is rate limited: the request moves on to the fallback at once instead of
+	// waiting in the queue, and only the final target waits before failing.
+	start := time.Now()
+	_, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "claude", "queue-sonnet", []byte(`{}`), "")
+	elapsed := time.Since(start)
+	if errMsg == nil || errMsg.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected a 429 once every target is exhausted, got %+v", errMsg)
+	}
+	if got := primary.Models(); len(got) != 1 {
+		t.Fatalf("claude calls = %v, want a single call", got)
+	}
+	if got := secondary.Models(); len(got) != 1 {
+		t.Fatalf("gemini calls = %v, want a single call", got)
+	}
+	if elapsed < time.Second || elapsed > 1500*time.Millisecond {
+		t.Fatalf("request took %v, want only the final target to wait the 1s queue limit", elapsed)
+	}
+	if stats := manager.QueueStats(); len(stats) != 0 {
+		t.Fatalf("queue not drained: %+v", stats)
+	}
+}
+
+func TestExecuteWithAuthManager_FallbackHonorsClientLimits(t *testing.T) {
+	primary := &fallbackTestExecutor{provider: "antigravity", status: http.StatusTooManyRequests}
+	denied := &fallbackTestExecutor{provider: "iflow"}
+	allowed := &fallbackTestExecutor{provider: "kimi"}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(primary)
+	manager.RegisterExecutor(denied)
+	manager.RegisterExecutor(allowed)
+	registerFallbackTestAuth(t, manager, "fallback-limits-primary", "antigravity", "limited-primary")
+	registerFallbackTestAuth(t, manager, "fallback-limits-denied", "iflow", "outside-model")
+	registerFallbackTestAuth(t, manager, "fallback-limits-allowed", "kimi", "limited-backup")
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		APIKeyLimits:   []sdkconfig.APIKeyLimit{{APIKeys: []string{"fallback-limited-key"}, AllowedModels: []string{"limited-*"}}},
+		ModelFallbacks: []sdkconfig.ModelFallback{{Model: "limited-primary", Fallbacks: []string{"outside-model", "limited-backup"}}},
+	}, manager)
+
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Set("apiKey", "fallback-limited-key")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	body, _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "limited-primary", []byte(`{}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if string(body) != `{"served":"kimi"}` {
+		t.Fatalf("body = %s, want the allowed fallback", body)
+	}
+	if got := denied.Models(); len(got) != 0 {
+		t.Fatalf("fallback outside the key's allowed models was called: %v", got)
+	}
+}
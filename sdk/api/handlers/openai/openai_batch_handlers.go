@@ -0,0 +1,314 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const batchStoreTimeout = 30 * time.Second
+
+// OpenAIBatchAPIHandler serves the emulated OpenAI Files and Batch APIs and executes batch
+// requests for the background runner.
+type OpenAIBatchAPIHandler struct {
+	*handlers.BaseAPIHandler
+}
+
+// NewOpenAIBatchAPIHandler creates a new OpenAI Batch API handlers instance.
+func NewOpenAIBatchAPIHandler(apiHandlers *handlers.BaseAPIHandler) *OpenAIBatchAPIHandler {
+	return &OpenAIBatchAPIHandler{
+		BaseAPIHandler: apiHandlers,
+	}
+}
+
+// UploadFile handles POST /v1/files with a multipart "file" and "purpose".
+func (h *OpenAIBatchAPIHandler) UploadFile(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, batch.MaxFileBytes()+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeBatchError(c, &batch.ValidationError{Message: fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	content, err := io.ReadAll(src)
+	_ = src.Close()
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	file, err := batch.CreateFile(ctx, batchOwner(c), header.Filename, strings.TrimSpace(c.PostForm("purpose")), content)
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file.Public())
+}
+
+// ListFiles handles GET /v1/files.
+func (h *OpenAIBatchAPIHandler) ListFiles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	files, err := batch.ListFiles(ctx, batchOwner(c), strings.TrimSpace(c.Query("purpose")))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	data := make([]*batch.File, 0, len(files))
+	for _, file := range files {
+		data = append(data, file.Public())
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data, "has_more": false})
+}
+
+// GetFile handles GET /v1/files/{id}.
+func (h *OpenAIBatchAPIHandler) GetFile(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	file, err := batch.GetFile(ctx, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file.Public())
+}
+
+// GetFileContent handles GET /v1/files/{id}/content.
+func (h *OpenAIBatchAPIHandler) GetFileContent(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	_, content, err := batch.FileContent(ctx, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/octet-stream", content)
+}
+
+// DeleteFile handles DELETE /v1/files/{id}.
+func (h *OpenAIBatchAPIHandler) DeleteFile(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	if err := batch.DeleteFile(ctx, id, batchOwner(c)); err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+// CreateBatch handles POST /v1/batches.
+func (h *OpenAIBatchAPIHandler) CreateBatch(c *gin.Context) {
+	var request struct {
+		InputFileID      string            `json:"input_file_id"`
+		Endpoint         string            `json:"endpoint"`
+		CompletionWindow string            `json:"completion_window"`
+		Metadata         map[string]string `json:"metadata"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeBatchError(c, &batch.ValidationError{Message: fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	var access map[string]string
+	if value, exists := c.Get("accessMetadata"); exists {
+		access, _ = value.(map[string]string)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	job, err := batch.CreateJob(ctx, batchOwner(c), batchClientKey(c), access, strings.TrimSpace(request.InputFileID), strings.TrimSpace(request.Endpoint), strings.TrimSpace(request.CompletionWindow), request.Metadata)
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job.Public())
+}
+
+// GetBatch handles GET /v1/batches/{id}.
+func (h *OpenAIBatchAPIHandler) GetBatch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	job, err := batch.GetJob(ctx, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job.Public())
+}
+
+// ListBatches handles GET /v1/batches with the "after" cursor and "limit" (1-100, default 20).
+func (h *OpenAIBatchAPIHandler) ListBatches(c *gin.Context) {
+	limit := 20
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 100 {
+			writeBatchError(c, &batch.ValidationError{Message: "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	jobs, err := batch.ListJobs(ctx, batchOwner(c))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	if after := strings.TrimSpace(c.Query("after")); after != "" {
+		for i, job := range jobs {
+			if job.ID == after {
+				jobs = jobs[i+1:]
+				break
+			}
+		}
+	}
+	hasMore := len(jobs) > limit
+	if hasMore {
+		jobs = jobs[:limit]
+	}
+	data := make([]*batch.Job, 0, len(jobs))
+	for _, job := range jobs {
+		data = append(data, job.Public())
+	}
+	response := gin.H{"object": "list", "data": data, "has_more": hasMore}
+	if len(data) > 0 {
+		response["first_id"] = data[0].ID
+		response["last_id"] = data[len(data)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelBatch handles POST /v1/batches/{id}/cancel.
+func (h *OpenAIBatchAPIHandler) CancelBatch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchStoreTimeout)
+	defer cancel()
+	job, err := batch.CancelJob(ctx, c.Param("id"), batchOwner(c))
+	if err != nil {
+		writeBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job.Public())
+}
+
+// ExecuteBatchRequest executes one batch request through the auth manager. It implements
+// batch.ExecuteFunc; requests always run non-streaming under the policy, limits and usage
+// attribution of the job's client.
+func (h *OpenAIBatchAPIHandler) ExecuteBatchRequest(ctx context.Context, job *batch.Job, body []byte) batch.Outcome {
+	handlerType, alt := OpenAI, ""
+	switch job.Endpoint {
+	case "/v1/responses":
+		handlerType = OpenaiResponse
+	case "/v1/embeddings":
+		alt = cliproxyexecutor.AltEmbeddings
+	}
+	if gjson.GetBytes(body, "stream").Exists() {
+		if updated, err := sjson.DeleteBytes(body, "stream"); err == nil {
+			body = updated
+		}
+	}
+	ctx = handlers.WithAccessMetadata(ctx, job.AccessMetadata)
+	ctx = cliproxyexecutor.WithClientAPIKey(ctx, job.ClientKey)
+	modelName := gjson.GetBytes(body, "model").String()
+	var (
+		resp   []byte
+		errMsg *interfaces.ErrorMessage
+	)
+	if alt == cliproxyexecutor.AltEmbeddings {
+		errMsg = h.CheckEmbeddingModel(modelName)
+	}
+	if errMsg == nil {
+		resp, _, errMsg = h.ExecuteWithAuthManager(ctx, handlerType, modelName, body, alt)
+	}
+	if errMsg == nil {
+		return batch.Outcome{StatusCode: http.StatusOK, Body: resp}
+	}
+	status := errMsg.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	errText := ""
+	if errMsg.Error != nil {
+		errText = errMsg.Error.Error()
+	}
+	outcome := batch.Outcome{StatusCode: status, Body: handlers.BuildErrorResponseBody(status, errText)}
+	if errMsg.Addon != nil {
+		if seconds, err := strconv.Atoi(strings.TrimSpace(errMsg.Addon.Get("Retry-After"))); err == nil && seconds > 0 {
+			outcome.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return outcome
+}
+
+// batchOwner identifies the client API key that owns files and batches.
+func batchOwner(c *gin.Context) string {
+	return batch.OwnerKey(batchClientKey(c))
+}
+
+// batchClientKey returns the client API key of the request.
+func batchClientKey(c *gin.Context) string {
+	apiKey, _ := c.Get("apiKey")
+	key, _ := apiKey.(string)
+	return key
+}
+
+func writeBatchError(c *gin.Context, err error) {
+	var validation *batch.ValidationError
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.Is(err, batch.ErrDisabled):
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "The Batch API is not enabled on this server",
+				Type:    "invalid_request_error",
+			},
+		})
+	case errors.Is(err, batch.ErrNotFound):
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("No such object: %s", strings.TrimSpace(c.Param("id"))),
+				Type:    "invalid_request_error",
+			},
+		})
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: validation.Message,
+				Type:    "invalid_request_error",
+			},
+		})
+	case errors.As(err, &maxBytes):
+		c.JSON(http.StatusRequestEntityTooLarge, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("File exceeds the maximum size of %d bytes", batch.MaxFileBytes()),
+				Type:    "invalid_request_error",
+			},
+		})
+	default:
+		log.WithError(err).Warn("batch: request failed")
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "batch store unavailable",
+				Type:    "server_error",
+			},
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestExecuteBatchRequestAppliesClientLimits(t *testing.T) {
+	executor := &compactCaptureExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "batch-limits-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "batch-allowed-model"}, {ID: "batch-denied-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+	})
+
+	cfg := &sdkconfig.SDKConfig{APIKeyLimits: []sdkconfig.APIKeyLimit{{APIKeys: []string{"batch-key"}, AllowedModels: []string{"batch-allowed-*"}}}}
+	h := NewOpenAIBatchAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+	job := &batch.Job{ID: "batch_test", Endpoint: "/v1/chat/completions", ClientKey: "batch-key"}
+
+	denied := h.ExecuteBatchRequest(context.Background(), job, []byte(`{"model":"batch-denied-model","messages":[{"role":"user","content":"hi"}]}`))
+	if denied.StatusCode != http.StatusForbidden || executor.calls != 0 {
+		t.Fatalf("denied model: status = %d, calls = %d; want 403 without dispatch", denied.StatusCode, executor.calls)
+	}
+	allowed := h.ExecuteBatchRequest(context.Background(), job, []byte(`{"model":"batch-allowed-model","messages":[{"role":"user","content":"hi"}]}`))
+	if allowed.StatusCode != http.StatusOK || executor.calls != 1 {
+		t.Fatalf("allowed model: status = %d, calls = %d, body = %s", allowed.StatusCode, executor.calls, allowed.Body)
+	}
+}
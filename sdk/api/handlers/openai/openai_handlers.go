@@ -61,9 +61,9 @@ func (h *OpenAIAPIHandler) Models() []map[string]any {
 // and specifications in OpenAI-compatible format.
 func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 	// Get all available models
-	allModels := h.Models()
+	allModels := h.FilterModelsForClient(c, h.Models())
 
-	// Filter to only include the 4 required fields: id, object, created, owned_by
+	// Filter to only include the 4 required fields: id, object, created, owned_by (plus kind when set)
 	filteredModels := make([]map[string]any, len(allModels))
 	for i, model := range allModels {
 		filteredModel := map[string]any{
@@ -81,6 +81,11 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 			filteredModel["owned_by"] = ownedBy
 		}
 
+		// Keep kind so clients can tell embedding models from chat models
+		if kind, exists := model["kind"]; exists {
+			filteredModel["kind"] = kind
+		}
+
 		filteredModels[i] = filteredModel
 	}
 
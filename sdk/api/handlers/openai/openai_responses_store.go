@@ -0,0 +1,201 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const responseStoreTimeout = 10 * time.Second
+
+// GetResponse handles GET /v1/responses/{id} by returning a stored response.
+func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), responseStoreTimeout)
+	defer cancel()
+	record, err := responsestore.Load(ctx, id, responseOwner(c))
+	if err != nil {
+		writeResponseStoreError(c, id, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", record.Response)
+}
+
+// DeleteResponse handles DELETE /v1/responses/{id} by removing a stored response.
+func (h *OpenAIResponsesAPIHandler) DeleteResponse(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), responseStoreTimeout)
+	defer cancel()
+	if err := responsestore.Remove(ctx, id, responseOwner(c)); err != nil {
+		writeResponseStoreError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "response", "deleted": true})
+}
+
+func writeResponseStoreError(c *gin.Context, id string, err error) {
+	if errors.Is(err, responsestore.ErrNotFound) {
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Response with id '%s' not found.", id),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	log.WithError(err).Warn("response store: request failed")
+	c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: "response store unavailable",
+			Type:    "server_error",
+		},
+	})
+}
+
+// responseOwner identifies the client API key that owns stored responses.
+func responseOwner(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	apiKey, _ := c.Get("apiKey")
+	key, _ := apiKey.(string)
+	return responsestore.OwnerKey(key)
+}
+
+// rehydrateResponsesRequest replaces previous_response_id with the stored conversation so the
+// request carries its full input transcript. Backends without server-side state then see every turn.
+// The request is returned unchanged when the store is disabled, no previous response is
+// referenced, or the store does not hold it, so upstreams keeping their own state still resolve it.
+func rehydrateResponsesRequest(c *gin.Context, rawJSON []byte) ([]byte, error) {
+	previousID := strings.TrimSpace(gjson.GetBytes(rawJSON, "previous_response_id").String())
+	if previousID == "" || !responsestore.Enabled() {
+		return rawJSON, nil
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), responseStoreTimeout)
+	defer cancel()
+	record, err := responsestore.Load(ctx, previousID, responseOwner(c))
+	if errors.Is(err, responsestore.ErrNotFound) {
+		return rawJSON, nil
+	}
+	if err != nil {
+		return rawJSON, err
+	}
+
+	merged, err := mergeJSONArrayRaw(string(record.Input), string(record.Output()))
+	if err != nil {
+		return rawJSON, fmt.Errorf("invalid stored response: %w", err)
+	}
+	merged, err = mergeJSONArrayRaw(merged, responsesInputArray(rawJSON))
+	if err != nil {
+		return rawJSON, fmt.Errorf("invalid request input: %w", err)
+	}
+	out, err := sjson.SetRawBytes(rawJSON, "input", []byte(merged))
+	if err != nil {
+		return rawJSON, err
+	}
+	out, _ = sjson.DeleteBytes(out, "previous_response_id")
+	if strings.TrimSpace(gjson.GetBytes(out, "model").String()) == "" && record.Model != "" {
+		out, _ = sjson.SetBytes(out, "model", record.Model)
+	}
+	return out, nil
+}
+
+// responsesInputArray returns the request input as a JSON array of input items.
+// A plain string input is the shorthand for a single user message.
+func responsesInputArray(rawJSON []byte) string {
+	input := gjson.GetBytes(rawJSON, "input")
+	switch {
+	case input.IsArray():
+		return input.Raw
+	case input.Type == gjson.String:
+		item, _ := sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", input.String())
+		return "[" + item + "]"
+	default:
+		return "[]"
+	}
+}
+
+// responseRecorder captures the completed response of a /v1/responses request for the store.
+// A nil recorder ignores everything.
+type responseRecorder struct {
+	owner      string
+	model      string
+	input      string
+	previousID string
+	done       bool
+}
+
+// newResponseRecorder returns a recorder for requests that should be stored, or nil.
+// rawJSON is the request after rehydration; previousID is the previous_response_id it referenced.
+// Requests still carrying previous_response_id continue a conversation held upstream, whose
+// earlier turns the store lacks, so they are not recorded.
+func newResponseRecorder(c *gin.Context, rawJSON []byte, previousID string) *responseRecorder {
+	if !responsestore.Enabled() || gjson.GetBytes(rawJSON, "previous_response_id").Exists() {
+		return nil
+	}
+	if store := gjson.GetBytes(rawJSON, "store"); store.Exists() && !store.Bool() {
+		return nil
+	}
+	return &responseRecorder{
+		owner:      responseOwner(c),
+		model:      gjson.GetBytes(rawJSON, "model").String(),
+		input:      responsesInputArray(rawJSON),
+		previousID: previousID,
+	}
+}
+
+// observeResponse stores a complete response object.
+func (r *responseRecorder) observeResponse(response []byte) {
+	if r == nil || r.done {
+		return
+	}
+	id := strings.TrimSpace(gjson.GetBytes(response, "id").String())
+	if id == "" || !gjson.GetBytes(response, "output").IsArray() {
+		return
+	}
+	r.done = true
+	if r.previousID != "" && !gjson.GetBytes(response, "previous_response_id").Exists() {
+		response, _ = sjson.SetBytes(response, "previous_response_id", r.previousID)
+	}
+	model := gjson.GetBytes(response, "model").String()
+	if model == "" {
+		model = r.model
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), responseStoreTimeout)
+	defer cancel()
+	err := responsestore.Save(ctx, &responsestore.Record{
+		ID:       id,
+		Owner:    r.owner,
+		Model:    model,
+		Input:    []byte(r.input),
+		Response: append([]byte(nil), response...),
+	})
+	if err != nil {
+		log.WithError(err).Warnf("response store: failed to save response %s", id)
+	}
+}
+
+// observeChunk stores the response carried by a response.completed stream event.
+func (r *responseRecorder) observeChunk(chunk []byte) {
+	if r == nil || r.done {
+		return
+	}
+	for _, payload := range websocketJSONPayloadsFromChunk(chunk) {
+		if gjson.GetBytes(payload, "type").String() != "response.completed" {
+			continue
+		}
+		if response := gjson.GetBytes(payload, "response"); response.IsObject() {
+			r.observeResponse([]byte(response.Raw))
+		}
+	}
+}
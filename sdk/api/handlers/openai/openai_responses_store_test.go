@@ -0,0 +1,127 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type responsesStoreExecutor struct {
+	payloads [][]byte
+}
+
+func (e *responsesStoreExecutor) Identifier() string { return "test-store-provider" }
+
+func (e *responsesStoreExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.payloads = append(e.payloads, req.Payload)
+	id := "resp_" + string(rune('0'+len(e.payloads)))
+	return coreexecutor.Response{Payload: []byte(`{"id":"` + id + `","object":"response","status":"completed","model":"test-store-model","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"answer"}]}]}`)}, nil
+}
+
+func (e *responsesStoreExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *responsesStoreExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *responsesStoreExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *responsesStoreExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestOpenAIResponsesStoreRehydratesPreviousResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &sdkconfig.Config{}
+	cfg.ResponseStore.Driver = "memory"
+	if err := responsestore.Configure(cfg); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(responsestore.Close)
+
+	executor := &responsesStoreExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "store-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "test-store-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+	})
+
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("Authorization"))
+	})
+	router.POST("/v1/responses", h.Responses)
+	router.GET("/v1/responses/:id", h.GetResponse)
+	router.DELETE("/v1/responses/:id", h.DeleteResponse)
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/v1/responses", "k1", `{"model":"test-store-model","input":"first"}`); rec.Code != http.StatusOK {
+		t.Fatalf("first turn status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/v1/responses", "k1", `{"previous_response_id":"resp_1","input":[{"type":"message","role":"user","content":"second"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("second turn status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	second := executor.payloads[1]
+	if gjson.GetBytes(second, "previous_response_id").Exists() {
+		t.Fatalf("previous_response_id should be replaced by the stored transcript: %s", second)
+	}
+	input := gjson.GetBytes(second, "input").Array()
+	if len(input) != 3 || input[0].Get("content.0.text").String() != "first" || input[1].Get("role").String() != "assistant" || input[2].Get("content").String() != "second" {
+		t.Fatalf("rehydrated input = %s", gjson.GetBytes(second, "input").Raw)
+	}
+	if gjson.GetBytes(second, "model").String() != "test-store-model" {
+		t.Fatalf("model should default to the stored model: %s", second)
+	}
+
+	// IDs the store does not hold for the caller are left for the upstream to resolve.
+	if rec := do(http.MethodPost, "/v1/responses", "k2", `{"model":"test-store-model","previous_response_id":"resp_1","input":"x"}`); rec.Code != http.StatusOK {
+		t.Fatalf("other key previous_response_id status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	forwarded := executor.payloads[2]
+	if gjson.GetBytes(forwarded, "previous_response_id").String() != "resp_1" || gjson.GetBytes(forwarded, "input").String() != "x" {
+		t.Fatalf("unknown previous_response_id should be forwarded unchanged: %s", forwarded)
+	}
+	if rec := do(http.MethodGet, "/v1/responses/resp_3", "k2", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("response continuing an upstream conversation was stored: status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/v1/responses/resp_2", "k1", ""); rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "previous_response_id").String() != "resp_1" {
+		t.Fatalf("GET status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/v1/responses/resp_2", "k2", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("DELETE by other key status = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/v1/responses/resp_2", "k1", ""); rec.Code != http.StatusOK || !gjson.Get(rec.Body.String(), "deleted").Bool() {
+		t.Fatalf("DELETE status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/v1/responses/resp_2", "k1", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET after delete status = %d, want 404", rec.Code)
+	}
+}
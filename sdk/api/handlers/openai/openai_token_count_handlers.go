@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// CountTokens handles the /v1/chat/completions/count_tokens endpoint.
+// The prompt of a chat completions request is counted by the local tokenizer of the model.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) CountTokens(c *gin.Context) {
+	handleLocalTokenCount(c, h.BaseAPIHandler, h)
+}
+
+// InputTokens handles the /v1/responses/input_tokens endpoint.
+// The input of a Responses request is counted by the local tokenizer of the model.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIResponsesAPIHandler) InputTokens(c *gin.Context) {
+	handleLocalTokenCount(c, h.BaseAPIHandler, h)
+}
+
+// handleLocalTokenCount counts the prompt of an OpenAI-format request; these formats have no
+// upstream counting endpoint, so ExecuteCountWithAuthManager answers them locally.
+func handleLocalTokenCount(c *gin.Context, h *handlers.BaseAPIHandler, handler interfaces.APIHandler) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if modelName == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: model is required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(handler, c, context.Background())
+	resp, _, errMsg := h.ExecuteCountWithAuthManager(cliCtx, handler.HandlerType(), modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIInputTokensCountsLocally(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	executor := &compactCaptureExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+
+	auth := &coreauth.Auth{ID: "count-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "test-count-model", Tokenizer: "cl100k_base"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+	})
+
+	base := handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+	router := gin.New()
+	router.POST("/v1/chat/completions/count_tokens", NewOpenAIAPIHandler(base).CountTokens)
+	router.POST("/v1/responses/input_tokens", NewOpenAIResponsesAPIHandler(base).InputTokens)
+
+	requests := map[string]string{
+		"/v1/chat/completions/count_tokens": `{"model":"test-count-model","messages":[{"role":"user","content":"hello there"}]}`,
+		"/v1/responses/input_tokens":        `{"model":"test-count-model","input":"hello there"}`,
+	}
+	for path, body := range requests {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", path, resp.Code, resp.Body.String())
+		}
+		if gjson.Get(resp.Body.String(), "object").String() != "response.input_tokens" || gjson.Get(resp.Body.String(), "input_tokens").Int() <= 0 {
+			t.Fatalf("%s: body = %s", path, resp.Body.String())
+		}
+	}
+	if executor.calls != 0 {
+		t.Fatalf("local counting should not reach the executor, calls = %d", executor.calls)
+	}
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/v1/responses/input_tokens", strings.NewReader(`{"model":"unknown-count-model","input":"hi"}`)))
+	if resp.Code == http.StatusOK {
+		t.Fatalf("unknown models should be rejected, body = %s", resp.Body.String())
+	}
+}
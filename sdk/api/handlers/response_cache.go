@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// CacheControlHeader lets clients opt out of the response cache with "bypass" (or "no-cache").
+	CacheControlHeader = "X-CPA-Cache"
+	// CacheStatusHeader reports HIT, MISS or BYPASS for requests eligible for the response cache.
+	CacheStatusHeader = "X-CPA-Cache-Status"
+
+	// responseCacheProvider is the usage record provider reported for cache hits.
+	responseCacheProvider = "response-cache"
+
+	defaultResponseCacheTTL        = 300 * time.Second
+	defaultResponseCacheEntries    = 1000
+	defaultResponseCacheEntryBytes = 1 << 20
+	defaultResponseCacheTotalBytes = 64 << 20
+)
+
+// responseCacheLookup carries the cache decision for a single request.
+type responseCacheLookup struct {
+	key        string
+	ttl        time.Duration
+	entryLimit int64
+}
+
+// configureResponseCache applies the configured capacity limits to the shared cache.
+func configureResponseCache(cfg *config.SDKConfig) {
+	responseCache := cache.DefaultResponseCache()
+	if cfg == nil || !cfg.ResponseCache.Enable {
+		responseCache.Purge()
+		return
+	}
+	maxEntries := cfg.ResponseCache.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultResponseCacheEntries
+	}
+	maxBytes := cfg.ResponseCache.MaxTotalBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultResponseCacheTotalBytes
+	}
+	responseCache.SetLimits(maxEntries, maxBytes)
+}
+
+// responseCacheFor decides whether a request may be served from or stored in the response cache.
+// It returns nil when caching does not apply. The cache status header is set on the client response.
+// The key hashes the request as translated for providers[0] and normalized, so client-side
+// differences that do not reach the upstream do not split the cache.
+func (h *BaseAPIHandler) responseCacheFor(ctx context.Context, handlerType, model, alt string, stream bool, providers []string, rawJSON []byte) *responseCacheLookup {
+	if ctx == nil || h == nil || h.Cfg == nil || !h.Cfg.ResponseCache.Enable || len(rawJSON) == 0 {
+		return nil
+	}
+	// Pinned auths and execution sessions are stateful and must always reach upstream.
+	if pinnedAuthIDFromContext(ctx) != "" || executionSessionIDFromContext(ctx) != "" {
+		return nil
+	}
+	settings := h.Cfg.ResponseCache
+	if !settings.IncludeNonDeterministic && !isDeterministicPayload(rawJSON) {
+		return nil
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if cacheBypassRequested(ginCtx) {
+		setCacheStatus(ginCtx, "BYPASS")
+		return nil
+	}
+	normalized, ok := normalizeCachePayload(translateCachePayload(handlerType, model, alt, stream, providers, rawJSON))
+	if !ok {
+		return nil
+	}
+
+	namespace := ""
+	if !settings.SharedAcrossKeys {
+		namespace = clientAPIKeyFromContext(ctx)
+	}
+	hasher := sha256.New()
+	for _, part := range []string{namespace, handlerType, model, alt} {
+		hasher.Write([]byte(part))
+		hasher.Write([]byte{0})
+	}
+	if stream {
+		hasher.Write([]byte("stream"))
+	}
+	hasher.Write([]byte{0})
+	hasher.Write(normalized)
+
+	lookup := &responseCacheLookup{
+		key:        hex.EncodeToString(hasher.Sum(nil)),
+		ttl:        time.Duration(settings.TTLSeconds) * time.Second,
+		entryLimit: settings.MaxEntryBytes,
+	}
+	if lookup.ttl <= 0 {
+		lookup.ttl = defaultResponseCacheTTL
+	}
+	if lookup.entryLimit <= 0 {
+		lookup.entryLimit = defaultResponseCacheEntryBytes
+	}
+	return lookup
+}
+
+// load returns the cached entry and records the hit as a zero-token usage record.
+func (l *responseCacheLookup) load(ctx context.Context, model string) (*cache.ResponseEntry, bool) {
+	if l == nil {
+		return nil, false
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	entry, ok := cache.DefaultResponseCache().Get(l.key)
+	if !ok {
+		setCacheStatus(ginCtx, "MISS")
+		return nil, false
+	}
+	setCacheStatus(ginCtx, "HIT")
+	servedModel := entry.Model
+	if servedModel == "" {
+		servedModel = model
+	}
+	record := coreusage.Record{
+		Provider:    responseCacheProvider,
+		Model:       servedModel,
+		APIKey:      clientAPIKeyFromContext(ctx),
+		Source:      responseCacheProvider,
+		RequestedAt: time.Now(),
+	}
+	logging.AttachUsageRecord(ctx, record)
+	coreusage.PublishRecord(ctx, record)
+	return entry, true
+}
+
+// store caches entry when it fits within the per-entry size limit.
+func (l *responseCacheLookup) store(entry *cache.ResponseEntry) {
+	if l == nil || entry == nil || entry.Size() > l.entryLimit {
+		return
+	}
+	cache.DefaultResponseCache().Put(l.key, entry, l.ttl)
+}
+
+// streamRecorder accumulates forwarded chunks until the per-entry size limit is exceeded.
+type streamRecorder struct {
+	lookup   *responseCacheLookup
+	chunks   [][]byte
+	size     int64
+	overflow bool
+}
+
+func (l *responseCacheLookup) newStreamRecorder() *streamRecorder {
+	if l == nil {
+		return nil
+	}
+	return &streamRecorder{lookup: l}
+}
+
+func (r *streamRecorder) add(chunk []byte) {
+	if r == nil || r.overflow {
+		return
+	}
+	r.size += int64(len(chunk))
+	if r.size > r.lookup.entryLimit {
+		r.overflow = true
+		r.chunks = nil
+		return
+	}
+	r.chunks = append(r.chunks, bytes.Clone(chunk))
+}
+
+func (r *streamRecorder) commit(headers http.Header, model string) {
+	if r == nil || r.overflow || len(r.chunks) == 0 {
+		return
+	}
+	r.lookup.store(&cache.ResponseEntry{Chunks: r.chunks, Headers: cloneHeader(headers), Model: model})
+}
+
+// replayCachedStream emits cached chunks on a fresh channel pair.
+func replayCachedStream(ctx context.Context, entry *cache.ResponseEntry) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		for _, chunk := range entry.Chunks {
+			select {
+			case <-ctx.Done():
+				return
+			case dataChan <- cloneBytes(chunk):
+			}
+		}
+	}()
+	return dataChan, errChan
+}
+
+// isDeterministicPayload reports whether the request pins sampling temperature to 0.
+func isDeterministicPayload(rawJSON []byte) bool {
+	for _, path := range []string{"temperature", "generationConfig.temperature", "request.generationConfig.temperature"} {
+		if value := gjson.GetBytes(rawJSON, path); value.Exists() {
+			return value.Type == gjson.Number && value.Float() == 0
+		}
+	}
+	return false
+}
+
+// translateCachePayload returns the request as the translator for the first provider's format
+// would send it upstream. Requests without a registered translator, and non-generation calls
+// such as embeddings, are returned unchanged.
+func translateCachePayload(handlerType, model, alt string, stream bool, providers []string, rawJSON []byte) []byte {
+	if alt != "" || len(providers) == 0 {
+		return rawJSON
+	}
+	from, to := sdktranslator.FromString(handlerType), sdktranslator.FromString(providers[0])
+	if from == to {
+		return rawJSON
+	}
+	return sdktranslator.TranslateRequest(from, to, model, bytes.Clone(rawJSON), stream)
+}
+
+// normalizeCachePayload re-encodes the payload with sorted keys and without transport-only fields,
+// so semantically identical requests share a cache key. The model is dropped because the key
+// already carries the resolved model, which aliases and suffixes in the payload may not match.
+func normalizeCachePayload(rawJSON []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	var payload any
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, false
+	}
+	if object, ok := payload.(map[string]any); ok {
+		delete(object, "model")
+		delete(object, "stream")
+		delete(object, "stream_options")
+	}
+	normalized, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false
+	}
+	return normalized, true
+}
+
+func cacheBypassRequested(ginCtx *gin.Context) bool {
+	if ginCtx == nil || ginCtx.Request == nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(CacheControlHeader))) {
+	case "bypass", "no-cache", "no-store", "off":
+		return true
+	default:
+		return false
+	}
+}
+
+func setCacheStatus(ginCtx *gin.Context, status string) {
+	if ginCtx != nil {
+		ginCtx.Header(CacheStatusHeader, status)
+	}
+}
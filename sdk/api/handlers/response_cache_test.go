@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/sjson"
+)
+
+type countingCacheExecutor struct {
+	mu          sync.Mutex
+	calls       int
+	streamCalls int
+}
+
+func (e *countingCacheExecutor) Identifier() string { return "cache-test" }
+
+func (e *countingCacheExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"answer":42}`)}, nil
+}
+
+func (e *countingCacheExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	e.mu.Lock()
+	e.streamCalls++
+	e.mu.Unlock()
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	ch <- coreexecutor.StreamChunk{Payload: []byte("data: one")}
+	ch <- coreexecutor.StreamChunk{Payload: []byte("data: two")}
+	close(ch)
+	return &coreexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func (e *countingCacheExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *countingCacheExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *countingCacheExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func (e *countingCacheExecutor) counts() (int, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls, e.streamCalls
+}
+
+func newResponseCacheTestHandler(t *testing.T) (*BaseAPIHandler, *countingCacheExecutor) {
+	t.Helper()
+	executor := &countingCacheExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "cache-auth", Provider: "cache-test", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "cache-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+		configureResponseCache(nil)
+	})
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ResponseCache: sdkconfig.ResponseCacheConfig{Enable: true},
+	}, manager)
+	return handler, executor
+}
+
+func newResponseCacheTestContext(header string) (context.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if header != "" {
+		ginCtx.Request.Header.Set(CacheControlHeader, header)
+	}
+	return context.WithValue(context.Background(), "gin", ginCtx), recorder
+}
+
+func TestExecuteWithAuthManager_ResponseCache(t *testing.T) {
+	handler, executor := newResponseCacheTestHandler(t)
+
+	payload := []byte(`{"model":"cache-model","temperature":0,"messages":[{"role":"user","content":"hi"}]}`)
+	// Same request with different key order must hit the same entry.
+	reordered := []byte(`{"messages":[{"content":"hi","role":"user"}],"temperature":0,"model":"cache-model"}`)
+
+	for i, tc := range []struct {
+		body       []byte
+		header     string
+		wantStatus string
+		wantCalls  int
+	}{
+		{body: payload, wantStatus: "MISS", wantCalls: 1},
+		{body: reordered, wantStatus: "HIT", wantCalls: 1},
+		{body: payload, header: "bypass", wantStatus: "BYPASS", wantCalls: 2},
+		{body: []byte(`{"model":"cache-model","messages":[]}`), wantStatus: "", wantCalls: 3},
+	} {
+		ctx, recorder := newResponseCacheTestContext(tc.header)
+		body, _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "cache-model", tc.body, "")
+		if errMsg != nil {
+			t.Fatalf("request %d: unexpected error: %+v", i, errMsg)
+		}
+		if string(body) != `{"answer":42}` {
+			t.Fatalf("request %d: body = %s", i, body)
+		}
+		if got := recorder.Header().Get(CacheStatusHeader); got != tc.wantStatus {
+			t.Fatalf("request %d: %s = %q, want %q", i, CacheStatusHeader, got, tc.wantStatus)
+		}
+		if calls, _ := executor.counts(); calls != tc.wantCalls {
+			t.Fatalf("request %d: upstream calls = %d, want %d", i, calls, tc.wantCalls)
+		}
+	}
+}
+
+func TestExecuteStreamWithAuthManager_ResponseCacheReplaysChunks(t *testing.T) {
+	handler, executor := newResponseCacheTestHandler(t)
+	payload := []byte(`{"model":"cache-model","temperature":0,"stream":true}`)
+
+	for i := 0; i < 2; i++ {
+		ctx, recorder := newResponseCacheTestContext("")
+		dataChan, _, errChan := handler.ExecuteStreamWithAuthManager(ctx, "openai", "cache-model", payload, "")
+		var got []string
+		for chunk := range dataChan {
+			got = append(got, string(chunk))
+		}
+		for msg := range errChan {
+			if msg != nil {
+				t.Fatalf("stream %d: unexpected error: %+v", i, msg.Error)
+			}
+		}
+		if len(got) != 2 || got[0] != "data: one" || got[1] != "data: two" {
+			t.Fatalf("stream %d: chunks = %q", i, got)
+		}
+		wantStatus := "MISS"
+		if i == 1 {
+			wantStatus = "HIT"
+		}
+		if status := recorder.Header().Get(CacheStatusHeader); status != wantStatus {
+			t.Fatalf("stream %d: %s = %q, want %q", i, CacheStatusHeader, status, wantStatus)
+		}
+	}
+	if _, streamCalls := executor.counts(); streamCalls != 1 {
+		t.Fatalf("upstream stream calls = %d, want 1", streamCalls)
+	}
+}
+
+func TestExecuteWithAuthManager_ResponseCacheKeysTranslatedPayload(t *testing.T) {
+	handler, executor := newResponseCacheTestHandler(t)
+	// The client format's translator drops "user", so requests differing only there reach the
+	// upstream identically and must share an entry.
+	sdktranslator.Register(sdktranslator.FromString("cache-client"), sdktranslator.FromString("cache-test"),
+		func(model string, rawJSON []byte, _ bool) []byte {
+			out, _ := sjson.DeleteBytes(rawJSON, "user")
+			out, _ = sjson.SetBytes(out, "model", model)
+			return out
+		}, sdktranslator.ResponseTransform{})
+
+	for i, body := range []string{
+		`{"model":"cache-model","temperature":0,"user":"alice","messages":[{"role":"user","content":"hi"}]}`,
+		`{"model":"cache-model","temperature":0,"user":"bob","messages":[{"role":"user","content":"hi"}]}`,
+	} {
+		ctx, _ := newResponseCacheTestContext("")
+		if _, _, errMsg := handler.ExecuteWithAuthManager(ctx, "cache-client", "cache-model", []byte(body), ""); errMsg != nil {
+			t.Fatalf("request %d: unexpected error: %+v", i, errMsg)
+		}
+	}
+	if calls, _ := executor.counts(); calls != 1 {
+		t.Fatalf("upstream calls = %d, want 1", calls)
+	}
+}
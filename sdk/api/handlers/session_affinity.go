@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// defaultSessionAffinitySources is used when session-affinity.sources is empty.
+var defaultSessionAffinitySources = []string{
+	config.SessionAffinityHeaderPrefix + "X-Session-Id",
+	config.SessionAffinityClaudeUserID,
+	config.SessionAffinityPromptCacheKey,
+	config.SessionAffinityPromptHash,
+}
+
+// attachSessionAffinity records the conversation identity of the request in meta so the
+// conductor can route its turns to the same credential.
+func (h *BaseAPIHandler) attachSessionAffinity(ctx context.Context, meta map[string]any, handlerType string, rawJSON []byte, alt string) {
+	if key := h.sessionAffinityKey(ctx, handlerType, rawJSON, alt); key != "" {
+		meta[coreexecutor.SessionAffinityMetadataKey] = key
+	}
+}
+
+// sessionAffinityKey returns the hashed identity of the conversation the request belongs to,
+// read from the first configured source present, or "" when affinity does not apply.
+func (h *BaseAPIHandler) sessionAffinityKey(ctx context.Context, handlerType string, rawJSON []byte, alt string) string {
+	if h == nil || h.Cfg == nil || !h.Cfg.SessionAffinity.Enable || alt == coreexecutor.AltEmbeddings {
+		return ""
+	}
+	sources := h.Cfg.SessionAffinity.Sources
+	if len(sources) == 0 {
+		sources = defaultSessionAffinitySources
+	}
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		var identity string
+		switch {
+		case strings.HasPrefix(strings.ToLower(source), config.SessionAffinityHeaderPrefix):
+			identity = sessionHeader(ctx, strings.TrimSpace(source[len(config.SessionAffinityHeaderPrefix):]))
+		case strings.EqualFold(source, config.SessionAffinityClaudeUserID):
+			if handlerType == "claude" {
+				identity = gjson.GetBytes(rawJSON, "metadata.user_id").String()
+			}
+		case strings.EqualFold(source, config.SessionAffinityPromptCacheKey):
+			identity = gjson.GetBytes(rawJSON, "prompt_cache_key").String()
+		case strings.EqualFold(source, config.SessionAffinityPromptHash):
+			identity = conversationOpening(handlerType, rawJSON)
+		}
+		if identity = strings.TrimSpace(identity); identity != "" {
+			sum := sha256.Sum256([]byte(identity))
+			return strings.ToLower(source) + ":" + hex.EncodeToString(sum[:16])
+		}
+	}
+	return ""
+}
+
+func sessionHeader(ctx context.Context, name string) string {
+	if ctx == nil || name == "" {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return ""
+	}
+	return ginCtx.GetHeader(name)
+}
+
+// conversationOpening returns the system prompt and the first user message of the request,
+// which stay the same across the turns of a conversation. It returns "" without a user message.
+func conversationOpening(handlerType string, rawJSON []byte) string {
+	var system []string
+	switch handlerType {
+	case "claude":
+		system = append(system, gjson.GetBytes(rawJSON, "system").Raw)
+	case "openai-response", "codex":
+		system = append(system, gjson.GetBytes(rawJSON, "instructions").Raw)
+		if input := gjson.GetBytes(rawJSON, "input"); input.Type == gjson.String {
+			return strings.Join(append(system, input.Raw), "\x00")
+		}
+	case "gemini":
+		system = append(system, gjson.GetBytes(rawJSON, "systemInstruction").Raw, gjson.GetBytes(rawJSON, "system_instruction").Raw)
+	case "gemini-cli":
+		system = append(system, gjson.GetBytes(rawJSON, "request.systemInstruction").Raw, gjson.GetBytes(rawJSON, "request.system_instruction").Raw)
+	}
+	contentField := "content"
+	if handlerType == "gemini" || handlerType == "gemini-cli" {
+		contentField = "parts"
+	}
+	for _, item := range gjson.GetBytes(rawJSON, conversationPath(handlerType)).Array() {
+		switch role := item.Get("role").String(); role {
+		case "system", "developer":
+			system = append(system, item.Get(contentField).Raw)
+		case "user", "":
+			if role == "" && contentField != "parts" {
+				continue
+			}
+			return strings.Join(append(system, item.Get(contentField).Raw), "\x00")
+		}
+	}
+	return ""
+}
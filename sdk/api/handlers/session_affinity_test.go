@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestSessionAffinityKey_Sources(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &sdkconfig.SDKConfig{SessionAffinity: sdkconfig.SessionAffinityConfig{Enable: true}}}
+
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest("POST", "/v1/messages", nil)
+	ginCtx.Request.Header.Set("X-Session-Id", "conv-1")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	claude := []byte(`{"metadata":{"user_id":"user_1"},"messages":[{"role":"user","content":"hi"}]}`)
+	if key := h.sessionAffinityKey(ctx, "claude", claude, ""); !strings.HasPrefix(key, "header:x-session-id:") {
+		t.Fatalf("key = %q, want the session header to win", key)
+	}
+	if key := h.sessionAffinityKey(context.Background(), "claude", claude, ""); !strings.HasPrefix(key, "claude-user-id:") {
+		t.Fatalf("key = %q, want claude metadata.user_id", key)
+	}
+
+	turn1 := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"plan a trip"}]}`)
+	turn2 := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"plan a trip"},{"role":"assistant","content":"where to?"},{"role":"user","content":"Rome"}]}`)
+	other := []byte(`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"write a poem"}]}`)
+	key1 := h.sessionAffinityKey(context.Background(), "openai", turn1, "")
+	if !strings.HasPrefix(key1, "prompt-hash:") || key1 != h.sessionAffinityKey(context.Background(), "openai", turn2, "") {
+		t.Fatalf("turns of one conversation got different keys")
+	}
+	if key1 == h.sessionAffinityKey(context.Background(), "openai", other, "") {
+		t.Fatalf("different conversations share a key")
+	}
+
+	h.Cfg.SessionAffinity.Sources = []string{"prompt-cache-key"}
+	if key := h.sessionAffinityKey(context.Background(), "openai", turn1, ""); key != "" {
+		t.Fatalf("key = %q, want none without prompt_cache_key", key)
+	}
+	h.Cfg.SessionAffinity.Enable = false
+	if key := h.sessionAffinityKey(ctx, "claude", claude, ""); key != "" {
+		t.Fatalf("key = %q while disabled", key)
+	}
+}
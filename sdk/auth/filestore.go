@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -66,8 +67,8 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
-			return "", err
+		if err = authcrypt.WriteStorage(path, auth.Storage, auth.Provider); err != nil {
+			return "", fmt.Errorf("auth filestore: write token file failed: %w", err)
 		}
 	case auth.Metadata != nil:
 		auth.Metadata["disabled"] = auth.Disabled
@@ -75,15 +76,21 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 		if errMarshal != nil {
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
+		sealed, errSeal := authcrypt.Seal(raw)
+		if errSeal != nil {
+			return "", fmt.Errorf("auth filestore: encrypt metadata failed: %w", errSeal)
+		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
+			if authcrypt.Current(existing) {
+				if plain, errOpen := authcrypt.Open(existing); errOpen == nil && jsonEqual(plain, raw) {
+					return path, nil
+				}
 			}
 			file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600)
 			if errOpen != nil {
 				return "", fmt.Errorf("auth filestore: open existing failed: %w", errOpen)
 			}
-			if _, errWrite := file.Write(raw); errWrite != nil {
+			if _, errWrite := file.Write(sealed); errWrite != nil {
 				_ = file.Close()
 				return "", fmt.Errorf("auth filestore: write existing failed: %w", errWrite)
 			}
@@ -94,7 +101,7 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 		} else if !os.IsNotExist(errRead) {
 			return "", fmt.Errorf("auth filestore: read existing failed: %w", errRead)
 		}
-		if errWrite := os.WriteFile(path, raw, 0o600); errWrite != nil {
+		if errWrite := os.WriteFile(path, sealed, 0o600); errWrite != nil {
 			return "", fmt.Errorf("auth filestore: write file failed: %w", errWrite)
 		}
 	default:
@@ -161,6 +168,32 @@ func (s *FileTokenStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// Read implements cliproxyauth.AuthReader by reading the auth file back from disk.
+func (s *FileTokenStore) Read(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	path, err := s.resolveAuthPath(auth)
+	if err != nil {
+		return nil, err
+	}
+	if _, errStat := os.Stat(path); os.IsNotExist(errStat) {
+		return nil, nil
+	}
+	stored, err := s.readAuthFile(path, s.baseDirSnapshot())
+	if err != nil {
+		return nil, fmt.Errorf("auth filestore: %w", err)
+	}
+	return stored, nil
+}
+
+// LockRefresh implements cliproxyauth.RefreshLocker with a lock file next to the auth file, so
+// processes sharing the auth directory refresh each auth one at a time.
+func (s *FileTokenStore) LockRefresh(ctx context.Context, auth *cliproxyauth.Auth) (func(), error) {
+	path, err := s.resolveAuthPath(auth)
+	if err != nil {
+		return nil, err
+	}
+	return lockFile(ctx, path+".lock")
+}
+
 func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 	if strings.ContainsRune(id, os.PathSeparator) || filepath.IsAbs(id) {
 		return id, nil
@@ -173,7 +206,7 @@ func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -209,9 +242,11 @@ func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 				if errFetch == nil && strings.TrimSpace(fetchedProjectID) != "" {
 					metadata["project_id"] = strings.TrimSpace(fetchedProjectID)
 					if raw, errMarshal := json.Marshal(metadata); errMarshal == nil {
-						if file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600); errOpen == nil {
-							_, _ = file.Write(raw)
-							_ = file.Close()
+						if sealed, errSeal := authcrypt.Seal(raw); errSeal == nil {
+							if file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600); errOpen == nil {
+								_, _ = file.Write(sealed)
+								_ = file.Close()
+							}
 						}
 					}
 				}
@@ -1,6 +1,18 @@
 package auth
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
 
 func TestExtractAccessToken(t *testing.T) {
 	t.Parallel()
@@ -78,3 +90,161 @@ func TestExtractAccessToken(t *testing.T) {
 		})
 	}
 }
+
+func TestFileTokenStoreEncryptsOnWrite(t *testing.T) {
+	t.Setenv("FILESTORE_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	t.Cleanup(func() { _ = authcrypt.Configure(config.AuthEncryptionConfig{}) })
+	dir := t.TempDir()
+	path := filepath.Join(dir, "claude-user.json")
+	if err := os.WriteFile(path, []byte(`{"type":"claude","email":"user@example.com","refresh_token":"rt-secret"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := authcrypt.Configure(config.AuthEncryptionConfig{Enable: true, Keys: []config.AuthEncryptionKey{{ID: "k1", Env: "FILESTORE_TEST_KEY"}}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	store := NewFileTokenStore()
+	store.SetBaseDir(dir)
+	auths, err := store.List(context.Background())
+	if err != nil || len(auths) != 1 {
+		t.Fatalf("List = %v, %v", auths, err)
+	}
+	// Unchanged content still migrates the plaintext file.
+	if _, err = store.Save(context.Background(), auths[0]); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	stored, _ := os.ReadFile(path)
+	if !authcrypt.IsSealed(stored) || bytes.Contains(stored, []byte("rt-secret")) {
+		t.Fatalf("stored file not encrypted: %s", stored)
+	}
+	auths, err = store.List(context.Background())
+	if err != nil || len(auths) != 1 || auths[0].Provider != "claude" || auths[0].Metadata["refresh_token"] != "rt-secret" {
+		t.Fatalf("List after encryption = %+v, %v", auths, err)
+	}
+}
+
+// plaintextStorage fails the test when asked to write itself, since that would put the
+// plaintext token on disk before it is sealed.
+type plaintextStorage struct {
+	t            *testing.T
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *plaintextStorage) SaveTokenToFile(string) error {
+	s.t.Fatalf("SaveTokenToFile called; token storages must be sealed in memory")
+	return nil
+}
+
+func TestFileTokenStoreSealsStorageInMemory(t *testing.T) {
+	t.Setenv("FILESTORE_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	t.Cleanup(func() { _ = authcrypt.Configure(config.AuthEncryptionConfig{}) })
+	if err := authcrypt.Configure(config.AuthEncryptionConfig{Enable: true, Keys: []config.AuthEncryptionKey{{ID: "k1", Env: "FILESTORE_TEST_KEY"}}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	dir := t.TempDir()
+	store := NewFileTokenStore()
+	store.SetBaseDir(dir)
+	auth := &cliproxyauth.Auth{
+		ID:       "codex-user.json",
+		Provider: "codex",
+		Storage:  &plaintextStorage{t: t, RefreshToken: "rt-secret"},
+	}
+	path, err := store.Save(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("auth dir holds %d entries, want only the auth file", len(entries))
+	}
+	stored, _ := os.ReadFile(path)
+	if !authcrypt.IsSealed(stored) || bytes.Contains(stored, []byte("rt-secret")) {
+		t.Fatalf("stored file not encrypted: %s", stored)
+	}
+	auths, err := store.List(context.Background())
+	if err != nil || len(auths) != 1 || auths[0].Provider != "codex" || auths[0].Metadata["refresh_token"] != "rt-secret" {
+		t.Fatalf("List = %+v, %v", auths, err)
+	}
+}
+
+func TestFileTokenStoreRefreshLockAndRead(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codex-user.json")
+	if err := os.WriteFile(path, []byte(`{"type":"codex","refresh_token":"rt-1"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	first, second := NewFileTokenStore(), NewFileTokenStore()
+	first.SetBaseDir(dir)
+	second.SetBaseDir(dir)
+	auths, err := first.List(ctx)
+	if err != nil || len(auths) != 1 {
+		t.Fatalf("List = %v, %v", auths, err)
+	}
+	auth := auths[0]
+
+	unlock, err := first.LockRefresh(ctx, auth)
+	if err != nil {
+		t.Fatalf("LockRefresh: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	if _, err = second.LockRefresh(waitCtx, auth); err == nil {
+		t.Fatal("second store took a refresh lock the first one holds")
+	}
+	if err = os.WriteFile(path, []byte(`{"type":"codex","refresh_token":"rt-2"}`), 0o600); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	unlock()
+	unlock, err = second.LockRefresh(ctx, auth)
+	if err != nil {
+		t.Fatalf("LockRefresh after release: %v", err)
+	}
+	defer unlock()
+
+	stored, err := second.Read(ctx, auth)
+	if err != nil || stored == nil || stored.Metadata["refresh_token"] != "rt-2" {
+		t.Fatalf("Read = %+v, %v; want the rotated token", stored, err)
+	}
+	if err = os.Remove(path); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if stored, err = second.Read(ctx, auth); err != nil || stored != nil {
+		t.Fatalf("Read of a removed auth = %+v, %v", stored, err)
+	}
+}
+
+func TestLockFileStaleTakeoverKeepsSuccessorLock(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codex-user.json.lock")
+	releaseFirst, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("lockFile: %v", err)
+	}
+	stale := time.Now().Add(-2 * lockFileStale)
+	if err = os.Chtimes(path, stale, stale); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	releaseSecond, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("lockFile over a stale lock: %v", err)
+	}
+
+	// The first holder lost its lock as stale; releasing it must leave the second one's lock.
+	releaseFirst()
+	if _, err = os.Stat(path); err != nil {
+		t.Fatalf("stale holder removed its successor's lock: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 3*lockFilePoll)
+	defer cancel()
+	if _, err = lockFile(waitCtx, path); err == nil {
+		t.Fatal("lock taken while held")
+	}
+
+	releaseSecond()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("lock dir holds %d entries after release, want none", len(entries))
+	}
+}
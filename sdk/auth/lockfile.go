@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockFilePoll is how often a held lock file is checked for release.
+	lockFilePoll = 100 * time.Millisecond
+	// lockFileStale is the age past which a lock file is taken to belong to a process that
+	// died holding it. Refreshes finish well within it.
+	lockFileStale = 5 * time.Minute
+)
+
+// lockFile takes the lock file at path, waiting while another process holds it, and returns
+// the function that releases it. The lock is the existence of the file, which works on every
+// platform and on shared volumes. The file holds a token unique to this holder, so a holder
+// whose lock was taken over as stale does not remove its successor's lock on release.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("auth filestore: create lock dir failed: %w", err)
+	}
+	token, err := lockToken()
+	if err != nil {
+		return nil, fmt.Errorf("auth filestore: create lock token failed: %w", err)
+	}
+	for {
+		file, errOpen := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if errOpen == nil {
+			_, errWrite := file.Write(token)
+			if errClose := file.Close(); errWrite == nil {
+				errWrite = errClose
+			}
+			if errWrite != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("auth filestore: write lock file failed: %w", errWrite)
+			}
+			return func() { removeLockFile(path, token) }, nil
+		}
+		if !errors.Is(errOpen, fs.ErrExist) {
+			return nil, fmt.Errorf("auth filestore: create lock file failed: %w", errOpen)
+		}
+		if info, errStat := os.Stat(path); errStat == nil && time.Since(info.ModTime()) > lockFileStale {
+			if holder, errRead := os.ReadFile(path); errRead == nil {
+				removeLockFile(path, holder)
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("auth filestore: wait for lock %s: %w", filepath.Base(path), ctx.Err())
+		case <-time.After(lockFilePoll):
+		}
+	}
+}
+
+// lockToken returns the content identifying one lock holder: the process ID, which helps
+// operators find the holder, and a random suffix that tells holders of the same process apart.
+func lockToken() ([]byte, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, "%d-%s\n", os.Getpid(), hex.EncodeToString(suffix)), nil
+}
+
+// removeLockFile removes the lock file at path if it still holds holder. The file is first
+// renamed to a name unique to this call, which is atomic, so of several processes removing the
+// same stale lock only one moves it. When the moved file holds something else, another process
+// took the lock in between; its file is linked back into place.
+func removeLockFile(path string, holder []byte) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return
+	}
+	moved := path + "." + hex.EncodeToString(suffix) + ".removed"
+	if err := os.Rename(path, moved); err != nil {
+		return
+	}
+	if content, err := os.ReadFile(moved); err != nil || !bytes.Equal(content, holder) {
+		_ = os.Link(moved, path)
+	}
+	_ = os.Remove(moved)
+}
@@ -0,0 +1,345 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultBreakerFailureThreshold opens a breaker when circuit-breaker.failure-threshold is unset.
+	defaultBreakerFailureThreshold = 5
+	// defaultBreakerOpenDuration keeps a breaker open when circuit-breaker.open-seconds is unset.
+	defaultBreakerOpenDuration = 30 * time.Second
+	// defaultBreakerHalfOpenProbes caps probes when circuit-breaker.half-open-probes is unset.
+	defaultBreakerHalfOpenProbes = 1
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen skips every credential behind the base URL.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a limited number of probe requests through.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus describes the circuit breaker of one provider and base URL.
+type BreakerStatus struct {
+	Provider            string       `json:"provider"`
+	BaseURL             string       `json:"base_url"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Trips               int          `json:"trips"`
+	OpenedAt            time.Time    `json:"opened_at,omitzero"`
+	RetryAt             time.Time    `json:"retry_at,omitzero"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// BreakerHook is implemented by hooks that want to observe circuit breaker state changes.
+type BreakerHook interface {
+	// OnBreakerStateChange fires when a breaker opens or closes.
+	OnBreakerStateChange(ctx context.Context, status BreakerStatus)
+}
+
+// OnBreakerStateChange forwards the change to the hooks implementing BreakerHook.
+func (hooks MultiHook) OnBreakerStateChange(ctx context.Context, status BreakerStatus) {
+	for _, hook := range hooks {
+		if observer, ok := hook.(BreakerHook); ok && observer != nil {
+			observer.OnBreakerStateChange(ctx, status)
+		}
+	}
+}
+
+// breakerSettings holds the circuit-breaker configuration with defaults applied.
+type breakerSettings struct {
+	threshold int
+	open      time.Duration
+	probes    int
+}
+
+// circuitBreakers tracks one breaker per openai-compatibility provider and base URL.
+type circuitBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+type circuitBreaker struct {
+	provider  string
+	baseURL   string
+	state     BreakerState
+	failures  int
+	trips     int
+	openedAt  time.Time
+	lastError string
+	// probes counts the probe requests admitted while half-open; they are forgotten after
+	// probeDeadline so a probe that never reports back cannot wedge the breaker.
+	probes        int
+	probeDeadline time.Time
+}
+
+// newBreakerOpenError reports that every credential for model sits behind an open breaker.
+func newBreakerOpenError(model string, retryIn time.Duration) *Error {
+	return &Error{
+		Code:       "circuit_open",
+		Message:    fmt.Sprintf("upstreams for model %s are failing; retry in %s", model, retryIn.Round(time.Second)),
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
+// breakerSettings returns the circuit-breaker configuration, reporting false when it is disabled.
+func (m *Manager) breakerSettings() (breakerSettings, bool) {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || !cfg.CircuitBreaker.Enable {
+		return breakerSettings{}, false
+	}
+	settings := breakerSettings{
+		threshold: cfg.CircuitBreaker.FailureThreshold,
+		open:      time.Duration(cfg.CircuitBreaker.OpenSeconds) * time.Second,
+		probes:    cfg.CircuitBreaker.HalfOpenProbes,
+	}
+	if settings.threshold <= 0 {
+		settings.threshold = defaultBreakerFailureThreshold
+	}
+	if settings.open <= 0 {
+		settings.open = defaultBreakerOpenDuration
+	}
+	if settings.probes <= 0 {
+		settings.probes = defaultBreakerHalfOpenProbes
+	}
+	return settings, true
+}
+
+// breakerKey returns the breaker guarding auth, or "" for auths that are not
+// openai-compatibility credentials with a base URL.
+func breakerKey(auth *Auth) string {
+	if auth == nil || auth.Attributes == nil || strings.TrimSpace(auth.Attributes["compat_name"]) == "" {
+		return ""
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(auth.Attributes["base_url"]), "/")
+	if baseURL == "" {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(auth.Provider)) + "|" + baseURL
+}
+
+// breakerGoverns reports whether connection errors and 5xx responses of auth are handled by a
+// breaker rather than by cooling down the credential itself.
+func (m *Manager) breakerGoverns(auth *Auth) bool {
+	if breakerKey(auth) == "" {
+		return false
+	}
+	_, enabled := m.breakerSettings()
+	return enabled
+}
+
+// isBreakerFailure reports whether a result means the upstream itself is failing: a 5xx
+// response or an error without any HTTP status, such as a refused connection.
+func isBreakerFailure(result Result) bool {
+	if result.Success {
+		return false
+	}
+	status := statusCodeFromResult(result.Error)
+	return status == 0 || status >= http.StatusInternalServerError
+}
+
+// admitsBreaker reports whether the breaker guarding auth lets a request through, and when it
+// does not, how long until it admits one again.
+func (m *Manager) admitsBreaker(auth *Auth, now time.Time) (bool, time.Duration) {
+	key := breakerKey(auth)
+	if key == "" {
+		return true, 0
+	}
+	settings, enabled := m.breakerSettings()
+	if !enabled {
+		return true, 0
+	}
+	return m.breakers.admits(key, settings, now)
+}
+
+// acquireBreakerProbe takes a probe slot when the breaker guarding auth is half-open.
+func (m *Manager) acquireBreakerProbe(auth *Auth, now time.Time) bool {
+	key := breakerKey(auth)
+	if key == "" {
+		return true
+	}
+	settings, enabled := m.breakerSettings()
+	if !enabled {
+		return true
+	}
+	return m.breakers.acquireProbe(key, settings, now)
+}
+
+// recordBreakerResult feeds a result of auth into its breaker. Failures of requests the client
+// cancelled say nothing about the upstream and are ignored.
+func (m *Manager) recordBreakerResult(ctx context.Context, auth *Auth, result Result) {
+	key := breakerKey(auth)
+	if key == "" || (!result.Success && ctx != nil && ctx.Err() != nil) {
+		return
+	}
+	settings, enabled := m.breakerSettings()
+	if !enabled {
+		return
+	}
+	message := ""
+	if result.Error != nil {
+		message = result.Error.Message
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(auth.Attributes["base_url"]), "/")
+	if changed := m.breakers.record(key, auth.Provider, baseURL, isBreakerFailure(result), message, settings, time.Now()); changed != nil {
+		m.emitBreakerChange(ctx, *changed)
+	}
+}
+
+func (m *Manager) emitBreakerChange(ctx context.Context, status BreakerStatus) {
+	switch status.State {
+	case BreakerOpen:
+		log.Warnf("circuit breaker opened for %s (%s) after %d consecutive failures: %s", status.Provider, status.BaseURL, status.ConsecutiveFailures, status.LastError)
+	case BreakerClosed:
+		log.Infof("circuit breaker closed for %s (%s)", status.Provider, status.BaseURL)
+	}
+	if observer, ok := m.hook.(BreakerHook); ok {
+		observer.OnBreakerStateChange(ctx, status)
+	}
+}
+
+// BreakerStatuses returns the state of every circuit breaker, sorted by provider and base URL.
+func (m *Manager) BreakerStatuses() []BreakerStatus {
+	if m == nil {
+		return nil
+	}
+	settings, _ := m.breakerSettings()
+	return m.breakers.statuses(settings)
+}
+
+// admits moves an open breaker whose open period is over to half-open, which is only logged:
+// hooks hear about a breaker when it opens and when a probe closes it.
+func (b *circuitBreakers) admits(key string, settings breakerSettings, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker := b.breakers[key]
+	if breaker == nil {
+		return true, 0
+	}
+	if breaker.state == BreakerOpen {
+		retryAt := breaker.openedAt.Add(settings.open)
+		if now.Before(retryAt) {
+			return false, retryAt.Sub(now)
+		}
+		breaker.state = BreakerHalfOpen
+		breaker.probes = 0
+		log.Infof("circuit breaker half-open for %s (%s); probing", breaker.provider, breaker.baseURL)
+	}
+	if breaker.state == BreakerHalfOpen {
+		if now.After(breaker.probeDeadline) {
+			breaker.probes = 0
+		}
+		if breaker.probes >= settings.probes {
+			return false, breaker.probeDeadline.Sub(now)
+		}
+	}
+	return true, 0
+}
+
+func (b *circuitBreakers) acquireProbe(key string, settings breakerSettings, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker := b.breakers[key]
+	if breaker == nil || breaker.state != BreakerHalfOpen {
+		return true
+	}
+	if now.After(breaker.probeDeadline) {
+		breaker.probes = 0
+	}
+	if breaker.probes >= settings.probes {
+		return false
+	}
+	breaker.probes++
+	breaker.probeDeadline = now.Add(settings.open)
+	return true
+}
+
+// record applies a result to the breaker and returns its status when its state changed.
+func (b *circuitBreakers) record(key, provider, baseURL string, failed bool, message string, settings breakerSettings, now time.Time) *BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker := b.breakers[key]
+	if breaker == nil {
+		if !failed {
+			return nil
+		}
+		if b.breakers == nil {
+			b.breakers = make(map[string]*circuitBreaker)
+		}
+		breaker = &circuitBreaker{provider: provider, baseURL: baseURL, state: BreakerClosed}
+		b.breakers[key] = breaker
+	}
+	if !failed {
+		breaker.failures = 0
+		if breaker.state == BreakerClosed {
+			return nil
+		}
+		breaker.state = BreakerClosed
+		breaker.probes = 0
+		status := breaker.status(settings)
+		return &status
+	}
+	breaker.failures++
+	breaker.lastError = message
+	switch breaker.state {
+	case BreakerOpen:
+		return nil
+	case BreakerClosed:
+		if breaker.failures < settings.threshold {
+			return nil
+		}
+	}
+	breaker.state = BreakerOpen
+	breaker.openedAt = now
+	breaker.probes = 0
+	breaker.trips++
+	status := breaker.status(settings)
+	return &status
+}
+
+func (b *circuitBreakers) statuses(settings breakerSettings) []BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]BreakerStatus, 0, len(b.breakers))
+	for _, breaker := range b.breakers {
+		out = append(out, breaker.status(settings))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].BaseURL < out[j].BaseURL
+	})
+	return out
+}
+
+func (c *circuitBreaker) status(settings breakerSettings) BreakerStatus {
+	status := BreakerStatus{
+		Provider:            c.provider,
+		BaseURL:             c.baseURL,
+		State:               c.state,
+		ConsecutiveFailures: c.failures,
+		Trips:               c.trips,
+		LastError:           c.lastError,
+	}
+	if c.state != BreakerClosed {
+		status.OpenedAt = c.openedAt
+		status.RetryAt = c.openedAt.Add(settings.open)
+	}
+	return status
+}
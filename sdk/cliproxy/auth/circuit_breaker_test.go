@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type breakerRecorder struct {
+	NoopHook
+	states []BreakerState
+}
+
+func (r *breakerRecorder) OnBreakerStateChange(_ context.Context, status BreakerStatus) {
+	r.states = append(r.states, status.State)
+}
+
+func TestManagerCircuitBreaker_TripsFailsFastAndRecovers(t *testing.T) {
+	recorder := &breakerRecorder{}
+	m := NewManager(nil, nil, MultiHook{recorder})
+	m.RegisterExecutor(&queueTestExecutor{})
+	m.SetConfig(&internalconfig.Config{CircuitBreaker: internalconfig.CircuitBreakerConfig{Enable: true, FailureThreshold: 2, OpenSeconds: 30}})
+	for _, id := range []string{"breaker-a", "breaker-b"} {
+		attrs := map[string]string{"compat_name": "queue-test", "base_url": "https://upstream.example/v1/"}
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "queue-test", Status: StatusActive, Attributes: attrs}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, "queue-test", []*registry.ModelInfo{{ID: "breaker-model"}})
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+	}
+	pick := func() (*Auth, error) {
+		auth, _, _, err := m.pickNextMixed(context.Background(), []string{"queue-test"}, "breaker-model", cliproxyexecutor.Options{}, map[string]struct{}{})
+		if auth != nil {
+			m.releaseCredential(auth.ID, "breaker-model")
+		}
+		return auth, err
+	}
+
+	fail := Result{Provider: "queue-test", Model: "breaker-model", Error: &Error{Message: "bad gateway", HTTPStatus: http.StatusBadGateway}}
+	fail.AuthID = "breaker-a"
+	m.MarkResult(context.Background(), fail)
+	if _, err := pick(); err != nil {
+		t.Fatalf("pick below threshold: %v", err)
+	}
+	if auth, _ := m.GetByID("breaker-a"); !auth.ModelStates["breaker-model"].NextRetryAfter.IsZero() {
+		t.Fatalf("5xx cooled down a breaker-governed credential")
+	}
+	fail.AuthID = "breaker-b"
+	m.MarkResult(context.Background(), fail)
+
+	if _, err := pick(); statusCodeFromError(err) != http.StatusServiceUnavailable {
+		t.Fatalf("pick with open breaker err = %v, want 503", err)
+	}
+	statuses := m.BreakerStatuses()
+	if len(statuses) != 1 || statuses[0].State != BreakerOpen || statuses[0].BaseURL != "https://upstream.example/v1" {
+		t.Fatalf("statuses = %+v, want one open breaker", statuses)
+	}
+
+	// Let the open period pass: one probe goes through, the next request still fails fast.
+	m.breakers.mu.Lock()
+	for _, breaker := range m.breakers.breakers {
+		breaker.openedAt = time.Now().Add(-time.Minute)
+	}
+	m.breakers.mu.Unlock()
+	probe, err := pick()
+	if err != nil {
+		t.Fatalf("probe pick: %v", err)
+	}
+	if _, err := pick(); statusCodeFromError(err) != http.StatusServiceUnavailable {
+		t.Fatalf("pick beside the probe err = %v, want 503", err)
+	}
+	m.MarkResult(context.Background(), Result{AuthID: probe.ID, Provider: "queue-test", Model: "breaker-model", Success: true})
+	if _, err := pick(); err != nil {
+		t.Fatalf("pick after recovery: %v", err)
+	}
+	if len(recorder.states) != 2 || recorder.states[0] != BreakerOpen || recorder.states[1] != BreakerClosed {
+		t.Fatalf("hook states = %v, want open then closed", recorder.states)
+	}
+}
+
+func TestCircuitBreakers_HalfOpenFailureReopens(t *testing.T) {
+	var b circuitBreakers
+	settings := breakerSettings{threshold: 1, open: 10 * time.Second, probes: 1}
+	now := time.Now()
+	if changed := b.record("p|u", "p", "u", true, "refused", settings, now); changed == nil || changed.State != BreakerOpen {
+		t.Fatalf("first failure = %+v, want open", changed)
+	}
+	if ok, wait := b.admits("p|u", settings, now.Add(4*time.Second)); ok || wait != 6*time.Second {
+		t.Fatalf("admits while open = %t, %s", ok, wait)
+	}
+	later := now.Add(11 * time.Second)
+	if ok, _ := b.admits("p|u", settings, later); !ok || !b.acquireProbe("p|u", settings, later) {
+		t.Fatalf("half-open breaker rejected its probe")
+	}
+	if changed := b.record("p|u", "p", "u", true, "refused", settings, later); changed == nil || changed.State != BreakerOpen {
+		t.Fatalf("probe failure = %+v, want open", changed)
+	}
+	if statuses := b.statuses(settings); statuses[0].Trips != 2 || !statuses[0].RetryAt.Equal(later.Add(10*time.Second)) {
+		t.Fatalf("status = %+v", statuses[0])
+	}
+	if !isBreakerFailure(Result{Error: &Error{Message: "dial tcp: connection refused"}}) || isBreakerFailure(Result{Error: &Error{HTTPStatus: http.StatusBadRequest}}) {
+		t.Fatalf("isBreakerFailure misclassified results")
+	}
+}
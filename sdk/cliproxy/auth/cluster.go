@@ -0,0 +1,412 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+// refreshLeasePrefix names the lease an instance holds while refreshing an auth.
+const refreshLeasePrefix = "refresh:"
+
+// ClusterState shares the runtime state of auths between instances serving the same
+// credentials, so a credential cooled down or disabled on one instance is skipped by all of
+// them, and elects a single refresher per credential through leases. Implementations must be
+// safe for concurrent use.
+//
+// Times in published states are expressed in the backend's clock: each instance measures the
+// offset of its own clock from Now and shifts times on the way out and back, so instances need
+// not keep their clocks in sync. The offset is measured to within half the round trip to the
+// backend, which bounds how far apart two near-simultaneous updates can be misordered.
+type ClusterState interface {
+	// Instance identifies this instance among those sharing the state.
+	Instance() string
+	// Now returns the current time of the backend's clock.
+	Now(ctx context.Context) (time.Time, error)
+	// Publish stores the latest state of each auth, replacing what this instance stored before.
+	Publish(ctx context.Context, states []SharedAuthState) error
+	// Changes returns the states published since cursor, which is 0 on the first call, and the
+	// cursor to pass next. States may be returned more than once.
+	Changes(ctx context.Context, cursor int64) ([]SharedAuthState, int64, error)
+	// AcquireLease takes or renews the named lease for ttl and reports whether this instance
+	// holds it.
+	AcquireLease(ctx context.Context, name string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives the named lease up if this instance holds it.
+	ReleaseLease(ctx context.Context, name string) error
+}
+
+// SharedAuthState is the runtime state of an auth as published to other instances.
+type SharedAuthState struct {
+	AuthID   string `json:"auth_id"`
+	Instance string `json:"instance"`
+	Disabled bool   `json:"disabled"`
+	// DisabledAt is when Disabled last changed, so a stale state cannot undo a newer toggle.
+	DisabledAt      time.Time              `json:"disabled_at,omitzero"`
+	Status          Status                 `json:"status"`
+	StatusMessage   string                 `json:"status_message,omitempty"`
+	Unavailable     bool                   `json:"unavailable"`
+	NextRetryAfter  time.Time              `json:"next_retry_after,omitzero"`
+	Quota           QuotaState             `json:"quota"`
+	LastError       *Error                 `json:"last_error,omitempty"`
+	ModelStates     map[string]*ModelState `json:"model_states,omitempty"`
+	LastRefreshedAt time.Time              `json:"last_refreshed_at,omitzero"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// clusterSync publishes local auth state changes and applies those of other instances.
+type clusterSync struct {
+	mu     sync.Mutex
+	state  ClusterState
+	lease  time.Duration
+	cursor int64
+	// offset is the backend clock minus the local clock.
+	offset time.Duration
+	cancel context.CancelFunc
+	// dirty holds the auths whose state changed since the last publish.
+	dirty map[string]struct{}
+	// disabledAt remembers when the disabled flag of each auth last changed.
+	disabledAt map[string]time.Time
+}
+
+// SetClusterState starts sharing auth state through state: local changes are published and
+// those of other instances applied every interval, and refreshes are guarded by leases held for
+// lease. A nil state stops sharing.
+func (m *Manager) SetClusterState(state ClusterState, interval, lease time.Duration) {
+	if m == nil {
+		return
+	}
+	c := &m.cluster
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.state = state
+	c.lease = lease
+	c.cursor = 0
+	c.offset = 0
+	c.dirty = nil
+	if state == nil {
+		c.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.syncCluster(ctx, state)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// markClusterDirty queues the state of auth id for publishing.
+func (m *Manager) markClusterDirty(id string) {
+	c := &m.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == nil {
+		return
+	}
+	if c.dirty == nil {
+		c.dirty = make(map[string]struct{})
+	}
+	c.dirty[id] = struct{}{}
+}
+
+// noteDisabledChange records that the disabled flag of auth id was toggled locally.
+func (m *Manager) noteDisabledChange(id string, now time.Time) {
+	c := &m.cluster
+	c.mu.Lock()
+	if c.disabledAt == nil {
+		c.disabledAt = make(map[string]time.Time)
+	}
+	c.disabledAt[id] = now
+	c.mu.Unlock()
+	m.markClusterDirty(id)
+}
+
+// syncCluster publishes the dirty auths, then applies the states published by other instances.
+func (m *Manager) syncCluster(ctx context.Context, state ClusterState) {
+	offset, err := measureClockOffset(ctx, state)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("cluster: failed to read the backend clock: %v", err)
+		}
+		return
+	}
+	c := &m.cluster
+	c.mu.Lock()
+	if c.state != state {
+		c.mu.Unlock()
+		return
+	}
+	c.offset = offset
+	dirty := c.dirty
+	c.dirty = nil
+	cursor := c.cursor
+	c.mu.Unlock()
+
+	if len(dirty) > 0 {
+		states := m.sharedStates(dirty, state.Instance(), offset)
+		if err := state.Publish(ctx, states); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("cluster: failed to publish state of %d auths: %v", len(states), err)
+			for id := range dirty {
+				m.markClusterDirty(id)
+			}
+		}
+	}
+
+	changes, next, err := state.Changes(ctx, cursor)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("cluster: failed to fetch auth state changes: %v", err)
+		}
+		return
+	}
+	c.mu.Lock()
+	if c.state != state {
+		c.mu.Unlock()
+		return
+	}
+	c.cursor = next
+	c.mu.Unlock()
+	for _, change := range changes {
+		if change.Instance == state.Instance() {
+			continue
+		}
+		m.applySharedState(ctx, shiftSharedState(change, -offset))
+	}
+}
+
+// measureClockOffset returns how far the backend clock is ahead of the local clock, taking the
+// local time halfway through the backend round trip.
+func measureClockOffset(ctx context.Context, state ClusterState) (time.Duration, error) {
+	before := time.Now()
+	backendNow, err := state.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+	after := time.Now()
+	return backendNow.Sub(before.Add(after.Sub(before) / 2)), nil
+}
+
+// shiftSharedState returns state with every time moved by offset, converting between the local
+// clock and the backend clock.
+func shiftSharedState(state SharedAuthState, offset time.Duration) SharedAuthState {
+	state.DisabledAt = shiftTime(state.DisabledAt, offset)
+	state.NextRetryAfter = shiftTime(state.NextRetryAfter, offset)
+	state.Quota.NextRecoverAt = shiftTime(state.Quota.NextRecoverAt, offset)
+	state.LastRefreshedAt = shiftTime(state.LastRefreshedAt, offset)
+	state.UpdatedAt = shiftTime(state.UpdatedAt, offset)
+	if len(state.ModelStates) > 0 {
+		models := make(map[string]*ModelState, len(state.ModelStates))
+		for model, modelState := range state.ModelStates {
+			if modelState == nil {
+				continue
+			}
+			shifted := modelState.Clone()
+			shifted.NextRetryAfter = shiftTime(shifted.NextRetryAfter, offset)
+			shifted.Quota.NextRecoverAt = shiftTime(shifted.Quota.NextRecoverAt, offset)
+			shifted.UpdatedAt = shiftTime(shifted.UpdatedAt, offset)
+			models[model] = shifted
+		}
+		state.ModelStates = models
+	}
+	return state
+}
+
+// shiftTime moves t by offset, leaving the zero time unset.
+func shiftTime(t time.Time, offset time.Duration) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.Add(offset)
+}
+
+// sharedStates snapshots the auths in ids for publishing, with times in the backend clock.
+func (m *Manager) sharedStates(ids map[string]struct{}, instance string, offset time.Duration) []SharedAuthState {
+	m.cluster.mu.Lock()
+	disabledAt := make(map[string]time.Time, len(ids))
+	for id := range ids {
+		disabledAt[id] = m.cluster.disabledAt[id]
+	}
+	m.cluster.mu.Unlock()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	states := make([]SharedAuthState, 0, len(ids))
+	for id := range ids {
+		auth := m.auths[id]
+		if auth == nil {
+			continue
+		}
+		clone := auth.Clone()
+		states = append(states, shiftSharedState(SharedAuthState{
+			AuthID:          id,
+			Instance:        instance,
+			Disabled:        clone.Disabled,
+			DisabledAt:      disabledAt[id],
+			Status:          clone.Status,
+			StatusMessage:   clone.StatusMessage,
+			Unavailable:     clone.Unavailable,
+			NextRetryAfter:  clone.NextRetryAfter,
+			Quota:           clone.Quota,
+			LastError:       cloneError(clone.LastError),
+			ModelStates:     clone.ModelStates,
+			LastRefreshedAt: clone.LastRefreshedAt,
+			UpdatedAt:       clone.UpdatedAt,
+		}, offset))
+	}
+	return states
+}
+
+// applySharedState merges the state another instance published, already shifted to the local
+// clock, into the local auth. Each model state, the disabled flag and the auth-level state are
+// taken when they are newer than the local ones; a newer refresh reloads the credentials the
+// refreshing instance stored.
+func (m *Manager) applySharedState(ctx context.Context, remote SharedAuthState) {
+	now := time.Now()
+	c := &m.cluster
+	c.mu.Lock()
+	localDisabledAt := c.disabledAt[remote.AuthID]
+	takeDisabled := remote.DisabledAt.After(localDisabledAt)
+	if takeDisabled {
+		if c.disabledAt == nil {
+			c.disabledAt = make(map[string]time.Time)
+		}
+		c.disabledAt[remote.AuthID] = remote.DisabledAt
+	}
+	c.mu.Unlock()
+
+	m.mu.Lock()
+	auth := m.auths[remote.AuthID]
+	if auth == nil {
+		m.mu.Unlock()
+		return
+	}
+	if remote.UpdatedAt.After(auth.UpdatedAt) {
+		auth.Status = remote.Status
+		auth.StatusMessage = remote.StatusMessage
+		auth.Unavailable = remote.Unavailable
+		auth.NextRetryAfter = remote.NextRetryAfter
+		auth.Quota = remote.Quota
+		auth.LastError = cloneError(remote.LastError)
+		auth.UpdatedAt = remote.UpdatedAt
+	}
+	if takeDisabled {
+		auth.Disabled = remote.Disabled
+		if auth.Disabled {
+			auth.Status = StatusDisabled
+		} else if auth.Status == StatusDisabled {
+			auth.Status = StatusActive
+		}
+	}
+	merged := make(map[string]*ModelState)
+	for model, state := range remote.ModelStates {
+		if state == nil {
+			continue
+		}
+		if local := auth.ModelStates[model]; local != nil && !state.UpdatedAt.After(local.UpdatedAt) {
+			continue
+		}
+		if auth.ModelStates == nil {
+			auth.ModelStates = make(map[string]*ModelState)
+		}
+		auth.ModelStates[model] = state.Clone()
+		merged[model] = state.Clone()
+	}
+	if len(merged) > 0 {
+		updateAggregatedAvailability(auth, now)
+	}
+	reload := remote.LastRefreshedAt.After(auth.LastRefreshedAt)
+	m.mu.Unlock()
+
+	for model, state := range merged {
+		syncRegistryModel(remote.AuthID, model, state, now)
+		if !state.Unavailable || !state.NextRetryAfter.After(now) {
+			m.notifyQueue(model)
+		}
+	}
+	if reload {
+		m.reloadRefreshedAuth(ctx, remote.AuthID, remote.LastRefreshedAt)
+	}
+}
+
+// syncRegistryModel mirrors a shared model state into the model registry the way MarkResult
+// does for local quota errors and successes.
+func syncRegistryModel(authID, model string, state *ModelState, now time.Time) {
+	reg := registry.GetGlobalRegistry()
+	if state.Unavailable && state.NextRetryAfter.After(now) {
+		if state.Quota.Exceeded {
+			reg.SetModelQuotaExceeded(authID, model)
+			reg.SuspendClientModel(authID, model, "quota")
+		}
+		return
+	}
+	reg.ClearModelQuotaExceeded(authID, model)
+	reg.ResumeClientModel(authID, model)
+}
+
+// reloadRefreshedAuth takes the credentials another instance stored when it refreshed auth id.
+func (m *Manager) reloadRefreshedAuth(ctx context.Context, id string, refreshedAt time.Time) {
+	stored, err := m.loadStoredAuth(ctx, id)
+	if err != nil {
+		log.Warnf("cluster: failed to reload %s after a remote refresh: %v", id, err)
+		return
+	}
+	if stored == nil {
+		return
+	}
+	m.mu.Lock()
+	if current := m.auths[id]; current != nil && refreshedAt.After(current.LastRefreshedAt) {
+		current.Metadata = stored.Clone().Metadata
+		current.Storage = nil
+		current.LastRefreshedAt = refreshedAt
+		current.NextRefreshAfter = stored.NextRefreshAfter
+	}
+	m.mu.Unlock()
+	log.Debugf("cluster: reloaded %s refreshed by another instance", id)
+}
+
+// acquireRefreshLease makes this instance the refresher of auth id. It reports false when
+// another instance holds the lease; without cluster state, or when the state backend is
+// unreachable, every instance refreshes on its own.
+func (m *Manager) acquireRefreshLease(ctx context.Context, id string) (func(), bool) {
+	c := &m.cluster
+	c.mu.Lock()
+	state, lease := c.state, c.lease
+	c.mu.Unlock()
+	if state == nil {
+		return func() {}, true
+	}
+	name := refreshLeasePrefix + id
+	held, err := state.AcquireLease(ctx, name, lease)
+	if err != nil {
+		log.Warnf("cluster: failed to acquire refresh lease for %s, refreshing locally: %v", id, err)
+		return func() {}, true
+	}
+	if !held {
+		log.Debugf("cluster: %s is being refreshed by another instance", id)
+		return nil, false
+	}
+	return func() {
+		if errRelease := state.ReleaseLease(context.Background(), name); errRelease != nil {
+			log.Debugf("cluster: failed to release refresh lease for %s: %v", id, errRelease)
+		}
+	}, true
+}
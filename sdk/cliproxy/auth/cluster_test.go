@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryCluster is a ClusterState shared by the managers of one test.
+type memoryCluster struct {
+	mu     sync.Mutex
+	seq    int64
+	states map[string]memoryClusterEntry
+	leases map[string]string
+}
+
+type memoryClusterEntry struct {
+	seq   int64
+	state SharedAuthState
+}
+
+type memoryClusterMember struct {
+	shared   *memoryCluster
+	instance string
+	// skew is how far the shared clock runs ahead of this member's clock.
+	skew time.Duration
+}
+
+func (c *memoryCluster) member(instance string) *memoryClusterMember {
+	return &memoryClusterMember{shared: c, instance: instance}
+}
+
+func (m *memoryClusterMember) Instance() string { return m.instance }
+
+func (m *memoryClusterMember) Now(context.Context) (time.Time, error) {
+	return time.Now().Add(m.skew), nil
+}
+
+func (m *memoryClusterMember) Publish(_ context.Context, states []SharedAuthState) error {
+	c := m.shared
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.states == nil {
+		c.states = make(map[string]memoryClusterEntry)
+	}
+	for _, state := range states {
+		c.seq++
+		state.Instance = m.instance
+		c.states[state.AuthID+"|"+m.instance] = memoryClusterEntry{seq: c.seq, state: state}
+	}
+	return nil
+}
+
+func (m *memoryClusterMember) Changes(_ context.Context, cursor int64) ([]SharedAuthState, int64, error) {
+	c := m.shared
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []SharedAuthState
+	for _, entry := range c.states {
+		if entry.seq > cursor {
+			out = append(out, entry.state)
+		}
+	}
+	return out, c.seq, nil
+}
+
+func (m *memoryClusterMember) AcquireLease(_ context.Context, name string, _ time.Duration) (bool, error) {
+	c := m.shared
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leases == nil {
+		c.leases = make(map[string]string)
+	}
+	if holder, ok := c.leases[name]; ok && holder != m.instance {
+		return false, nil
+	}
+	c.leases[name] = m.instance
+	return true, nil
+}
+
+func (m *memoryClusterMember) ReleaseLease(_ context.Context, name string) error {
+	c := m.shared
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leases[name] == m.instance {
+		delete(c.leases, name)
+	}
+	return nil
+}
+
+func TestManagerCluster_SharesCooldownsDisabledFlagsAndRefreshLeases(t *testing.T) {
+	ctx := context.Background()
+	shared := &memoryCluster{}
+	newMember := func(instance string) (*Manager, *memoryClusterMember) {
+		m := NewManager(nil, nil, nil)
+		m.RegisterExecutor(&queueTestExecutor{})
+		if _, err := m.Register(ctx, &Auth{ID: "cluster-auth", Provider: "queue-test", Status: StatusActive}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		member := shared.member(instance)
+		m.SetClusterState(member, time.Hour, time.Minute)
+		t.Cleanup(func() { m.SetClusterState(nil, 0, 0) })
+		return m, member
+	}
+	a, stateA := newMember("a")
+	b, stateB := newMember("b")
+
+	retryAfter := time.Minute
+	a.MarkResult(ctx, Result{AuthID: "cluster-auth", Provider: "queue-test", Model: "cluster-model", RetryAfter: &retryAfter, Error: &Error{Message: "rate limited", HTTPStatus: http.StatusTooManyRequests}})
+	a.syncCluster(ctx, stateA)
+	b.syncCluster(ctx, stateB)
+	auth, _ := b.GetByID("cluster-auth")
+	if blocked, reason, _ := isAuthBlockedForModel(auth, "cluster-model", time.Now()); !blocked || reason != blockReasonCooldown {
+		t.Fatalf("b blocked = %t (%v), want the cooldown published by a", blocked, reason)
+	}
+
+	auth.Disabled = true
+	auth.Status = StatusDisabled
+	if _, err := b.Update(ctx, auth); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	b.syncCluster(ctx, stateB)
+	// A later result on a publishes a state that predates the toggle; it must not re-enable b.
+	a.MarkResult(ctx, Result{AuthID: "cluster-auth", Provider: "queue-test", Model: "other-model", Error: &Error{Message: "bad request", HTTPStatus: http.StatusBadRequest}})
+	a.syncCluster(ctx, stateA)
+	b.syncCluster(ctx, stateB)
+	if auth, _ = a.GetByID("cluster-auth"); !auth.Disabled {
+		t.Fatal("a did not pick up the disabled flag set on b")
+	}
+	if auth, _ = b.GetByID("cluster-auth"); !auth.Disabled {
+		t.Fatal("a stale state re-enabled the auth on b")
+	}
+
+	if held, _ := stateA.AcquireLease(ctx, refreshLeasePrefix+"cluster-auth", time.Minute); !held {
+		t.Fatal("a could not take the refresh lease")
+	}
+	if _, ok := b.acquireRefreshLease(ctx, "cluster-auth"); ok {
+		t.Fatal("b refreshed an auth whose lease a holds")
+	}
+}
+
+func TestManagerCluster_TranslatesTimesThroughBackendClock(t *testing.T) {
+	ctx := context.Background()
+	shared := &memoryCluster{}
+	newMember := func(instance string, skew time.Duration) (*Manager, *memoryClusterMember) {
+		m := NewManager(nil, nil, nil)
+		m.RegisterExecutor(&queueTestExecutor{})
+		if _, err := m.Register(ctx, &Auth{ID: "skew-auth", Provider: "queue-test", Status: StatusActive}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		member := shared.member(instance)
+		member.skew = skew
+		m.SetClusterState(member, time.Hour, time.Minute)
+		t.Cleanup(func() { m.SetClusterState(nil, 0, 0) })
+		return m, member
+	}
+	// a's clock runs ten minutes behind the backend, b's is in sync with it.
+	a, stateA := newMember("a", 10*time.Minute)
+	b, stateB := newMember("b", 0)
+
+	retryAfter := time.Minute
+	a.MarkResult(ctx, Result{AuthID: "skew-auth", Provider: "queue-test", Model: "skew-model", RetryAfter: &retryAfter, Error: &Error{Message: "rate limited", HTTPStatus: http.StatusTooManyRequests}})
+	a.syncCluster(ctx, stateA)
+	b.syncCluster(ctx, stateB)
+
+	authA, _ := a.GetByID("skew-auth")
+	authB, _ := b.GetByID("skew-auth")
+	local, remote := authA.ModelStates["skew-model"], authB.ModelStates["skew-model"]
+	if local == nil || remote == nil {
+		t.Fatalf("model states = %+v / %+v, want the cooldown on both members", local, remote)
+	}
+	// The cooldown ends at the same backend instant, which is ten minutes later on b's clock.
+	if diff := remote.NextRetryAfter.Sub(local.NextRetryAfter) - 10*time.Minute; diff < -time.Second || diff > time.Second {
+		t.Fatalf("b cooldown ends %v after a's, want 10m", remote.NextRetryAfter.Sub(local.NextRetryAfter))
+	}
+}
@@ -19,9 +19,12 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ProviderExecutor defines the contract required by Manager to execute provider calls.
@@ -96,6 +99,13 @@ type Result struct {
 	RetryAfter *time.Duration
 	// Error describes the failure when Success is false.
 	Error *Error
+	// Attempt is the zero-based upstream attempt number within the originating request;
+	// values above zero indicate a retry or a failover to another auth.
+	Attempt int
+	// Latency measures the upstream call duration (the full stream for streaming requests).
+	Latency time.Duration
+	// FirstByte measures the time until the first stream chunk arrived (streaming only).
+	FirstByte time.Duration
 }
 
 // Selector chooses an auth candidate for execution.
@@ -125,6 +135,36 @@ func (NoopHook) OnAuthUpdated(context.Context, *Auth) {}
 // OnResult implements Hook.
 func (NoopHook) OnResult(context.Context, Result) {}
 
+// MultiHook fans lifecycle callbacks out to several hooks in order.
+type MultiHook []Hook
+
+// OnAuthRegistered implements Hook.
+func (hooks MultiHook) OnAuthRegistered(ctx context.Context, auth *Auth) {
+	for _, hook := range hooks {
+		if hook != nil {
+			hook.OnAuthRegistered(ctx, auth)
+		}
+	}
+}
+
+// OnAuthUpdated implements Hook.
+func (hooks MultiHook) OnAuthUpdated(ctx context.Context, auth *Auth) {
+	for _, hook := range hooks {
+		if hook != nil {
+			hook.OnAuthUpdated(ctx, auth)
+		}
+	}
+}
+
+// OnResult implements Hook.
+func (hooks MultiHook) OnResult(ctx context.Context, result Result) {
+	for _, hook := range hooks {
+		if hook != nil {
+			hook.OnResult(ctx, result)
+		}
+	}
+}
+
 // Manager orchestrates auth lifecycle, selection, execution, and persistence.
 type Manager struct {
 	store     Store
@@ -151,6 +191,24 @@ type Manager struct {
 	// It is initialized in NewManager; never Load() before first Store().
 	runtimeConfig atomic.Value
 
+	// queue parks requests while every credential for their model is cooling down.
+	queue requestQueue
+
+	// limiter tracks per-credential in-flight requests and rates against their limits.
+	limiter credentialLimiter
+
+	// affinity binds conversations to the auth that served them for sticky routing.
+	affinity sessionAffinity
+
+	// breakers fail fast past openai-compatibility base URLs that keep erroring.
+	breakers circuitBreakers
+
+	// cluster shares cooldown, quota and disabled state with other instances.
+	cluster clusterSync
+
+	// hedgesInFlight counts the hedge attempts currently running, bounded by routing.hedging.max-in-flight.
+	hedgesInFlight atomic.Int64
+
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
@@ -192,6 +250,16 @@ func (m *Manager) SetSelector(selector Selector) {
 	m.mu.Unlock()
 }
 
+// Selector returns the active credential selector.
+func (m *Manager) Selector() Selector {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.selector
+}
+
 // SetStore swaps the underlying persistence store.
 func (m *Manager) SetStore(store Store) {
 	m.mu.Lock()
@@ -455,13 +523,18 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		return nil, nil
 	}
 	m.mu.Lock()
-	if existing, ok := m.auths[auth.ID]; ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
+	existing, ok := m.auths[auth.ID]
+	if ok && existing != nil && !auth.indexAssigned && auth.Index == "" {
 		auth.Index = existing.Index
 		auth.indexAssigned = existing.indexAssigned
 	}
+	disabledChanged := ok && existing != nil && existing.Disabled != auth.Disabled
 	auth.EnsureIndex()
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
+	if disabledChanged {
+		m.noteDisabledChange(auth.ID, time.Now())
+	}
 	m.rebuildAPIKeyModelAliasFromRuntimeConfig()
 	_ = m.persist(ctx, auth)
 	m.hook.OnAuthUpdated(ctx, auth.Clone())
@@ -505,16 +578,30 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 
 	_, maxWait := m.retrySettings()
 
-	var lastErr error
+	var (
+		lastErr       error
+		queueDeadline time.Time
+		release       func()
+	)
+	attempts := 0
 	for attempt := 0; ; attempt++ {
-		resp, errExec := m.executeMixedOnce(ctx, normalized, req, opts)
+		resp, errExec := m.executeMixedOnce(ctx, normalized, req, opts, &attempts)
+		if release != nil {
+			release()
+			release = nil
+		}
 		if errExec == nil {
 			return resp, nil
 		}
 		lastErr = errExec
 		wait, shouldRetry := m.shouldRetryAfterError(errExec, attempt, normalized, req.Model, maxWait)
 		if !shouldRetry {
-			break
+			// Park in the model's queue while every credential is cooling down.
+			if release, errExec = m.waitForQueueTurn(ctx, normalized, req.Model, opts, errExec, &queueDeadline); errExec != nil {
+				lastErr = errExec
+				break
+			}
+			continue
 		}
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
@@ -536,16 +623,30 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 
 	_, maxWait := m.retrySettings()
 
-	var lastErr error
+	var (
+		lastErr       error
+		queueDeadline time.Time
+		release       func()
+	)
+	attempts := 0
 	for attempt := 0; ; attempt++ {
-		resp, errExec := m.executeCountMixedOnce(ctx, normalized, req, opts)
+		resp, errExec := m.executeCountMixedOnce(ctx, normalized, req, opts, &attempts)
+		if release != nil {
+			release()
+			release = nil
+		}
 		if errExec == nil {
 			return resp, nil
 		}
 		lastErr = errExec
 		wait, shouldRetry := m.shouldRetryAfterError(errExec, attempt, normalized, req.Model, maxWait)
 		if !shouldRetry {
-			break
+			// Park in the model's queue while every credential is cooling down.
+			if release, errExec = m.waitForQueueTurn(ctx, normalized, req.Model, opts, errExec, &queueDeadline); errExec != nil {
+				lastErr = errExec
+				break
+			}
+			continue
 		}
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
@@ -567,16 +668,30 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 
 	_, maxWait := m.retrySettings()
 
-	var lastErr error
+	var (
+		lastErr       error
+		queueDeadline time.Time
+		release       func()
+	)
+	attempts := 0
 	for attempt := 0; ; attempt++ {
-		result, errStream := m.executeStreamMixedOnce(ctx, normalized, req, opts)
+		result, errStream := m.executeStreamMixedOnce(ctx, normalized, req, opts, &attempts)
+		if release != nil {
+			release()
+			release = nil
+		}
 		if errStream == nil {
 			return result, nil
 		}
 		lastErr = errStream
 		wait, shouldRetry := m.shouldRetryAfterError(errStream, attempt, normalized, req.Model, maxWait)
 		if !shouldRetry {
-			break
+			// Park in the model's queue while every credential is cooling down.
+			if release, errStream = m.waitForQueueTurn(ctx, normalized, req.Model, opts, errStream, &queueDeadline); errStream != nil {
+				lastErr = errStream
+				break
+			}
+			continue
 		}
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return nil, errWait
@@ -588,7 +703,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
-func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *int) (cliproxyexecutor.Response, error) {
 	if len(providers) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
@@ -619,8 +734,13 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		attempt := nextAttempt(attempts)
+		execCtx, span := startAttemptSpan(execCtx, "auth.execute", auth, provider, execReq.Model, attempt)
+		started := time.Now()
 		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+		tracing.EndSpan(span, errExec)
+		m.releaseCredential(auth.ID, routeModel)
+		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil, Attempt: attempt, Latency: time.Since(started)}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
 				return cliproxyexecutor.Response{}, errCtx
@@ -644,7 +764,7 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 	}
 }
 
-func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *int) (cliproxyexecutor.Response, error) {
 	if len(providers) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
@@ -675,8 +795,13 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		attempt := nextAttempt(attempts)
+		execCtx, span := startAttemptSpan(execCtx, "auth.count_tokens", auth, provider, execReq.Model, attempt)
+		started := time.Now()
 		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+		tracing.EndSpan(span, errExec)
+		m.releaseCredential(auth.ID, routeModel)
+		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil, Attempt: attempt, Latency: time.Since(started)}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
 				return cliproxyexecutor.Response{}, errCtx
@@ -700,12 +825,15 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 	}
 }
 
-func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *int) (*cliproxyexecutor.StreamResult, error) {
 	if len(providers) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
 	routeModel := req.Model
 	opts = ensureRequestedModelMetadata(opts, routeModel)
+	if delay := m.hedgeDelay(routeModel); delay > 0 {
+		return m.executeStreamHedged(ctx, providers, req, opts, attempts, delay)
+	}
 	tried := make(map[string]struct{})
 	var lastErr error
 	for {
@@ -722,69 +850,158 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
-		}
-		execReq := req
-		execReq.Model = rewriteModelForAuth(routeModel, auth)
-		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
-		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
-		streamResult, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+		attempt, errStream := m.openStream(ctx, auth, executor, provider, routeModel, req, opts, nextAttempt(attempts))
 		if errStream != nil {
-			if errCtx := execCtx.Err(); errCtx != nil {
+			if errCtx := ctx.Err(); errCtx != nil {
 				return nil, errCtx
 			}
-			rerr := &Error{Message: errStream.Error()}
-			if se, ok := errors.AsType[cliproxyexecutor.StatusError](errStream); ok && se != nil {
-				rerr.HTTPStatus = se.StatusCode()
-			}
-			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
-			result.RetryAfter = retryAfterFromError(errStream)
-			m.MarkResult(execCtx, result)
 			if isRequestInvalidError(errStream) {
 				return nil, errStream
 			}
 			lastErr = errStream
 			continue
 		}
-		out := make(chan cliproxyexecutor.StreamChunk)
-		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
-			defer close(out)
-			var failed bool
-			forward := true
-			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
-					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					if se, ok := errors.AsType[cliproxyexecutor.StatusError](chunk.Err); ok && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
-					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr})
-				}
-				if !forward {
-					continue
-				}
-				if streamCtx == nil {
-					out <- chunk
-					continue
-				}
-				select {
-				case <-streamCtx.Done():
-					forward = false
-				case out <- chunk:
+		return m.forwardStream(attempt, routeModel, nil, 0), nil
+	}
+}
+
+// streamAttempt is a streaming execution opened against a single auth.
+type streamAttempt struct {
+	ctx      context.Context
+	auth     *Auth
+	provider string
+	attempt  int
+	started  time.Time
+	span     trace.Span
+	result   *cliproxyexecutor.StreamResult
+	// release runs once the stream has been fully forwarded or drained, freeing the auth's
+	// in-flight slot.
+	release func()
+}
+
+// openStream starts a streaming execution of req against auth. Failures are recorded through
+// MarkResult unless the context was cancelled. The in-flight slot taken when auth was picked is
+// released on failure, or by the returned attempt once its stream ends.
+func (m *Manager) openStream(ctx context.Context, auth *Auth, executor ProviderExecutor, provider, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempt int) (*streamAttempt, error) {
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execReq := req
+	execReq.Model = rewriteModelForAuth(routeModel, auth)
+	execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
+	execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+	execCtx, span := startAttemptSpan(execCtx, "auth.execute_stream", auth, provider, execReq.Model, attempt)
+	started := time.Now()
+	streamResult, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+	if errStream != nil {
+		tracing.EndSpan(span, errStream)
+		m.releaseCredential(auth.ID, routeModel)
+		if errCtx := execCtx.Err(); errCtx != nil {
+			return nil, errCtx
+		}
+		rerr := &Error{Message: errStream.Error()}
+		if se, ok := errors.AsType[cliproxyexecutor.StatusError](errStream); ok && se != nil {
+			rerr.HTTPStatus = se.StatusCode()
+		}
+		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr, Attempt: attempt, Latency: time.Since(started)}
+		result.RetryAfter = retryAfterFromError(errStream)
+		m.MarkResult(execCtx, result)
+		return nil, errStream
+	}
+	release := func() { m.releaseCredential(auth.ID, routeModel) }
+	return &streamAttempt{ctx: execCtx, auth: auth.Clone(), provider: provider, attempt: attempt, started: started, span: span, result: streamResult, release: release}, nil
+}
+
+// forwardStream relays the chunks of an opened attempt and records its outcome once the stream
+// ends. first is a chunk already read from the attempt, received firstByte after it started.
+func (m *Manager) forwardStream(a *streamAttempt, routeModel string, first *cliproxyexecutor.StreamChunk, firstByte time.Duration) *cliproxyexecutor.StreamResult {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
+		defer close(out)
+		if a.release != nil {
+			defer a.release()
+		}
+		var streamErr error
+		// Chunks are counted into one attribute rather than recorded one event each, which
+		// would grow the span without bound on long streams.
+		var chunks int
+		defer func() {
+			if a.span.IsRecording() {
+				a.span.SetAttributes(attribute.Int("cliproxy.stream.chunks", chunks))
+			}
+			tracing.EndSpan(a.span, streamErr)
+		}()
+		var failed bool
+		forward := true
+		handle := func(chunk cliproxyexecutor.StreamChunk) {
+			chunks++
+			if firstByte == 0 {
+				firstByte = time.Since(a.started)
+			}
+			if chunk.Err != nil && !failed {
+				failed = true
+				streamErr = chunk.Err
+				rerr := &Error{Message: chunk.Err.Error()}
+				if se, ok := errors.AsType[cliproxyexecutor.StatusError](chunk.Err); ok && se != nil {
+					rerr.HTTPStatus = se.StatusCode()
 				}
+				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr, Attempt: a.attempt, Latency: time.Since(a.started), FirstByte: firstByte})
+			}
+			if !forward {
+				return
 			}
-			if !failed {
-				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
+			if streamCtx == nil {
+				out <- chunk
+				return
 			}
-		}(execCtx, auth.Clone(), provider, streamResult.Chunks)
-		return &cliproxyexecutor.StreamResult{
-			Headers: streamResult.Headers,
-			Chunks:  out,
-		}, nil
+			select {
+			case <-streamCtx.Done():
+				forward = false
+			case out <- chunk:
+			}
+		}
+		if first != nil {
+			handle(*first)
+		}
+		for chunk := range streamChunks {
+			handle(chunk)
+		}
+		if !failed {
+			m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true, Attempt: a.attempt, Latency: time.Since(a.started), FirstByte: firstByte})
+		}
+	}(a.ctx, a.auth, a.provider, a.result.Chunks)
+	return &cliproxyexecutor.StreamResult{
+		Headers: a.result.Headers,
+		Chunks:  out,
+	}
+}
+
+// startAttemptSpan opens the span covering a single execution attempt against auth.
+func startAttemptSpan(ctx context.Context, name string, auth *Auth, provider, model string, attempt int) (context.Context, trace.Span) {
+	if !tracing.Enabled() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("cliproxy.provider", provider),
+		attribute.String("gen_ai.request.model", model),
+		attribute.Int("cliproxy.attempt", attempt),
+	}
+	if auth != nil {
+		attrs = append(attrs, attribute.String("cliproxy.auth_index", auth.Index))
+	}
+	return tracing.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// nextAttempt returns the current attempt number and advances the shared counter.
+func nextAttempt(attempts *int) int {
+	if attempts == nil {
+		return 0
 	}
+	attempt := *attempts
+	*attempts = attempt + 1
+	return attempt
 }
 
 func ensureRequestedModelMetadata(opts cliproxyexecutor.Options, requestedModel string) cliproxyexecutor.Options {
@@ -1214,14 +1431,20 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	shareState := false
+	var breakerAuth *Auth
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
+		if breakerKey(auth) != "" {
+			breakerAuth = auth.Clone()
+		}
 
 		if result.Success {
 			if result.Model != "" {
 				state := ensureModelState(auth, result.Model)
+				shareState = state.Unavailable || state.Status != StatusActive
 				resetModelState(state, now)
 				updateAggregatedAvailability(auth, now)
 				if !hasModelError(auth, now) {
@@ -1233,9 +1456,11 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				shouldResumeModel = true
 				clearModelQuota = true
 			} else {
+				shareState = auth.Unavailable || auth.Status != StatusActive
 				clearAuthStateOnSuccess(auth, now)
 			}
 		} else {
+			shareState = true
 			if result.Model != "" {
 				state := ensureModelState(auth, result.Model)
 				state.Unavailable = true
@@ -1302,7 +1527,8 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 					shouldSuspendModel = true
 					setModelQuota = true
 				case 408, 500, 502, 503, 504:
-					if quotaCooldownDisabledForAuth(auth) {
+					// A tripping breaker takes the whole base URL out instead.
+					if quotaCooldownDisabledForAuth(auth) || (statusCode != 408 && m.breakerGoverns(auth)) {
 						state.NextRetryAfter = time.Time{}
 					} else {
 						next := now.Add(1 * time.Minute)
@@ -1336,6 +1562,18 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
 
+	m.mu.RLock()
+	selector := m.selector
+	m.mu.RUnlock()
+	if observer, ok := selector.(ResultObserver); ok {
+		observer.ObserveResult(result)
+	}
+
+	if shareState {
+		m.markClusterDirty(result.AuthID)
+	}
+	m.recordBreakerResult(ctx, breakerAuth, result)
+	m.notifyQueue(result.Model)
 	m.hook.OnResult(ctx, result)
 }
 
@@ -1769,8 +2007,6 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		return nil, nil, "", &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
 
-	m.mu.RLock()
-	candidates := make([]*Auth, 0, len(m.auths))
 	modelKey := strings.TrimSpace(model)
 	// Always use base model name (without thinking suffix) for auth matching.
 	if modelKey != "" {
@@ -1780,61 +2016,120 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
-	for _, candidate := range m.auths {
-		if candidate == nil || candidate.Disabled {
-			continue
-		}
-		if pinnedAuthID != "" && candidate.ID != pinnedAuthID {
-			continue
+	affinityKey := sessionAffinityKey(opts.Metadata, model)
+	// busy holds auths that filled up between filtering and acquiring a slot; probing holds
+	// auths whose half-open breaker ran out of probe slots in the same window.
+	busy := make(map[string]struct{})
+	probing := make(map[string]struct{})
+	for {
+		now := time.Now()
+		saturated := 0
+		var saturatedWait time.Duration
+		broken := 0
+		var brokenWait time.Duration
+		m.mu.RLock()
+		candidates := make([]*Auth, 0, len(m.auths))
+		for _, candidate := range m.auths {
+			if candidate == nil || candidate.Disabled {
+				continue
+			}
+			if pinnedAuthID != "" && candidate.ID != pinnedAuthID {
+				continue
+			}
+			providerKey := strings.TrimSpace(strings.ToLower(candidate.Provider))
+			if providerKey == "" {
+				continue
+			}
+			if _, ok := providerSet[providerKey]; !ok {
+				continue
+			}
+			if _, used := tried[candidate.ID]; used {
+				continue
+			}
+			if _, ok := m.executors[providerKey]; !ok {
+				continue
+			}
+			if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
+				continue
+			}
+			if _, full := busy[candidate.ID]; full {
+				saturated++
+				continue
+			}
+			if _, full := probing[candidate.ID]; full {
+				broken++
+				continue
+			}
+			if ok, wait := m.admitsBreaker(candidate, now); !ok {
+				broken++
+				if wait > 0 && (brokenWait == 0 || wait < brokenWait) {
+					brokenWait = wait
+				}
+				continue
+			}
+			if ok, wait := m.limiter.available(candidate.ID, m.credentialLimits(candidate), now); !ok {
+				saturated++
+				if wait > 0 && (saturatedWait == 0 || wait < saturatedWait) {
+					saturatedWait = wait
+				}
+				continue
+			}
+			candidates = append(candidates, candidate)
 		}
-		providerKey := strings.TrimSpace(strings.ToLower(candidate.Provider))
-		if providerKey == "" {
-			continue
+		if len(candidates) == 0 {
+			m.mu.RUnlock()
+			if saturated > 0 {
+				return nil, nil, "", newCredentialsSaturatedError(model, saturatedWait)
+			}
+			if broken > 0 {
+				return nil, nil, "", newBreakerOpenError(model, brokenWait)
+			}
+			return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
+		}
+		selected := m.stickyCandidate(affinityKey, model, candidates, now)
+		if selected == nil {
+			var errPick error
+			selected, errPick = m.selector.Pick(ctx, "mixed", model, opts, candidates)
+			if errPick != nil {
+				m.mu.RUnlock()
+				return nil, nil, "", errPick
+			}
 		}
-		if _, ok := providerSet[providerKey]; !ok {
-			continue
+		if selected == nil {
+			m.mu.RUnlock()
+			return nil, nil, "", &Error{Code: "auth_not_found", Message: "selector returned no auth"}
 		}
-		if _, used := tried[candidate.ID]; used {
-			continue
+		providerKey := strings.TrimSpace(strings.ToLower(selected.Provider))
+		executor, okExecutor := m.executors[providerKey]
+		if !okExecutor {
+			m.mu.RUnlock()
+			return nil, nil, "", &Error{Code: "executor_not_found", Message: "executor not registered"}
 		}
-		if _, ok := m.executors[providerKey]; !ok {
+		// The slot is held until the caller releases it through releaseCredential.
+		if !m.limiter.acquire(selected.ID, m.credentialLimits(selected), now) {
+			m.mu.RUnlock()
+			busy[selected.ID] = struct{}{}
 			continue
 		}
-		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
+		if !m.acquireBreakerProbe(selected, now) {
+			m.mu.RUnlock()
+			m.limiter.release(selected.ID)
+			probing[selected.ID] = struct{}{}
 			continue
 		}
-		candidates = append(candidates, candidate)
-	}
-	if len(candidates) == 0 {
-		m.mu.RUnlock()
-		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
-	}
-	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
-	if errPick != nil {
-		m.mu.RUnlock()
-		return nil, nil, "", errPick
-	}
-	if selected == nil {
+		authCopy := selected.Clone()
 		m.mu.RUnlock()
-		return nil, nil, "", &Error{Code: "auth_not_found", Message: "selector returned no auth"}
-	}
-	providerKey := strings.TrimSpace(strings.ToLower(selected.Provider))
-	executor, okExecutor := m.executors[providerKey]
-	if !okExecutor {
-		m.mu.RUnlock()
-		return nil, nil, "", &Error{Code: "executor_not_found", Message: "executor not registered"}
-	}
-	authCopy := selected.Clone()
-	m.mu.RUnlock()
-	if !selected.indexAssigned {
-		m.mu.Lock()
-		if current := m.auths[authCopy.ID]; current != nil && !current.indexAssigned {
-			current.EnsureIndex()
-			authCopy = current.Clone()
+		m.bindSession(affinityKey, authCopy.ID, tried, now)
+		if !selected.indexAssigned {
+			m.mu.Lock()
+			if current := m.auths[authCopy.ID]; current != nil && !current.indexAssigned {
+				current.EnsureIndex()
+				authCopy = current.Clone()
+			}
+			m.mu.Unlock()
 		}
-		m.mu.Unlock()
+		return authCopy, executor, providerKey, nil
 	}
-	return authCopy, executor, providerKey, nil
 }
 
 func (m *Manager) persist(ctx context.Context, auth *Auth) error {
@@ -2162,6 +2457,33 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	if auth == nil || exec == nil {
 		return
 	}
+	release, ok := m.acquireRefreshLease(ctx, id)
+	if !ok {
+		return
+	}
+	defer release()
+	unlock, errLock := m.lockRefresh(ctx, auth)
+	if errLock != nil {
+		if errors.Is(errLock, context.Canceled) {
+			return
+		}
+		log.Warnf("refresh lock unavailable for %s, %s: %v", auth.Provider, auth.ID, errLock)
+		m.mu.Lock()
+		if current := m.auths[id]; current != nil {
+			current.NextRefreshAfter = time.Now().Add(refreshFailureBackoff)
+		}
+		m.mu.Unlock()
+		return
+	}
+	defer unlock()
+	if stored := m.storedRotation(ctx, auth); stored != nil {
+		auth = stored
+		if !m.shouldRefresh(auth, time.Now()) {
+			log.Infof("picked up credentials of %s, %s refreshed by another process", auth.Provider, auth.ID)
+			_, _ = m.Update(ctx, auth)
+			return
+		}
+	}
 	cloned := auth.Clone()
 	updated, err := exec.Refresh(ctx, cloned)
 	if err != nil && errors.Is(err, context.Canceled) {
@@ -2171,13 +2493,18 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
 	if err != nil {
+		var snapshot *Auth
 		m.mu.Lock()
 		if current := m.auths[id]; current != nil {
 			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
-			current.LastError = &Error{Message: err.Error()}
+			current.LastError = &Error{Code: "refresh_failed", Message: err.Error()}
 			m.auths[id] = current
+			snapshot = current.Clone()
 		}
 		m.mu.Unlock()
+		if snapshot != nil {
+			m.hook.OnAuthUpdated(ctx, snapshot)
+		}
 		return
 	}
 	if updated == nil {
@@ -2194,7 +2521,13 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	// If the Authenticator did not set it (zero value), shouldRefresh will use default logic
 	updated.LastError = nil
 	updated.UpdatedAt = now
-	_, _ = m.Update(ctx, updated)
+	// The previous refresh token may already be invalid, so the rotated credentials are stored
+	// even when another writer changed the stored auth meanwhile.
+	if errPersist := m.persist(WithRotatedCredentials(ctx), updated); errPersist != nil {
+		log.Errorf("failed to store refreshed credentials of %s, %s: %v", auth.Provider, auth.ID, errPersist)
+	}
+	_, _ = m.Update(WithSkipPersist(ctx), updated)
+	m.markClusterDirty(id)
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
@@ -2250,10 +2583,14 @@ func logEntryWithRequestID(ctx context.Context) *log.Entry {
 	if ctx == nil {
 		return log.NewEntry(log.StandardLogger())
 	}
+	entry := log.NewEntry(log.StandardLogger())
 	if reqID := logging.GetRequestID(ctx); reqID != "" {
-		return log.WithField("request_id", reqID)
+		entry = entry.WithField("request_id", reqID)
+	}
+	if traceID := logging.GetTraceID(ctx); traceID != "" {
+		entry = entry.WithField("trace_id", traceID)
 	}
-	return log.NewEntry(log.StandardLogger())
+	return entry
 }
 
 func debugLogAuthSelection(entry *log.Entry, auth *Auth, provider string, model string) {
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// credentialRateWindow is the sliding window behind requests-per-minute and tokens-per-minute.
+const credentialRateWindow = time.Minute
+
+// credentialLimiter tracks the load placed on each credential: requests in flight plus the
+// requests started and tokens reported within the last minute.
+type credentialLimiter struct {
+	mu    sync.Mutex
+	loads map[string]*credentialLoad
+}
+
+// credentialLoad is the tracked load of one credential.
+type credentialLoad struct {
+	inFlight    int
+	requests    []time.Time
+	tokens      []tokenSample
+	tokensTotal int64
+}
+
+type tokenSample struct {
+	at     time.Time
+	tokens int64
+}
+
+// CredentialLoad describes the current load of a credential and the limits that apply to it.
+type CredentialLoad struct {
+	InFlight           int   `json:"in_flight"`
+	RequestsLastMinute int   `json:"requests_last_minute"`
+	TokensLastMinute   int64 `json:"tokens_last_minute"`
+	MaxInFlight        int   `json:"max_in_flight,omitempty"`
+	RequestsPerMinute  int   `json:"requests_per_minute,omitempty"`
+	TokensPerMinute    int   `json:"tokens_per_minute,omitempty"`
+}
+
+// credentialsSaturatedError reports that every credential for a model is at its concurrency
+// or rate limits.
+type credentialsSaturatedError struct {
+	model   string
+	resetIn time.Duration
+}
+
+func newCredentialsSaturatedError(model string, resetIn time.Duration) *credentialsSaturatedError {
+	if resetIn < 0 {
+		resetIn = 0
+	}
+	return &credentialsSaturatedError{model: model, resetIn: resetIn}
+}
+
+func (e *credentialsSaturatedError) Error() string {
+	modelName := e.model
+	if modelName == "" {
+		modelName = "requested model"
+	}
+	message := fmt.Sprintf("All credentials for model %s are at their concurrency or rate limits", modelName)
+	payload := map[string]any{"error": map[string]any{
+		"code":          "credentials_saturated",
+		"message":       message,
+		"model":         e.model,
+		"reset_seconds": e.resetSeconds(),
+	}}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Sprintf(`{"error":{"code":"credentials_saturated","message":"%s"}}`, message)
+	}
+	return string(data)
+}
+
+func (e *credentialsSaturatedError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func (e *credentialsSaturatedError) Headers() http.Header {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Retry-After", strconv.Itoa(e.resetSeconds()))
+	return headers
+}
+
+// resetSeconds rounds the wait up to whole seconds; in-flight saturation has no known end, so
+// clients are asked to come back after a second.
+func (e *credentialsSaturatedError) resetSeconds() int {
+	seconds := int(math.Ceil(e.resetIn.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// credentialLimits resolves the limits of auth: the provider-limits entry of its provider,
+// overridden by limits set on the credential itself.
+func (m *Manager) credentialLimits(auth *Auth) internalconfig.CredentialLimits {
+	var limits internalconfig.CredentialLimits
+	if auth == nil {
+		return limits
+	}
+	if cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config); cfg != nil && len(cfg.ProviderLimits) > 0 {
+		limits = cfg.ProviderLimits[strings.ToLower(strings.TrimSpace(auth.Provider))]
+	}
+	if v := authLimitAttribute(auth, "max_in_flight"); v > 0 {
+		limits.MaxInFlight = v
+	}
+	if v := authLimitAttribute(auth, "requests_per_minute"); v > 0 {
+		limits.RequestsPerMinute = v
+	}
+	if v := authLimitAttribute(auth, "tokens_per_minute"); v > 0 {
+		limits.TokensPerMinute = v
+	}
+	return limits
+}
+
+func authLimitAttribute(auth *Auth, key string) int {
+	if auth.Attributes == nil {
+		return 0
+	}
+	raw := strings.TrimSpace(auth.Attributes[key])
+	if raw == "" {
+		return 0
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+// releaseCredential frees the in-flight slot taken when auth was picked and lets requests
+// parked for model use it.
+func (m *Manager) releaseCredential(authID, model string) {
+	m.limiter.release(authID)
+	m.notifyQueue(model)
+}
+
+// CredentialLoad returns the current load of the credential with the given ID.
+func (m *Manager) CredentialLoad(id string) CredentialLoad {
+	if m == nil {
+		return CredentialLoad{}
+	}
+	load := m.limiter.snapshot(id, time.Now())
+	m.mu.RLock()
+	auth := m.auths[id]
+	m.mu.RUnlock()
+	if auth != nil {
+		limits := m.credentialLimits(auth)
+		load.MaxInFlight = limits.MaxInFlight
+		load.RequestsPerMinute = limits.RequestsPerMinute
+		load.TokensPerMinute = limits.TokensPerMinute
+	}
+	return load
+}
+
+// HandleUsage implements usage.Plugin, feeding reported tokens into tokens-per-minute limits.
+func (m *Manager) HandleUsage(_ context.Context, record usage.Record) {
+	if m == nil || record.AuthID == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens <= 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	m.limiter.addTokens(record.AuthID, tokens, time.Now())
+}
+
+// available reports whether the credential can take another request under limits, and when
+// it is saturated, how long until its rate windows free up (0 when only in-flight is at limit).
+func (l *credentialLimiter) available(id string, limits internalconfig.CredentialLimits, now time.Time) (bool, time.Duration) {
+	if limits == (internalconfig.CredentialLimits{}) {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	load := l.loads[id]
+	if load == nil {
+		return true, 0
+	}
+	load.pruneLocked(now)
+	return load.headroomLocked(limits, now)
+}
+
+// acquire takes an in-flight slot and counts a request against the credential unless that
+// would break limits.
+func (l *credentialLimiter) acquire(id string, limits internalconfig.CredentialLimits, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loads == nil {
+		l.loads = make(map[string]*credentialLoad)
+	}
+	load := l.loads[id]
+	if load == nil {
+		load = &credentialLoad{}
+		l.loads[id] = load
+	}
+	load.pruneLocked(now)
+	if ok, _ := load.headroomLocked(limits, now); !ok {
+		return false
+	}
+	load.inFlight++
+	load.requests = append(load.requests, now)
+	return true
+}
+
+func (l *credentialLimiter) release(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	load := l.loads[id]
+	if load == nil {
+		return
+	}
+	if load.inFlight > 0 {
+		load.inFlight--
+	}
+	l.dropIdleLocked(id, load, time.Now())
+}
+
+func (l *credentialLimiter) addTokens(id string, tokens int64, now time.Time) {
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loads == nil {
+		l.loads = make(map[string]*credentialLoad)
+	}
+	load := l.loads[id]
+	if load == nil {
+		load = &credentialLoad{}
+		l.loads[id] = load
+	}
+	load.pruneLocked(now)
+	load.tokens = append(load.tokens, tokenSample{at: now, tokens: tokens})
+	load.tokensTotal += tokens
+}
+
+func (l *credentialLimiter) snapshot(id string, now time.Time) CredentialLoad {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	load := l.loads[id]
+	if load == nil {
+		return CredentialLoad{}
+	}
+	load.pruneLocked(now)
+	out := CredentialLoad{InFlight: load.inFlight, RequestsLastMinute: len(load.requests), TokensLastMinute: load.tokensTotal}
+	l.dropIdleLocked(id, load, now)
+	return out
+}
+
+func (l *credentialLimiter) dropIdleLocked(id string, load *credentialLoad, now time.Time) {
+	load.pruneLocked(now)
+	if load.inFlight == 0 && len(load.requests) == 0 && len(load.tokens) == 0 {
+		delete(l.loads, id)
+	}
+}
+
+// pruneLocked drops requests and token samples that left the rate window.
+func (c *credentialLoad) pruneLocked(now time.Time) {
+	cutoff := now.Add(-credentialRateWindow)
+	drop := 0
+	for drop < len(c.requests) && !c.requests[drop].After(cutoff) {
+		drop++
+	}
+	c.requests = c.requests[drop:]
+	drop = 0
+	for drop < len(c.tokens) && !c.tokens[drop].at.After(cutoff) {
+		c.tokensTotal -= c.tokens[drop].tokens
+		drop++
+	}
+	c.tokens = c.tokens[drop:]
+}
+
+func (c *credentialLoad) headroomLocked(limits internalconfig.CredentialLimits, now time.Time) (bool, time.Duration) {
+	if limits.MaxInFlight > 0 && c.inFlight >= limits.MaxInFlight {
+		return false, 0
+	}
+	if limits.RequestsPerMinute > 0 && len(c.requests) >= limits.RequestsPerMinute {
+		// The request that has to expire before another one fits.
+		expires := c.requests[len(c.requests)-limits.RequestsPerMinute].Add(credentialRateWindow)
+		return false, expires.Sub(now)
+	}
+	if limits.TokensPerMinute > 0 && c.tokensTotal >= int64(limits.TokensPerMinute) {
+		over := c.tokensTotal - int64(limits.TokensPerMinute)
+		for _, sample := range c.tokens {
+			if over -= sample.tokens; over < 0 {
+				return false, sample.at.Add(credentialRateWindow).Sub(now)
+			}
+		}
+		return false, 0
+	}
+	return true, 0
+}
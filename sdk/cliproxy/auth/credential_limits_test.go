@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestManagerPickNextMixed_SkipsSaturatedCredentials(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&queueTestExecutor{})
+	m.SetConfig(&internalconfig.Config{})
+	if _, err := m.Register(context.Background(), &Auth{ID: "limit-auth", Provider: "queue-test", Status: StatusActive, Attributes: map[string]string{"max_in_flight": "1"}}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("limit-auth", "queue-test", []*registry.ModelInfo{{ID: "limit-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("limit-auth") })
+
+	pick := func() error {
+		_, _, _, err := m.pickNextMixed(context.Background(), []string{"queue-test"}, "limit-model", cliproxyexecutor.Options{}, map[string]struct{}{})
+		return err
+	}
+	if err := pick(); err != nil {
+		t.Fatalf("first pick: %v", err)
+	}
+	if load := m.CredentialLoad("limit-auth"); load.InFlight != 1 || load.MaxInFlight != 1 {
+		t.Fatalf("load = %+v, want one of one in flight", load)
+	}
+	if err := pick(); statusCodeFromError(err) != http.StatusTooManyRequests {
+		t.Fatalf("saturated pick err = %v, want 429", err)
+	}
+	m.releaseCredential("limit-auth", "limit-model")
+	if err := pick(); err != nil {
+		t.Fatalf("pick after release: %v", err)
+	}
+}
+
+func TestManagerCredentialLimits_AttributesOverrideProvider(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{ProviderLimits: map[string]internalconfig.CredentialLimits{
+		"kiro": {MaxInFlight: 2, RequestsPerMinute: 30},
+	}})
+	auth := &Auth{Provider: "Kiro", Attributes: map[string]string{"max_in_flight": "1", "tokens_per_minute": "9000"}}
+	want := internalconfig.CredentialLimits{MaxInFlight: 1, RequestsPerMinute: 30, TokensPerMinute: 9000}
+	if got := m.credentialLimits(auth); got != want {
+		t.Fatalf("credentialLimits = %+v, want %+v", got, want)
+	}
+}
+
+func TestCredentialLimiter_RateWindows(t *testing.T) {
+	var l credentialLimiter
+	now := time.Now()
+	rpm := internalconfig.CredentialLimits{RequestsPerMinute: 2}
+	for i := 0; i < 2; i++ {
+		if !l.acquire("a", rpm, now.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("acquire %d rejected below the rate", i)
+		}
+		l.release("a")
+	}
+	if ok, wait := l.available("a", rpm, now.Add(2*time.Second)); ok || wait != 58*time.Second {
+		t.Fatalf("available = %t, %s; want saturated for 58s", ok, wait)
+	}
+	if !l.acquire("a", rpm, now.Add(61*time.Second)) {
+		t.Fatalf("acquire rejected after the window moved on")
+	}
+
+	m := NewManager(nil, nil, nil)
+	m.HandleUsage(context.Background(), usage.Record{AuthID: "b", Detail: usage.Detail{TotalTokens: 400}})
+	m.HandleUsage(context.Background(), usage.Record{AuthID: "b", Detail: usage.Detail{InputTokens: 300, OutputTokens: 200}})
+	tpm := internalconfig.CredentialLimits{TokensPerMinute: 1000}
+	if ok, _ := m.limiter.available("b", tpm, time.Now()); !ok {
+		t.Fatalf("credential saturated below its token rate")
+	}
+	m.HandleUsage(context.Background(), usage.Record{AuthID: "b", Detail: usage.Detail{TotalTokens: 100}})
+	if ok, _ := m.limiter.available("b", tpm, time.Now()); ok {
+		t.Fatalf("credential available at its token rate")
+	}
+	if load := m.CredentialLoad("b"); load.TokensLastMinute != 1000 {
+		t.Fatalf("tokens last minute = %d, want 1000", load.TokensLastMinute)
+	}
+}
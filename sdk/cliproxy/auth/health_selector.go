@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// DefaultHealthDecay is the EWMA weight applied to each new sample when none is configured.
+	DefaultHealthDecay = 0.2
+
+	// healthMinLatency bounds the latency estimate so near-instant responses do not dominate.
+	healthMinLatency = 50 * time.Millisecond
+	// healthMinWeight keeps unhealthy auths in rotation so they can recover.
+	healthMinWeight = 0.05
+)
+
+// ResultObserver is implemented by selectors that learn from execution results.
+// Manager.MarkResult forwards every result to the active selector when it implements this interface.
+type ResultObserver interface {
+	ObserveResult(result Result)
+}
+
+// HealthWeightedSelector picks auths at random, weighted toward credentials with a low
+// EWMA latency and a low recent error rate. Only the highest available priority tier is
+// considered, matching the other selectors.
+type HealthWeightedSelector struct {
+	mu    sync.Mutex
+	decay float64
+	stats map[string]*authHealth
+	rand  func() float64
+}
+
+// authHealth tracks exponentially weighted health signals for a single auth.
+type authHealth struct {
+	latency   float64 // seconds, successful attempts only
+	errorRate float64 // 0..1
+}
+
+// NewHealthWeightedSelector constructs a selector using decay as the EWMA sample weight.
+// Values outside (0, 1] fall back to DefaultHealthDecay.
+func NewHealthWeightedSelector(decay float64) *HealthWeightedSelector {
+	s := &HealthWeightedSelector{stats: make(map[string]*authHealth)}
+	s.SetDecay(decay)
+	return s
+}
+
+// SetDecay updates the EWMA sample weight; higher values react faster to recent results.
+func (s *HealthWeightedSelector) SetDecay(decay float64) {
+	if decay <= 0 || decay > 1 || math.IsNaN(decay) {
+		decay = DefaultHealthDecay
+	}
+	s.mu.Lock()
+	s.decay = decay
+	s.mu.Unlock()
+}
+
+// ObserveResult implements ResultObserver.
+func (s *HealthWeightedSelector) ObserveResult(result Result) {
+	if result.AuthID == "" {
+		return
+	}
+	errSample := 0.0
+	if !result.Success && countsAsHealthError(result.Error) {
+		errSample = 1
+	}
+	// Streams report time-to-first-byte since total duration depends on output length.
+	// Failed attempts do not update latency so a fast-failing auth does not look fast.
+	var latency time.Duration
+	if result.Success {
+		latency = result.FirstByte
+		if latency <= 0 {
+			latency = result.Latency
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats == nil {
+		s.stats = make(map[string]*authHealth)
+	}
+	decay := s.decay
+	if decay <= 0 {
+		decay = DefaultHealthDecay
+	}
+	stats, ok := s.stats[result.AuthID]
+	if !ok {
+		stats = &authHealth{}
+		s.stats[result.AuthID] = stats
+	}
+	stats.errorRate += decay * (errSample - stats.errorRate)
+	if latency > 0 {
+		if stats.latency <= 0 {
+			stats.latency = latency.Seconds()
+		} else {
+			stats.latency += decay * (latency.Seconds() - stats.latency)
+		}
+	}
+}
+
+// Pick selects an auth from the best priority tier, weighted by observed health.
+func (s *HealthWeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = opts
+	available, err := getAvailableAuths(auths, provider, model, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	available = preferCodexWebsocketAuths(ctx, provider, available)
+	if len(available) == 1 {
+		return available[0], nil
+	}
+
+	weights := s.weights(available)
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	randFn := s.rand
+	if randFn == nil {
+		randFn = rand.Float64
+	}
+	target := randFn() * total
+	for i, w := range weights {
+		if target < w {
+			return available[i], nil
+		}
+		target -= w
+	}
+	return available[len(available)-1], nil
+}
+
+// weights scores each candidate as (1 - errorRate)^2 / latency, normalised so the
+// healthiest auth has weight 1. Auths without history assume the mean known latency.
+func (s *HealthWeightedSelector) weights(available []*Auth) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	knownLatency, knownCount := 0.0, 0
+	for _, auth := range available {
+		if stats := s.stats[auth.ID]; stats != nil && stats.latency > 0 {
+			knownLatency += stats.latency
+			knownCount++
+		}
+	}
+	defaultLatency := healthMinLatency.Seconds()
+	if knownCount > 0 {
+		defaultLatency = knownLatency / float64(knownCount)
+	}
+
+	scores := make([]float64, len(available))
+	best := 0.0
+	for i, auth := range available {
+		latency, errorRate := defaultLatency, 0.0
+		if stats := s.stats[auth.ID]; stats != nil {
+			if stats.latency > 0 {
+				latency = stats.latency
+			}
+			errorRate = stats.errorRate
+		}
+		latency = math.Max(latency, healthMinLatency.Seconds())
+		health := 1 - errorRate
+		scores[i] = health * health / latency
+		if scores[i] > best {
+			best = scores[i]
+		}
+	}
+	for i := range scores {
+		if best > 0 {
+			scores[i] /= best
+		}
+		if scores[i] < healthMinWeight {
+			scores[i] = healthMinWeight
+		}
+	}
+	return scores
+}
+
+// countsAsHealthError reports whether a failure reflects upstream health rather than the request itself.
+func countsAsHealthError(err *Error) bool {
+	if err == nil {
+		return true
+	}
+	status := err.HTTPStatus
+	switch {
+	case status == 0:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
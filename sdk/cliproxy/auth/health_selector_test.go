@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestHealthWeightedSelectorPick_PrefersHealthyFastAuth(t *testing.T) {
+	t.Parallel()
+
+	selector := NewHealthWeightedSelector(0.5)
+	for i := 0; i < 10; i++ {
+		selector.ObserveResult(Result{AuthID: "fast", Success: true, Latency: 200 * time.Millisecond})
+		selector.ObserveResult(Result{AuthID: "slow", Success: true, Latency: 2 * time.Second})
+		selector.ObserveResult(Result{AuthID: "flaky", Error: &Error{HTTPStatus: 502}, Latency: 10 * time.Millisecond})
+	}
+	// A client error must not count against the auth's health.
+	selector.ObserveResult(Result{AuthID: "fast", Error: &Error{HTTPStatus: 400}})
+
+	weights := selector.weights([]*Auth{{ID: "fast"}, {ID: "slow"}, {ID: "flaky"}})
+	if weights[0] < 0.9 {
+		t.Fatalf("fast weight = %v, want ~1", weights[0])
+	}
+	if weights[1] > 0.2 || weights[1] < healthMinWeight {
+		t.Fatalf("slow weight = %v, want ~0.1", weights[1])
+	}
+	if weights[2] != healthMinWeight {
+		t.Fatalf("flaky weight = %v, want floor %v", weights[2], healthMinWeight)
+	}
+
+	auths := []*Auth{{ID: "slow"}, {ID: "fast"}, {ID: "flaky"}}
+	selector.rand = func() float64 { return 0.5 }
+	got, err := selector.Pick(context.Background(), "codex", "gpt-5", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "fast" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "fast")
+	}
+}
+
+func TestHealthWeightedSelectorPick_RespectsPriority(t *testing.T) {
+	t.Parallel()
+
+	selector := NewHealthWeightedSelector(0)
+	selector.ObserveResult(Result{AuthID: "high", Error: &Error{HTTPStatus: 503}})
+	selector.ObserveResult(Result{AuthID: "low", Success: true, Latency: time.Millisecond})
+
+	auths := []*Auth{
+		{ID: "low", Attributes: map[string]string{"priority": "0"}},
+		{ID: "high", Attributes: map[string]string{"priority": "10"}},
+	}
+	for i := 0; i < 5; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		if got.ID != "high" {
+			t.Fatalf("Pick() auth.ID = %q, want higher priority %q", got.ID, "high")
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// defaultHedgeMaxInFlight caps concurrent hedge attempts when routing.hedging.max-in-flight is unset.
+const defaultHedgeMaxInFlight = 8
+
+// hedgeRacer is one attempt of a hedged stream race.
+type hedgeRacer struct {
+	cancel context.CancelFunc
+	// gate holds the attempt's usage records until the race is decided.
+	gate *usage.Gate
+	// hedge marks attempts started while another was running; they count against the hedge cap.
+	hedge  bool
+	authID string
+}
+
+// hedgeEvent reports how a racer started: the first chunk of its stream, or the error that ended it.
+type hedgeEvent struct {
+	racer     *hedgeRacer
+	attempt   *streamAttempt
+	first     cliproxyexecutor.StreamChunk
+	hasFirst  bool
+	firstByte time.Duration
+	err       error
+}
+
+// hedgeDelay returns how long a stream for model may go without a first chunk before it is
+// hedged, or 0 when hedging does not apply to model.
+func (m *Manager) hedgeDelay(model string) time.Duration {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil {
+		return 0
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, rule := range cfg.Routing.Hedging.Rules {
+		for _, pattern := range rule.Models {
+			if !util.MatchModelPattern(strings.ToLower(strings.TrimSpace(pattern)), model) {
+				continue
+			}
+			if rule.DelayMS <= 0 {
+				return 0
+			}
+			return time.Duration(rule.DelayMS) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// acquireHedge reserves a slot under the global hedge cap.
+func (m *Manager) acquireHedge() bool {
+	limit := int64(defaultHedgeMaxInFlight)
+	if cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config); cfg != nil && cfg.Routing.Hedging.MaxInFlight > 0 {
+		limit = int64(cfg.Routing.Hedging.MaxInFlight)
+	}
+	for {
+		current := m.hedgesInFlight.Load()
+		if current >= limit {
+			return false
+		}
+		if m.hedgesInFlight.CompareAndSwap(current, current+1) {
+			return true
+		}
+	}
+}
+
+func (m *Manager) releaseHedge() { m.hedgesInFlight.Add(-1) }
+
+// executeStreamHedged races streaming attempts across auths. The request starts on one auth;
+// when it produces no chunk within delay, the same request is sent to a second eligible auth.
+// The first attempt to produce a chunk wins and the others are cancelled without being
+// recorded, and with their usage discarded. Attempts that fail before producing a chunk are
+// replaced like in the sequential path.
+func (m *Manager) executeStreamHedged(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempts *int, delay time.Duration) (*cliproxyexecutor.StreamResult, error) {
+	routeModel := req.Model
+	tried := make(map[string]struct{})
+	racers := make(map[*hedgeRacer]struct{})
+	events := make(chan hedgeEvent)
+	done := make(chan struct{})
+	defer close(done)
+
+	var lastErr error
+	finish := func(r *hedgeRacer, won bool) {
+		delete(racers, r)
+		if won {
+			r.gate.Accept()
+		} else {
+			r.cancel()
+			r.gate.Discard()
+		}
+		if r.hedge {
+			m.releaseHedge()
+		}
+	}
+	abandonAll := func() {
+		for r := range racers {
+			finish(r, false)
+		}
+	}
+	launch := func(hedge bool) bool {
+		if hedge && !m.acquireHedge() {
+			return false
+		}
+		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
+		if errPick != nil {
+			if hedge {
+				m.releaseHedge()
+			}
+			if lastErr == nil {
+				lastErr = errPick
+			}
+			return false
+		}
+		entry := logEntryWithRequestID(ctx)
+		debugLogAuthSelection(entry, auth, provider, req.Model)
+		if hedge {
+			entry.Infof("hedging stream for %s on auth %s after %s without a first chunk", routeModel, auth.ID, delay)
+		}
+		tried[auth.ID] = struct{}{}
+
+		racerCtx, cancel := context.WithCancel(ctx)
+		r := &hedgeRacer{cancel: cancel, gate: usage.NewGate(), hedge: hedge, authID: auth.ID}
+		racerCtx = usage.WithGate(racerCtx, r.gate)
+		racers[r] = struct{}{}
+		racerOpts := opts
+		racerOpts.Metadata = racerMetadata(opts.Metadata, auth.ID)
+		go m.runHedgeRacer(racerCtx, r, auth, executor, provider, routeModel, req, racerOpts, nextAttempt(attempts), events, done)
+		return true
+	}
+
+	if !launch(false) {
+		return nil, lastErr
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedgeReady := false
+	for {
+		select {
+		case <-ctx.Done():
+			abandonAll()
+			return nil, ctx.Err()
+		case <-timer.C:
+			hedgeReady = true
+		case ev := <-events:
+			r := ev.racer
+			switch {
+			case ev.err != nil:
+				finish(r, false)
+				if ev.attempt != nil {
+					m.abandonAttempt(ev.attempt, routeModel, nil)
+				}
+				if errCtx := ctx.Err(); errCtx != nil {
+					abandonAll()
+					return nil, errCtx
+				}
+				if isRequestInvalidError(ev.err) {
+					abandonAll()
+					return nil, ev.err
+				}
+				lastErr = ev.err
+			case ev.hasFirst && ev.first.Err != nil && len(racers) > 1:
+				// The attempt failed before producing bytes while another one is still running.
+				finish(r, false)
+				m.abandonAttempt(ev.attempt, routeModel, ev.first.Err)
+				lastErr = ev.first.Err
+			default:
+				finish(r, true)
+				abandonAll()
+				releaseSlot := ev.attempt.release
+				ev.attempt.release = func() {
+					r.cancel()
+					if releaseSlot != nil {
+						releaseSlot()
+					}
+				}
+				publishSelectedAuthMetadata(opts.Metadata, r.authID)
+				if r.hedge {
+					logEntryWithRequestID(ctx).Infof("hedged stream for %s won by auth %s", routeModel, r.authID)
+				}
+				var first *cliproxyexecutor.StreamChunk
+				if ev.hasFirst {
+					first = &ev.first
+				}
+				return m.forwardStream(ev.attempt, routeModel, first, ev.firstByte), nil
+			}
+		}
+		if len(racers) == 0 && !launch(false) {
+			return nil, lastErr
+		}
+		if hedgeReady && len(racers) == 1 {
+			launch(true)
+		}
+	}
+}
+
+// runHedgeRacer opens one attempt of a hedged race and reports its first chunk to the coordinator.
+func (m *Manager) runHedgeRacer(ctx context.Context, r *hedgeRacer, auth *Auth, executor ProviderExecutor, provider, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, attempt int, events chan<- hedgeEvent, done <-chan struct{}) {
+	ev := hedgeEvent{racer: r}
+	a, err := m.openStream(ctx, auth, executor, provider, routeModel, req, opts, attempt)
+	if err != nil {
+		ev.err = err
+	} else {
+		ev.attempt = a
+		select {
+		case chunk, ok := <-a.result.Chunks:
+			ev.first, ev.hasFirst = chunk, ok
+			ev.firstByte = time.Since(a.started)
+		case <-ctx.Done():
+			ev.err = ctx.Err()
+		}
+	}
+	select {
+	case events <- ev:
+	case <-done:
+		// The race is already decided; this attempt lost.
+		if ev.attempt != nil {
+			m.abandonAttempt(ev.attempt, routeModel, nil)
+		}
+	}
+}
+
+// abandonAttempt closes out an attempt that will not be forwarded. Only a failure reported by
+// the upstream is recorded; attempts cancelled because they lost the race are not.
+// The attempt's in-flight slot is freed once its stream is drained.
+func (m *Manager) abandonAttempt(a *streamAttempt, routeModel string, failure error) {
+	if failure != nil {
+		rerr := &Error{Message: failure.Error()}
+		if se, ok := errors.AsType[cliproxyexecutor.StatusError](failure); ok && se != nil {
+			rerr.HTTPStatus = se.StatusCode()
+		}
+		m.MarkResult(a.ctx, Result{AuthID: a.auth.ID, Provider: a.provider, Model: routeModel, Success: false, Error: rerr, Attempt: a.attempt, Latency: time.Since(a.started)})
+	}
+	tracing.EndSpan(a.span, failure)
+	go func() {
+		for range a.result.Chunks {
+		}
+		if a.release != nil {
+			a.release()
+		}
+	}()
+}
+
+// racerMetadata copies the request metadata for one racer. The selected-auth callback is left
+// out; it runs once for the winner.
+func racerMetadata(meta map[string]any, authID string) map[string]any {
+	out := make(map[string]any, len(meta)+1)
+	for k, v := range meta {
+		if k == cliproxyexecutor.SelectedAuthCallbackMetadataKey {
+			continue
+		}
+		out[k] = v
+	}
+	out[cliproxyexecutor.SelectedAuthMetadataKey] = authID
+	return out
+}
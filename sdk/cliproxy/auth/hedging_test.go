@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// hedgeTestExecutor stalls the first stream it opens until cancelled and answers later ones at once.
+type hedgeTestExecutor struct {
+	mu        sync.Mutex
+	calls     []string
+	cancelled chan string
+}
+
+func (e *hedgeTestExecutor) Identifier() string { return "hedge-test" }
+
+func (e *hedgeTestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *hedgeTestExecutor) ExecuteStream(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	e.mu.Lock()
+	e.calls = append(e.calls, auth.ID)
+	first := len(e.calls) == 1
+	e.mu.Unlock()
+	ch := make(chan cliproxyexecutor.StreamChunk, 1)
+	if first {
+		go func() {
+			<-ctx.Done()
+			e.cancelled <- auth.ID
+			ch <- cliproxyexecutor.StreamChunk{Err: ctx.Err()}
+			close(ch)
+		}()
+	} else {
+		ch <- cliproxyexecutor.StreamChunk{Payload: []byte(auth.ID)}
+		close(ch)
+	}
+	return &cliproxyexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func (e *hedgeTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *hedgeTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *hedgeTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestManagerExecuteStream_HedgesSlowFirstChunk(t *testing.T) {
+	executor := &hedgeTestExecutor{cancelled: make(chan string, 1)}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(executor)
+	m.SetConfig(&internalconfig.Config{Routing: internalconfig.RoutingConfig{Hedging: internalconfig.HedgingConfig{
+		Rules: []internalconfig.HedgingRule{{Models: []string{"hedge-*"}, DelayMS: 20}},
+	}}})
+	for _, id := range []string{"hedge-a", "hedge-b"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "hedge-test", Status: StatusActive}); err != nil {
+			t.Fatalf("Register(%s): %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, "hedge-test", []*registry.ModelInfo{{ID: "hedge-model"}})
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+	}
+
+	result, err := m.ExecuteStream(context.Background(), []string{"hedge-test"}, cliproxyexecutor.Request{Model: "hedge-model"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	var chunks []string
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected chunk error: %v", chunk.Err)
+		}
+		chunks = append(chunks, string(chunk.Payload))
+	}
+
+	executor.mu.Lock()
+	calls := append([]string(nil), executor.calls...)
+	executor.mu.Unlock()
+	if len(calls) != 2 || len(chunks) != 1 || chunks[0] != calls[1] {
+		t.Fatalf("calls = %v, chunks = %v, want the hedge to win", calls, chunks)
+	}
+	select {
+	case id := <-executor.cancelled:
+		if id != calls[0] {
+			t.Fatalf("cancelled %s, want %s", id, calls[0])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("losing stream was not cancelled")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if loser, ok := m.GetByID(calls[0]); !ok || loser.LastError != nil {
+		t.Fatalf("losing auth should not be marked failed: %+v", loser)
+	}
+	if got := m.hedgesInFlight.Load(); got != 0 {
+		t.Fatalf("hedgesInFlight = %d, want 0", got)
+	}
+}
+
+func TestManagerHedgeDelay(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{Routing: internalconfig.RoutingConfig{Hedging: internalconfig.HedgingConfig{
+		Rules: []internalconfig.HedgingRule{
+			{Models: []string{"claude-*-haiku*"}, DelayMS: 0},
+			{Models: []string{"claude-*"}, DelayMS: 1500},
+		},
+	}}})
+	cases := map[string]time.Duration{
+		"claude-sonnet-4-5":     1500 * time.Millisecond,
+		"Claude-3-5-haiku-2024": 0,
+		"gpt-5":                 0,
+	}
+	for model, want := range cases {
+		if got := m.hedgeDelay(model); got != want {
+			t.Errorf("hedgeDelay(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
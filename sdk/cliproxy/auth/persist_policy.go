@@ -22,3 +22,25 @@ func shouldSkipPersist(ctx context.Context) bool {
 	enabled, ok := v.(bool)
 	return ok && enabled
 }
+
+type rotatedCredentialsContextKey struct{}
+
+// WithRotatedCredentials returns a derived context marking a save as carrying credentials a
+// refresh has just rotated. Stores that reject writes based on an outdated version should merge
+// such a save into the newer record instead of failing: the previous refresh token is no longer
+// valid, so dropping the write would lose the credential.
+func WithRotatedCredentials(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, rotatedCredentialsContextKey{}, true)
+}
+
+// RotatedCredentials reports whether ctx was derived from WithRotatedCredentials.
+func RotatedCredentials(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	enabled, ok := ctx.Value(rotatedCredentialsContextKey{}).(bool)
+	return ok && enabled
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// refreshLockWait bounds how long a refresh waits for another process to finish refreshing
+// the same auth.
+const refreshLockWait = 2 * time.Minute
+
+// lockRefresh takes the cross-process refresh lock of auth when the store provides one.
+func (m *Manager) lockRefresh(ctx context.Context, auth *Auth) (func(), error) {
+	locker, ok := m.store.(RefreshLocker)
+	if !ok {
+		return func() {}, nil
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, refreshLockWait)
+	defer cancel()
+	return locker.LockRefresh(lockCtx, auth.Clone())
+}
+
+// storedRotation re-reads auth once the refresh lock is held and returns it with the stored
+// credentials when another writer replaced them since this process loaded them, typically by
+// refreshing first. Refreshing again with the old refresh token would fail once it is rotated.
+func (m *Manager) storedRotation(ctx context.Context, auth *Auth) *Auth {
+	reader, ok := m.store.(AuthReader)
+	if !ok {
+		return nil
+	}
+	stored, err := reader.Read(ctx, auth.Clone())
+	if err != nil {
+		log.Debugf("failed to re-read %s before refresh: %v", auth.ID, err)
+		return nil
+	}
+	if stored == nil || sameStoredMetadata(auth.Metadata, stored.Metadata) {
+		return nil
+	}
+	adopted := auth.Clone()
+	adopted.Metadata = stored.Metadata
+	// Stored metadata supersedes the token storage captured at login.
+	adopted.Storage = nil
+	adopted.NextRefreshAfter = stored.NextRefreshAfter
+	return adopted
+}
+
+// loadStoredAuth reads auth id back from the store, scanning the whole store when it cannot
+// read a single auth.
+func (m *Manager) loadStoredAuth(ctx context.Context, id string) (*Auth, error) {
+	if m.store == nil {
+		return nil, nil
+	}
+	if reader, ok := m.store.(AuthReader); ok {
+		m.mu.RLock()
+		current := m.auths[id].Clone()
+		m.mu.RUnlock()
+		if current == nil {
+			return nil, nil
+		}
+		return reader.Read(ctx, current)
+	}
+	items, err := m.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item != nil && item.ID == id {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// sameStoredMetadata compares auth metadata as stored, ignoring the disabled flag stores add.
+func sameStoredMetadata(a, b map[string]any) bool {
+	strip := func(metadata map[string]any) []byte {
+		copied := make(map[string]any, len(metadata))
+		for key, value := range metadata {
+			if key != "disabled" {
+				copied[key] = value
+			}
+		}
+		raw, _ := json.Marshal(copied)
+		return raw
+	}
+	return string(strip(a)) == string(strip(b))
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rotatingStore is a Store shared with another process that has already rotated the stored
+// credentials.
+type rotatingStore struct {
+	mu      sync.Mutex
+	stored  *Auth
+	locks   int
+	unlocks int
+}
+
+func (s *rotatingStore) List(context.Context) ([]*Auth, error) { return nil, nil }
+
+func (s *rotatingStore) Save(_ context.Context, auth *Auth) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stored = auth.Clone()
+	return auth.ID, nil
+}
+
+func (s *rotatingStore) Delete(context.Context, string) error { return nil }
+
+func (s *rotatingStore) Read(context.Context, *Auth) (*Auth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stored.Clone(), nil
+}
+
+func (s *rotatingStore) LockRefresh(context.Context, *Auth) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks++
+	return func() {
+		s.mu.Lock()
+		s.unlocks++
+		s.mu.Unlock()
+	}, nil
+}
+
+// countingRefreshExecutor counts the refreshes it performs.
+type countingRefreshExecutor struct {
+	queueTestExecutor
+	mu        sync.Mutex
+	refreshes int
+}
+
+func (e *countingRefreshExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	e.mu.Lock()
+	e.refreshes++
+	e.mu.Unlock()
+	return auth, nil
+}
+
+func TestManagerRefreshAuth_AdoptsCredentialsRotatedElsewhere(t *testing.T) {
+	ctx := context.Background()
+	store := &rotatingStore{}
+	m := NewManager(store, nil, nil)
+	exec := &countingRefreshExecutor{}
+	m.RegisterExecutor(exec)
+	stale := &Auth{
+		ID:       "rotated-auth",
+		Provider: "queue-test",
+		Status:   StatusActive,
+		Metadata: map[string]any{
+			"refresh_token":            "rt-1",
+			"refresh_interval_seconds": 3600,
+			"expired":                  time.Now().Add(-time.Minute).Format(time.RFC3339),
+		},
+	}
+	if _, err := m.Register(ctx, stale); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	store.stored = stale.Clone()
+	store.stored.Metadata = map[string]any{
+		"refresh_token":            "rt-2",
+		"refresh_interval_seconds": 3600,
+		"expired":                  time.Now().Add(2 * time.Hour).Format(time.RFC3339),
+		"last_refresh":             time.Now().Format(time.RFC3339),
+	}
+
+	m.refreshAuth(ctx, "rotated-auth")
+
+	if exec.refreshes != 0 {
+		t.Fatalf("refreshes = %d, want the rotated token adopted without refreshing", exec.refreshes)
+	}
+	auth, _ := m.GetByID("rotated-auth")
+	if auth.Metadata["refresh_token"] != "rt-2" {
+		t.Fatalf("refresh_token = %v, want rt-2", auth.Metadata["refresh_token"])
+	}
+	if store.locks != 1 || store.unlocks != 1 {
+		t.Fatalf("locks = %d, unlocks = %d; want one held refresh lock", store.locks, store.unlocks)
+	}
+
+	// Once the stored credentials are stale as well, this process refreshes them.
+	store.stored.Metadata["expired"] = time.Now().Add(-time.Minute).Format(time.RFC3339)
+	store.stored.Metadata["last_refresh"] = time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	m.refreshAuth(ctx, "rotated-auth")
+	if exec.refreshes != 1 {
+		t.Fatalf("refreshes = %d, want 1 once the stored credentials expired", exec.refreshes)
+	}
+}
+
+// conflictingStore rejects saves based on an outdated copy unless they carry rotated credentials,
+// which it merges into the record another writer changed, like the SQL store does.
+type conflictingStore struct {
+	rotatingStore
+	changedElsewhere bool
+}
+
+func (s *conflictingStore) Save(ctx context.Context, auth *Auth) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changedElsewhere && !RotatedCredentials(ctx) {
+		return "", errors.New("record changed by another writer")
+	}
+	merged := s.stored.Clone()
+	if merged == nil {
+		merged = &Auth{ID: auth.ID, Metadata: map[string]any{}}
+	}
+	for key, value := range auth.Metadata {
+		merged.Metadata[key] = value
+	}
+	s.stored = merged
+	s.changedElsewhere = false
+	return auth.ID, nil
+}
+
+// rotatingRefreshExecutor rotates the refresh token on every refresh.
+type rotatingRefreshExecutor struct {
+	queueTestExecutor
+}
+
+func (e *rotatingRefreshExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	auth.Metadata["refresh_token"] = "rt-rotated"
+	auth.Metadata["expired"] = time.Now().Add(time.Hour).Format(time.RFC3339)
+	return auth, nil
+}
+
+func TestManagerRefreshAuth_StoresRotatedCredentialsOverConflictingWrite(t *testing.T) {
+	ctx := context.Background()
+	store := &conflictingStore{}
+	m := NewManager(store, nil, nil)
+	m.RegisterExecutor(&rotatingRefreshExecutor{})
+	auth := &Auth{
+		ID:       "conflict-auth",
+		Provider: "queue-test",
+		Status:   StatusActive,
+		Metadata: map[string]any{
+			"refresh_token":            "rt-1",
+			"refresh_interval_seconds": 3600,
+			"expired":                  time.Now().Add(-time.Minute).Format(time.RFC3339),
+		},
+	}
+	if _, err := m.Register(ctx, auth); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	// Another writer changes the stored auth without touching its credentials.
+	store.stored.Metadata["label"] = "edited elsewhere"
+	store.changedElsewhere = true
+
+	m.refreshAuth(ctx, "conflict-auth")
+
+	if got := store.stored.Metadata["refresh_token"]; got != "rt-rotated" {
+		t.Fatalf("stored refresh_token = %v, want the rotated token", got)
+	}
+	if got := store.stored.Metadata["label"]; got != "edited elsewhere" {
+		t.Fatalf("stored label = %v, want the other writer's change kept", got)
+	}
+	if current, _ := m.GetByID("conflict-auth"); current.Metadata["refresh_token"] != "rt-rotated" {
+		t.Fatalf("in-memory refresh_token = %v, want the rotated token", current.Metadata["refresh_token"])
+	}
+}
@@ -0,0 +1,388 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// defaultQueueMaxDepth caps the parked requests per model when request-queue.max-depth is unset.
+	defaultQueueMaxDepth = 100
+	// defaultQueueMaxWait bounds how long a request stays parked when request-queue.max-wait-seconds is unset.
+	defaultQueueMaxWait = 5 * time.Minute
+	// queueRecheckInterval is how often a queue re-checks availability when no cooldown end is known.
+	queueRecheckInterval = time.Second
+)
+
+// requestQueue parks requests while every credential for their model is cooling down or
+// saturated by its credential limits. Parked requests are released in round-robin order across
+// client keys, one per available credential; a released request holds its slot until its retry
+// returns.
+type requestQueue struct {
+	mu     sync.Mutex
+	models map[string]*modelQueue
+}
+
+// modelQueue holds the parked requests of one model.
+type modelQueue struct {
+	// model and providers come from the latest parked request and drive availability checks.
+	model     string
+	providers []string
+	// clients lists the client keys with parked requests, in round-robin order.
+	clients []string
+	next    int
+	waiters map[string][]*queuedRequest
+	size    int
+	// inflight counts released requests whose retry has not returned yet.
+	inflight int
+	timer    *time.Timer
+}
+
+// queuedRequest is a parked request, released by closing ready.
+type queuedRequest struct {
+	client   string
+	enqueued time.Time
+	ready    chan struct{}
+}
+
+// QueueStat describes the parked requests of one model.
+type QueueStat struct {
+	Model             string  `json:"model"`
+	Queued            int     `json:"queued"`
+	Clients           int     `json:"clients"`
+	InFlight          int     `json:"in_flight"`
+	OldestWaitSeconds float64 `json:"oldest_wait_seconds"`
+}
+
+// queueSettings returns the request-queue configuration with defaults applied.
+func (m *Manager) queueSettings() (bool, int, time.Duration) {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || !cfg.RequestQueue.Enable {
+		return false, 0, 0
+	}
+	depth := cfg.RequestQueue.MaxDepth
+	if depth <= 0 {
+		depth = defaultQueueMaxDepth
+	}
+	maxWait := time.Duration(cfg.RequestQueue.MaxWaitSeconds) * time.Second
+	if maxWait <= 0 {
+		maxWait = defaultQueueMaxWait
+	}
+	return true, depth, maxWait
+}
+
+// isQueueableError reports whether err means the model is temporarily out of credentials.
+func isQueueableError(err error) bool {
+	return err != nil && statusCodeFromError(err) == http.StatusTooManyRequests && !isRequestInvalidError(err)
+}
+
+// waitForQueueTurn parks the request until a credential for model is available and the request's
+// turn comes. cause is returned when queueing is disabled, cause is not a cooldown, the request
+// has fallback targets left, the model's queue is full, or the request has waited past deadline,
+// which is set on the first call. The returned release must be called once the retry returns.
+func (m *Manager) waitForQueueTurn(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options, cause error, deadline *time.Time) (func(), error) {
+	if !isQueueableError(cause) || queueSkipped(opts.Metadata) {
+		return nil, cause
+	}
+	enabled, depth, maxWait := m.queueSettings()
+	if !enabled {
+		return nil, cause
+	}
+	now := time.Now()
+	if deadline.IsZero() {
+		*deadline = now.Add(maxWait)
+	}
+	remaining := deadline.Sub(now)
+	if remaining <= 0 {
+		return nil, cause
+	}
+	key := canonicalModelKey(model)
+	waiter := &queuedRequest{client: clientIDFromMetadata(opts.Metadata), enqueued: now, ready: make(chan struct{})}
+	if !m.queue.push(key, model, providers, waiter, depth) {
+		logEntryWithRequestID(ctx).Debugf("request queue for %s is full", key)
+		return nil, cause
+	}
+	logEntryWithRequestID(ctx).Debugf("request parked in the queue for %s", key)
+	m.pumpQueue(key)
+
+	release := func() {
+		m.queue.finish(key)
+		m.pumpQueue(key)
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-waiter.ready:
+		return release, nil
+	case <-ctx.Done():
+		cause = ctx.Err()
+	case <-timer.C:
+	}
+	if !m.queue.remove(key, waiter) {
+		// Released while giving up; hand the slot to the next request.
+		release()
+	}
+	return nil, cause
+}
+
+// pumpQueue releases the parked requests of a model that its available credentials can take,
+// and schedules the next check while requests remain parked.
+func (m *Manager) pumpQueue(key string) {
+	model, providers, ok := m.queue.target(key)
+	if !ok {
+		return
+	}
+	available, wait := m.modelAvailability(providers, model)
+	if wait <= 0 {
+		wait = queueRecheckInterval
+	}
+	m.queue.release(key, available, wait, func() { m.pumpQueue(key) })
+}
+
+// modelAvailability counts the credentials that can serve model now and returns the time until
+// the next cooldown ends or rate window frees up.
+func (m *Manager) modelAvailability(providers []string, model string) (int, time.Duration) {
+	providerSet := make(map[string]struct{}, len(providers))
+	for _, provider := range providers {
+		providerSet[strings.TrimSpace(strings.ToLower(provider))] = struct{}{}
+	}
+	modelKey := canonicalModelKey(model)
+	registryRef := registry.GetGlobalRegistry()
+	now := time.Now()
+	available := 0
+	var wait time.Duration
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, auth := range m.auths {
+		if auth == nil {
+			continue
+		}
+		if _, ok := providerSet[strings.TrimSpace(strings.ToLower(auth.Provider))]; !ok {
+			continue
+		}
+		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(auth.ID, modelKey) {
+			continue
+		}
+		blocked, reason, next := isAuthBlockedForModel(auth, model, now)
+		if !blocked {
+			ok, freeIn := m.limiter.available(auth.ID, m.credentialLimits(auth), now)
+			if ok {
+				available++
+			} else if freeIn > 0 && (wait == 0 || freeIn < wait) {
+				wait = freeIn
+			}
+			continue
+		}
+		if reason == blockReasonDisabled || next.IsZero() {
+			continue
+		}
+		if until := next.Sub(now); until > 0 && (wait == 0 || until < wait) {
+			wait = until
+		}
+	}
+	return available, wait
+}
+
+// QueueStats returns the parked requests per model, sorted by model.
+func (m *Manager) QueueStats() []QueueStat {
+	if m == nil {
+		return nil
+	}
+	return m.queue.stats()
+}
+
+// notifyQueue re-checks the queue of model after its credentials changed state.
+func (m *Manager) notifyQueue(model string) {
+	key := canonicalModelKey(model)
+	if key == "" || !m.queue.has(key) {
+		return
+	}
+	m.pumpQueue(key)
+}
+
+func (q *requestQueue) push(key, model string, providers []string, waiter *queuedRequest, depth int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.models == nil {
+		q.models = make(map[string]*modelQueue)
+	}
+	mq := q.models[key]
+	if mq == nil {
+		mq = &modelQueue{waiters: make(map[string][]*queuedRequest)}
+		q.models[key] = mq
+	}
+	if mq.size >= depth {
+		return false
+	}
+	mq.model, mq.providers = model, providers
+	if len(mq.waiters[waiter.client]) == 0 {
+		mq.clients = append(mq.clients, waiter.client)
+	}
+	mq.waiters[waiter.client] = append(mq.waiters[waiter.client], waiter)
+	mq.size++
+	return true
+}
+
+func (q *requestQueue) has(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	mq := q.models[key]
+	return mq != nil && mq.size > 0
+}
+
+func (q *requestQueue) target(key string) (string, []string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	mq := q.models[key]
+	if mq == nil || mq.size == 0 {
+		return "", nil, false
+	}
+	return mq.model, mq.providers, true
+}
+
+// release hands out up to available slots in round-robin order across client keys and arms
+// the next check while requests remain parked.
+func (q *requestQueue) release(key string, available int, wait time.Duration, recheck func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	mq := q.models[key]
+	if mq == nil {
+		return
+	}
+	for mq.size > 0 && mq.inflight < available {
+		waiter := mq.popLocked()
+		mq.inflight++
+		close(waiter.ready)
+	}
+	if mq.timer != nil {
+		mq.timer.Stop()
+		mq.timer = nil
+	}
+	if mq.size == 0 {
+		if mq.inflight == 0 {
+			delete(q.models, key)
+		}
+		return
+	}
+	mq.timer = time.AfterFunc(wait, recheck)
+}
+
+func (q *requestQueue) finish(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	mq := q.models[key]
+	if mq == nil {
+		return
+	}
+	if mq.inflight > 0 {
+		mq.inflight--
+	}
+	if mq.size == 0 && mq.inflight == 0 {
+		delete(q.models, key)
+	}
+}
+
+// remove drops a parked request. It reports false when the request was already released.
+func (q *requestQueue) remove(key string, waiter *queuedRequest) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	mq := q.models[key]
+	if mq == nil {
+		return false
+	}
+	list := mq.waiters[waiter.client]
+	for i, candidate := range list {
+		if candidate != waiter {
+			continue
+		}
+		mq.waiters[waiter.client] = append(list[:i:i], list[i+1:]...)
+		mq.size--
+		if len(mq.waiters[waiter.client]) == 0 {
+			mq.dropClientLocked(waiter.client)
+		}
+		if mq.size == 0 && mq.inflight == 0 {
+			if mq.timer != nil {
+				mq.timer.Stop()
+			}
+			delete(q.models, key)
+		}
+		return true
+	}
+	return false
+}
+
+func (q *requestQueue) stats() []QueueStat {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	out := make([]QueueStat, 0, len(q.models))
+	for key, mq := range q.models {
+		stat := QueueStat{Model: key, Queued: mq.size, Clients: len(mq.clients), InFlight: mq.inflight}
+		for _, list := range mq.waiters {
+			if len(list) > 0 {
+				if waited := now.Sub(list[0].enqueued).Seconds(); waited > stat.OldestWaitSeconds {
+					stat.OldestWaitSeconds = waited
+				}
+			}
+		}
+		out = append(out, stat)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+	return out
+}
+
+// popLocked takes the oldest request of the next client key in round-robin order.
+func (mq *modelQueue) popLocked() *queuedRequest {
+	if mq.next >= len(mq.clients) {
+		mq.next = 0
+	}
+	client := mq.clients[mq.next]
+	list := mq.waiters[client]
+	waiter := list[0]
+	mq.waiters[client] = list[1:]
+	mq.size--
+	if len(mq.waiters[client]) == 0 {
+		mq.dropClientLocked(client)
+	} else {
+		mq.next++
+	}
+	return waiter
+}
+
+func (mq *modelQueue) dropClientLocked(client string) {
+	delete(mq.waiters, client)
+	for i, candidate := range mq.clients {
+		if candidate != client {
+			continue
+		}
+		mq.clients = append(mq.clients[:i], mq.clients[i+1:]...)
+		if i < mq.next {
+			mq.next--
+		}
+		return
+	}
+}
+
+// clientIDFromMetadata returns the client identity recorded in the execution metadata.
+func clientIDFromMetadata(meta map[string]any) string {
+	if len(meta) == 0 {
+		return ""
+	}
+	key, _ := meta[cliproxyexecutor.ClientIDMetadataKey].(string)
+	return strings.TrimSpace(key)
+}
+
+// queueSkipped reports whether the execution metadata asks to fail fast instead of queueing,
+// which lets the caller move on to its next fallback target.
+func queueSkipped(meta map[string]any) bool {
+	skip, _ := meta[cliproxyexecutor.SkipQueueMetadataKey].(bool)
+	return skip
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type queueTestError struct {
+	retryAfter time.Duration
+}
+
+func (e *queueTestError) Error() string { return "rate limited" }
+
+func (e *queueTestError) StatusCode() int { return http.StatusTooManyRequests }
+
+func (e *queueTestError) RetryAfter() *time.Duration { return &e.retryAfter }
+
+// queueTestExecutor rate limits its first call and serves the rest.
+type queueTestExecutor struct {
+	calls atomic.Int32
+}
+
+func (e *queueTestExecutor) Identifier() string { return "queue-test" }
+
+func (e *queueTestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if e.calls.Add(1) == 1 {
+		return cliproxyexecutor.Response{}, &queueTestError{retryAfter: 150 * time.Millisecond}
+	}
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func (e *queueTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	return nil, &Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *queueTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *queueTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *queueTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func newQueueTestManager(t *testing.T, queue internalconfig.RequestQueueConfig) (*Manager, *queueTestExecutor) {
+	t.Helper()
+	executor := &queueTestExecutor{}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(executor)
+	m.SetConfig(&internalconfig.Config{RequestQueue: queue})
+	if _, err := m.Register(context.Background(), &Auth{ID: "queue-auth", Provider: "queue-test", Status: StatusActive}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("queue-auth", "queue-test", []*registry.ModelInfo{{ID: "queue-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("queue-auth") })
+	return m, executor
+}
+
+func TestManagerExecute_QueuesUntilCooldownEnds(t *testing.T) {
+	m, executor := newQueueTestManager(t, internalconfig.RequestQueueConfig{Enable: true, MaxWaitSeconds: 5})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Execute(context.Background(), []string{"queue-test"}, cliproxyexecutor.Request{Model: "queue-model"}, cliproxyexecutor.Options{})
+		done <- err
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for parked := false; !parked; {
+		select {
+		case err := <-done:
+			t.Fatalf("request finished before being queued: %v", err)
+		case <-deadline:
+			t.Fatalf("request was never queued")
+		case <-time.After(5 * time.Millisecond):
+		}
+		stats := m.QueueStats()
+		parked = len(stats) == 1 && stats[0].Model == "queue-model" && stats[0].Queued == 1
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("queued request was not released after the cooldown")
+	}
+	if got := executor.calls.Load(); got != 2 {
+		t.Fatalf("executor calls = %d, want 2", got)
+	}
+	if stats := m.QueueStats(); len(stats) != 0 {
+		t.Fatalf("queue not drained: %+v", stats)
+	}
+}
+
+func TestManagerExecute_QueueDisabledFailsFast(t *testing.T) {
+	m, _ := newQueueTestManager(t, internalconfig.RequestQueueConfig{})
+
+	_, err := m.Execute(context.Background(), []string{"queue-test"}, cliproxyexecutor.Request{Model: "queue-model"}, cliproxyexecutor.Options{})
+	if statusCodeFromError(err) != http.StatusTooManyRequests {
+		t.Fatalf("err = %v, want 429", err)
+	}
+}
+
+func TestRequestQueue_ReleasesRoundRobinAcrossClients(t *testing.T) {
+	var q requestQueue
+	waiters := []*queuedRequest{
+		{client: "a", ready: make(chan struct{})},
+		{client: "a", ready: make(chan struct{})},
+		{client: "b", ready: make(chan struct{})},
+	}
+	for _, waiter := range waiters {
+		if !q.push("model", "model", nil, waiter, 3) {
+			t.Fatalf("push rejected below max depth")
+		}
+	}
+	if q.push("model", "model", nil, &queuedRequest{client: "c", ready: make(chan struct{})}, 3) {
+		t.Fatalf("push accepted beyond max depth")
+	}
+
+	released := func() []bool {
+		out := make([]bool, len(waiters))
+		for i, waiter := range waiters {
+			select {
+			case <-waiter.ready:
+				out[i] = true
+			default:
+			}
+		}
+		return out
+	}
+	q.release("model", 2, time.Hour, func() {})
+	if got := released(); !got[0] || got[1] || !got[2] {
+		t.Fatalf("released = %v, want the first request of each client", got)
+	}
+	q.finish("model")
+	q.release("model", 2, time.Hour, func() {})
+	if got := released(); !got[1] {
+		t.Fatalf("released = %v, want the remaining request after a slot freed", got)
+	}
+	q.finish("model")
+	q.finish("model")
+	if stats := q.stats(); len(stats) != 0 {
+		t.Fatalf("queue not drained: %+v", stats)
+	}
+}
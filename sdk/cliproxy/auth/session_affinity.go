@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// defaultSessionAffinityTTL bounds idle bindings when session-affinity.ttl-seconds is unset.
+	defaultSessionAffinityTTL = time.Hour
+	// sessionAffinitySweepInterval is how often expired bindings are dropped.
+	sessionAffinitySweepInterval = time.Minute
+)
+
+// sessionAffinity binds conversations to the auth that served them, per model.
+type sessionAffinity struct {
+	mu        sync.Mutex
+	bindings  map[string]affinityBinding
+	lastSweep time.Time
+}
+
+type affinityBinding struct {
+	authID  string
+	expires time.Time
+}
+
+// sessionAffinityTTL returns how long a binding outlives the last request of its conversation.
+func (m *Manager) sessionAffinityTTL() time.Duration {
+	if cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config); cfg != nil && cfg.SessionAffinity.TTLSeconds > 0 {
+		return time.Duration(cfg.SessionAffinity.TTLSeconds) * time.Second
+	}
+	return defaultSessionAffinityTTL
+}
+
+// stickyCandidate returns the candidate bound to the conversation, or nil when the conversation
+// is unbound or its auth is not eligible or is cooling down for model, leaving the pick to the
+// selector.
+func (m *Manager) stickyCandidate(key, model string, candidates []*Auth, now time.Time) *Auth {
+	if key == "" {
+		return nil
+	}
+	authID := m.affinity.lookup(key, now)
+	if authID == "" {
+		return nil
+	}
+	for _, candidate := range candidates {
+		if candidate.ID != authID {
+			continue
+		}
+		if blocked, _, _ := isAuthBlockedForModel(candidate, model, now); blocked {
+			return nil
+		}
+		return candidate
+	}
+	return nil
+}
+
+// bindSession binds the conversation to authID unless the conversation's current auth was
+// already tried by this request, as with a retry or a hedge, so a one-off failover does not
+// move the conversation.
+func (m *Manager) bindSession(key, authID string, tried map[string]struct{}, now time.Time) {
+	if key == "" {
+		return
+	}
+	if current := m.affinity.lookup(key, now); current != "" && current != authID {
+		if _, used := tried[current]; used {
+			return
+		}
+	}
+	m.affinity.store(key, authID, now, m.sessionAffinityTTL())
+}
+
+func (s *sessionAffinity) lookup(key string, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	binding, ok := s.bindings[key]
+	if !ok || !now.Before(binding.expires) {
+		return ""
+	}
+	return binding.authID
+}
+
+func (s *sessionAffinity) store(key, authID string, now time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bindings == nil {
+		s.bindings = make(map[string]affinityBinding)
+	}
+	s.bindings[key] = affinityBinding{authID: authID, expires: now.Add(ttl)}
+	if now.Sub(s.lastSweep) < sessionAffinitySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, binding := range s.bindings {
+		if !now.Before(binding.expires) {
+			delete(s.bindings, k)
+		}
+	}
+}
+
+// sessionAffinityKey returns the binding key of the request: its conversation identity scoped
+// to the routed model, or "" when the request carries no identity.
+func sessionAffinityKey(meta map[string]any, model string) string {
+	if len(meta) == 0 {
+		return ""
+	}
+	identity, _ := meta[cliproxyexecutor.SessionAffinityMetadataKey].(string)
+	identity = strings.TrimSpace(identity)
+	if identity == "" {
+		return ""
+	}
+	return identity + "|" + canonicalModelKey(model)
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestManagerPickNextMixed_StickySessions(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(&queueTestExecutor{})
+	m.SetConfig(&internalconfig.Config{})
+	for _, id := range []string{"sticky-a", "sticky-b"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "queue-test", Status: StatusActive}); err != nil {
+			t.Fatalf("Register(%s): %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, "queue-test", []*registry.ModelInfo{{ID: "sticky-model"}})
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(id) })
+	}
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.SessionAffinityMetadataKey: "header:x-session-id:abc"}}
+	pick := func(tried map[string]struct{}) string {
+		t.Helper()
+		auth, _, _, err := m.pickNextMixed(context.Background(), []string{"queue-test"}, "sticky-model", opts, tried)
+		if err != nil {
+			t.Fatalf("pickNextMixed: %v", err)
+		}
+		m.releaseCredential(auth.ID, "sticky-model")
+		return auth.ID
+	}
+
+	bound := pick(map[string]struct{}{})
+	for i := 0; i < 4; i++ {
+		if got := pick(map[string]struct{}{}); got != bound {
+			t.Fatalf("turn %d routed to %s, want %s", i, got, bound)
+		}
+	}
+
+	// A retry within one request moves to the other auth without rebinding the conversation.
+	other := pick(map[string]struct{}{bound: {}})
+	if other == bound {
+		t.Fatalf("retry picked the tried auth %s", bound)
+	}
+	if got := pick(map[string]struct{}{}); got != bound {
+		t.Fatalf("after a retry routed to %s, want %s", got, bound)
+	}
+
+	// A cooldown moves the conversation to the other auth.
+	retryAfter := time.Minute
+	m.MarkResult(context.Background(), Result{AuthID: bound, Provider: "queue-test", Model: "sticky-model", Error: &Error{HTTPStatus: http.StatusTooManyRequests}, RetryAfter: &retryAfter})
+	for i := 0; i < 2; i++ {
+		if got := pick(map[string]struct{}{}); got != other {
+			t.Fatalf("during cooldown routed to %s, want %s", got, other)
+		}
+	}
+}
+
+func TestSessionAffinity_ExpiresBindings(t *testing.T) {
+	var s sessionAffinity
+	now := time.Now()
+	s.store("conv|model", "auth-a", now, time.Minute)
+	if got := s.lookup("conv|model", now.Add(30*time.Second)); got != "auth-a" {
+		t.Fatalf("lookup = %q, want auth-a", got)
+	}
+	if got := s.lookup("conv|model", now.Add(2*time.Minute)); got != "" {
+		t.Fatalf("lookup after ttl = %q, want none", got)
+	}
+	s.store("other|model", "auth-b", now.Add(2*time.Minute), time.Minute)
+	if _, ok := s.bindings["conv|model"]; ok {
+		t.Fatalf("expired binding was not swept")
+	}
+}
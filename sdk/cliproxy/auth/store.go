@@ -11,3 +11,26 @@ type Store interface {
 	// Delete removes the auth record identified by id.
 	Delete(ctx context.Context, id string) error
 }
+
+// RefreshLocker is implemented by stores that processes share, such as several proxy instances
+// or the proxy and a CLI login, to serialise refreshes of one auth across them. Providers that
+// rotate refresh tokens on every use leave all but one concurrent refresher with a dead token.
+type RefreshLocker interface {
+	// LockRefresh blocks until this process holds the refresh lock of auth or ctx is done, and
+	// returns the function that releases it.
+	LockRefresh(ctx context.Context, auth *Auth) (func(), error)
+}
+
+// AuthReader is implemented by stores that can read a single auth back from the backend.
+type AuthReader interface {
+	// Read returns the stored state of auth, or nil when it is no longer stored.
+	Read(ctx context.Context, auth *Auth) (*Auth, error)
+}
+
+// ChangeWatcher is implemented by stores that are the source of truth for auths written by
+// several processes, and report their changes without a file watcher.
+type ChangeWatcher interface {
+	// Watch calls onChange with every stored auth, then with each auth another writer adds,
+	// changes or removes, until ctx is done. A removed auth is reported with a nil auth.
+	Watch(ctx context.Context, onChange func(id string, auth *Auth)) error
+}
@@ -9,10 +9,12 @@ import (
 
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/webhook"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
@@ -207,17 +209,22 @@ func (b *Builder) Build() (*Service, error) {
 		switch strategy {
 		case "fill-first", "fillfirst", "ff":
 			selector = &coreauth.FillFirstSelector{}
+		case "health-weighted", "healthweighted", "health":
+			selector = coreauth.NewHealthWeightedSelector(b.cfg.Routing.HealthDecay)
 		default:
 			selector = &coreauth.RoundRobinSelector{}
 		}
 
 		webhookHook = webhook.NewWebhookHook(b.cfg.Webhooks)
-		coreManager = coreauth.NewManager(tokenStore, selector, webhookHook)
+		coreManager = coreauth.NewManager(tokenStore, selector, coreauth.MultiHook{webhookHook, metrics.Default()})
 	}
+	metrics.Default().SetAuthSource(coreManager)
 	// Attach a default RoundTripper provider so providers can opt-in per-auth transports.
 	coreManager.SetRoundTripperProvider(newDefaultRoundTripperProvider())
 	coreManager.SetConfig(b.cfg)
 	coreManager.SetOAuthModelAlias(b.cfg.OAuthModelAlias)
+	// Feed reported tokens into per-credential tokens-per-minute limits.
+	usage.RegisterPlugin(coreManager)
 
 	service := &Service{
 		cfg:            b.cfg,
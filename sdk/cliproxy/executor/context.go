@@ -21,3 +21,27 @@ func DownstreamWebsocket(ctx context.Context) bool {
 	enabled, ok := raw.(bool)
 	return ok && enabled
 }
+
+type clientAPIKeyContextKey struct{}
+
+// WithClientAPIKey attaches the client API key a request runs for when it has no gin context,
+// such as a batch line running in the background, so client limits and usage attribution still
+// apply to it.
+func WithClientAPIKey(ctx context.Context, apiKey string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if apiKey == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, clientAPIKeyContextKey{}, apiKey)
+}
+
+// ClientAPIKey returns the client API key attached with WithClientAPIKey.
+func ClientAPIKey(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	apiKey, _ := ctx.Value(clientAPIKeyContextKey{}).(string)
+	return apiKey
+}
@@ -19,8 +19,20 @@ const (
 	SelectedAuthCallbackMetadataKey = "selected_auth_callback"
 	// ExecutionSessionMetadataKey identifies a long-lived downstream execution session.
 	ExecutionSessionMetadataKey = "execution_session_id"
+	// ClientIDMetadataKey carries a hash identifying the client API key, used to order queued
+	// requests fairly without exposing the key to executors and plugins.
+	ClientIDMetadataKey = "client_id"
+	// SessionAffinityMetadataKey carries the hashed conversation identity used for sticky routing.
+	SessionAffinityMetadataKey = "session_affinity_key"
+	// SkipQueueMetadataKey marks a request that must fail fast instead of waiting in the request
+	// queue, set while model fallback targets remain to be tried.
+	SkipQueueMetadataKey = "skip_queue"
 )
 
+// AltEmbeddings is the Options.Alt value of embedding requests. Their payload is an OpenAI
+// /v1/embeddings request or a Gemini embedContent/batchEmbedContents request, per SourceFormat.
+const AltEmbeddings = "embeddings"
+
 // Request encapsulates the translated payload that will be sent to a provider executor.
 type Request struct {
 	// Model is the upstream model identifier after translation.
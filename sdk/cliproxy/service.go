@@ -14,10 +14,14 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cluster"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/webhook"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
-	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/wsrelay"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -86,7 +90,7 @@ type Service struct {
 	// coreManager handles core authentication and execution.
 	coreManager *coreauth.Manager
 
-	// webhookHook dispatches webhook alerts for ban/rate-limit events.
+	// webhookHook dispatches webhook alerts for credential lifecycle, ban and rate-limit events.
 	webhookHook *webhook.WebhookHook
 
 	// shutdownOnce ensures shutdown is called only once.
@@ -186,6 +190,29 @@ func (s *Service) emitAuthUpdate(ctx context.Context, update watcher.AuthUpdate)
 	s.handleAuthUpdate(ctx, update)
 }
 
+// watchStoreChanges feeds the auths a store reports changed into the auth update queue. The
+// updates bypass the file watcher, which would otherwise replay them on config reloads.
+func (s *Service) watchStoreChanges(ctx context.Context, store coreauth.ChangeWatcher) {
+	err := store.Watch(ctx, func(id string, auth *coreauth.Auth) {
+		update := watcher.AuthUpdate{Action: watcher.AuthUpdateActionModify, ID: id, Auth: auth}
+		if auth == nil {
+			update.Action = watcher.AuthUpdateActionDelete
+		}
+		if s.authUpdates != nil {
+			select {
+			case s.authUpdates <- update:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+		s.handleAuthUpdate(ctx, update)
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Errorf("token store change feed stopped: %v", err)
+	}
+}
+
 func (s *Service) handleAuthUpdate(ctx context.Context, update watcher.AuthUpdate) {
 	if s == nil {
 		return
@@ -596,6 +623,8 @@ func (s *Service) Run(ctx context.Context) error {
 			switch strategy {
 			case "fill-first", "fillfirst", "ff":
 				return "fill-first"
+			case "health-weighted", "healthweighted", "health":
+				return "health-weighted"
 			default:
 				return "round-robin"
 			}
@@ -607,12 +636,21 @@ func (s *Service) Run(ctx context.Context) error {
 			switch nextStrategy {
 			case "fill-first":
 				selector = &coreauth.FillFirstSelector{}
+			case "health-weighted":
+				selector = coreauth.NewHealthWeightedSelector(newCfg.Routing.HealthDecay)
 			default:
 				selector = &coreauth.RoundRobinSelector{}
 			}
 			s.coreManager.SetSelector(selector)
+		} else if s.coreManager != nil && nextStrategy == "health-weighted" {
+			if selector, ok := s.coreManager.Selector().(*coreauth.HealthWeightedSelector); ok {
+				selector.SetDecay(newCfg.Routing.HealthDecay)
+			}
 		}
 
+		if errCrypt := authcrypt.Configure(newCfg.AuthEncryption); errCrypt != nil {
+			log.Errorf("failed to apply auth encryption config, keeping previous keys: %v", errCrypt)
+		}
 		s.applyRetryConfig(newCfg)
 		s.applyPprofConfig(newCfg)
 		if s.server != nil {
@@ -628,6 +666,9 @@ func (s *Service) Run(ctx context.Context) error {
 		if s.webhookHook != nil {
 			s.webhookHook.UpdateConfig(newCfg.Webhooks)
 		}
+		if errCluster := cluster.Configure(newCfg, s.coreManager); errCluster != nil {
+			log.Errorf("failed to apply cluster config: %v", errCluster)
+		}
 		s.rebindExecutors()
 	}
 
@@ -661,6 +702,15 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 	log.Info("file watcher started for config and auth directory changes")
 
+	if changes, ok := sdkAuth.GetTokenStore().(coreauth.ChangeWatcher); ok {
+		go s.watchStoreChanges(watcherCtx, changes)
+		log.Info("token store change feed started")
+	}
+
+	if errCluster := cluster.Configure(s.cfg, s.coreManager); errCluster != nil {
+		log.Errorf("failed to join cluster: %v", errCluster)
+	}
+
 	// Prefer core auth manager auto refresh if available.
 	if s.coreManager != nil {
 		interval := 15 * time.Minute
@@ -743,7 +793,11 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			}
 		}
 
+		cluster.Close()
 		usage.StopDefault()
+		internalusage.CloseStore()
+		responsestore.Close()
+		batch.Close()
 	})
 	return shutdownErr
 }
@@ -940,6 +994,8 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 							OwnedBy:     compat.Name,
 							Type:        "openai-compatibility",
 							DisplayName: modelID,
+							Kind:        strings.ToLower(strings.TrimSpace(m.Kind)),
+							Tokenizer:   strings.ToLower(strings.TrimSpace(m.Tokenizer)),
 							UserDefined: true,
 						})
 					}
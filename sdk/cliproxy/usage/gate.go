@@ -0,0 +1,82 @@
+package usage
+
+import (
+	"context"
+	"sync"
+)
+
+type gateContextKey struct{}
+
+// Gate defers the usage records of a speculative attempt, such as a hedged stream, until the
+// attempt is known to have won or lost. Publishers route records through Do; Accept releases
+// the held records and Discard drops them.
+type Gate struct {
+	mu      sync.Mutex
+	decided bool
+	won     bool
+	held    []func()
+}
+
+// NewGate returns an undecided gate.
+func NewGate() *Gate { return &Gate{} }
+
+// WithGate returns a context whose usage records are routed through gate.
+func WithGate(ctx context.Context, gate *Gate) context.Context {
+	return context.WithValue(ctx, gateContextKey{}, gate)
+}
+
+// GateFromContext returns the gate attached to ctx, or nil.
+func GateFromContext(ctx context.Context) *Gate {
+	if ctx == nil {
+		return nil
+	}
+	gate, _ := ctx.Value(gateContextKey{}).(*Gate)
+	return gate
+}
+
+// Do runs publish immediately when the gate is nil or accepted, holds it while the gate is
+// undecided, and drops it once the gate is discarded.
+func (g *Gate) Do(publish func()) {
+	if g == nil {
+		publish()
+		return
+	}
+	g.mu.Lock()
+	if !g.decided {
+		g.held = append(g.held, publish)
+		g.mu.Unlock()
+		return
+	}
+	won := g.won
+	g.mu.Unlock()
+	if won {
+		publish()
+	}
+}
+
+// Accept releases the held records and lets later ones through.
+func (g *Gate) Accept() { g.decide(true) }
+
+// Discard drops the held records and every later one.
+func (g *Gate) Discard() { g.decide(false) }
+
+func (g *Gate) decide(won bool) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	if g.decided {
+		g.mu.Unlock()
+		return
+	}
+	g.decided, g.won = true, won
+	held := g.held
+	g.held = nil
+	g.mu.Unlock()
+	if !won {
+		return
+	}
+	for _, publish := range held {
+		publish()
+	}
+}
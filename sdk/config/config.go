@@ -13,6 +13,8 @@ type Config = internalconfig.Config
 type StreamingConfig = internalconfig.StreamingConfig
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
+type AuthEncryptionConfig = internalconfig.AuthEncryptionConfig
+type AuthEncryptionKey = internalconfig.AuthEncryptionKey
 type AmpCode = internalconfig.AmpCode
 type OAuthModelAlias = internalconfig.OAuthModelAlias
 type PayloadConfig = internalconfig.PayloadConfig
@@ -28,12 +30,32 @@ type VertexCompatModel = internalconfig.VertexCompatModel
 type OpenAICompatibility = internalconfig.OpenAICompatibility
 type OpenAICompatibilityAPIKey = internalconfig.OpenAICompatibilityAPIKey
 type OpenAICompatibilityModel = internalconfig.OpenAICompatibilityModel
+type CredentialLimits = internalconfig.CredentialLimits
 type WebhookEntry = internalconfig.WebhookEntry
+type APIKeyLimit = internalconfig.APIKeyLimit
+type APIKeyPolicy = internalconfig.APIKeyPolicy
+type ModelFallback = internalconfig.ModelFallback
+type ResponseCacheConfig = internalconfig.ResponseCacheConfig
+type ContextGuardConfig = internalconfig.ContextGuardConfig
+type SessionAffinityConfig = internalconfig.SessionAffinityConfig
 
 type TLS = internalconfig.TLSConfig
 
+type SecretResolver = internalconfig.SecretResolver
+type SecretResolverFunc = internalconfig.SecretResolverFunc
+type VaultResolver = internalconfig.VaultResolver
+
 const (
 	DefaultPanelGitHubRepository = internalconfig.DefaultPanelGitHubRepository
+
+	ContextGuardTruncateToolResults = internalconfig.ContextGuardTruncateToolResults
+	ContextGuardDropOldestTurns     = internalconfig.ContextGuardDropOldestTurns
+	ContextGuardReroute             = internalconfig.ContextGuardReroute
+
+	SessionAffinityHeaderPrefix   = internalconfig.SessionAffinityHeaderPrefix
+	SessionAffinityClaudeUserID   = internalconfig.SessionAffinityClaudeUserID
+	SessionAffinityPromptCacheKey = internalconfig.SessionAffinityPromptCacheKey
+	SessionAffinityPromptHash     = internalconfig.SessionAffinityPromptHash
 )
 
 func LoadConfig(configFile string) (*Config, error) { return internalconfig.LoadConfig(configFile) }
@@ -42,6 +64,10 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	return internalconfig.LoadConfigOptional(configFile, optional)
 }
 
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	internalconfig.RegisterSecretResolver(scheme, resolver)
+}
+
 func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 	return internalconfig.SaveConfigPreserveComments(configFile, cfg)
 }
@@ -3,6 +3,10 @@ package translator
 import (
 	"context"
 	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Registry manages translation functions across schemas.
@@ -52,6 +56,13 @@ func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byt
 	return rawJSON
 }
 
+// TranslateRequestWithContext is TranslateRequest recorded as a span of the trace carried by ctx.
+func (r *Registry) TranslateRequestWithContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	_, span := tracing.Start(ctx, "translator.request", translateSpanAttributes(from, to, model))
+	defer span.End()
+	return r.TranslateRequest(from, to, model, rawJSON, stream)
+}
+
 // HasResponseTransformer indicates whether a response translator exists.
 func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	r.mu.RLock()
@@ -85,7 +96,9 @@ func (r *Registry) TranslateNonStream(ctx context.Context, from, to Format, mode
 
 	if byTarget, ok := r.responses[to]; ok {
 		if fn, isOk := byTarget[from]; isOk && fn.NonStream != nil {
-			return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+			spanCtx, span := tracing.Start(ctx, "translator.response", translateSpanAttributes(from, to, model))
+			defer span.End()
+			return fn.NonStream(spanCtx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 		}
 	}
 	return string(rawJSON)
@@ -121,6 +134,11 @@ func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
+// TranslateRequestWithContext is a helper on the default registry.
+func TranslateRequestWithContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	return defaultRegistry.TranslateRequestWithContext(ctx, from, to, model, rawJSON, stream)
+}
+
 // HasResponseTransformer inspects the default registry.
 func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
@@ -140,3 +158,12 @@ func TranslateNonStream(ctx context.Context, from, to Format, model string, orig
 func TranslateTokenCount(ctx context.Context, from, to Format, count int64, rawJSON []byte) string {
 	return defaultRegistry.TranslateTokenCount(ctx, from, to, count, rawJSON)
 }
+
+// translateSpanAttributes describes a translation between two schemas.
+func translateSpanAttributes(from, to Format, model string) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		attribute.String("translator.from", from.String()),
+		attribute.String("translator.to", to.String()),
+		attribute.String("gen_ai.request.model", model),
+	)
+}